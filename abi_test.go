@@ -18,27 +18,35 @@ func TestABIVersionChecking(t *testing.T) {
 	}{
 		{
 			name:       "Compatible version - exact match",
-			abiVersion: "0.1.0",
-			constraint: "^0.1.x",
+			abiVersion: "0.2.0",
+			constraint: "^0.2.x",
 			shouldPass: true,
 		},
 		{
 			name:       "Compatible version - patch version",
-			abiVersion: "0.1.5",
-			constraint: "^0.1.x",
+			abiVersion: "0.2.5",
+			constraint: "^0.2.x",
 			shouldPass: true,
 		},
 		{
 			name:          "Incompatible version - major version",
 			abiVersion:    "1.0.0",
-			constraint:    "^0.1.x",
+			constraint:    "^0.2.x",
 			shouldPass:    false,
 			expectedError: "not compatible",
 		},
 		{
 			name:          "Incompatible version - minor version",
-			abiVersion:    "0.2.0",
-			constraint:    "^0.1.x",
+			abiVersion:    "0.3.0",
+			constraint:    "^0.2.x",
+			shouldPass:    false,
+			expectedError: "not compatible",
+		},
+		{
+			// 0.1.x libraries use the FFI struct layouts from before the ABI bump.
+			name:          "Incompatible version - previous ABI",
+			abiVersion:    "0.1.2",
+			constraint:    "^0.2.x",
 			shouldPass:    false,
 			expectedError: "not compatible",
 		},
@@ -72,7 +80,7 @@ func TestVersionCheck(t *testing.T) {
 	t.Run("Uses version for compatibility check", func(t *testing.T) {
 		tokenizer := &Tokenizer{
 			getVersion: func() string {
-				return "0.1.0"
+				return "0.2.0"
 			},
 		}
 
@@ -96,7 +104,7 @@ func TestVersionCheck(t *testing.T) {
 func TestABIErrorMessages(t *testing.T) {
 	tokenizer := &Tokenizer{
 		getVersion: func() string {
-			return "0.2.0" // Incompatible version
+			return "0.1.0" // Incompatible version
 		},
 	}
 
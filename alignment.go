@@ -0,0 +1,80 @@
+package tokenizers
+
+// Alignment helpers mirroring the HuggingFace Encoding API. They operate on the
+// attributes already present in the EncodeResult, so the relevant attributes must
+// have been requested at encode time: Offsets (WithReturnOffsets), WordIDs
+// (WithReturnWordIDs) and SequenceIDs (WithReturnSequenceIDs).
+//
+// When SequenceIDs were not requested every token is treated as belonging to
+// sequence 0.
+
+// TokenToSequence returns the input sequence (0 or 1 for pairs) that the token at
+// index belongs to. It returns false for special tokens, for an out of range index,
+// or if SequenceIDs were not requested.
+func (r *EncodeResult) TokenToSequence(index int) (int, bool) {
+	if index < 0 || index >= len(r.SequenceIDs) || r.SequenceIDs[index] < 0 {
+		return 0, false
+	}
+	return int(r.SequenceIDs[index]), true
+}
+
+// TokenToWord returns the index of the word that the token at index belongs to,
+// within its own sequence. It returns false for special tokens, for an out of range
+// index, or if WordIDs were not requested.
+func (r *EncodeResult) TokenToWord(index int) (int, bool) {
+	if index < 0 || index >= len(r.WordIDs) || r.WordIDs[index] < 0 {
+		return 0, false
+	}
+	return int(r.WordIDs[index]), true
+}
+
+// TokenToChars returns the byte span [start, end) of the token at index in the
+// sequence it was produced from. Offsets are byte offsets into the UTF-8 input, not
+// rune positions. It returns false for an out of range index or if Offsets were not
+// requested.
+func (r *EncodeResult) TokenToChars(index int) (start, end uint32, ok bool) {
+	if index < 0 || 2*index+1 >= len(r.Offsets) {
+		return 0, 0, false
+	}
+	return r.Offsets[2*index], r.Offsets[2*index+1], true
+}
+
+// CharToToken returns the index of the token covering the byte at offset pos in the
+// given input sequence (0 for single inputs, 0 or 1 for pairs). pos is a byte offset
+// into the UTF-8 input, not a rune index. It returns false if no token covers pos,
+// e.g. for whitespace or positions past the end of the input.
+func (r *EncodeResult) CharToToken(pos uint32, sequenceID int) (int, bool) {
+	for i := 0; 2*i+1 < len(r.Offsets); i++ {
+		if !r.inSequence(i, sequenceID) {
+			continue
+		}
+		if r.Offsets[2*i] <= pos && pos < r.Offsets[2*i+1] {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// WordToTokens returns the token span [start, end) of word in the given input
+// sequence. It returns false if the word does not exist or WordIDs were not
+// requested.
+func (r *EncodeResult) WordToTokens(word uint32, sequenceID int) (start, end int, ok bool) {
+	for i, w := range r.WordIDs {
+		if w < 0 || uint32(w) != word || !r.inSequence(i, sequenceID) {
+			continue
+		}
+		if !ok {
+			start, ok = i, true
+		}
+		end = i + 1
+	}
+	return start, end, ok
+}
+
+// inSequence reports whether the token at index belongs to sequenceID.
+func (r *EncodeResult) inSequence(index, sequenceID int) bool {
+	if r.SequenceIDs == nil {
+		return sequenceID == 0
+	}
+	return index < len(r.SequenceIDs) && int(r.SequenceIDs[index]) == sequenceID
+}
@@ -0,0 +1,131 @@
+package tokenizers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// pairResult models "[CLS] hello world [SEP] fine ##tune [SEP]" for the pair
+// ("hello world", "finetune").
+func pairResult() *EncodeResult {
+	return &EncodeResult{
+		IDs:         []uint32{101, 7592, 2088, 102, 2986, 8525, 102},
+		Offsets:     []uint32{0, 0, 0, 5, 6, 11, 0, 0, 0, 4, 4, 8, 0, 0},
+		WordIDs:     []int32{-1, 0, 1, -1, 0, 0, -1},
+		SequenceIDs: []int32{-1, 0, 0, -1, 1, 1, -1},
+	}
+}
+
+func TestEncodeResultAlignment(t *testing.T) {
+	r := pairResult()
+
+	t.Run("TokenToSequence", func(t *testing.T) {
+		seq, ok := r.TokenToSequence(2)
+		require.True(t, ok)
+		require.Equal(t, 0, seq)
+		seq, ok = r.TokenToSequence(5)
+		require.True(t, ok)
+		require.Equal(t, 1, seq)
+		_, ok = r.TokenToSequence(0)
+		require.False(t, ok, "special tokens have no sequence")
+		_, ok = r.TokenToSequence(len(r.IDs))
+		require.False(t, ok)
+	})
+
+	t.Run("TokenToWord", func(t *testing.T) {
+		word, ok := r.TokenToWord(2)
+		require.True(t, ok)
+		require.Equal(t, 1, word)
+		_, ok = r.TokenToWord(3)
+		require.False(t, ok)
+		_, ok = r.TokenToWord(-1)
+		require.False(t, ok)
+	})
+
+	t.Run("TokenToChars", func(t *testing.T) {
+		start, end, ok := r.TokenToChars(2)
+		require.True(t, ok)
+		require.Equal(t, []uint32{6, 11}, []uint32{start, end})
+		_, _, ok = r.TokenToChars(len(r.IDs))
+		require.False(t, ok)
+	})
+
+	t.Run("CharToToken", func(t *testing.T) {
+		idx, ok := r.CharToToken(7, 0)
+		require.True(t, ok)
+		require.Equal(t, 2, idx)
+		idx, ok = r.CharToToken(5, 1)
+		require.True(t, ok, "offsets are resolved against the requested sequence")
+		require.Equal(t, 5, idx)
+		_, ok = r.CharToToken(5, 0)
+		require.False(t, ok, "whitespace is not covered by any token")
+		_, ok = r.CharToToken(100, 0)
+		require.False(t, ok)
+	})
+
+	t.Run("WordToTokens", func(t *testing.T) {
+		start, end, ok := r.WordToTokens(0, 1)
+		require.True(t, ok)
+		require.Equal(t, []int{4, 6}, []int{start, end})
+		start, end, ok = r.WordToTokens(0, 0)
+		require.True(t, ok)
+		require.Equal(t, []int{1, 2}, []int{start, end})
+		_, _, ok = r.WordToTokens(2, 0)
+		require.False(t, ok)
+	})
+
+	t.Run("Without sequence IDs", func(t *testing.T) {
+		single := &EncodeResult{
+			Offsets: []uint32{0, 5, 6, 11},
+			WordIDs: []int32{0, 1},
+		}
+		idx, ok := single.CharToToken(8, 0)
+		require.True(t, ok)
+		require.Equal(t, 1, idx)
+		_, ok = single.CharToToken(8, 1)
+		require.False(t, ok)
+		_, ok = single.TokenToSequence(0)
+		require.False(t, ok)
+	})
+}
+
+func TestEncodeWordAndSequenceIDs(t *testing.T) {
+	libpath := checkLibraryExists(t)
+	tok, err := FromFile("./tokenizer.json", WithLibraryPath(libpath))
+	require.NoError(t, err, "Failed to load tokenizer from file")
+	t.Cleanup(func() {
+		_ = tok.Close()
+	})
+
+	t.Run("Not returned by default", func(t *testing.T) {
+		res, err := tok.Encode("Hello world")
+		require.NoError(t, err)
+		require.Nil(t, res.WordIDs)
+		require.Nil(t, res.SequenceIDs)
+	})
+
+	t.Run("Pair alignment", func(t *testing.T) {
+		res, err := tok.EncodePair("Hello world", "goodbye", WithReturnOffsets(), WithReturnWordIDs(),
+			WithReturnSequenceIDs(), WithAddSpecialTokens())
+		require.NoError(t, err)
+		require.Len(t, res.WordIDs, len(res.IDs))
+		require.Len(t, res.SequenceIDs, len(res.IDs))
+		require.Equal(t, int32(-1), res.SequenceIDs[0], "[CLS] has no sequence")
+
+		idx, ok := res.CharToToken(6, 0)
+		require.True(t, ok)
+		require.Equal(t, uint32(2088), res.IDs[idx])
+
+		idx, ok = res.CharToToken(0, 1)
+		require.True(t, ok)
+		seq, ok := res.TokenToSequence(idx)
+		require.True(t, ok)
+		require.Equal(t, 1, seq)
+
+		start, end, ok := res.WordToTokens(1, 0)
+		require.True(t, ok)
+		require.Equal(t, idx-1, end, "word 1 of the first sequence ends right before [SEP]")
+		require.Equal(t, 1, end-start)
+	})
+}
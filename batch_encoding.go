@@ -0,0 +1,118 @@
+package tokenizers
+
+// BatchEncoding is the result of the batch encode APIs: one EncodeResult per input,
+// in input order. It is a plain slice, so it can be indexed and ranged over like
+// []*EncodeResult, and adds tensor-ready views for model runtimes such as ONNX.
+//
+// The matrix methods return flat row-major [batch*seq] slices, where seq is the
+// length of the longest encoding (see Shape). Rows shorter than seq are padded with
+// the pad ID and pad type ID of the tokenizer, on the side set by its padding
+// direction, and attention mask 0. If the tokenizer does not pad, rows are
+// right-padded with ID 0 and type ID 0.
+//
+// The batch APIs always return the attention mask and type IDs. For results built by
+// hand without them, every token is treated as attended with type ID 0.
+type BatchEncoding []*EncodeResult
+
+// batchPadding is the padding of the tokenizer that produced a batch result, used to
+// fill the short rows of the batch matrices.
+type batchPadding struct {
+	id     uint32
+	typeID uint32
+	left   bool
+}
+
+// Shape returns the dimensions of the matrices: the number of encodings and the
+// length of the longest one.
+func (b BatchEncoding) Shape() (batch, seq int) {
+	for _, r := range b {
+		if r != nil && len(r.IDs) > seq {
+			seq = len(r.IDs)
+		}
+	}
+	return len(b), seq
+}
+
+// InputIDs returns the token IDs as a flat row-major int64 matrix.
+func (b BatchEncoding) InputIDs() []int64 {
+	return batchMatrix(b, idAt[int64], padIDOf[int64])
+}
+
+// InputIDsInt32 returns the token IDs as a flat row-major int32 matrix.
+func (b BatchEncoding) InputIDsInt32() []int32 {
+	return batchMatrix(b, idAt[int32], padIDOf[int32])
+}
+
+// AttentionMask returns the attention mask as a flat row-major int64 matrix.
+func (b BatchEncoding) AttentionMask() []int64 {
+	return batchMatrix(b, attentionMaskAt[int64], nil)
+}
+
+// AttentionMaskInt32 returns the attention mask as a flat row-major int32 matrix.
+func (b BatchEncoding) AttentionMaskInt32() []int32 {
+	return batchMatrix(b, attentionMaskAt[int32], nil)
+}
+
+// TokenTypeIDs returns the token type IDs as a flat row-major int64 matrix.
+func (b BatchEncoding) TokenTypeIDs() []int64 {
+	return batchMatrix(b, typeIDAt[int64], padTypeIDOf[int64])
+}
+
+// TokenTypeIDsInt32 returns the token type IDs as a flat row-major int32 matrix.
+func (b BatchEncoding) TokenTypeIDsInt32() []int32 {
+	return batchMatrix(b, typeIDAt[int32], padTypeIDOf[int32])
+}
+
+// batchMatrix lays out value(r, i) for every token of every result in a row-major
+// matrix. The rest of each row is filled with pad(r), or 0 if pad is nil, and the
+// tokens are placed at the end of rows that are padded on the left.
+func batchMatrix[T int32 | int64](b BatchEncoding, value func(r *EncodeResult, i int) T, pad func(r *EncodeResult) T) []T {
+	batch, seq := b.Shape()
+	out := make([]T, batch*seq)
+	for row, r := range b {
+		if r == nil {
+			continue
+		}
+		line := out[row*seq : (row+1)*seq]
+		start := 0
+		if r.padding.left {
+			start = seq - len(r.IDs)
+		}
+		if pad != nil {
+			fill := pad(r)
+			for i := range line {
+				line[i] = fill
+			}
+		}
+		for i := range r.IDs {
+			line[start+i] = value(r, i)
+		}
+	}
+	return out
+}
+
+func padIDOf[T int32 | int64](r *EncodeResult) T {
+	return T(r.padding.id)
+}
+
+func padTypeIDOf[T int32 | int64](r *EncodeResult) T {
+	return T(r.padding.typeID)
+}
+
+func idAt[T int32 | int64](r *EncodeResult, i int) T {
+	return T(r.IDs[i])
+}
+
+func attentionMaskAt[T int32 | int64](r *EncodeResult, i int) T {
+	if i < len(r.AttentionMask) {
+		return T(r.AttentionMask[i])
+	}
+	return 1
+}
+
+func typeIDAt[T int32 | int64](r *EncodeResult, i int) T {
+	if i < len(r.TypeIDs) {
+		return T(r.TypeIDs[i])
+	}
+	return 0
+}
@@ -0,0 +1,116 @@
+package tokenizers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchEncodingMatrices(t *testing.T) {
+	batch := BatchEncoding{
+		{IDs: []uint32{101, 7592, 102}, AttentionMask: []uint32{1, 1, 1}, TypeIDs: []uint32{0, 0, 0}},
+		{IDs: []uint32{101, 102}, AttentionMask: []uint32{1, 1}, TypeIDs: []uint32{0, 1}},
+	}
+
+	b, seq := batch.Shape()
+	require.Equal(t, 2, b)
+	require.Equal(t, 3, seq)
+
+	require.Equal(t, []int64{101, 7592, 102, 101, 102, 0}, batch.InputIDs())
+	require.Equal(t, []int32{101, 7592, 102, 101, 102, 0}, batch.InputIDsInt32())
+	require.Equal(t, []int64{1, 1, 1, 1, 1, 0}, batch.AttentionMask())
+	require.Equal(t, []int32{1, 1, 1, 1, 1, 0}, batch.AttentionMaskInt32())
+	require.Equal(t, []int64{0, 0, 0, 0, 1, 0}, batch.TokenTypeIDs())
+	require.Equal(t, []int32{0, 0, 0, 0, 1, 0}, batch.TokenTypeIDsInt32())
+}
+
+func TestBatchEncodingWithoutOptionalAttributes(t *testing.T) {
+	batch := BatchEncoding{
+		{IDs: []uint32{5}},
+		{IDs: []uint32{6, 7}},
+	}
+	require.Equal(t, []int64{1, 0, 1, 1}, batch.AttentionMask(), "Tokens are attended, padding is not")
+	require.Equal(t, []int64{0, 0, 0, 0}, batch.TokenTypeIDs())
+
+	empty := BatchEncoding{}
+	b, seq := empty.Shape()
+	require.Zero(t, b)
+	require.Zero(t, seq)
+	require.Empty(t, empty.InputIDs())
+}
+
+func TestBatchEncodingPadding(t *testing.T) {
+	left := batchPadding{id: 3, typeID: 2, left: true}
+	batch := BatchEncoding{
+		{IDs: []uint32{101, 7592, 102}, AttentionMask: []uint32{1, 1, 1}, TypeIDs: []uint32{0, 0, 0}, padding: left},
+		{IDs: []uint32{101, 102}, AttentionMask: []uint32{1, 1}, TypeIDs: []uint32{0, 1}, padding: left},
+	}
+	require.Equal(t, []int64{101, 7592, 102, 3, 101, 102}, batch.InputIDs(), "Short rows are padded on the left with the pad ID")
+	require.Equal(t, []int64{1, 1, 1, 0, 1, 1}, batch.AttentionMask(), "Padding is not attended")
+	require.Equal(t, []int64{0, 0, 0, 2, 0, 1}, batch.TokenTypeIDs(), "Padding has the pad type ID")
+
+	right := batchPadding{id: 3, typeID: 2}
+	batch[0].padding, batch[1].padding = right, right
+	require.Equal(t, []int32{101, 7592, 102, 101, 102, 3}, batch.InputIDsInt32())
+	require.Equal(t, []int32{1, 1, 1, 1, 1, 0}, batch.AttentionMaskInt32())
+	require.Equal(t, []int32{0, 0, 0, 0, 1, 2}, batch.TokenTypeIDsInt32())
+}
+
+func TestEncodeBatchMasks(t *testing.T) {
+	libpath := checkLibraryExists(t)
+	tok, err := FromFile("./tokenizer.json", WithLibraryPath(libpath))
+	require.NoError(t, err, "Failed to load tokenizer from file")
+	t.Cleanup(func() {
+		_ = tok.Close()
+	})
+
+	t.Run("Padding is not attended", func(t *testing.T) {
+		require.NoError(t, tok.SetPadding(PaddingParams{Strategy: PaddingStrategy{Tag: PaddingStrategyBatchLongest}}))
+		t.Cleanup(func() {
+			_ = tok.DisablePadding()
+		})
+		batch, err := tok.EncodeBatch([]string{"Hello", "Hello, how are you doing today?"})
+		require.NoError(t, err)
+		_, seq := batch.Shape()
+		mask := batch.AttentionMask()
+		require.Contains(t, mask[:seq], int64(0), "The padded row should not attend to its padding")
+	})
+
+	t.Run("Pair type IDs", func(t *testing.T) {
+		batch, err := tok.EncodePairs([]string{"Hello"}, []string{"World"}, WithAddSpecialTokens())
+		require.NoError(t, err)
+		require.Contains(t, batch.TokenTypeIDs(), int64(1), "The second sequence should have type ID 1")
+	})
+}
+
+func TestEncodePairsBatchEncoding(t *testing.T) {
+	libpath := checkLibraryExists(t)
+	tok, err := FromFile("./tokenizer.json", WithLibraryPath(libpath))
+	require.NoError(t, err, "Failed to load tokenizer from file")
+	t.Cleanup(func() {
+		_ = tok.Close()
+	})
+
+	batch, err := tok.EncodePairs(
+		[]string{"What is the capital of France?", "Hello"},
+		[]string{"Paris is the capital of France.", "World"},
+		WithReturnAttentionMask(), WithReturnTypeIDs(),
+	)
+	require.NoError(t, err)
+
+	b, seq := batch.Shape()
+	require.Equal(t, 2, b)
+	ids := batch.InputIDs()
+	mask := batch.AttentionMask()
+	typeIDs := batch.TokenTypeIDs()
+	require.Len(t, ids, b*seq)
+	require.Len(t, mask, b*seq)
+	require.Len(t, typeIDs, b*seq)
+	for row, r := range batch {
+		for i, id := range r.IDs {
+			require.Equal(t, int64(id), ids[row*seq+i])
+			require.Equal(t, int64(r.AttentionMask[i]), mask[row*seq+i])
+			require.Equal(t, int64(r.TypeIDs[i]), typeIDs[row*seq+i])
+		}
+	}
+}
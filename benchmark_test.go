@@ -159,6 +159,27 @@ func BenchmarkBatchEncode(b *testing.B) {
 	}
 }
 
+func BenchmarkEncodeBatch(b *testing.B) {
+	tokenizer := setupBenchmark(b)
+	defer func() { _ = tokenizer.Close() }()
+
+	texts := []string{
+		shortText,
+		mediumText,
+		longText,
+		"Another sample text for batch processing.",
+		"Tokenization is an important step in NLP pipelines.",
+	}
+
+	b.ResetTimer()
+	for b.Loop() {
+		_, err := tokenizer.EncodeBatch(texts)
+		if err != nil {
+			b.Fatalf("Failed to encode batch: %v", err)
+		}
+	}
+}
+
 func BenchmarkFromFile(b *testing.B) {
 	testFilePath := "test-data/tokenizer.json"
 	if _, err := os.Stat(testFilePath); err != nil {
@@ -324,3 +345,17 @@ func BenchmarkPadding(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkEncodeInto(b *testing.B) {
+	tokenizer := setupBenchmark(b)
+	defer func() { _ = tokenizer.Close() }()
+
+	dst := NewEncodeBuffersInt64(512)
+
+	b.ResetTimer()
+	for b.Loop() {
+		if err := tokenizer.EncodeInto(mediumText, dst); err != nil {
+			b.Fatalf("Failed to encode: %v", err)
+		}
+	}
+}
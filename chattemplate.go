@@ -0,0 +1,176 @@
+package tokenizers
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ChatMessage is a message of a conversation rendered by ApplyChatTemplate.
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// WithChatTemplate sets the Jinja chat template used by ApplyChatTemplate, overriding the
+// template of tokenizer_config.json.
+func WithChatTemplate(template string) TokenizerOption {
+	return func(t *Tokenizer) error {
+		if template == "" {
+			return errors.New("chat template cannot be empty")
+		}
+		tmpl, err := parseJinjaTemplate(template)
+		if err != nil {
+			return errors.Wrap(err, "invalid chat template")
+		}
+		t.chatTemplate = template
+		t.parsedChatTemplate, t.parsedChatSource = tmpl, template
+		return nil
+	}
+}
+
+// ChatTemplate returns the chat template of the tokenizer, or an empty string if it has
+// none.
+func (t *Tokenizer) ChatTemplate() string {
+	if t.chatTemplate != "" {
+		return t.chatTemplate
+	}
+	if t.tokenizerConfig != nil {
+		return t.tokenizerConfig.ChatTemplate
+	}
+	return ""
+}
+
+// ApplyChatTemplate renders messages with the chat template of the tokenizer, as
+// apply_chat_template of transformers does with tokenize=False. If addGenerationPrompt
+// is true, the prompt that starts an assistant message is appended. Templates are
+// rendered with a subset of Jinja that covers the templates of common models.
+func (t *Tokenizer) ApplyChatTemplate(messages []ChatMessage, addGenerationPrompt bool) (string, error) {
+	unlock, err := t.beginOperation()
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	tmpl, err := t.parseChatTemplate()
+	if err != nil {
+		return "", err
+	}
+
+	items := make([]any, len(messages))
+	for i, message := range messages {
+		item := newJinjaDict()
+		item.set("role", message.Role)
+		item.set("content", message.Content)
+		items[i] = item
+	}
+	vars := map[string]any{
+		"messages":              items,
+		"add_generation_prompt": addGenerationPrompt,
+		"raise_exception": jinjaFunc(func(args []any, _ map[string]any) (any, error) {
+			if len(args) == 0 {
+				return nil, errors.New("chat template raised an exception")
+			}
+			return nil, errors.Errorf("chat template raised an exception: %s", jinjaString(args[0]))
+		}),
+		"strftime_now": jinjaFunc(func(args []any, _ map[string]any) (any, error) {
+			if len(args) != 1 {
+				return nil, errors.New("strftime_now() takes exactly one argument")
+			}
+			return strftime(time.Now(), jinjaString(args[0])), nil
+		}),
+	}
+	tokens := t.SpecialTokens()
+	for name, token := range map[string]string{
+		"bos_token":  tokens.BOSToken,
+		"eos_token":  tokens.EOSToken,
+		"unk_token":  tokens.UnkToken,
+		"pad_token":  tokens.PadToken,
+		"sep_token":  tokens.SepToken,
+		"cls_token":  tokens.ClsToken,
+		"mask_token": tokens.MaskToken,
+	} {
+		if token != "" {
+			vars[name] = token
+		}
+	}
+	if tokens.AdditionalSpecialTokens != nil {
+		additional := make([]any, len(tokens.AdditionalSpecialTokens))
+		for i, token := range tokens.AdditionalSpecialTokens {
+			additional[i] = token
+		}
+		vars["additional_special_tokens"] = additional
+	}
+
+	text, err := tmpl.render(vars)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to render chat template")
+	}
+	return text, nil
+}
+
+// parseChatTemplate returns the parsed chat template of the tokenizer. The template is
+// parsed on first use and kept, as it does not change once the tokenizer is loaded.
+func (t *Tokenizer) parseChatTemplate() (*jinjaTemplate, error) {
+	source := t.ChatTemplate()
+	if source == "" {
+		return nil, errors.New("tokenizer has no chat template; set one with WithChatTemplate or WithTokenizerConfig")
+	}
+	t.chatTemplateMu.Lock()
+	defer t.chatTemplateMu.Unlock()
+	if t.parsedChatTemplate == nil || t.parsedChatSource != source {
+		tmpl, err := parseJinjaTemplate(source)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid chat template")
+		}
+		t.parsedChatTemplate, t.parsedChatSource = tmpl, source
+	}
+	return t.parsedChatTemplate, nil
+}
+
+// EncodeChat renders messages with ApplyChatTemplate and encodes the result. Special
+// tokens are not added unless WithAddSpecialTokens is given, as the template includes
+// them.
+func (t *Tokenizer) EncodeChat(messages []ChatMessage, addGenerationPrompt bool, opts ...EncodeOption) (*EncodeResult, error) {
+	text, err := t.ApplyChatTemplate(messages, addGenerationPrompt)
+	if err != nil {
+		return nil, err
+	}
+	withoutSpecialTokens := func(eo *EncodeOptions) error {
+		eo.AddSpecialTokens = false
+		return nil
+	}
+	return t.Encode(text, append([]EncodeOption{withoutSpecialTokens}, opts...)...)
+}
+
+// strftime formats tm with the C format directives that chat templates use for dates.
+func strftime(tm time.Time, format string) string {
+	directives := map[byte]string{
+		'Y': "2006", 'y': "06", 'm': "01", 'd': "02", 'H': "15", 'I': "03", 'M': "04",
+		'S': "05", 'p': "PM", 'B': "January", 'b': "Jan", 'A': "Monday", 'a': "Mon",
+		'Z': "MST", 'z': "-0700",
+	}
+	var out []byte
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			out = append(out, format[i])
+			continue
+		}
+		i++
+		switch c := format[i]; c {
+		case '%':
+			out = append(out, '%')
+		case 'e':
+			out = append(out, tm.Format("_2")...)
+		case 'j':
+			out = append(out, tm.Format("002")...)
+		default:
+			if layout, ok := directives[c]; ok {
+				out = append(out, tm.Format(layout)...)
+			} else {
+				out = append(out, '%', c)
+			}
+		}
+	}
+	return string(out)
+}
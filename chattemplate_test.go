@@ -0,0 +1,287 @@
+package tokenizers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Chat templates as they appear in the tokenizer_config.json of their models.
+const (
+	chatMLConfig  = `{"chat_template": "{% for message in messages %}{% if loop.first and messages[0]['role'] != 'system' %}{{ '<|im_start|>system\nYou are a helpful assistant.<|im_end|>\n' }}{% endif %}{{'<|im_start|>' + message['role'] + '\n' + message['content'] + '<|im_end|>' + '\n'}}{% endfor %}{% if add_generation_prompt %}{{ '<|im_start|>assistant\n' }}{% endif %}", "eos_token": "<|im_end|>"}`
+	llama3Config  = `{"chat_template": "{% set loop_messages = messages %}{% for message in loop_messages %}{% set content = '<|start_header_id|>' + message['role'] + '<|end_header_id|>\n\n'+ message['content'] | trim + '<|eot_id|>' %}{% if loop.index0 == 0 %}{% set content = bos_token + content %}{% endif %}{{ content }}{% endfor %}{% if add_generation_prompt %}{{ '<|start_header_id|>assistant<|end_header_id|>\n\n' }}{% endif %}", "bos_token": "<|begin_of_text|>", "eos_token": "<|eot_id|>"}`
+	mistralConfig = `{"chat_template": "{{ bos_token }}{% for message in messages %}{% if (message['role'] == 'user') != (loop.index0 % 2 == 0) %}{{ raise_exception('Conversation roles must alternate user/assistant/user/assistant/...') }}{% endif %}{% if message['role'] == 'user' %}{{ '[INST] ' + message['content'] + ' [/INST]' }}{% elif message['role'] == 'assistant' %}{{ message['content'] + eos_token}}{% else %}{{ raise_exception('Only user and assistant roles are supported!') }}{% endif %}{% endfor %}", "bos_token": {"__type": "AddedToken", "content": "<s>", "special": true}, "eos_token": {"__type": "AddedToken", "content": "</s>", "special": true}}`
+	gemmaConfig   = `{"chat_template": "{{ bos_token }}{% if messages[0]['role'] == 'system' %}{{ raise_exception('System role not supported') }}{% endif %}{% for message in messages %}{% if (message['role'] == 'user') != (loop.index0 % 2 == 0) %}{{ raise_exception('Conversation roles must alternate user/assistant/user/assistant/...') }}{% endif %}{% if (message['role'] == 'assistant') %}{% set role = 'model' %}{% else %}{% set role = message['role'] %}{% endif %}{{ '<start_of_turn>' + role + '\n' + message['content'] | trim + '<end_of_turn>\n' }}{% endfor %}{% if add_generation_prompt %}{{'<start_of_turn>model\n'}}{% endif %}", "bos_token": "<bos>"}`
+	zephyrConfig  = `{"chat_template": "{% for message in messages %}\n{% if message['role'] == 'user' %}\n{{ '<|user|>\n' + message['content'] + eos_token }}\n{% elif message['role'] == 'system' %}\n{{ '<|system|>\n' + message['content'] + eos_token }}\n{% elif message['role'] == 'assistant' %}\n{{ '<|assistant|>\n'  + message['content'] + eos_token }}\n{% endif %}\n{% if loop.last and add_generation_prompt %}\n{{ '<|assistant|>' }}\n{% endif %}\n{% endfor %}", "eos_token": "</s>"}`
+)
+
+func TestApplyChatTemplate(t *testing.T) {
+	conversation := []ChatMessage{
+		{Role: "user", Content: "Hello"},
+		{Role: "assistant", Content: "Hi there "},
+		{Role: "user", Content: "How are you?"},
+	}
+
+	tests := []struct {
+		name                string
+		config              string
+		messages            []ChatMessage
+		addGenerationPrompt bool
+		expected            string
+	}{
+		{
+			name:                "ChatML",
+			config:              chatMLConfig,
+			messages:            conversation[:1],
+			addGenerationPrompt: true,
+			expected:            "<|im_start|>system\nYou are a helpful assistant.<|im_end|>\n<|im_start|>user\nHello<|im_end|>\n<|im_start|>assistant\n",
+		},
+		{
+			name:     "Llama 3",
+			config:   llama3Config,
+			messages: conversation[:2],
+			expected: "<|begin_of_text|><|start_header_id|>user<|end_header_id|>\n\nHello<|eot_id|><|start_header_id|>assistant<|end_header_id|>\n\nHi there<|eot_id|>",
+		},
+		{
+			name:     "Mistral",
+			config:   mistralConfig,
+			messages: conversation,
+			expected: "<s>[INST] Hello [/INST]Hi there </s>[INST] How are you? [/INST]",
+		},
+		{
+			name:                "Gemma",
+			config:              gemmaConfig,
+			messages:            conversation[:2],
+			addGenerationPrompt: true,
+			expected:            "<bos><start_of_turn>user\nHello<end_of_turn>\n<start_of_turn>model\nHi there<end_of_turn>\n<start_of_turn>model\n",
+		},
+		{
+			name:                "Zephyr",
+			config:              zephyrConfig,
+			messages:            []ChatMessage{{Role: "system", Content: "You are friendly"}, {Role: "user", Content: "Hi"}},
+			addGenerationPrompt: true,
+			expected:            "<|system|>\nYou are friendly</s>\n<|user|>\nHi</s>\n<|assistant|>\n",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tok := &Tokenizer{}
+			require.NoError(t, WithTokenizerConfig([]byte(tc.config))(tok))
+
+			text, err := tok.ApplyChatTemplate(tc.messages, tc.addGenerationPrompt)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, text)
+		})
+	}
+
+	t.Run("Template exception", func(t *testing.T) {
+		tok := &Tokenizer{}
+		require.NoError(t, WithTokenizerConfig([]byte(mistralConfig))(tok))
+
+		_, err := tok.ApplyChatTemplate([]ChatMessage{{Role: "assistant", Content: "Hi"}}, false)
+		require.ErrorContains(t, err, "chat template raised an exception: Conversation roles must alternate")
+	})
+
+	t.Run("WithChatTemplate overrides the config", func(t *testing.T) {
+		tok := &Tokenizer{}
+		require.NoError(t, WithTokenizerConfig([]byte(llama3Config))(tok))
+		require.NoError(t, WithChatTemplate("{% for m in messages %}{{ eos_token }}{{ m.content }}{% endfor %}")(tok))
+
+		text, err := tok.ApplyChatTemplate(conversation[:1], true)
+		require.NoError(t, err)
+		require.Equal(t, "<|eot_id|>Hello", text, "Special tokens still come from the config")
+	})
+
+	t.Run("Invalid template", func(t *testing.T) {
+		err := WithChatTemplate("{% if %}")(&Tokenizer{})
+		require.ErrorContains(t, err, "invalid chat template")
+
+		tok := &Tokenizer{}
+		require.NoError(t, WithTokenizerConfig([]byte(`{"chat_template": "{% include 'chat.jinja' %}"}`))(tok))
+		_, err = tok.ApplyChatTemplate(conversation, false)
+		require.ErrorContains(t, err, `invalid chat template: line 1: unsupported tag "include"`)
+	})
+
+	t.Run("No template", func(t *testing.T) {
+		_, err := (&Tokenizer{}).ApplyChatTemplate(conversation, true)
+		require.ErrorContains(t, err, "tokenizer has no chat template")
+	})
+
+	t.Run("Closed tokenizer", func(t *testing.T) {
+		_, err := (&Tokenizer{closed: true, chatTemplate: "x"}).ApplyChatTemplate(conversation, true)
+		require.ErrorIs(t, err, ErrTokenizerClosed)
+	})
+}
+
+// TestApplyChatTemplateModels renders the chat templates of released models, kept in
+// testdata/chat_templates as published, and compares them with the output of
+// apply_chat_template of transformers.
+func TestApplyChatTemplateModels(t *testing.T) {
+	system := ChatMessage{Role: "system", Content: "Be brief."}
+	conversation := []ChatMessage{
+		{Role: "user", Content: "Hello"},
+		{Role: "assistant", Content: "Hi there "},
+		{Role: "user", Content: "How are you?"},
+	}
+
+	tests := []struct {
+		name                string
+		template            string
+		specialTokens       string
+		messages            []ChatMessage
+		addGenerationPrompt bool
+		expected            string
+		err                 string
+	}{
+		{
+			name:                "Llama 3.1",
+			template:            "llama3.1.jinja",
+			specialTokens:       `{"bos_token": "<|begin_of_text|>", "eos_token": "<|eot_id|>"}`,
+			messages:            []ChatMessage{system, conversation[0]},
+			addGenerationPrompt: true,
+			expected:            "<|begin_of_text|><|start_header_id|>system<|end_header_id|>\n\nCutting Knowledge Date: December 2023\nToday Date: 26 Jul 2024\n\nBe brief.<|eot_id|><|start_header_id|>user<|end_header_id|>\n\nHello<|eot_id|><|start_header_id|>assistant<|end_header_id|>\n\n",
+		},
+		{
+			name:          "Llama 3.1 without system message",
+			template:      "llama3.1.jinja",
+			specialTokens: `{"bos_token": "<|begin_of_text|>", "eos_token": "<|eot_id|>"}`,
+			messages:      conversation[:2],
+			expected:      "<|begin_of_text|><|start_header_id|>system<|end_header_id|>\n\nCutting Knowledge Date: December 2023\nToday Date: 26 Jul 2024\n\n<|eot_id|><|start_header_id|>user<|end_header_id|>\n\nHello<|eot_id|><|start_header_id|>assistant<|end_header_id|>\n\nHi there<|eot_id|>",
+		},
+		{
+			name:          "Mistral v0.3",
+			template:      "mistral-v0.3.jinja",
+			specialTokens: `{"bos_token": "<s>", "eos_token": "</s>"}`,
+			messages:      conversation,
+			expected:      "<s>[INST] Hello[/INST] Hi there</s>[INST] How are you?[/INST]",
+		},
+		{
+			name:          "Mistral v0.3 with system message",
+			template:      "mistral-v0.3.jinja",
+			specialTokens: `{"bos_token": "<s>", "eos_token": "</s>"}`,
+			messages:      append([]ChatMessage{system}, conversation...),
+			expected:      "<s>[INST] Hello[/INST] Hi there</s>[INST] Be brief.\n\nHow are you?[/INST]",
+		},
+		{
+			name:          "Mistral v0.3 roles must alternate",
+			template:      "mistral-v0.3.jinja",
+			specialTokens: `{"bos_token": "<s>", "eos_token": "</s>"}`,
+			messages:      conversation[1:],
+			err:           "chat template raised an exception: After the optional system message, conversation roles must alternate",
+		},
+		{
+			name:          "Mistral v0.3 unknown role",
+			template:      "mistral-v0.3.jinja",
+			specialTokens: `{"bos_token": "<s>", "eos_token": "</s>"}`,
+			messages:      []ChatMessage{conversation[0], {Role: "narrator", Content: "Meanwhile"}},
+			err:           "chat template raised an exception: Only user and assistant roles are supported",
+		},
+		{
+			name:                "Qwen 2.5",
+			template:            "qwen2.5.jinja",
+			specialTokens:       `{"eos_token": "<|im_end|>", "pad_token": "<|endoftext|>"}`,
+			messages:            conversation[:1],
+			addGenerationPrompt: true,
+			expected:            "<|im_start|>system\nYou are Qwen, created by Alibaba Cloud. You are a helpful assistant.<|im_end|>\n<|im_start|>user\nHello<|im_end|>\n<|im_start|>assistant\n",
+		},
+		{
+			name:          "Qwen 2.5 with system message",
+			template:      "qwen2.5.jinja",
+			specialTokens: `{"eos_token": "<|im_end|>", "pad_token": "<|endoftext|>"}`,
+			messages:      append([]ChatMessage{system}, conversation...),
+			expected:      "<|im_start|>system\nBe brief.<|im_end|>\n<|im_start|>user\nHello<|im_end|>\n<|im_start|>assistant\nHi there <|im_end|>\n<|im_start|>user\nHow are you?<|im_end|>\n",
+		},
+		{
+			name:                "Gemma 2",
+			template:            "gemma2.jinja",
+			specialTokens:       `{"bos_token": "<bos>", "eos_token": "<eos>"}`,
+			messages:            conversation[:2],
+			addGenerationPrompt: true,
+			expected:            "<bos><start_of_turn>user\nHello<end_of_turn>\n<start_of_turn>model\nHi there<end_of_turn>\n<start_of_turn>model\n",
+		},
+		{
+			name:          "Gemma 2 system role",
+			template:      "gemma2.jinja",
+			specialTokens: `{"bos_token": "<bos>", "eos_token": "<eos>"}`,
+			messages:      []ChatMessage{system, conversation[0]},
+			err:           "chat template raised an exception: System role not supported",
+		},
+		{
+			name:                "Zephyr",
+			template:            "zephyr.jinja",
+			specialTokens:       `{"eos_token": "</s>"}`,
+			messages:            append([]ChatMessage{system}, conversation[:2]...),
+			addGenerationPrompt: true,
+			expected:            "<|system|>\nBe brief.</s>\n<|user|>\nHello</s>\n<|assistant|>\nHi there </s>\n<|assistant|>\n",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			template, err := os.ReadFile(filepath.Join("testdata", "chat_templates", tc.template))
+			require.NoError(t, err)
+			tok := &Tokenizer{}
+			require.NoError(t, WithTokenizerConfig([]byte(tc.specialTokens))(tok))
+			require.NoError(t, WithChatTemplate(string(template))(tok))
+
+			text, err := tok.ApplyChatTemplate(tc.messages, tc.addGenerationPrompt)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, text)
+		})
+	}
+}
+
+func TestChatTemplateIsParsedOnce(t *testing.T) {
+	tok := &Tokenizer{}
+	require.NoError(t, WithTokenizerConfig([]byte(chatMLConfig))(tok))
+	messages := []ChatMessage{{Role: "user", Content: "Hello"}}
+
+	_, err := tok.ApplyChatTemplate(messages, true)
+	require.NoError(t, err)
+	parsed := tok.parsedChatTemplate
+	require.NotNil(t, parsed)
+	_, err = tok.ApplyChatTemplate(messages, false)
+	require.NoError(t, err)
+	require.Same(t, parsed, tok.parsedChatTemplate, "The parsed template is reused")
+
+	require.NoError(t, WithChatTemplate("{{ messages[0].content }}")(tok))
+	text, err := tok.ApplyChatTemplate(messages, false)
+	require.NoError(t, err)
+	require.Equal(t, "Hello", text, "A new template replaces the cached one")
+}
+
+func TestStrftime(t *testing.T) {
+	tm := time.Date(2024, time.July, 5, 14, 3, 9, 0, time.UTC)
+	require.Equal(t, "05 Jul 2024", strftime(tm, "%d %b %Y"))
+	require.Equal(t, "2024-07-05 14:03:09 100%", strftime(tm, "%Y-%m-%d %H:%M:%S 100%%"))
+}
+
+func TestEncodeChat(t *testing.T) {
+	libpath := checkLibraryExists(t)
+
+	tok, err := FromWordPieceVocab("testdata/vocab.txt", true,
+		WithLibraryPath(libpath),
+		WithChatTemplate("[CLS] {% for m in messages %}{{ m.content }} [SEP] {% endfor %}"),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = tok.Close()
+	})
+
+	messages := []ChatMessage{{Role: "user", Content: "hello"}, {Role: "assistant", Content: "world"}}
+	res, err := tok.EncodeChat(messages, false, WithReturnTokens())
+	require.NoError(t, err)
+	require.Equal(t, []string{"[CLS]", "hello", "[SEP]", "world", "[SEP]"}, res.Tokens)
+
+	// As with WithHFApplyTokenizerConfig, which adds special tokens by default.
+	tok.defaultEncodingOpts.AddSpecialTokens = true
+	res, err = tok.EncodeChat(messages, false, WithReturnTokens())
+	require.NoError(t, err)
+	require.Equal(t, []string{"[CLS]", "hello", "[SEP]", "world", "[SEP]"}, res.Tokens, "The template adds the special tokens")
+}
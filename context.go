@@ -0,0 +1,93 @@
+package tokenizers
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// contextBatchChunkSize is the number of inputs handed to a single FFI call by the
+// context-aware batch APIs. The FFI call itself cannot be interrupted, so the context
+// is checked between chunks; smaller chunks bound the work done after cancellation.
+const contextBatchChunkSize = 64
+
+// EncodeContext is like Encode but returns early if ctx is already done.
+// The underlying FFI call cannot be interrupted once started.
+func (t *Tokenizer) EncodeContext(ctx context.Context, message string, opts ...EncodeOption) (*EncodeResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err, "encode canceled")
+	}
+	return t.Encode(message, opts...)
+}
+
+// EncodeBatchContext is like EncodeBatch but splits the input into chunks and checks
+// ctx between them, so a deadline bounds the total tokenization work.
+//
+// Padding with PaddingStrategyBatchLongest is applied per chunk rather than across the
+// whole input.
+func (t *Tokenizer) EncodeBatchContext(ctx context.Context, messages []string, opts ...EncodeOption) (BatchEncoding, error) {
+	results := make(BatchEncoding, 0, len(messages))
+	for start := 0; start < len(messages) || start == 0; start += contextBatchChunkSize {
+		if err := ctx.Err(); err != nil {
+			return nil, errors.Wrap(err, "encode batch canceled")
+		}
+		end := min(start+contextBatchChunkSize, len(messages))
+		chunk, err := t.EncodeBatch(messages[start:end], opts...)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, chunk...)
+	}
+	return results, nil
+}
+
+// EncodePairsContext is like EncodePairs but splits the input into chunks and checks
+// ctx between them, so a deadline bounds the total tokenization work.
+//
+// Padding with PaddingStrategyBatchLongest is applied per chunk rather than across the
+// whole input.
+func (t *Tokenizer) EncodePairsContext(ctx context.Context, sequences []string, pairs []string, opts ...EncodeOption) (BatchEncoding, error) {
+	if len(sequences) != len(pairs) {
+		return nil, errors.Errorf("sequences and pairs must have the same length, got %d and %d", len(sequences), len(pairs))
+	}
+	results := make(BatchEncoding, 0, len(sequences))
+	for start := 0; start < len(sequences) || start == 0; start += contextBatchChunkSize {
+		if err := ctx.Err(); err != nil {
+			return nil, errors.Wrap(err, "encode pairs canceled")
+		}
+		end := min(start+contextBatchChunkSize, len(sequences))
+		chunk, err := t.EncodePairs(sequences[start:end], pairs[start:end], opts...)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, chunk...)
+	}
+	return results, nil
+}
+
+// DecodeContext is like Decode but returns early if ctx is already done.
+// The underlying FFI call cannot be interrupted once started.
+func (t *Tokenizer) DecodeContext(ctx context.Context, ids []uint32, skipSpecialTokens bool) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", errors.Wrap(err, "decode canceled")
+	}
+	return t.Decode(ids, skipSpecialTokens)
+}
+
+// DecodeBatchContext is like DecodeBatch but splits the input into chunks and checks
+// ctx between them, so a deadline bounds the total decoding work.
+func (t *Tokenizer) DecodeBatchContext(ctx context.Context, sequences [][]uint32, skipSpecialTokens bool) ([]string, error) {
+	results := make([]string, 0, len(sequences))
+	for start := 0; start < len(sequences) || start == 0; start += contextBatchChunkSize {
+		if err := ctx.Err(); err != nil {
+			return nil, errors.Wrap(err, "decode batch canceled")
+		}
+		end := min(start+contextBatchChunkSize, len(sequences))
+		chunk, err := t.DecodeBatch(sequences[start:end], skipSpecialTokens)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, chunk...)
+	}
+	return results, nil
+}
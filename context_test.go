@@ -0,0 +1,102 @@
+package tokenizers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextMethodsRespectCanceledContext(t *testing.T) {
+	// A canceled context must be reported before the tokenizer is touched,
+	// so an uninitialized tokenizer is sufficient here.
+	tok := &Tokenizer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := tok.EncodeContext(ctx, "Hello")
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = tok.EncodeBatchContext(ctx, []string{"Hello"})
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = tok.EncodePairsContext(ctx, []string{"Hello"}, []string{"World"})
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = tok.DecodeContext(ctx, []uint32{1, 2, 3}, false)
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = tok.DecodeBatchContext(ctx, [][]uint32{{1, 2, 3}}, false)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestEncodePairsContextMismatchedLengths(t *testing.T) {
+	tok := &Tokenizer{}
+	_, err := tok.EncodePairsContext(context.Background(), []string{"a", "b"}, []string{"c"})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "same length")
+}
+
+func TestContextMethods(t *testing.T) {
+	libpath := checkLibraryExists(t)
+	tok, err := FromFile("./tokenizer.json", WithLibraryPath(libpath))
+	require.NoError(t, err, "Failed to load tokenizer from file")
+	t.Cleanup(func() {
+		_ = tok.Close()
+	})
+	ctx := context.Background()
+
+	t.Run("EncodeContext matches Encode", func(t *testing.T) {
+		expected, err := tok.Encode("Hello, world!", WithReturnAllAttributes())
+		require.NoError(t, err)
+		res, err := tok.EncodeContext(ctx, "Hello, world!", WithReturnAllAttributes())
+		require.NoError(t, err)
+		require.Equal(t, expected, res)
+	})
+
+	t.Run("EncodePairsContext spans multiple chunks", func(t *testing.T) {
+		count := contextBatchChunkSize*2 + 3
+		sequences := make([]string, count)
+		pairs := make([]string, count)
+		for i := range sequences {
+			sequences[i] = fmt.Sprintf("Query %d", i)
+			pairs[i] = fmt.Sprintf("Document %d", i)
+		}
+
+		expected, err := tok.EncodePairs(sequences, pairs, WithReturnTokens())
+		require.NoError(t, err)
+		results, err := tok.EncodePairsContext(ctx, sequences, pairs, WithReturnTokens())
+		require.NoError(t, err)
+		require.Equal(t, expected, results)
+	})
+
+	t.Run("EncodeBatchContext spans multiple chunks", func(t *testing.T) {
+		messages := make([]string, contextBatchChunkSize+1)
+		for i := range messages {
+			messages[i] = fmt.Sprintf("Message number %d", i)
+		}
+
+		expected, err := tok.EncodeBatch(messages)
+		require.NoError(t, err)
+		results, err := tok.EncodeBatchContext(ctx, messages)
+		require.NoError(t, err)
+		require.Equal(t, expected, results)
+	})
+
+	t.Run("Empty input", func(t *testing.T) {
+		results, err := tok.EncodePairsContext(ctx, []string{}, []string{})
+		require.NoError(t, err)
+		require.Len(t, results, 0)
+	})
+
+	t.Run("DecodeContext matches Decode", func(t *testing.T) {
+		enc, err := tok.Encode("Hello world")
+		require.NoError(t, err)
+		expected, err := tok.Decode(enc.IDs, true)
+		require.NoError(t, err)
+		decoded, err := tok.DecodeContext(ctx, enc.IDs, true)
+		require.NoError(t, err)
+		require.Equal(t, expected, decoded)
+	})
+}
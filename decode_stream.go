@@ -0,0 +1,137 @@
+package tokenizers
+
+import (
+	"sync"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+// ErrDecodeStreamClosed is returned when Step is called on a closed DecodeStream.
+var ErrDecodeStreamClosed = errors.New("decode stream is closed")
+
+// ErrDecodeStreamsOpen is returned by calls that modify the tokenizer, such as
+// SetTruncation, SetPadding and AddTokens, while any of its decode streams is open.
+var ErrDecodeStreamsOpen = errors.New("tokenizer has open decode streams")
+
+// DecodeStream incrementally decodes token IDs as they are generated, e.g. by an LLM.
+// Unlike calling Decode on every new token, it only emits text once it is final, so
+// byte-fallback tokens, multi-byte UTF-8 characters and SentencePiece word boundaries
+// come out correctly.
+//
+// A DecodeStream is safe for concurrent use, but tokens must be fed in order, so it is
+// normally owned by a single generation loop. It must be closed when no longer needed;
+// closing the tokenizer closes all of its streams.
+type DecodeStream struct {
+	t      *Tokenizer
+	mu     sync.Mutex
+	handle unsafe.Pointer
+}
+
+// NewDecodeStream creates an incremental decoder for this tokenizer. The stream reads
+// the native tokenizer directly, so while it is open, calls that modify the tokenizer
+// (SetTruncation, DisableTruncation, SetPadding, DisablePadding, AddTokens and
+// AddSpecialTokens) fail with ErrDecodeStreamsOpen. Close the stream first.
+func (t *Tokenizer) NewDecodeStream(skipSpecialTokens bool) (*DecodeStream, error) {
+	unlock, err := t.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if t.newDecodeStream == nil || t.tokenizerh == nil {
+		return nil, errors.New("new_decode_stream function is not initialized or tokenizer is not loaded")
+	}
+
+	var handle unsafe.Pointer
+	err = t.callNative("new_decode_stream", func() int32 {
+		return t.newDecodeStream(t.tokenizerh, skipSpecialTokens, &handle)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create decode stream")
+	}
+	if handle == nil {
+		return nil, errors.New("new_decode_stream returned null pointer")
+	}
+
+	stream := &DecodeStream{t: t, handle: handle}
+	t.streamsMu.Lock()
+	if t.decodeStreams == nil {
+		t.decodeStreams = make(map[*DecodeStream]struct{})
+	}
+	t.decodeStreams[stream] = struct{}{}
+	t.streamsMu.Unlock()
+	return stream, nil
+}
+
+// Step feeds the next token ID to the stream. It returns the text finalized by this
+// token and true, or an empty string and false if the token did not complete any text
+// yet (e.g. the first byte of a multi-byte character).
+func (s *DecodeStream) Step(id uint32) (string, bool, error) {
+	unlock, err := s.t.beginOperation()
+	if err != nil {
+		return "", false, err
+	}
+	defer unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.handle == nil {
+		return "", false, ErrDecodeStreamClosed
+	}
+
+	var cStrPtr unsafe.Pointer
+	err = s.t.callNative("decode_stream_step", func() int32 {
+		return s.t.decodeStreamStep(s.handle, id, &cStrPtr)
+	})
+	if err != nil {
+		return "", false, errors.Wrapf(err, "failed to decode token %d", id)
+	}
+	if cStrPtr == nil {
+		return "", false, nil
+	}
+	text := goStringFromPtr(cStrPtr)
+	s.t.freeString(cStrPtr)
+	return text, true, nil
+}
+
+// Close releases the stream. It is safe to call Close more than once and after the
+// tokenizer has been closed.
+func (s *DecodeStream) Close() error {
+	unlock, err := s.t.beginOperation()
+	if err != nil {
+		// The tokenizer already released all of its streams.
+		return nil
+	}
+	defer unlock()
+
+	s.t.streamsMu.Lock()
+	delete(s.t.decodeStreams, s)
+	s.t.streamsMu.Unlock()
+
+	s.free(s.t.freeDecodeStream)
+	return nil
+}
+
+// free releases the native stream once.
+func (s *DecodeStream) free(freeDecodeStream func(unsafe.Pointer)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.handle != nil && freeDecodeStream != nil {
+		freeDecodeStream(s.handle)
+	}
+	s.handle = nil
+}
+
+// closeDecodeStreams frees every open stream. It must be called with lifecycleMu held
+// for writing, before the tokenizer itself is freed.
+func (t *Tokenizer) closeDecodeStreams() {
+	t.streamsMu.Lock()
+	streams := t.decodeStreams
+	t.decodeStreams = nil
+	t.streamsMu.Unlock()
+
+	for stream := range streams {
+		stream.free(t.freeDecodeStream)
+	}
+}
@@ -0,0 +1,94 @@
+package tokenizers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeStreamOnClosedTokenizer(t *testing.T) {
+	tok := &Tokenizer{closed: true}
+	_, err := tok.NewDecodeStream(true)
+	require.ErrorIs(t, err, ErrTokenizerClosed)
+
+	stream := &DecodeStream{t: tok}
+	_, _, err = stream.Step(1)
+	require.ErrorIs(t, err, ErrTokenizerClosed)
+	require.NoError(t, stream.Close())
+}
+
+func TestModifyWithOpenDecodeStream(t *testing.T) {
+	tok := &Tokenizer{}
+	tok.decodeStreams = map[*DecodeStream]struct{}{{t: tok}: {}}
+
+	require.ErrorIs(t, tok.SetTruncation(TruncationParams{MaxLength: 8}), ErrDecodeStreamsOpen)
+	require.ErrorIs(t, tok.DisableTruncation(), ErrDecodeStreamsOpen)
+	require.ErrorIs(t, tok.DisablePadding(), ErrDecodeStreamsOpen)
+	_, err := tok.AddTokens([]AddedToken{{Content: "<extra>"}})
+	require.ErrorIs(t, err, ErrDecodeStreamsOpen)
+}
+
+func TestDecodeStream(t *testing.T) {
+	libpath := checkLibraryExists(t)
+	tok, err := FromFile("./tokenizer.json", WithLibraryPath(libpath))
+	require.NoError(t, err, "Failed to load tokenizer from file")
+	t.Cleanup(func() {
+		_ = tok.Close()
+	})
+
+	text := "Hello, world! Tokenization streams nicely."
+	enc, err := tok.Encode(text, WithAddSpecialTokens())
+	require.NoError(t, err)
+	expected, err := tok.Decode(enc.IDs, true)
+	require.NoError(t, err)
+
+	t.Run("Matches Decode", func(t *testing.T) {
+		stream, err := tok.NewDecodeStream(true)
+		require.NoError(t, err)
+		defer func() { _ = stream.Close() }()
+
+		var sb strings.Builder
+		for _, id := range enc.IDs {
+			chunk, ok, err := stream.Step(id)
+			require.NoError(t, err)
+			if ok {
+				sb.WriteString(chunk)
+			} else {
+				require.Empty(t, chunk)
+			}
+		}
+		require.Equal(t, expected, sb.String())
+	})
+
+	t.Run("Closed stream", func(t *testing.T) {
+		stream, err := tok.NewDecodeStream(true)
+		require.NoError(t, err)
+		require.NoError(t, stream.Close())
+		require.NoError(t, stream.Close(), "Close should be idempotent")
+
+		_, _, err = stream.Step(enc.IDs[0])
+		require.ErrorIs(t, err, ErrDecodeStreamClosed)
+	})
+
+	t.Run("Modify after close", func(t *testing.T) {
+		stream, err := tok.NewDecodeStream(true)
+		require.NoError(t, err)
+		require.ErrorIs(t, tok.DisablePadding(), ErrDecodeStreamsOpen)
+
+		require.NoError(t, stream.Close())
+		require.NoError(t, tok.DisablePadding())
+	})
+
+	t.Run("Tokenizer close releases streams", func(t *testing.T) {
+		other, err := FromFile("./tokenizer.json", WithLibraryPath(libpath))
+		require.NoError(t, err)
+		stream, err := other.NewDecodeStream(false)
+		require.NoError(t, err)
+
+		require.NoError(t, other.Close())
+		_, _, err = stream.Step(enc.IDs[0])
+		require.ErrorIs(t, err, ErrTokenizerClosed)
+		require.NoError(t, stream.Close())
+	})
+}
@@ -48,4 +48,37 @@ func TestDecodeWithValidIDs(t *testing.T) {
 	decodedSkip, err := tok.Decode(encoding.IDs, true)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, decodedSkip)
-}
\ No newline at end of file
+}
+func TestDecodeBatch(t *testing.T) {
+	libpath := checkLibraryExists(t)
+	data, err := os.ReadFile("./tokenizer.json")
+	require.NoError(t, err, "Failed to read tokenizer file")
+
+	tok, err := FromBytes(data, WithLibraryPath(libpath))
+	require.NoError(t, err)
+	defer func() { _ = tok.Close() }()
+
+	texts := []string{"Hello, world!", "The quick brown fox", "Goodbye"}
+	encodings, err := tok.EncodeBatch(texts)
+	require.NoError(t, err)
+
+	sequences := make([][]uint32, 0, len(encodings)+1)
+	for _, enc := range encodings {
+		sequences = append(sequences, enc.IDs)
+	}
+	sequences = append(sequences, []uint32{})
+
+	decoded, err := tok.DecodeBatch(sequences, true)
+	require.NoError(t, err)
+	require.Len(t, decoded, len(sequences))
+	for i, ids := range sequences[:len(encodings)] {
+		expected, err := tok.Decode(ids, true)
+		require.NoError(t, err)
+		assert.Equal(t, expected, decoded[i])
+	}
+	assert.Equal(t, "", decoded[len(decoded)-1], "Empty sequences should decode to an empty string")
+
+	decoded, err = tok.DecodeBatch(nil, true)
+	require.NoError(t, err)
+	assert.Empty(t, decoded)
+}
@@ -925,13 +925,38 @@ func verifyLibraryABICompatibilityHandle(libh uintptr) error {
 	requiredSymbols := []string{
 		"from_file",
 		"from_bytes",
+		"train_tokenizer",
 		"encode",
+		"encode_batch",
 		"encode_batch_pairs",
+		"encode_into",
 		"free_buffer",
 		"free_tokenizer",
+		"clone_tokenizer",
+		"to_json",
 		"free_string",
 		"decode",
+		"decode_batch",
+		"free_string_array",
 		"vocab_size",
+		"token_to_id",
+		"id_to_token",
+		"add_tokens",
+		"get_vocab",
+		"set_truncation",
+		"get_truncation",
+		"set_padding",
+		"get_padding",
+		"free_vocab",
+		"normalize",
+		"free_normalized",
+		"pre_tokenize",
+		"free_pre_tokens",
+		"new_decode_stream",
+		"decode_stream_step",
+		"free_decode_stream",
+		"take_last_error_message",
+		"get_error_message",
 		"get_version",
 	}
 
@@ -0,0 +1,192 @@
+package tokenizers
+
+import (
+	"runtime"
+	"unsafe"
+
+	"github.com/ebitengine/purego"
+	"github.com/pkg/errors"
+)
+
+// EncodeIntoBuffers mirrors the Rust EncodeIntoBuffers struct: caller-owned destination
+// arrays, each holding at least Capacity elements, or nil to skip the field.
+type EncodeIntoBuffers struct {
+	IDs                *uint32
+	AttentionMask      *uint32
+	TypeIDs            *uint32
+	IDsInt64           *int64
+	AttentionMaskInt64 *int64
+	TypeIDsInt64       *int64
+	Capacity           uintptr
+	Len                uintptr
+}
+
+// EncodeBuffers holds preallocated destination slices for EncodeInto. Any combination
+// of slices may be set and empty slices are skipped, so for example the int64 slices can
+// be bound directly to the input tensors of an ONNX model. All non-empty slices must
+// have room for the longest expected encoding.
+type EncodeBuffers struct {
+	IDs                []uint32
+	AttentionMask      []uint32
+	TypeIDs            []uint32
+	IDsInt64           []int64
+	AttentionMaskInt64 []int64
+	TypeIDsInt64       []int64
+	// Len is the number of tokens written by the last EncodeInto call. Elements past
+	// Len are left untouched. If the encoding did not fit, Len is the required size.
+	Len int
+
+	// Reused across calls so that steady-state encoding does not allocate. This makes
+	// EncodeBuffers unsafe for concurrent use.
+	native  EncodeIntoBuffers
+	options EncodeOptions
+	args    [5]uintptr
+	pinner  runtime.Pinner
+}
+
+// NewEncodeBuffers allocates uint32 IDs, attention mask and type ID slices of size capacity.
+func NewEncodeBuffers(capacity int) *EncodeBuffers {
+	return &EncodeBuffers{
+		IDs:           make([]uint32, capacity),
+		AttentionMask: make([]uint32, capacity),
+		TypeIDs:       make([]uint32, capacity),
+	}
+}
+
+// NewEncodeBuffersInt64 allocates int64 IDs, attention mask and type ID slices of size
+// capacity, the layout expected by most ONNX and PyTorch models.
+func NewEncodeBuffersInt64(capacity int) *EncodeBuffers {
+	return &EncodeBuffers{
+		IDsInt64:           make([]int64, capacity),
+		AttentionMaskInt64: make([]int64, capacity),
+		TypeIDsInt64:       make([]int64, capacity),
+	}
+}
+
+// capacity returns the length of the shortest non-empty slice.
+func (b *EncodeBuffers) capacity() (int, error) {
+	capacity := -1
+	for _, n := range []int{len(b.IDs), len(b.AttentionMask), len(b.TypeIDs)} {
+		if n > 0 && (capacity < 0 || n < capacity) {
+			capacity = n
+		}
+	}
+	for _, n := range []int{len(b.IDsInt64), len(b.AttentionMaskInt64), len(b.TypeIDsInt64)} {
+		if n > 0 && (capacity < 0 || n < capacity) {
+			capacity = n
+		}
+	}
+	if capacity < 0 {
+		return 0, errors.New("at least one destination slice must be non-empty")
+	}
+	return capacity, nil
+}
+
+func firstElem[T any](s []T) *T {
+	if len(s) == 0 {
+		return nil
+	}
+	return &s[0]
+}
+
+// pin pins message and the memory that the native call reads and writes through the
+// arguments and b.native, until b.pinner is unpinned.
+func (b *EncodeBuffers) pin(message string) {
+	if len(message) > 0 {
+		b.pinner.Pin(unsafe.StringData(message))
+	}
+	b.pinner.Pin(b)
+	for _, p := range [...]*uint32{b.native.IDs, b.native.AttentionMask, b.native.TypeIDs} {
+		if p != nil {
+			b.pinner.Pin(p)
+		}
+	}
+	for _, p := range [...]*int64{b.native.IDsInt64, b.native.AttentionMaskInt64, b.native.TypeIDsInt64} {
+		if p != nil {
+			b.pinner.Pin(p)
+		}
+	}
+}
+
+// EncodeInto encodes message directly into the caller-provided slices of dst, skipping
+// the intermediate EncodeResult. From opts, AddSpecialTokens and the per-call overrides
+// (WithMaxLength, WithTruncationSide, WithPadToLength and WithNoPadding) are honored;
+// the fields written are the ones with a non-empty slice in dst.
+//
+// If the encoding is longer than the shortest slice in dst, an error matching
+// ErrBufferTooSmall is returned and dst.Len is set to the required size. Configure
+// truncation and fixed padding on the tokenizer, or pass the overrides above, to get
+// fixed-shape output.
+//
+// Reusing dst keeps the Go side of repeated calls free of allocations, apart from the
+// argument block that purego allocates for every native call. dst holds per-call state,
+// so it must not be used by concurrent EncodeInto calls; give each goroutine its own.
+func (t *Tokenizer) EncodeInto(message string, dst *EncodeBuffers, opts ...EncodeOption) error {
+	if dst == nil {
+		return errors.New("destination buffers cannot be nil")
+	}
+	capacity, err := dst.capacity()
+	if err != nil {
+		return err
+	}
+
+	// Locked directly rather than with beginOperation, whose unlock function is
+	// allocated on every call.
+	t.lifecycleMu.RLock()
+	defer t.lifecycleMu.RUnlock()
+	if t.closed {
+		return ErrTokenizerClosed
+	}
+
+	if t.encodeInto == 0 || t.tokenizerh == nil {
+		return errors.New("encode_into function is not initialized or tokenizer is not loaded")
+	}
+
+	dst.options = t.defaultEncodingOpts
+	for _, opt := range opts {
+		if err := opt(&dst.options); err != nil {
+			return errors.Wrap(err, "failed to apply encoding option")
+		}
+	}
+	dst.native = EncodeIntoBuffers{
+		IDs:                firstElem(dst.IDs),
+		AttentionMask:      firstElem(dst.AttentionMask),
+		TypeIDs:            firstElem(dst.TypeIDs),
+		IDsInt64:           firstElem(dst.IDsInt64),
+		AttentionMaskInt64: firstElem(dst.AttentionMaskInt64),
+		TypeIDsInt64:       firstElem(dst.TypeIDsInt64),
+		Capacity:           uintptr(capacity),
+	}
+
+	h, variant, err := t.handleFor(&dst.options)
+	if err != nil {
+		return err
+	}
+	defer t.releaseVariant(variant)
+
+	// The message is passed as pointer and length, so that it is not copied into a C
+	// string, and the call is made without the closure of callNative. Both would
+	// allocate on every call.
+	dst.pin(message)
+	defer dst.pinner.Unpin()
+	dst.args = [...]uintptr{
+		uintptr(h),
+		uintptr(unsafe.Pointer(unsafe.StringData(message))), // #nosec G103 -- The message is pinned for the duration of the call.
+		uintptr(len(message)),
+		uintptr(unsafe.Pointer(&dst.options)), // #nosec G103 -- dst is pinned for the duration of the call.
+		uintptr(unsafe.Pointer(&dst.native)),  // #nosec G103 -- dst is pinned for the duration of the call.
+	}
+	runtime.LockOSThread()
+	r1, _, _ := purego.SyscallN(t.encodeInto, dst.args[:]...)
+	rc := int32(r1) // #nosec G115 -- encode_into returns a C int32 in the low bits of the register.
+	if rc < SUCCESS {
+		err = t.nativeError("encode_into", rc)
+	}
+	runtime.UnlockOSThread()
+
+	dst.Len = int(dst.native.Len)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode message into buffers")
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package tokenizers
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeIntoBuffersLayout(t *testing.T) {
+	if unsafe.Sizeof(uintptr(0)) != 8 {
+		t.Skip("Layout offsets are asserted for 64-bit platforms")
+	}
+	// Must match the #[repr(C)] EncodeIntoBuffers struct in src/lib.rs
+	var b EncodeIntoBuffers
+	require.Equal(t, uintptr(24), unsafe.Offsetof(b.IDsInt64))
+	require.Equal(t, uintptr(48), unsafe.Offsetof(b.Capacity))
+	require.Equal(t, uintptr(56), unsafe.Offsetof(b.Len))
+}
+
+func TestEncodeBuffersCapacity(t *testing.T) {
+	_, err := (&EncodeBuffers{}).capacity()
+	require.Error(t, err)
+
+	capacity, err := NewEncodeBuffers(16).capacity()
+	require.NoError(t, err)
+	require.Equal(t, 16, capacity)
+
+	mixed := &EncodeBuffers{IDs: make([]uint32, 32), AttentionMaskInt64: make([]int64, 8)}
+	capacity, err = mixed.capacity()
+	require.NoError(t, err)
+	require.Equal(t, 8, capacity, "The shortest slice bounds the capacity")
+}
+
+func TestEncodeIntoValidation(t *testing.T) {
+	tok := &Tokenizer{}
+	require.Error(t, tok.EncodeInto("Hello", nil))
+	require.Error(t, tok.EncodeInto("Hello", &EncodeBuffers{}))
+
+	closed := &Tokenizer{closed: true}
+	require.ErrorIs(t, closed.EncodeInto("Hello", NewEncodeBuffers(8)), ErrTokenizerClosed)
+}
+
+func TestEncodeInto(t *testing.T) {
+	libpath := checkLibraryExists(t)
+	tok, err := FromFile("./tokenizer.json", WithLibraryPath(libpath))
+	require.NoError(t, err, "Failed to load tokenizer from file")
+	t.Cleanup(func() {
+		_ = tok.Close()
+	})
+
+	expected, err := tok.Encode("Hello, world!", WithReturnAttentionMask(), WithReturnTypeIDs())
+	require.NoError(t, err)
+
+	t.Run("uint32", func(t *testing.T) {
+		dst := NewEncodeBuffers(256)
+		require.NoError(t, tok.EncodeInto("Hello, world!", dst))
+		require.Equal(t, len(expected.IDs), dst.Len)
+		require.Equal(t, expected.IDs, dst.IDs[:dst.Len])
+		require.Equal(t, expected.AttentionMask, dst.AttentionMask[:dst.Len])
+		require.Equal(t, expected.TypeIDs, dst.TypeIDs[:dst.Len])
+	})
+
+	t.Run("int64", func(t *testing.T) {
+		dst := NewEncodeBuffersInt64(256)
+		require.NoError(t, tok.EncodeInto("Hello, world!", dst))
+		require.Equal(t, len(expected.IDs), dst.Len)
+		for i, id := range expected.IDs {
+			require.Equal(t, int64(id), dst.IDsInt64[i])
+			require.Equal(t, int64(expected.AttentionMask[i]), dst.AttentionMaskInt64[i])
+		}
+	})
+
+	t.Run("Reused buffers", func(t *testing.T) {
+		if raceEnabled {
+			t.Skip("The race detector allocates on its own")
+		}
+		dst := NewEncodeBuffersInt64(256)
+		require.NoError(t, tok.EncodeInto("Hello, world!", dst), "Warm up")
+		allocs := testing.AllocsPerRun(100, func() {
+			if err := tok.EncodeInto("Hello, world!", dst); err != nil {
+				t.Fatal(err)
+			}
+		})
+		// purego allocates the argument block of every native call; nothing else may.
+		require.LessOrEqual(t, allocs, 1.0)
+	})
+
+	t.Run("Buffer too small", func(t *testing.T) {
+		dst := NewEncodeBuffers(4)
+		err := tok.EncodeInto("Hello, world!", dst)
+		require.ErrorIs(t, err, ErrBufferTooSmall)
+		require.Equal(t, len(expected.IDs), dst.Len, "Len should report the required size")
+	})
+}
@@ -0,0 +1,107 @@
+package tokenizers
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// ErrorCode is an error code returned by the native library.
+type ErrorCode int32
+
+// Error returns a short description of the code.
+func (c ErrorCode) Error() string {
+	switch c {
+	case ErrInvalidUTF8:
+		return "invalid UTF-8 in input message"
+	case ErrEncodingFailed:
+		return "tokenization failed"
+	case ErrNullOutput:
+		return "internal error: null output buffer"
+	case ErrInvalidTokenizerRef:
+		return "invalid tokenizer reference"
+	case ErrNullInput:
+		return "null input provided"
+	case ErrTokenizerCreationFailed:
+		return "failed to create tokenizer instance"
+	case ErrInvalidPath:
+		return "invalid file path provided"
+	case ErrFileNotFound:
+		return "file not found at specified path"
+	case ErrTruncationFailed:
+		return "truncation failed"
+	case ErrPaddingFailed:
+		return "padding failed"
+	case ErrDecodeFailed:
+		return "decoding failed"
+	case ErrCStringConversionFailed:
+		return "C string conversion failed"
+	case ErrInvalidIDs:
+		return "invalid IDs provided for decoding"
+	case ErrInvalidOptions:
+		return "invalid options provided for encoding/decoding"
+	case ErrBufferTooSmall:
+		return "destination buffer is too small"
+	case ErrSerializationFailed:
+		return "tokenizer serialization failed"
+	case ErrTrainingFailed:
+		return "tokenizer training failed"
+	default:
+		return fmt.Sprintf("unknown error code: %d", int32(c))
+	}
+}
+
+// TokenizerError is returned when a call into the native library fails.
+// It matches its Code with errors.Is, e.g. errors.Is(err, ErrInvalidUTF8).
+type TokenizerError struct {
+	Code    ErrorCode
+	Op      string // Native operation that failed, e.g. "from_bytes"
+	Message string // Detail reported by the library, e.g. why tokenizer.json failed to parse
+}
+
+func (e *TokenizerError) Error() string {
+	msg := e.Op + ": " + e.Code.Error()
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// Unwrap returns the error code so that errors.Is and errors.As match against it.
+func (e *TokenizerError) Unwrap() error {
+	return e.Code
+}
+
+// callNative runs call, a single FFI call returning an error code, pinned to the
+// current OS thread so that the thread-local detail of a failure can be read back.
+// A negative code is returned as a *TokenizerError for op.
+func (t *Tokenizer) callNative(op string, call func() int32) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	rc := call()
+	if rc >= SUCCESS {
+		return nil
+	}
+	return t.nativeError(op, rc)
+}
+
+// nativeError builds the error for a failed FFI call. It must run on the same OS
+// thread as the call.
+func (t *Tokenizer) nativeError(op string, rc int32) *TokenizerError {
+	tokErr := &TokenizerError{Code: ErrorCode(rc), Op: op}
+	if t.takeLastErrorMessage != nil {
+		if p := t.takeLastErrorMessage(); p != nil {
+			tokErr.Message = goStringFromPtr(p)
+			t.freeString(p)
+		}
+	}
+	if tokErr.Message == "" && t.getErrorMessage != nil && isUnknownErrorCode(tokErr.Code) {
+		// The library is newer than this package; let it describe the code.
+		tokErr.Message = t.getErrorMessage(rc)
+	}
+	return tokErr
+}
+
+func isUnknownErrorCode(c ErrorCode) bool {
+	return c < ErrTrainingFailed || c > ErrInvalidUTF8
+}
@@ -0,0 +1,64 @@
+package tokenizers
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorCode(t *testing.T) {
+	require.Equal(t, "invalid UTF-8 in input message", ErrInvalidUTF8.Error())
+	require.Equal(t, "unknown error code: -99", ErrorCode(-99).Error())
+	require.True(t, isUnknownErrorCode(-99))
+	require.False(t, isUnknownErrorCode(ErrDecodeFailed))
+	require.False(t, isUnknownErrorCode(ErrTrainingFailed))
+}
+
+func TestTokenizerError(t *testing.T) {
+	var err error = &TokenizerError{
+		Code:    ErrTokenizerCreationFailed,
+		Op:      "from_bytes",
+		Message: "expected value at line 1 column 1",
+	}
+	wrapped := errors.Wrap(err, "failed to create tokenizer from bytes")
+
+	require.ErrorIs(t, wrapped, ErrTokenizerCreationFailed)
+	require.NotErrorIs(t, wrapped, ErrInvalidUTF8)
+	require.Equal(t, "from_bytes: failed to create tokenizer instance: expected value at line 1 column 1", err.Error())
+
+	var tokErr *TokenizerError
+	require.ErrorAs(t, wrapped, &tokErr)
+	require.Equal(t, "from_bytes", tokErr.Op)
+
+	var code ErrorCode
+	require.ErrorAs(t, wrapped, &code)
+	require.Equal(t, ErrTokenizerCreationFailed, code)
+}
+
+func TestCallNativeWithoutErrorBindings(t *testing.T) {
+	tok := &Tokenizer{}
+	require.NoError(t, tok.callNative("encode", func() int32 { return SUCCESS }))
+
+	err := tok.callNative("encode", func() int32 { return int32(ErrEncodingFailed) })
+	require.ErrorIs(t, err, ErrEncodingFailed)
+	require.Equal(t, "encode: tokenization failed", err.Error())
+}
+
+func TestTokenizerErrorDetailFromLibrary(t *testing.T) {
+	libpath := checkLibraryExists(t)
+
+	_, err := FromBytes([]byte(`{"version": "1.0", "model": 42}`), WithLibraryPath(libpath))
+	require.Error(t, err)
+	require.ErrorIs(t, err, ErrTokenizerCreationFailed)
+
+	var tokErr *TokenizerError
+	require.ErrorAs(t, err, &tokErr)
+	require.Equal(t, "from_bytes", tokErr.Op)
+	require.NotEmpty(t, tokErr.Message, "The parse error from the library should be surfaced")
+
+	_, err = FromBytes(nil, WithLibraryPath(libpath))
+	require.ErrorIs(t, err, ErrNullInput)
+	require.ErrorAs(t, err, &tokErr)
+	require.Equal(t, "input is null", tokErr.Message)
+}
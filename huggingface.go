@@ -35,6 +35,14 @@ const (
 	// to prevent excessive waits from misconfigured or malicious servers
 	HFMaxRetryAfterDelay = 5 * time.Minute
 
+	// Files fetched along with tokenizer.json for the chat template and special tokens
+	hfTokenizerConfigFile  = "tokenizer_config.json"
+	hfSpecialTokensMapFile = "special_tokens_map.json"
+	hfChatTemplateFile     = "chat_template.jinja"
+	// hfNotFoundDir holds an empty file for each of those files that a model does not have,
+	// as huggingface_hub records them in .no_exist
+	hfNotFoundDir = ".no_exist"
+
 	// DefaultMaxTokenizerSize is the default maximum size for tokenizer files (500MB)
 	// This prevents OOM errors from excessively large downloads
 	DefaultMaxTokenizerSize = 500 * 1024 * 1024
@@ -51,7 +59,7 @@ const (
 )
 
 var (
-	libraryVersion = "0.1.0" // Default version, will be set from library if available
+	libraryVersion = "0.2.0" // Default version, will be set from library if available
 
 	// Shared HTTP client for HuggingFace downloads with connection pooling
 	hfHTTPClient *http.Client
@@ -60,6 +68,10 @@ var (
 
 	// ErrCacheNotFound is returned when a requested cache file does not exist
 	ErrCacheNotFound = errors.New("cache file not found")
+
+	// ErrHFFileNotFound is returned when a model or one of its files does not exist on
+	// the hub
+	ErrHFFileNotFound = errors.New("not found")
 )
 
 // GetLibraryVersion returns the current library version used in User-Agent
@@ -253,6 +265,9 @@ type HFConfig struct {
 	UseLocalCache bool
 	// CacheTTL specifies how long cached tokenizers are considered valid (0 = forever)
 	CacheTTL time.Duration
+	// ApplyTokenizerConfig configures truncation, padding and special tokens from
+	// tokenizer_config.json and special_tokens_map.json, as transformers does
+	ApplyTokenizerConfig bool
 	// MaxTokenizerSize is the maximum allowed size for tokenizer files in bytes
 	// (env: HF_MAX_TOKENIZER_SIZE, default: 500MB).
 	// When set to 0 (zero value), falls back to HF_MAX_TOKENIZER_SIZE environment variable,
@@ -358,6 +373,12 @@ func resolveHFBaseURL(config *HFConfig) (string, error) {
 //	}
 //	defer tokenizer.Close()
 func FromHuggingFace(modelID string, opts ...TokenizerOption) (*Tokenizer, error) {
+	return FromHuggingFaceContext(context.Background(), modelID, opts...)
+}
+
+// FromHuggingFaceContext is like FromHuggingFace but uses ctx for the download,
+// including the delays between retries. Cancelling ctx aborts an in-flight download.
+func FromHuggingFaceContext(ctx context.Context, modelID string, opts ...TokenizerOption) (*Tokenizer, error) {
 	if modelID == "" {
 		return nil, errors.New("model ID cannot be empty")
 	}
@@ -367,6 +388,80 @@ func FromHuggingFace(modelID string, opts ...TokenizerOption) (*Tokenizer, error
 		return nil, errors.Wrapf(err, "invalid model ID: %s", modelID)
 	}
 
+	hfConfig, err := newHFConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	data, cachedPath, commit, err := loadHFTokenizerJSON(ctx, modelID, hfConfig)
+	if err != nil {
+		return nil, err
+	}
+	return newHFTokenizer(ctx, data, modelID, pinHFConfig(hfConfig, commit), cachedPath, opts)
+}
+
+// loadHFTokenizerJSON returns the tokenizer.json of a model, its cache path and the commit
+// it was taken from, or an empty string if the commit is not known. If a cached branch or
+// tag has moved to a new commit but the new tokenizer cannot be downloaded, the cached one
+// is returned with a warning.
+func loadHFTokenizerJSON(ctx context.Context, modelID string, config *HFConfig) ([]byte, string, string, error) {
+	migrateHFCacheRevision(modelID, config)
+
+	// Try cache lookup hierarchy:
+	// 1. Pure-tokenizers cache, unless a branch or tag has moved to a new commit
+	cachedPath := getHFCachePath(config.CacheDir, modelID, config.Revision)
+	cached, err := loadFromCacheWithValidation(cachedPath, config.CacheTTL)
+	stale := err == nil && isHFCacheStale(ctx, modelID, "tokenizer.json", config)
+	if err == nil && !stale {
+		return cached, cachedPath, cachedHFCommit(modelID, config), nil
+	}
+
+	// 2. HuggingFace hub cache (if enabled)
+	if config.UseLocalCache && !stale {
+		if data, commit, err := loadFromHFHubCache(modelID, config.Revision, "tokenizer.json"); err == nil {
+			// Save to our cache for faster future access
+			path, err := saveHFCacheFile(modelID, "tokenizer.json", data, commit, config)
+			if err != nil {
+				log.Printf("[WARNING] Failed to save HuggingFace tokenizer cache at %s: %v", path, err)
+			}
+			return data, path, commit, nil
+		}
+	}
+
+	// 3. Offline mode check
+	if config.OfflineMode {
+		return nil, "", "", errors.New("offline mode enabled but tokenizer not found in any cache")
+	}
+
+	// Download tokenizer.json from HuggingFace, falling back to building it from the
+	// legacy tokenizer files of the model
+	data, commit, err := downloadHFFile(ctx, modelID, "tokenizer.json", config)
+	if errors.Is(err, ErrHFFileNotFound) {
+		// Keep the tokenizer.json error if the model has no legacy files either
+		if legacyData, legacyErr := downloadLegacyTokenizerFromHF(ctx, modelID, config); !errors.Is(legacyErr, ErrHFFileNotFound) {
+			// The legacy files recorded the commit they were downloaded from.
+			data, commit, err = legacyData, cachedHFCommit(modelID, config), legacyErr
+		}
+	}
+	if err != nil {
+		if stale {
+			log.Printf("[WARNING] Failed to download the new commit of %s revision %s, using the cached tokenizer: %v", modelID, config.Revision, err)
+			return cached, cachedPath, cachedHFCommit(modelID, config), nil
+		}
+		return nil, "", "", errors.Wrapf(err, "failed to download tokenizer from HuggingFace")
+	}
+
+	// Save to cache
+	path, err := saveHFCacheFile(modelID, "tokenizer.json", data, commit, config)
+	if err != nil {
+		log.Printf("[WARNING] Failed to save HuggingFace tokenizer cache at %s: %v", path, err)
+	}
+	return data, path, commit, nil
+}
+
+// newHFConfig returns the HuggingFace configuration set by opts, with defaults and
+// environment settings applied.
+func newHFConfig(opts []TokenizerOption) (*HFConfig, error) {
 	// Create tokenizer with HF config
 	tokenizer := &Tokenizer{
 		defaultEncodingOpts: EncodeOptions{
@@ -402,43 +497,118 @@ func FromHuggingFace(modelID string, opts ...TokenizerOption) (*Tokenizer, error
 	if !tokenizer.hfConfig.UseLocalCache && os.Getenv("HF_USE_LOCAL_CACHE") != "false" {
 		tokenizer.hfConfig.UseLocalCache = true
 	}
+	return tokenizer.hfConfig, nil
+}
 
-	// Try cache lookup hierarchy:
-	// 1. Pure-tokenizers cache
-	cachedPath := getHFCachePath(tokenizer.hfConfig.CacheDir, modelID, tokenizer.hfConfig.Revision)
-	if data, err := loadFromCacheWithValidation(cachedPath, tokenizer.hfConfig.CacheTTL); err == nil {
-		return FromBytes(data, opts...)
+// newHFTokenizer creates the tokenizer of a model from its tokenizer.json and attaches
+// the tokenizer_config.json and special_tokens_map.json of the model, unless they were
+// given as options. config should be pinned to the commit of tokenizer.json, so that the
+// files come from the same commit. Files that are cached next to tokenizer.json, or recorded as missing
+// there, are not requested from the hub. Missing or unavailable files do not fail the load.
+func newHFTokenizer(ctx context.Context, data []byte, modelID string, config *HFConfig, cachedPath string, opts []TokenizerOption) (*Tokenizer, error) {
+	tokenizer, err := FromBytes(data, opts...)
+	if err != nil {
+		return nil, err
 	}
-
-	// 2. HuggingFace hub cache (if enabled)
-	if tokenizer.hfConfig.UseLocalCache {
-		if data, err := checkHFHubCache(modelID, tokenizer.hfConfig.Revision); err == nil {
-			// Save to our cache for faster future access
-			if cacheErr := saveToHFCache(cachedPath, data); cacheErr != nil {
-				log.Printf("[WARNING] Failed to save HuggingFace tokenizer cache at %s: %v", cachedPath, cacheErr)
-			}
-			return FromBytes(data, opts...)
+	if tokenizer.tokenizerConfig == nil || tokenizer.specialTokensMap == nil {
+		tokenizerConfig, specialTokensMap, err := loadHFTokenizerConfig(ctx, modelID, config, filepath.Dir(cachedPath))
+		if err != nil {
+			log.Printf("[WARNING] Failed to load tokenizer config of %s: %v", modelID, err)
+		}
+		if tokenizer.tokenizerConfig == nil {
+			tokenizer.tokenizerConfig = tokenizerConfig
+		}
+		if tokenizer.specialTokensMap == nil {
+			tokenizer.specialTokensMap = specialTokensMap
 		}
 	}
+	if config.ApplyTokenizerConfig {
+		if err := tokenizer.applyTokenizerConfig(); err != nil {
+			_ = tokenizer.Close()
+			return nil, errors.Wrap(err, "failed to apply tokenizer config")
+		}
+	}
+	return tokenizer, nil
+}
 
-	// 3. Offline mode check
-	if tokenizer.hfConfig.OfflineMode {
-		return nil, errors.New("offline mode enabled but tokenizer not found in any cache")
+// loadHFTokenizerConfig loads the tokenizer_config.json and special_tokens_map.json of a
+// model from cacheDir, or downloads and caches them. Models that keep their chat template
+// in chat_template.jinja get it from there. Files the model does not have are nil.
+func loadHFTokenizerConfig(ctx context.Context, modelID string, config *HFConfig, cacheDir string) (*hfTokenizerConfig, *SpecialTokens, error) {
+	data, err := loadHFMetadataFile(ctx, modelID, config, cacheDir, hfTokenizerConfigFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var tokenizerConfig *hfTokenizerConfig
+	if data != nil {
+		if tokenizerConfig, err = parseHFTokenizerConfig(data); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	// Download tokenizer.json from HuggingFace
-	data, err := downloadTokenizerFromHF(modelID, tokenizer.hfConfig)
+	var specialTokensMap *SpecialTokens
+	data, err = loadHFMetadataFile(ctx, modelID, config, cacheDir, hfSpecialTokensMapFile)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to download tokenizer from HuggingFace")
+		return tokenizerConfig, nil, err
+	}
+	if data != nil {
+		tokens, err := parseHFSpecialTokensMap(data)
+		if err != nil {
+			return tokenizerConfig, nil, err
+		}
+		specialTokensMap = &tokens
 	}
 
-	// Save to cache
-	if err := saveToHFCache(cachedPath, data); err != nil {
-		log.Printf("[WARNING] Failed to save HuggingFace tokenizer cache at %s: %v", cachedPath, err)
+	if tokenizerConfig == nil || tokenizerConfig.ChatTemplate != "" {
+		return tokenizerConfig, specialTokensMap, nil
+	}
+	data, err = loadHFMetadataFile(ctx, modelID, config, cacheDir, hfChatTemplateFile)
+	if err != nil {
+		return tokenizerConfig, specialTokensMap, err
 	}
+	tokenizerConfig.ChatTemplate = string(data)
+	return tokenizerConfig, specialTokensMap, nil
+}
 
-	// Create tokenizer from downloaded data
-	return FromBytes(data, opts...)
+// loadHFMetadataFile returns filename of a model from cacheDir, from the HuggingFace hub
+// cache or, unless the model is offline, downloads and caches it. Files the model does not
+// have are recorded in cacheDir, so that they are not requested again. It returns nil if
+// the file is not available.
+func loadHFMetadataFile(ctx context.Context, modelID string, config *HFConfig, cacheDir, filename string) ([]byte, error) {
+	path := filepath.Join(cacheDir, filename)
+	if data, err := loadFromCacheWithValidation(path, config.CacheTTL); err == nil {
+		return data, nil
+	}
+	notFoundPath := filepath.Join(cacheDir, hfNotFoundDir, filename)
+	if info, err := os.Stat(notFoundPath); err == nil && (config.CacheTTL <= 0 || time.Since(info.ModTime()) <= config.CacheTTL) {
+		return nil, nil
+	}
+	if config.UseLocalCache {
+		if data, _, err := loadFromHFHubCache(modelID, config.Revision, filename); err == nil {
+			if err := saveToHFCache(path, data); err != nil {
+				log.Printf("[WARNING] Failed to save HuggingFace %s cache at %s: %v", filename, path, err)
+			}
+			return data, nil
+		}
+	}
+	if config.OfflineMode {
+		return nil, nil
+	}
+	data, err := downloadHFFileContext(ctx, modelID, filename, config)
+	if errors.Is(err, ErrHFFileNotFound) {
+		if err := saveToHFCache(notFoundPath, nil); err != nil {
+			log.Printf("[WARNING] Failed to record missing HuggingFace %s at %s: %v", filename, notFoundPath, err)
+		}
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := saveToHFCache(path, data); err != nil {
+		log.Printf("[WARNING] Failed to save HuggingFace %s cache at %s: %v", filename, path, err)
+	}
+	return data, nil
 }
 
 // WithHFToken sets the HuggingFace API token for authentication
@@ -515,6 +685,21 @@ func WithHFOfflineMode(offline bool) TokenizerOption {
 	}
 }
 
+// WithHFApplyTokenizerConfig configures the tokenizer from the tokenizer_config.json and
+// special_tokens_map.json of the model, as transformers does: inputs are truncated to
+// model_max_length on truncation_side, batches are padded with the pad token on
+// padding_side, and Encode adds special tokens unless add_bos_token and add_eos_token
+// are both false. Truncation and padding set by options take precedence.
+func WithHFApplyTokenizerConfig() TokenizerOption {
+	return func(t *Tokenizer) error {
+		if t.hfConfig == nil {
+			t.hfConfig = &HFConfig{}
+		}
+		t.hfConfig.ApplyTokenizerConfig = true
+		return nil
+	}
+}
+
 // WithHFMaxTokenizerSize sets the maximum allowed size for tokenizer files in bytes
 // Default is 500MB. Set to a very large value to effectively disable size validation.
 func WithHFMaxTokenizerSize(maxSize int64) TokenizerOption {
@@ -532,18 +717,50 @@ func WithHFMaxTokenizerSize(maxSize int64) TokenizerOption {
 
 // downloadTokenizerFromHF downloads the tokenizer.json file from HuggingFace Hub
 func downloadTokenizerFromHF(modelID string, config *HFConfig) ([]byte, error) {
+	return downloadTokenizerFromHFContext(context.Background(), modelID, config)
+}
+
+// downloadTokenizerFromHFContext downloads the tokenizer.json file from HuggingFace Hub,
+// giving up as soon as ctx is done.
+func downloadTokenizerFromHFContext(ctx context.Context, modelID string, config *HFConfig) ([]byte, error) {
+	return downloadHFFileContext(ctx, modelID, "tokenizer.json", config)
+}
+
+// downloadHFFileContext downloads a file of the model repository from HuggingFace Hub,
+// retrying transient failures until ctx is done.
+func downloadHFFileContext(ctx context.Context, modelID, filename string, config *HFConfig) ([]byte, error) {
+	data, _, err := downloadHFFile(ctx, modelID, filename, config)
+	return data, err
+}
+
+// hfFileURL returns the URL that resolves a file of the model repository at the
+// configured revision.
+func hfFileURL(modelID, filename string, config *HFConfig) (string, error) {
 	baseURL, err := resolveHFBaseURL(config)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	revision := strings.TrimSpace(config.Revision)
 	if revision == "" {
 		revision = HFDefaultRevision
 	}
 	if err := validateHFRevision(revision); err != nil {
-		return nil, errors.Wrap(err, "invalid HuggingFace revision")
+		return "", errors.Wrap(err, "invalid HuggingFace revision")
+	}
+	parts := strings.Split(filename, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return fmt.Sprintf("%s/%s/resolve/%s/%s", baseURL, modelID, revision, strings.Join(parts, "/")), nil
+}
+
+// downloadHFFile is like downloadHFFileContext, but also returns the commit the revision
+// resolved to, or an empty string if the hub did not report it.
+func downloadHFFile(ctx context.Context, modelID, filename string, config *HFConfig) ([]byte, string, error) {
+	fileURL, err := hfFileURL(modelID, filename, config)
+	if err != nil {
+		return nil, "", err
 	}
-	url := fmt.Sprintf("%s/%s/resolve/%s/tokenizer.json", baseURL, modelID, revision)
 
 	var lastErr error
 	var retryAfterDuration time.Duration
@@ -572,16 +789,29 @@ func downloadTokenizerFromHF(modelID string, config *HFConfig) ([]byte, error) {
 				}
 			}
 
-			time.Sleep(delay)
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, "", errors.Wrapf(ctx.Err(), "download canceled (last error: %v)", lastErr)
+			case <-timer.C:
+			}
 		}
 
-		data, resp, err := downloadWithRetryAndResponse(url, config)
+		data, resp, err := downloadWithRetryAndResponse(ctx, fileURL, filename, config)
 		if err == nil {
-			return data, nil
+			commit := hfResponseCommit(resp)
+			recordHFETag(modelID, filename, commit, hfResponseETag(resp), config)
+			return data, commit, nil
 		}
 
 		lastErr = err
 
+		// Don't retry once the caller has given up
+		if ctx.Err() != nil {
+			break
+		}
+
 		// Parse Retry-After header if present
 		if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
 			if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
@@ -608,15 +838,15 @@ func downloadTokenizerFromHF(modelID string, config *HFConfig) ([]byte, error) {
 		}
 	}
 
-	return nil, lastErr
+	return nil, "", lastErr
 }
 
 // downloadWithRetryAndResponse performs a single download attempt and returns the response.
 // Unlike a simple download function, this returns the HTTP response alongside the data
 // to allow the caller to inspect response headers (e.g., Retry-After header for rate limiting).
-func downloadWithRetryAndResponse(url string, config *HFConfig) ([]byte, *http.Response, error) {
+func downloadWithRetryAndResponse(ctx context.Context, url, filename string, config *HFConfig) ([]byte, *http.Response, error) {
 	// Create a context with timeout for this specific request
-	ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
@@ -649,7 +879,7 @@ func downloadWithRetryAndResponse(url string, config *HFConfig) ([]byte, *http.R
 	case http.StatusForbidden:
 		return nil, resp, errors.New("access forbidden: token may be invalid or model may be gated")
 	case http.StatusNotFound:
-		return nil, resp, errors.New("model or tokenizer.json not found")
+		return nil, resp, fmt.Errorf("model or %s %w", filename, ErrHFFileNotFound)
 	case http.StatusTooManyRequests:
 		// Return with response so caller can parse Retry-After
 		return nil, resp, errors.New("rate limited: too many requests")
@@ -682,14 +912,32 @@ func downloadWithRetryAndResponse(url string, config *HFConfig) ([]byte, *http.R
 	}
 
 	// Validate it's valid JSON
-	var validateJSON map[string]interface{}
-	if err := json.Unmarshal(data, &validateJSON); err != nil {
-		return nil, resp, errors.Wrap(err, "invalid tokenizer.json format")
+	if strings.HasSuffix(filename, ".json") {
+		if !json.Valid(data) {
+			return nil, resp, errors.Errorf("invalid %s format", filename)
+		}
 	}
 
 	return data, resp, nil
 }
 
+// validateHFFilename checks that filename is a relative path within a repository, such
+// as "tokenizer.json" or "1_Pooling/config.json", that cannot escape the cache directory.
+func validateHFFilename(filename string) error {
+	if filename == "" {
+		return errors.New("filename cannot be empty")
+	}
+	if strings.ContainsAny(filename, "\\\x00") {
+		return errors.Errorf("filename contains invalid characters: %q", filename)
+	}
+	for _, part := range strings.Split(filename, "/") {
+		if part == "" || part == "." || part == ".." {
+			return errors.Errorf("filename must be a relative path without '.' or '..' components: %q", filename)
+		}
+	}
+	return nil
+}
+
 // validateModelID checks if the model ID is valid
 func validateModelID(modelID string) error {
 	// Empty model ID is handled separately in FromHuggingFace
@@ -796,6 +1044,29 @@ func isValidRepoName(name string) bool {
 
 // getHFCachePath returns the cache path for a HuggingFace tokenizer
 func getHFCachePath(customCacheDir, modelID, revision string) string {
+	return getHFCacheFilePath(customCacheDir, modelID, revision, "tokenizer.json")
+}
+
+// getHFCacheFilePath returns the cache path for a file of a HuggingFace model revision.
+// filename is a path within the repository that passed validateHFFilename. Files are kept
+// in the snapshot of the commit that refs/<revision> points to, as in the HuggingFace hub
+// cache. A revision without a ref, such as a commit hash, names its snapshot itself.
+func getHFCacheFilePath(customCacheDir, modelID, revision, filename string) string {
+	modelDir := getHFModelCacheDir(customCacheDir, modelID)
+	safeRevision := strings.TrimSpace(revision)
+	if safeRevision == "" || validateHFRevision(safeRevision) != nil {
+		safeRevision = HFDefaultRevision
+	}
+	snapshot := safeRevision
+	if commit, ok := readHFCacheRef(modelDir, safeRevision); ok {
+		snapshot = commit
+	}
+
+	return filepath.Join(modelDir, "snapshots", filepath.FromSlash(snapshot), filepath.FromSlash(filename))
+}
+
+// getHFModelCacheDir returns the cache directory of a HuggingFace model.
+func getHFModelCacheDir(customCacheDir, modelID string) string {
 	var cacheDir string
 	if customCacheDir != "" {
 		cacheDir = customCacheDir
@@ -805,12 +1076,7 @@ func getHFCachePath(customCacheDir, modelID, revision string) string {
 
 	// Sanitize model ID for filesystem
 	sanitizedModelID := strings.ReplaceAll(modelID, "/", "--")
-	safeRevision := strings.TrimSpace(revision)
-	if safeRevision == "" || validateHFRevision(safeRevision) != nil {
-		safeRevision = HFDefaultRevision
-	}
-
-	return filepath.Join(cacheDir, "models", sanitizedModelID, safeRevision, "tokenizer.json")
+	return filepath.Join(cacheDir, "models", sanitizedModelID)
 }
 
 // getHFCacheDir returns the default HuggingFace cache directory
@@ -1059,80 +1325,131 @@ func secureJitter(baseDelay time.Duration) time.Duration {
 
 // checkHFHubCache checks if tokenizer exists in the standard HuggingFace hub cache
 func checkHFHubCache(modelID, revision string) ([]byte, error) {
-	if revision != "" {
-		if err := validateHFRevision(revision); err != nil {
-			return nil, errors.Wrap(err, "invalid HuggingFace revision")
-		}
+	data, _, err := loadFromHFHubCache(modelID, revision, "tokenizer.json")
+	return data, err
+}
+
+// loadFromHFHubCache reads a file of a model revision from the HuggingFace hub cache and
+// returns it with the commit of the snapshot it came from. Branches and tags are resolved
+// through refs/<revision>, and full or abbreviated commit hashes name their snapshot
+// directly. Files that are symlinks to blobs, as huggingface_hub stores them, are
+// resolved within the cache of the model.
+func loadFromHFHubCache(modelID, revision, filename string) ([]byte, string, error) {
+	revision = strings.TrimSpace(revision)
+	if revision == "" {
+		revision = HFDefaultRevision
+	}
+	if err := validateHFRevision(revision); err != nil {
+		return nil, "", errors.Wrap(err, "invalid HuggingFace revision")
+	}
+	if err := validateHFFilename(filename); err != nil {
+		return nil, "", errors.Wrap(err, "invalid filename")
 	}
 
 	// Get HuggingFace hub cache directory
 	hubCacheDir := getHFHubCacheDir()
 	if hubCacheDir == "" {
-		return nil, errors.New("HuggingFace hub cache directory not found")
+		return nil, "", errors.New("HuggingFace hub cache directory not found")
 	}
 
 	// Convert model ID to cache format
 	// HF uses "models--owner--name" format
-	sanitizedModelID := "models--" + strings.ReplaceAll(modelID, "/", "--")
-
-	// Check for snapshot directory
-	snapshotDir := filepath.Join(hubCacheDir, sanitizedModelID, "snapshots")
-	if _, err := os.Stat(snapshotDir); os.IsNotExist(err) {
-		return nil, errors.Errorf("model not found in HF hub cache: %s", modelID)
+	repoDir := filepath.Join(hubCacheDir, "models--"+strings.ReplaceAll(modelID, "/", "--"))
+	snapshotsDir := filepath.Join(repoDir, "snapshots")
+	if _, err := os.Stat(snapshotsDir); err != nil {
+		return nil, "", errors.Errorf("model not found in HF hub cache: %s", modelID)
 	}
 
-	// Look for the specific revision or find the latest
-	var tokenizerPath string
+	commit, err := resolveHFHubRevision(repoDir, revision)
+	if err != nil {
+		return nil, "", err
+	}
 
-	// First try to find by revision hash
-	entries, err := os.ReadDir(snapshotDir)
+	path, err := resolveHFHubFile(repoDir, filepath.Join(snapshotsDir, commit, filepath.FromSlash(filename)))
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "%s not found in HF hub cache for %s at %s", filename, modelID, revision)
+	}
+	data, err := os.ReadFile(path) // #nosec G304 -- path is resolved within the cache directory of the model.
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to read snapshots directory")
+		return nil, "", errors.Wrapf(err, "failed to read %s from HF hub cache", filename)
 	}
 
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
-		// Check if this snapshot has tokenizer.json
-		candidatePath := filepath.Join(snapshotDir, entry.Name(), "tokenizer.json")
-		if fileExists(candidatePath) {
-			// If we're looking for a specific revision, check refs
-			if revision != "main" && revision != "" {
-				// Check if this snapshot matches the revision
-				refPath := filepath.Join(hubCacheDir, sanitizedModelID, "refs", revision)
-				// #nosec G304 -- refPath is built from a validated revision under the HF cache root.
-				if refData, err := os.ReadFile(refPath); err == nil {
-					refHash := strings.TrimSpace(string(refData))
-					if entry.Name() == refHash {
-						tokenizerPath = candidatePath
-						break
-					}
-				}
-			} else {
-				// For main/default, use the most recent snapshot
-				tokenizerPath = candidatePath
-			}
+	// Validate it's valid JSON
+	if filepath.Ext(filename) == ".json" {
+		if !json.Valid(data) {
+			return nil, "", errors.Errorf("invalid %s format in HF hub cache", filename)
 		}
 	}
 
-	if tokenizerPath == "" {
-		return nil, errors.Errorf("tokenizer.json not found in HF hub cache for %s", modelID)
+	return data, commit, nil
+}
+
+// resolveHFHubRevision returns the snapshot of a revision in the hub cache directory of a
+// model: the commit refs/<revision> points to, or the snapshot of a full or abbreviated
+// commit hash.
+func resolveHFHubRevision(repoDir, revision string) (string, error) {
+	// #nosec G304 -- the ref path is built from a validated revision under the HF cache root.
+	if refData, err := os.ReadFile(filepath.Join(repoDir, "refs", filepath.FromSlash(revision))); err == nil {
+		commit := strings.TrimSpace(string(refData))
+		if commit == "." || commit == ".." || !isValidRepoName(commit) {
+			return "", errors.Errorf("invalid ref for revision %s in HF hub cache", revision)
+		}
+		return commit, nil
 	}
 
-	// Read and validate the tokenizer
-	data, err := os.ReadFile(tokenizerPath) // #nosec G304 -- tokenizerPath is discovered from entries under the HF cache snapshots directory.
+	if !isHFCommitPrefix(revision) {
+		return "", errors.Errorf("revision %s not found in HF hub cache", revision)
+	}
+	entries, err := os.ReadDir(filepath.Join(repoDir, "snapshots"))
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to read tokenizer from HF hub cache")
+		return "", errors.Wrap(err, "failed to read snapshots directory")
+	}
+	var matches []string
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), revision) {
+			matches = append(matches, entry.Name())
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", errors.Errorf("revision %s not found in HF hub cache", revision)
+	case 1:
+		return matches[0], nil
+	default:
+		return "", errors.Errorf("revision %s is ambiguous in HF hub cache", revision)
 	}
+}
 
-	// Validate it's valid JSON
-	var validateJSON map[string]interface{}
-	if err := json.Unmarshal(data, &validateJSON); err != nil {
-		return nil, errors.Wrap(err, "invalid tokenizer.json format in HF hub cache")
+// isHFCommitPrefix reports whether revision is a full or abbreviated commit hash.
+func isHFCommitPrefix(revision string) bool {
+	if len(revision) < 7 || len(revision) > 40 {
+		return false
 	}
+	return isHFCommitHash(revision + strings.Repeat("0", 40-len(revision)))
+}
 
-	return data, nil
+// resolveHFHubFile resolves the symlinks of a snapshot file and checks that the file it
+// points to is a regular file within the cache directory of the model.
+func resolveHFHubFile(repoDir, path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	root, err := filepath.EvalSymlinks(repoDir)
+	if err != nil {
+		return "", err
+	}
+	if rel, err := filepath.Rel(root, resolved); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", errors.New("file points outside the model cache")
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", errors.New("not a regular file")
+	}
+	return resolved, nil
 }
 
 // getHFHubCacheDir returns the standard HuggingFace hub cache directory
@@ -1181,9 +1498,10 @@ func loadFromCacheWithValidation(path string, ttl time.Duration) ([]byte, error)
 	}
 
 	// Validate JSON format
-	var validateJSON map[string]interface{}
-	if err := json.Unmarshal(data, &validateJSON); err != nil {
-		return nil, errors.Wrap(err, "invalid cached tokenizer format")
+	if filepath.Ext(path) == ".json" {
+		if !json.Valid(data) {
+			return nil, errors.New("invalid cached tokenizer format")
+		}
 	}
 
 	return data, nil
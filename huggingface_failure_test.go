@@ -1094,6 +1094,75 @@ func TestContextCancellation(t *testing.T) {
 			t.Error("Download did not complete within expected time")
 		}
 	})
+
+	t.Run("Caller context aborts retry backoff", func(t *testing.T) {
+		server := NewFailureInjectionServer(t)
+
+		server.ResetCounters()
+		server.SetFailureMode(FailureModeRateLimit)
+		server.SetRetryAfter("60")
+		server.SetFailureCount(10)
+
+		config := &HFConfig{
+			baseURL:    server.URL,
+			Revision:   "main",
+			CacheDir:   t.TempDir(),
+			Timeout:    shortTestTimeout,
+			MaxRetries: 3,
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := downloadTokenizerFromHFContext(ctx, "test-model", config)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), 5*time.Second, "Retry-After delay should be interrupted by the caller context")
+		assert.Equal(t, int32(1), server.GetRequestCount(), "No retry should be attempted after cancellation")
+	})
+
+	t.Run("Caller context aborts in-flight request", func(t *testing.T) {
+		server := NewFailureInjectionServer(t)
+
+		server.ResetCounters()
+		server.SetFailureMode(FailureModeNone)
+		server.SetResponseDelay(2 * time.Second)
+
+		config := &HFConfig{
+			baseURL:    server.URL,
+			Revision:   "main",
+			CacheDir:   t.TempDir(),
+			Timeout:    longTestTimeout,
+			MaxRetries: defaultMaxRetries,
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			cancel()
+		}()
+
+		_, err := downloadTokenizerFromHFContext(ctx, "test-model", config)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("FromHuggingFaceContext with canceled context", func(t *testing.T) {
+		t.Setenv("HF_USE_LOCAL_CACHE", "false")
+		server := NewFailureInjectionServer(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := FromHuggingFaceContext(ctx, "test-model",
+			WithHFBaseURL(server.URL),
+			WithHFCacheDir(t.TempDir()),
+			WithHFUseLocalCache(false),
+		)
+		require.Error(t, err)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
 }
 
 // TestErrorMessageQuality verifies that error messages are helpful for debugging
@@ -0,0 +1,178 @@
+package tokenizers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// hubCacheFixture builds the cache tree of a model as huggingface_hub writes it: file
+// contents in blobs/, named by their hash, snapshots/<commit>/ with relative symlinks to
+// the blobs, and refs/<revision> files with the commit of each branch and tag.
+type hubCacheFixture struct {
+	repoDir string
+}
+
+func newHubCacheFixture(t *testing.T, modelID string) *hubCacheFixture {
+	hubDir := t.TempDir()
+	t.Setenv("HF_HUB_CACHE", hubDir)
+	repoDir := filepath.Join(hubDir, "models--"+strings.ReplaceAll(modelID, "/", "--"))
+	require.NoError(t, os.MkdirAll(filepath.Join(repoDir, "snapshots"), 0750))
+	return &hubCacheFixture{repoDir: repoDir}
+}
+
+// addFile adds filename to the snapshot of commit as a symlink to its blob.
+func (f *hubCacheFixture) addFile(t *testing.T, commit, filename, content string) {
+	t.Helper()
+	sum := sha256.Sum256([]byte(content))
+	blob := filepath.Join(f.repoDir, "blobs", hex.EncodeToString(sum[:]))
+	require.NoError(t, os.MkdirAll(filepath.Dir(blob), 0750))
+	require.NoError(t, os.WriteFile(blob, []byte(content), 0600))
+
+	path := filepath.Join(f.repoDir, "snapshots", commit, filepath.FromSlash(filename))
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+	target, err := filepath.Rel(filepath.Dir(path), blob)
+	require.NoError(t, err)
+	if err := os.Symlink(target, path); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+}
+
+// setRef points refs/<revision> to commit.
+func (f *hubCacheFixture) setRef(t *testing.T, revision, commit string) {
+	t.Helper()
+	path := filepath.Join(f.repoDir, "refs", filepath.FromSlash(revision))
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0750))
+	require.NoError(t, os.WriteFile(path, []byte(commit), 0600))
+}
+
+func TestLoadFromHFHubCache(t *testing.T) {
+	const (
+		modelID   = "org/model"
+		oldCommit = "1111111111111111111111111111111111111111"
+		// Sorts after the others, as the last snapshot that os.ReadDir returns.
+		newCommit = "fffffff000000000000000000000000000000000"
+		tagCommit = "fffffff111111111111111111111111111111111"
+	)
+	fixture := newHubCacheFixture(t, modelID)
+	fixture.addFile(t, oldCommit, "tokenizer.json", `{"commit": "old"}`)
+	fixture.addFile(t, newCommit, "tokenizer.json", `{"commit": "new"}`)
+	fixture.addFile(t, newCommit, "1_Pooling/config.json", `{"pooling_mode_mean_tokens": true}`)
+	fixture.addFile(t, tagCommit, "tokenizer.json", `{"commit": "tag"}`)
+	fixture.setRef(t, "main", oldCommit)
+	fixture.setRef(t, "v1.0", tagCommit)
+	fixture.setRef(t, "refs/pr/1", newCommit)
+
+	tests := []struct {
+		name     string
+		revision string
+		commit   string
+	}{
+		{name: "Branch follows its ref", revision: "main", commit: oldCommit},
+		{name: "Default revision", revision: "", commit: oldCommit},
+		{name: "Tag", revision: "v1.0", commit: tagCommit},
+		{name: "Pull request ref", revision: "refs/pr/1", commit: newCommit},
+		{name: "Full commit hash", revision: newCommit, commit: newCommit},
+		{name: "Short commit hash", revision: "1111111", commit: oldCommit},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, commit, err := loadFromHFHubCache(modelID, tc.revision, "tokenizer.json")
+			require.NoError(t, err)
+			require.Equal(t, tc.commit, commit)
+		})
+	}
+
+	t.Run("Contents are read from the blob", func(t *testing.T) {
+		data, err := checkHFHubCache(modelID, "main")
+		require.NoError(t, err)
+		require.JSONEq(t, `{"commit": "old"}`, string(data))
+
+		data, _, err = loadFromHFHubCache(modelID, newCommit, "1_Pooling/config.json")
+		require.NoError(t, err)
+		require.JSONEq(t, `{"pooling_mode_mean_tokens": true}`, string(data))
+	})
+
+	t.Run("Lookup errors", func(t *testing.T) {
+		_, _, err := loadFromHFHubCache(modelID, "fffffff", "tokenizer.json")
+		require.ErrorContains(t, err, "revision fffffff is ambiguous")
+
+		_, _, err = loadFromHFHubCache(modelID, "dev", "tokenizer.json")
+		require.ErrorContains(t, err, "revision dev not found in HF hub cache")
+
+		_, _, err = loadFromHFHubCache(modelID, "2222222", "tokenizer.json")
+		require.ErrorContains(t, err, "revision 2222222 not found in HF hub cache")
+
+		_, _, err = loadFromHFHubCache(modelID, "v1.0", "1_Pooling/config.json")
+		require.ErrorContains(t, err, "1_Pooling/config.json not found in HF hub cache")
+
+		_, _, err = loadFromHFHubCache("org/other", "main", "tokenizer.json")
+		require.ErrorContains(t, err, "model not found in HF hub cache")
+
+		_, _, err = loadFromHFHubCache(modelID, "main", "../refs/main")
+		require.ErrorContains(t, err, "invalid filename")
+	})
+
+	t.Run("Missing blob", func(t *testing.T) {
+		const commit = "3333333333333333333333333333333333333333"
+		fixture.addFile(t, commit, "tokenizer.json", `{"commit": "removed"}`)
+		sum := sha256.Sum256([]byte(`{"commit": "removed"}`))
+		require.NoError(t, os.Remove(filepath.Join(fixture.repoDir, "blobs", hex.EncodeToString(sum[:]))))
+
+		_, _, err := loadFromHFHubCache(modelID, commit, "tokenizer.json")
+		require.ErrorContains(t, err, "tokenizer.json not found in HF hub cache")
+	})
+
+	t.Run("Symlink outside the model cache", func(t *testing.T) {
+		const commit = "4444444444444444444444444444444444444444"
+		outside := filepath.Join(t.TempDir(), "tokenizer.json")
+		require.NoError(t, os.WriteFile(outside, []byte(`{}`), 0600))
+		dir := filepath.Join(fixture.repoDir, "snapshots", commit)
+		require.NoError(t, os.MkdirAll(dir, 0750))
+		if err := os.Symlink(outside, filepath.Join(dir, "tokenizer.json")); err != nil {
+			t.Skipf("symlinks are not supported: %v", err)
+		}
+
+		_, _, err := loadFromHFHubCache(modelID, commit, "tokenizer.json")
+		require.ErrorContains(t, err, "file points outside the model cache")
+	})
+
+	t.Run("Copied files", func(t *testing.T) {
+		// huggingface_hub copies blobs into snapshots where symlinks are not available.
+		const commit = "5555555555555555555555555555555555555555"
+		dir := filepath.Join(fixture.repoDir, "snapshots", commit)
+		require.NoError(t, os.MkdirAll(dir, 0750))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "tokenizer.json"), []byte(`{"commit": "copy"}`), 0600))
+		fixture.setRef(t, "copied", commit)
+
+		data, _, err := loadFromHFHubCache(modelID, "copied", "tokenizer.json")
+		require.NoError(t, err)
+		require.JSONEq(t, `{"commit": "copy"}`, string(data))
+	})
+}
+
+func TestFromHuggingFaceHubCache(t *testing.T) {
+	libpath := checkLibraryExists(t)
+	const commit = "6666666666666666666666666666666666666666"
+	fixture := newHubCacheFixture(t, "org/model")
+	fixture.addFile(t, commit, "tokenizer.json", mockTokenizerJSON)
+	fixture.setRef(t, "main", commit)
+
+	cacheDir := t.TempDir()
+	tok, err := FromHuggingFace("org/model",
+		WithLibraryPath(libpath),
+		WithHFCacheDir(cacheDir),
+		WithHFOfflineMode(true),
+	)
+	require.NoError(t, err)
+	_ = tok.Close()
+	require.Equal(t,
+		filepath.Join(cacheDir, "models", "org--model", "snapshots", commit, "tokenizer.json"),
+		getHFCachePath(cacheDir, "org/model", "main"),
+		"The tokenizer is cached under the commit of the hub snapshot")
+}
@@ -0,0 +1,347 @@
+package tokenizers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// hfRepoCommitHeader is the header with which HuggingFace Hub reports the commit a
+	// revision resolved to.
+	hfRepoCommitHeader = "X-Repo-Commit"
+	// hfLinkedETagHeader carries the ETag of files stored with Git LFS, whose ETag header
+	// belongs to the redirect.
+	hfLinkedETagHeader = "X-Linked-Etag"
+	// hfRevalidationTimeout bounds the request that checks the commit of a cached branch
+	// or tag, so that an unresponsive hub delays a cached load only briefly.
+	hfRevalidationTimeout = 5 * time.Second
+)
+
+// isHFCommitHash reports whether revision is a full commit hash.
+func isHFCommitHash(revision string) bool {
+	if len(revision) != 40 {
+		return false
+	}
+	for _, c := range revision {
+		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f')) {
+			return false
+		}
+	}
+	return true
+}
+
+// readHFCacheRef returns the commit that refs/<revision> of a model cache directory
+// points to.
+func readHFCacheRef(modelDir, revision string) (string, bool) {
+	// #nosec G304 -- the ref path is built from a validated revision under the cache root.
+	data, err := os.ReadFile(filepath.Join(modelDir, "refs", filepath.FromSlash(revision)))
+	if err != nil {
+		return "", false
+	}
+	commit := strings.TrimSpace(string(data))
+	if !isHFCommitHash(commit) {
+		return "", false
+	}
+	return commit, true
+}
+
+// cachedHFCommit returns the commit that the configured revision of a model resolves to in
+// the cache: the revision itself for a commit hash, or the commit of its ref. It returns an
+// empty string if the commit is not known.
+func cachedHFCommit(modelID string, config *HFConfig) string {
+	revision := strings.TrimSpace(config.Revision)
+	if isHFCommitHash(revision) {
+		return revision
+	}
+	commit, _ := readHFCacheRef(getHFModelCacheDir(config.CacheDir, modelID), revision)
+	return commit
+}
+
+// pinHFConfig returns a copy of config whose revision is commit, so that the files of a
+// model are all fetched at the commit that the revision resolved to, even if a branch
+// moves in between. config itself is returned if commit is not a commit hash.
+func pinHFConfig(config *HFConfig, commit string) *HFConfig {
+	if !isHFCommitHash(commit) || strings.TrimSpace(config.Revision) == commit {
+		return config
+	}
+	pinned := new(HFConfig)
+	*pinned = *config
+	pinned.Revision = commit
+	return pinned
+}
+
+// saveHFCacheFile saves a downloaded file of a model in the cache. If the hub reported
+// the commit of the download, the file is kept in the snapshot of that commit and the
+// ref of the revision is pointed to it. It returns the path of the file.
+func saveHFCacheFile(modelID, filename string, data []byte, commit string, config *HFConfig) (string, error) {
+	if !isHFCommitHash(commit) {
+		path := getHFCacheFilePath(config.CacheDir, modelID, config.Revision, filename)
+		return path, saveToHFCache(path, data)
+	}
+	path := getHFCacheFilePath(config.CacheDir, modelID, commit, filename)
+	if err := saveToHFCache(path, data); err != nil {
+		return path, err
+	}
+	return path, saveHFCacheRef(modelID, commit, config)
+}
+
+// saveHFCacheRef points the ref of the configured revision of a model to commit. Commit
+// revisions have no ref. Snapshots of earlier commits are kept, as they may still be in
+// use; ClearHFModelCache removes them.
+func saveHFCacheRef(modelID, commit string, config *HFConfig) error {
+	revision := strings.TrimSpace(config.Revision)
+	if revision == commit || validateHFRevision(revision) != nil {
+		return nil
+	}
+	refPath := filepath.Join(getHFModelCacheDir(config.CacheDir, modelID), "refs", filepath.FromSlash(revision))
+	if err := saveToHFCache(refPath, []byte(commit)); err != nil {
+		return errors.Wrapf(err, "failed to save ref of revision %s", revision)
+	}
+	return nil
+}
+
+// migrateHFCacheRevision moves the files that versions before the snapshot layout cached
+// in models/<model>/<revision> to the snapshot named after the revision, where files of
+// unknown commits are kept. Once a download records the commit of the revision, the ref
+// points to the snapshot of that commit instead. Revisions whose old directory could be
+// part of the snapshot layout, such as "snapshots" or "refs/pr", are left alone.
+func migrateHFCacheRevision(modelID string, config *HFConfig) {
+	revision := strings.TrimSpace(config.Revision)
+	if validateHFRevision(revision) != nil {
+		return
+	}
+	parts := strings.Split(revision, "/")
+	if parts[0] == "snapshots" || parts[0] == "etags" || (parts[0] == "refs" && len(parts) < 3) {
+		return
+	}
+	modelDir := getHFModelCacheDir(config.CacheDir, modelID)
+	oldDir := filepath.Join(modelDir, filepath.FromSlash(revision))
+	if info, err := os.Stat(oldDir); err != nil || !info.IsDir() {
+		return
+	}
+	snapshot := filepath.Join(modelDir, "snapshots", filepath.FromSlash(revision))
+	if _, err := os.Stat(snapshot); err == nil {
+		// The revision has been cached in the snapshot layout already.
+		if err := os.RemoveAll(oldDir); err != nil {
+			log.Printf("[WARNING] Failed to remove old HuggingFace cache %s: %v", oldDir, err)
+		}
+		return
+	}
+	err := os.MkdirAll(filepath.Dir(snapshot), 0750)
+	if err == nil {
+		err = os.Rename(oldDir, snapshot)
+	}
+	if err != nil {
+		log.Printf("[WARNING] Failed to migrate HuggingFace cache %s: %v", oldDir, err)
+	}
+}
+
+// hfCacheETags is the content of etags/<revision> in a model cache directory: the ETags
+// that the hub returned for the files of a revision at one commit.
+type hfCacheETags struct {
+	Commit string            `json:"commit"`
+	ETags  map[string]string `json:"etags"`
+}
+
+// readHFCacheETags returns the ETags saved for the files of a revision, or nil.
+func readHFCacheETags(modelDir, revision string) *hfCacheETags {
+	// #nosec G304 -- the ETag path is built from a validated revision under the cache root.
+	data, err := os.ReadFile(filepath.Join(modelDir, "etags", filepath.FromSlash(revision)))
+	if err != nil {
+		return nil
+	}
+	var etags hfCacheETags
+	if err := json.Unmarshal(data, &etags); err != nil || !isHFCommitHash(etags.Commit) {
+		return nil
+	}
+	return &etags
+}
+
+// readHFCacheETag returns the ETag that the hub returned for filename of a revision at
+// commit, as saved by saveHFCacheETag, or an empty string.
+func readHFCacheETag(modelDir, revision, commit, filename string) string {
+	etags := readHFCacheETags(modelDir, revision)
+	if etags == nil || etags.Commit != commit {
+		return ""
+	}
+	return etags.ETags[filename]
+}
+
+// hfResponseETag returns the ETag of a file from the response of the hub to a resolve
+// request, or from the redirect that led to its content.
+func hfResponseETag(resp *http.Response) string {
+	for ; resp != nil; resp = resp.Request.Response {
+		if etag := resp.Header.Get(hfLinkedETagHeader); etag != "" {
+			return etag
+		}
+		if isHFCommitHash(resp.Header.Get(hfRepoCommitHeader)) {
+			return resp.Header.Get("ETag")
+		}
+		if resp.Request == nil {
+			break
+		}
+	}
+	return ""
+}
+
+// recordHFETag saves the ETag of filename of the configured revision of a model at
+// commit, for fetchHFRepoCommit to revalidate the revision with. Failures are only logged
+// in debug mode, as the ETag saves a response body at most.
+func recordHFETag(modelID, filename, commit, etag string, config *HFConfig) {
+	revision := strings.TrimSpace(config.Revision)
+	if etag == "" || !isHFCommitHash(commit) || isHFCommitHash(revision) || validateHFRevision(revision) != nil {
+		return
+	}
+	modelDir := getHFModelCacheDir(config.CacheDir, modelID)
+	if err := saveHFCacheETag(modelDir, revision, commit, filename, etag); err != nil && os.Getenv("DEBUG") != "" {
+		log.Printf("[DEBUG] Could not save ETag of revision %s of %s: %v", revision, modelID, err)
+	}
+}
+
+// saveHFCacheETag saves the ETag of filename of a revision at commit in etags/<revision>
+// of a model cache directory, next to refs/<revision>. The ETags of other files at the
+// same commit are kept; those of an earlier commit are dropped.
+func saveHFCacheETag(modelDir, revision, commit, filename, etag string) error {
+	etags := readHFCacheETags(modelDir, revision)
+	if etags == nil || etags.Commit != commit {
+		etags = &hfCacheETags{Commit: commit}
+	}
+	if etags.ETags == nil {
+		etags.ETags = make(map[string]string)
+	}
+	etags.ETags[filename] = etag
+	data, err := json.Marshal(etags)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode ETags")
+	}
+	return saveToHFCache(filepath.Join(modelDir, "etags", filepath.FromSlash(revision)), data)
+}
+
+// hfResponseCommit returns the commit reported by the response to a resolve request, or
+// by one of the redirects that led to it.
+func hfResponseCommit(resp *http.Response) string {
+	for resp != nil {
+		if commit := resp.Header.Get(hfRepoCommitHeader); isHFCommitHash(commit) {
+			return commit
+		}
+		if resp.Request == nil {
+			break
+		}
+		resp = resp.Request.Response
+	}
+	return ""
+}
+
+// isHFCacheStale reports whether the cached revision of a model points to an older commit
+// than the one the revision resolves to on the hub. Only branches and tags with a cached
+// ref are checked, with a HEAD request for filename. Commits never change, and the cache
+// is kept if the hub cannot be reached.
+func isHFCacheStale(ctx context.Context, modelID, filename string, config *HFConfig) bool {
+	revision := strings.TrimSpace(config.Revision)
+	if config.OfflineMode || isHFCommitHash(revision) {
+		return false
+	}
+	cached, ok := readHFCacheRef(getHFModelCacheDir(config.CacheDir, modelID), revision)
+	if !ok {
+		return false
+	}
+	commit, err := fetchHFRepoCommit(ctx, modelID, filename, cached, config)
+	if err != nil {
+		if os.Getenv("DEBUG") != "" {
+			log.Printf("[DEBUG] Could not revalidate cached revision %s of %s: %v", revision, modelID, err)
+		}
+		return false
+	}
+	return commit != cached
+}
+
+// resolveHFCommit returns the commit that the revision of a model resolves to: the
+// revision itself for a commit hash, the commit reported by the hub for a HEAD request
+// for filename, or the cached ref if the model is offline or the hub cannot be reached.
+func resolveHFCommit(ctx context.Context, modelID, filename string, config *HFConfig) (string, bool) {
+	revision := strings.TrimSpace(config.Revision)
+	if isHFCommitHash(revision) {
+		return revision, true
+	}
+	cached, ok := readHFCacheRef(getHFModelCacheDir(config.CacheDir, modelID), revision)
+	if config.OfflineMode {
+		return cached, ok
+	}
+	commit, err := fetchHFRepoCommit(ctx, modelID, filename, cached, config)
+	if err != nil {
+		if os.Getenv("DEBUG") != "" {
+			log.Printf("[DEBUG] Could not resolve revision %s of %s: %v", revision, modelID, err)
+		}
+		return cached, ok
+	}
+	return commit, true
+}
+
+// fetchHFRepoCommit returns the commit that the revision of a model resolves to, from
+// a HEAD request for filename. Redirects are not followed, as the hub reports the commit
+// in the response that redirects to the file content. cached is the commit of the cached
+// ref, or empty: the ETag saved for filename at that commit is sent in If-None-Match, and
+// a file the hub reports as not modified is taken to be at cached, unless the hub reports
+// the commit. The request is bounded by hfRevalidationTimeout.
+func fetchHFRepoCommit(ctx context.Context, modelID, filename, cached string, config *HFConfig) (string, error) {
+	fileURL, err := hfFileURL(modelID, filename, config)
+	if err != nil {
+		return "", err
+	}
+	timeout := hfRevalidationTimeout
+	if config.Timeout > 0 && config.Timeout < timeout {
+		timeout = config.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, fileURL, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create request")
+	}
+	req.Header.Set("User-Agent", fmt.Sprintf("pure-tokenizers/%s", GetLibraryVersion()))
+	if config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+config.Token)
+	}
+	modelDir := getHFModelCacheDir(config.CacheDir, modelID)
+	revision := strings.TrimSpace(config.Revision)
+	if cached != "" {
+		if etag := readHFCacheETag(modelDir, revision, cached, filename); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+	}
+
+	client := *getHFHTTPClient(config)
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "request failed")
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return "", errors.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	commit := resp.Header.Get(hfRepoCommitHeader)
+	if resp.StatusCode == http.StatusNotModified && !isHFCommitHash(commit) && cached != "" {
+		return cached, nil
+	}
+	if !isHFCommitHash(commit) {
+		return "", errors.Errorf("response has no %s header", hfRepoCommitHeader)
+	}
+	recordHFETag(modelID, filename, commit, hfResponseETag(resp), config)
+	return commit, nil
+}
@@ -0,0 +1,433 @@
+package tokenizers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testCommitA = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	testCommitB = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+)
+
+// revisionServer serves the files of a repository whose main branch points to a commit
+// that tests can move.
+type revisionServer struct {
+	*httptest.Server
+	mu          sync.Mutex
+	commit      string
+	files       map[string]map[string]string // commit -> filename -> content
+	failHead    bool
+	failGet     bool
+	moveTo      string // Commit the branch moves to after the next HEAD request
+	requested   []string
+	ifNoneMatch []string // If-None-Match headers received
+}
+
+func newRevisionServer(t *testing.T) *revisionServer {
+	s := &revisionServer{
+		commit: testCommitA,
+		files: map[string]map[string]string{
+			testCommitA: {"config.json": `{"version": "a"}`, "tokenizer.json": mockTokenizerJSON, "tokenizer_config.json": `{}`},
+			testCommitB: {"config.json": `{"version": "b"}`, "tokenizer.json": mockTokenizerJSON, "tokenizer_config.json": `{}`},
+		},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requested = append(s.requested, r.Method+" "+r.URL.Path)
+
+		if content, ok := strings.CutPrefix(r.URL.Path, "/cdn/"); ok {
+			// Large files are served from a CDN that does not know the commit.
+			_, _ = w.Write([]byte(content))
+			return
+		}
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/model/resolve/"), "/", 2)
+		if len(parts) != 2 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		commit := parts[0]
+		if commit == "main" {
+			commit = s.commit
+		}
+		if r.Method == http.MethodHead && s.failHead {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.Method == http.MethodHead && s.moveTo != "" {
+			s.commit, s.moveTo = s.moveTo, ""
+		}
+		if r.Method == http.MethodGet && s.failGet {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(hfRepoCommitHeader, commit)
+		if parts[1] == "model.bin" {
+			http.Redirect(w, r, "/cdn/weights-"+commit[:1], http.StatusFound)
+			return
+		}
+		content, ok := s.files[commit][parts[1]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// The ETag of a file depends on its content only, as on the hub.
+		sum := sha256.Sum256([]byte(content))
+		etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+		if match := r.Header.Get("If-None-Match"); match != "" {
+			s.ifNoneMatch = append(s.ifNoneMatch, match)
+			if match == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.Header().Set("ETag", etag)
+		if r.Method == http.MethodHead {
+			return
+		}
+		_, _ = w.Write([]byte(content))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// requests returns and resets the requests the server received.
+func (s *revisionServer) requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requested := s.requested
+	s.requested = nil
+	return requested
+}
+
+// conditionalRequests returns and resets the If-None-Match headers the server received.
+func (s *revisionServer) conditionalRequests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ifNoneMatch := s.ifNoneMatch
+	s.ifNoneMatch = nil
+	return ifNoneMatch
+}
+
+func (s *revisionServer) setCommit(commit string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commit = commit
+}
+
+func TestHFRevisionCache(t *testing.T) {
+	server := newRevisionServer(t)
+	cacheDir := t.TempDir()
+	modelDir := filepath.Join(cacheDir, "models", "model")
+	download := func(t *testing.T, revision string, opts ...TokenizerOption) string {
+		t.Helper()
+		opts = append([]TokenizerOption{WithHFBaseURL(server.URL), WithHFCacheDir(cacheDir), WithHFUseLocalCache(false)}, opts...)
+		snapshot, err := HFDownloadFilesContext(t.Context(), "model", revision, []string{"config.json"}, opts...)
+		require.NoError(t, err)
+		data, err := snapshot.ReadFile("config.json")
+		require.NoError(t, err)
+		return string(data)
+	}
+	ref := func(t *testing.T) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(modelDir, "refs", "main"))
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	require.JSONEq(t, `{"version": "a"}`, download(t, "main"))
+	require.Equal(t, []string{"HEAD /model/resolve/main/config.json", "GET /model/resolve/" + testCommitA + "/config.json"}, server.requests())
+	require.FileExists(t, filepath.Join(modelDir, "snapshots", testCommitA, "config.json"), "Content is stored under the commit")
+	require.Equal(t, testCommitA, ref(t))
+
+	require.JSONEq(t, `{"version": "a"}`, download(t, "main"))
+	require.Equal(t, []string{"HEAD /model/resolve/main/config.json"}, server.requests(), "An unchanged branch is revalidated without a download")
+	require.Len(t, server.conditionalRequests(), 1, "The ETag of the first request is sent")
+
+	t.Run("Branch moved", func(t *testing.T) {
+		server.setCommit(testCommitB)
+		t.Cleanup(func() {
+			server.setCommit(testCommitA)
+		})
+		require.JSONEq(t, `{"version": "b"}`, download(t, "main"))
+		require.Equal(t, []string{"HEAD /model/resolve/main/config.json", "GET /model/resolve/" + testCommitB + "/config.json"}, server.requests())
+		require.Equal(t, testCommitB, ref(t))
+		require.FileExists(t, filepath.Join(modelDir, "snapshots", testCommitA, "config.json"), "The snapshot of the old commit is kept")
+	})
+
+	t.Run("Commit revision", func(t *testing.T) {
+		require.JSONEq(t, `{"version": "a"}`, download(t, testCommitA))
+		require.Empty(t, server.requests(), "The snapshot of the commit main pointed to is reused, without revalidation")
+		require.JSONEq(t, `{"version": "b"}`, download(t, testCommitB))
+		require.Empty(t, server.requests())
+
+		_, err := HFDownloadFilesContext(t.Context(), "model", testCommitA, []string{"config.json"},
+			WithHFBaseURL(server.URL), WithHFCacheDir(t.TempDir()), WithHFUseLocalCache(false))
+		require.NoError(t, err)
+		require.Equal(t, []string{"GET /model/resolve/" + testCommitA + "/config.json"}, server.requests(), "A commit needs no resolution")
+		require.Equal(t, testCommitB, ref(t))
+	})
+
+	t.Run("Hub unavailable", func(t *testing.T) {
+		server.mu.Lock()
+		server.failHead = true
+		server.mu.Unlock()
+		t.Cleanup(func() {
+			server.mu.Lock()
+			server.failHead = false
+			server.mu.Unlock()
+		})
+		require.JSONEq(t, `{"version": "b"}`, download(t, "main"), "The cached commit is used")
+		require.Equal(t, []string{"HEAD /model/resolve/main/config.json"}, server.requests())
+	})
+
+	t.Run("Offline", func(t *testing.T) {
+		require.JSONEq(t, `{"version": "b"}`, download(t, "main", WithHFOfflineMode(true)))
+		require.Empty(t, server.requests())
+	})
+
+	t.Run("Redirected download", func(t *testing.T) {
+		snapshot, err := HFDownloadFilesContext(t.Context(), "model", "", []string{"model.bin"},
+			WithHFBaseURL(server.URL), WithHFCacheDir(cacheDir), WithHFUseLocalCache(false))
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(modelDir, "snapshots", testCommitA), snapshot.Dir, "The commit is read from the redirect")
+		data, err := snapshot.ReadFile("model.bin")
+		require.NoError(t, err)
+		require.Equal(t, "weights-a", string(data))
+	})
+
+	t.Run("Files of one commit", func(t *testing.T) {
+		server.requests()
+		server.mu.Lock()
+		server.moveTo = testCommitB
+		server.mu.Unlock()
+		t.Cleanup(func() {
+			server.setCommit(testCommitA)
+		})
+		snapshot, err := HFDownloadFilesContext(t.Context(), "model", "main", []string{"tokenizer_config.json", "config.json"},
+			WithHFBaseURL(server.URL), WithHFCacheDir(cacheDir), WithHFUseLocalCache(false))
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			"HEAD /model/resolve/main/tokenizer_config.json",
+			"GET /model/resolve/" + testCommitA + "/tokenizer_config.json",
+		}, server.requests(), "Files are fetched at the commit resolved first, although the branch moved")
+		require.Equal(t, filepath.Join(modelDir, "snapshots", testCommitA), snapshot.Dir)
+		data, err := snapshot.ReadFile("config.json")
+		require.NoError(t, err)
+		require.JSONEq(t, `{"version": "a"}`, string(data))
+	})
+}
+
+func TestFromHuggingFaceRevalidation(t *testing.T) {
+	libpath := checkLibraryExists(t)
+	server := newRevisionServer(t)
+	cacheDir := t.TempDir()
+	load := func(t *testing.T) {
+		t.Helper()
+		tok, err := FromHuggingFace("model",
+			WithLibraryPath(libpath),
+			WithHFBaseURL(server.URL),
+			WithHFCacheDir(cacheDir),
+			WithHFUseLocalCache(false),
+		)
+		require.NoError(t, err)
+		_ = tok.Close()
+	}
+
+	load(t)
+	requests := server.requests()
+	require.Contains(t, requests, "GET /model/resolve/main/tokenizer.json")
+	require.Contains(t, requests, "GET /model/resolve/"+testCommitA+"/tokenizer_config.json", "Metadata is fetched at the commit of tokenizer.json")
+	require.FileExists(t, filepath.Join(cacheDir, "models", "model", "snapshots", testCommitA, "tokenizer.json"))
+
+	load(t)
+	require.Equal(t, []string{"HEAD /model/resolve/main/tokenizer.json"}, server.requests())
+	require.Len(t, server.conditionalRequests(), 1, "The ETag of tokenizer.json is sent")
+
+	server.setCommit(testCommitB)
+	load(t)
+	require.Contains(t, server.requests(), "GET /model/resolve/main/tokenizer.json")
+	require.Equal(t, getHFCachePath(cacheDir, "model", "main"), filepath.Join(cacheDir, "models", "model", "snapshots", testCommitB, "tokenizer.json"))
+}
+
+func TestIsHFCommitHash(t *testing.T) {
+	require.True(t, isHFCommitHash(testCommitA))
+	require.True(t, isHFCommitHash("0123456789abcdef0123456789abcdef01234567"))
+	require.False(t, isHFCommitHash("main"))
+	require.False(t, isHFCommitHash("0123456"), "Short hashes are not full commits")
+	require.False(t, isHFCommitHash(strings.ToUpper(testCommitB)))
+}
+
+func TestLoadHFTokenizerJSON(t *testing.T) {
+	server := newRevisionServer(t)
+	cacheDir := t.TempDir()
+	snapshotA := filepath.Join(cacheDir, "models", "model", "snapshots", testCommitA, "tokenizer.json")
+	newConfig := func(t *testing.T, opts ...TokenizerOption) *HFConfig {
+		t.Helper()
+		opts = append([]TokenizerOption{WithHFBaseURL(server.URL), WithHFCacheDir(cacheDir), WithHFUseLocalCache(false)}, opts...)
+		config, err := newHFConfig(opts)
+		require.NoError(t, err)
+		config.MaxRetries = 1
+		return config
+	}
+
+	data, path, commit, err := loadHFTokenizerJSON(t.Context(), "model", newConfig(t))
+	require.NoError(t, err)
+	require.Equal(t, snapshotA, path)
+	require.Equal(t, testCommitA, commit)
+	require.JSONEq(t, mockTokenizerJSON, string(data))
+	server.requests()
+
+	t.Run("Metadata of the same commit", func(t *testing.T) {
+		tokenizerConfig, _, err := loadHFTokenizerConfig(t.Context(), "model", pinHFConfig(newConfig(t), commit), filepath.Dir(path))
+		require.NoError(t, err)
+		require.NotNil(t, tokenizerConfig)
+		require.Contains(t, server.requests(), "GET /model/resolve/"+testCommitA+"/tokenizer_config.json",
+			"Metadata is fetched at the commit of tokenizer.json")
+
+		// A file downloaded at the branch records its ETag next to that of tokenizer.json.
+		_, _, err = downloadHFFile(t.Context(), "model", "config.json", newConfig(t))
+		require.NoError(t, err)
+		server.requests()
+	})
+
+	t.Run("Unchanged branch", func(t *testing.T) {
+		_, path, commit, err := loadHFTokenizerJSON(t.Context(), "model", newConfig(t))
+		require.NoError(t, err)
+		require.Equal(t, snapshotA, path)
+		require.Equal(t, testCommitA, commit)
+		require.Equal(t, []string{"HEAD /model/resolve/main/tokenizer.json"}, server.requests())
+		require.Len(t, server.conditionalRequests(), 1)
+	})
+
+	t.Run("Branch moved but the download fails", func(t *testing.T) {
+		server.mu.Lock()
+		server.commit, server.failGet = testCommitB, true
+		server.mu.Unlock()
+		t.Cleanup(func() {
+			server.mu.Lock()
+			server.commit, server.failGet = testCommitA, false
+			server.mu.Unlock()
+		})
+		var logs bytes.Buffer
+		writer := log.Writer()
+		log.SetOutput(&logs)
+		t.Cleanup(func() {
+			log.SetOutput(writer)
+		})
+
+		data, path, commit, err := loadHFTokenizerJSON(t.Context(), "model", newConfig(t))
+		require.NoError(t, err)
+		require.Equal(t, snapshotA, path, "The cached commit is used")
+		require.Equal(t, testCommitA, commit)
+		require.JSONEq(t, mockTokenizerJSON, string(data))
+		require.Equal(t, []string{"HEAD /model/resolve/main/tokenizer.json", "GET /model/resolve/main/tokenizer.json"}, server.requests())
+		require.Contains(t, logs.String(), "[WARNING] Failed to download the new commit of model revision main, using the cached tokenizer")
+		require.FileExists(t, snapshotA)
+	})
+
+	t.Run("Hub unreachable", func(t *testing.T) {
+		unreachable := httptest.NewServer(http.NotFoundHandler())
+		unreachable.Close()
+
+		_, path, _, err := loadHFTokenizerJSON(t.Context(), "model", newConfig(t, WithHFBaseURL(unreachable.URL)))
+		require.NoError(t, err)
+		require.Equal(t, snapshotA, path)
+	})
+
+	t.Run("Not cached and hub unreachable", func(t *testing.T) {
+		unreachable := httptest.NewServer(http.NotFoundHandler())
+		unreachable.Close()
+
+		_, _, _, err := loadHFTokenizerJSON(t.Context(), "other", newConfig(t, WithHFBaseURL(unreachable.URL)))
+		require.ErrorContains(t, err, "failed to download tokenizer from HuggingFace")
+	})
+}
+
+func TestHFCacheETags(t *testing.T) {
+	modelDir := t.TempDir()
+	require.NoError(t, saveHFCacheETag(modelDir, "main", testCommitA, "tokenizer.json", `"tok"`))
+	require.NoError(t, saveHFCacheETag(modelDir, "main", testCommitA, "tokenizer_config.json", `"cfg"`))
+	require.Equal(t, `"tok"`, readHFCacheETag(modelDir, "main", testCommitA, "tokenizer.json"), "ETags are kept per file")
+	require.Equal(t, `"cfg"`, readHFCacheETag(modelDir, "main", testCommitA, "tokenizer_config.json"))
+	require.Empty(t, readHFCacheETag(modelDir, "main", testCommitB, "tokenizer.json"), "ETags belong to their commit")
+	require.Empty(t, readHFCacheETag(modelDir, "v1.0", testCommitA, "tokenizer.json"))
+
+	require.NoError(t, saveHFCacheETag(modelDir, "main", testCommitB, "config.json", `"new"`))
+	require.Empty(t, readHFCacheETag(modelDir, "main", testCommitA, "tokenizer.json"))
+	require.Empty(t, readHFCacheETag(modelDir, "main", testCommitB, "tokenizer.json"), "ETags of the old commit are dropped")
+	require.Equal(t, `"new"`, readHFCacheETag(modelDir, "main", testCommitB, "config.json"))
+
+	t.Run("Earlier format", func(t *testing.T) {
+		path := filepath.Join(modelDir, "etags", "v1.0")
+		require.NoError(t, os.WriteFile(path, []byte(testCommitA+"\ntokenizer.json\n\"tok\"\n"), 0600))
+		require.Empty(t, readHFCacheETag(modelDir, "v1.0", testCommitA, "tokenizer.json"))
+		require.NoError(t, saveHFCacheETag(modelDir, "v1.0", testCommitA, "tokenizer.json", `"tok"`))
+		require.Equal(t, `"tok"`, readHFCacheETag(modelDir, "v1.0", testCommitA, "tokenizer.json"))
+	})
+}
+
+func TestMigrateHFCacheRevision(t *testing.T) {
+	cacheDir := t.TempDir()
+	modelDir := filepath.Join(cacheDir, "models", "org--model")
+	writeOld := func(t *testing.T, revision string) {
+		t.Helper()
+		dir := filepath.Join(modelDir, filepath.FromSlash(revision))
+		require.NoError(t, os.MkdirAll(dir, 0750))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "tokenizer.json"), []byte(mockTokenizerJSON), 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "tokenizer_config.json"), []byte(`{}`), 0600))
+	}
+	newConfig := func(t *testing.T, revision string) *HFConfig {
+		t.Helper()
+		config, err := newHFConfig([]TokenizerOption{WithHFCacheDir(cacheDir), WithHFRevision(revision), WithHFOfflineMode(true), WithHFUseLocalCache(false)})
+		require.NoError(t, err)
+		return config
+	}
+
+	t.Run("Old layout is moved to the snapshot of the revision", func(t *testing.T) {
+		writeOld(t, "main")
+		data, path, commit, err := loadHFTokenizerJSON(t.Context(), "org/model", newConfig(t, "main"))
+		require.NoError(t, err)
+		require.Empty(t, commit, "The commit of files in the old layout is not known")
+		require.JSONEq(t, mockTokenizerJSON, string(data))
+		require.Equal(t, filepath.Join(modelDir, "snapshots", "main", "tokenizer.json"), path)
+		require.FileExists(t, filepath.Join(modelDir, "snapshots", "main", "tokenizer_config.json"))
+		require.NoDirExists(t, filepath.Join(modelDir, "main"))
+	})
+
+	t.Run("Pull request refs", func(t *testing.T) {
+		writeOld(t, "refs/pr/1")
+		migrateHFCacheRevision("org/model", newConfig(t, "refs/pr/1"))
+		require.FileExists(t, filepath.Join(modelDir, "snapshots", "refs", "pr", "1", "tokenizer.json"))
+		require.NoDirExists(t, filepath.Join(modelDir, "refs", "pr", "1"))
+	})
+
+	t.Run("Already migrated", func(t *testing.T) {
+		writeOld(t, "v1.0")
+		snapshot := filepath.Join(modelDir, "snapshots", "v1.0")
+		require.NoError(t, os.MkdirAll(snapshot, 0750))
+		migrateHFCacheRevision("org/model", newConfig(t, "v1.0"))
+		require.NoDirExists(t, filepath.Join(modelDir, "v1.0"), "The old copy is removed")
+		require.NoFileExists(t, filepath.Join(snapshot, "tokenizer.json"), "The snapshot is kept as it is")
+	})
+
+	t.Run("Directories of the snapshot layout", func(t *testing.T) {
+		require.NoError(t, os.MkdirAll(filepath.Join(modelDir, "refs", "pr"), 0750))
+		migrateHFCacheRevision("org/model", newConfig(t, "refs/pr"))
+		migrateHFCacheRevision("org/model", newConfig(t, "snapshots"))
+		require.DirExists(t, filepath.Join(modelDir, "refs", "pr"))
+		require.DirExists(t, filepath.Join(modelDir, "snapshots", "main"))
+	})
+}
@@ -0,0 +1,141 @@
+package tokenizers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// HFSnapshot is a set of files of a HuggingFace model revision in the local cache.
+type HFSnapshot struct {
+	ModelID  string
+	Revision string
+	// Dir is the snapshot directory of the commit the revision resolved to. Files keep
+	// their repository paths below it.
+	Dir string
+	// Files maps the repository path of each file, such as "1_Pooling/config.json", to
+	// its local path.
+	Files map[string]string
+}
+
+// Path returns the local path of filename, or false if it is not part of the snapshot.
+func (s *HFSnapshot) Path(filename string) (string, bool) {
+	path, ok := s.Files[filename]
+	return path, ok
+}
+
+// ReadFile returns the contents of filename.
+func (s *HFSnapshot) ReadFile(filename string) ([]byte, error) {
+	path, ok := s.Path(filename)
+	if !ok {
+		return nil, errors.Errorf("%s is not part of the snapshot of %s", filename, s.ModelID)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", filename)
+	}
+	return data, nil
+}
+
+// HFDownloadFiles downloads files of a HuggingFace model repository into the cache, as
+// FromHuggingFace does for tokenizer.json, and returns their local paths. filenames are
+// paths within the repository, such as "config.json" or "1_Pooling/config.json". The
+// revision is resolved to a commit once, and all files are taken from that commit; files
+// that are already cached are not downloaded again. If revision is empty, the revision
+// set by WithHFRevision, or "main", is used. The HuggingFace options of FromHuggingFace,
+// such as WithHFToken, WithHFCacheDir and WithHFOfflineMode, apply.
+func HFDownloadFiles(modelID, revision string, filenames []string, opts ...TokenizerOption) (*HFSnapshot, error) {
+	return HFDownloadFilesContext(context.Background(), modelID, revision, filenames, opts...)
+}
+
+// HFDownloadFilesContext is like HFDownloadFiles, but ctx bounds the downloads, including
+// the delays between retries.
+func HFDownloadFilesContext(ctx context.Context, modelID, revision string, filenames []string, opts ...TokenizerOption) (*HFSnapshot, error) {
+	if modelID == "" {
+		return nil, errors.New("model ID cannot be empty")
+	}
+	if err := validateModelID(modelID); err != nil {
+		return nil, errors.Wrapf(err, "invalid model ID: %s", modelID)
+	}
+	if len(filenames) == 0 {
+		return nil, errors.New("no files to download")
+	}
+	for _, filename := range filenames {
+		if err := validateHFFilename(filename); err != nil {
+			return nil, errors.Wrap(err, "invalid filename")
+		}
+	}
+	if revision != "" {
+		opts = append(opts, WithHFRevision(revision))
+	}
+	config, err := newHFConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every file is fetched at the commit the revision resolves to, so that a branch that
+	// moves during the downloads cannot mix the files of two commits.
+	pinned := config
+	if commit, ok := resolveHFCommit(ctx, modelID, filenames[0], config); ok {
+		pinned = pinHFConfig(config, commit)
+	}
+	snapshot := &HFSnapshot{
+		ModelID:  modelID,
+		Revision: config.Revision,
+		Files:    make(map[string]string, len(filenames)),
+	}
+	for _, filename := range filenames {
+		if _, ok := snapshot.Files[filename]; ok {
+			continue
+		}
+		path, err := fetchHFFile(ctx, modelID, filename, pinned)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to download %s", filename)
+		}
+		snapshot.Files[filename] = path
+	}
+	if pinned != config {
+		if err := saveHFCacheRef(modelID, pinned.Revision, config); err != nil {
+			return nil, err
+		}
+	}
+	snapshot.Dir = filepath.Dir(getHFCachePath(config.CacheDir, modelID, pinned.Revision))
+	return snapshot, nil
+}
+
+// fetchHFFile returns the cache path of a file of a model, downloading it into the cache
+// unless it is cached already.
+func fetchHFFile(ctx context.Context, modelID, filename string, config *HFConfig) (string, error) {
+	path := getHFCacheFilePath(config.CacheDir, modelID, config.Revision, filename)
+	if _, err := loadFromCacheWithValidation(path, config.CacheTTL); err == nil {
+		return path, nil
+	}
+	if config.OfflineMode {
+		return "", errors.Errorf("offline mode enabled but %s not found in cache", filename)
+	}
+	data, commit, err := downloadHFFile(ctx, modelID, filename, config)
+	if err != nil {
+		return "", err
+	}
+	path, err = saveHFCacheFile(modelID, filename, data, commit, config)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to cache %s", filename)
+	}
+	return path, nil
+}
+
+// loadHFFile returns the contents of a file of a model from the cache, or downloads it
+// and caches it first.
+func loadHFFile(ctx context.Context, modelID, filename string, config *HFConfig) ([]byte, error) {
+	path, err := fetchHFFile(ctx, modelID, filename, config)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path) // #nosec G304 -- path is a cache file of the model.
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", filename)
+	}
+	return data, nil
+}
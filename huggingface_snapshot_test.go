@@ -0,0 +1,132 @@
+package tokenizers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHFDownloadFiles(t *testing.T) {
+	files := map[string]string{
+		"/org/model/resolve/main/config.json":            `{"hidden_size": 384}`,
+		"/org/model/resolve/main/vocab.txt":              "[PAD]\n[UNK]\n",
+		"/org/model/resolve/main/1_Pooling/config.json":  `{"pooling_mode_mean_tokens": true}`,
+		"/org/model/resolve/v1.0/config.json":            `{"hidden_size": 768}`,
+		"/org/model/resolve/main/with%20space/notes.txt": "notes",
+		"/org/private-model/resolve/main/tokenizer.json": `{}`,
+	}
+	var mu sync.Mutex
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.EscapedPath())
+		mu.Unlock()
+		if r.URL.Path == "/org/private-model/resolve/main/tokenizer.json" && r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		data, ok := files[r.URL.EscapedPath()]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(data))
+	}))
+	defer server.Close()
+
+	cacheDir := t.TempDir()
+	opts := []TokenizerOption{WithHFBaseURL(server.URL), WithHFCacheDir(cacheDir), WithHFUseLocalCache(false)}
+	download := func(t *testing.T, modelID, revision string, filenames []string, extra ...TokenizerOption) (*HFSnapshot, []string, error) {
+		t.Helper()
+		mu.Lock()
+		requested = nil
+		mu.Unlock()
+		snapshot, err := HFDownloadFilesContext(t.Context(), modelID, revision, filenames, append(append([]TokenizerOption(nil), opts...), extra...)...)
+		mu.Lock()
+		defer mu.Unlock()
+		return snapshot, requested, err
+	}
+
+	t.Run("Downloaded and cached", func(t *testing.T) {
+		filenames := []string{"config.json", "vocab.txt", "1_Pooling/config.json", "with space/notes.txt"}
+		snapshot, requests, err := download(t, "org/model", "", filenames)
+		require.NoError(t, err)
+		require.Len(t, requests, 5, "The revision is resolved, then the files are downloaded")
+		require.Equal(t, "main", snapshot.Revision)
+		require.Equal(t, filepath.Join(cacheDir, "models", "org--model", "snapshots", "main"), snapshot.Dir)
+		require.Len(t, snapshot.Files, 4)
+		for _, filename := range filenames {
+			path, ok := snapshot.Path(filename)
+			require.True(t, ok)
+			require.Equal(t, filepath.Join(snapshot.Dir, filepath.FromSlash(filename)), path)
+		}
+
+		data, err := snapshot.ReadFile("1_Pooling/config.json")
+		require.NoError(t, err)
+		require.JSONEq(t, `{"pooling_mode_mean_tokens": true}`, string(data))
+		_, err = snapshot.ReadFile("missing.txt")
+		require.ErrorContains(t, err, "missing.txt is not part of the snapshot of org/model")
+
+		snapshot, requests, err = download(t, "org/model", "", filenames)
+		require.NoError(t, err)
+		require.Equal(t, []string{"/org/model/resolve/main/config.json"}, requests, "Cached files are not downloaded again")
+		require.Len(t, snapshot.Files, 4)
+	})
+
+	t.Run("Revision", func(t *testing.T) {
+		snapshot, _, err := download(t, "org/model", "v1.0", []string{"config.json"}, WithHFRevision("main"))
+		require.NoError(t, err)
+		require.Equal(t, "v1.0", snapshot.Revision, "The revision argument takes precedence")
+		data, err := snapshot.ReadFile("config.json")
+		require.NoError(t, err)
+		require.JSONEq(t, `{"hidden_size": 768}`, string(data))
+	})
+
+	t.Run("Authentication", func(t *testing.T) {
+		_, _, err := download(t, "org/private-model", "", []string{"tokenizer.json"}, WithHFToken(""))
+		require.ErrorContains(t, err, "authentication required")
+
+		_, _, err = download(t, "org/private-model", "", []string{"tokenizer.json"}, WithHFToken("secret"))
+		require.NoError(t, err)
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		_, _, err := download(t, "org/model", "", []string{"config.json", "missing.bin"})
+		require.ErrorIs(t, err, ErrHFFileNotFound)
+		require.ErrorContains(t, err, "failed to download missing.bin")
+		require.NoFileExists(t, getHFCacheFilePath(cacheDir, "org/model", "main", "missing.bin"))
+	})
+
+	t.Run("Offline", func(t *testing.T) {
+		_, requests, err := download(t, "org/model", "", []string{"config.json"}, WithHFOfflineMode(true))
+		require.NoError(t, err)
+		require.Empty(t, requests)
+
+		_, requests, err = download(t, "org/model", "", []string{"merges.txt"}, WithHFOfflineMode(true))
+		require.ErrorContains(t, err, "offline mode enabled but merges.txt not found in cache")
+		require.Empty(t, requests)
+	})
+
+	t.Run("Invalid arguments", func(t *testing.T) {
+		for _, filename := range []string{"", "../config.json", "/etc/passwd", "a//b", "a/./b", `a\b`, "a\x00b"} {
+			_, requests, err := download(t, "org/model", "", []string{filename})
+			require.ErrorContains(t, err, "invalid filename", "filename %q", filename)
+			require.Empty(t, requests)
+		}
+
+		_, _, err := download(t, "org/model", "", nil)
+		require.ErrorContains(t, err, "no files to download")
+
+		_, _, err = download(t, "org/../model", "", []string{"config.json"})
+		require.ErrorContains(t, err, "invalid model ID")
+
+		_, _, err = download(t, "org/model", "../main", []string{"config.json"})
+		require.Error(t, err)
+	})
+
+	require.FileExists(t, getHFCacheFilePath(cacheDir, "org/model", "v1.0", "config.json"), "Revisions are cached separately")
+}
@@ -489,19 +489,19 @@ func TestCacheKeyGeneration(t *testing.T) {
 			name:        "Simple model",
 			modelID:     "bert-base",
 			revision:    "main",
-			expectedKey: "bert-base/main",
+			expectedKey: "bert-base/snapshots/main",
 		},
 		{
 			name:        "Model with org",
 			modelID:     "google/t5-small",
 			revision:    "v1.0",
-			expectedKey: "google--t5-small/v1.0",
+			expectedKey: "google--t5-small/snapshots/v1.0",
 		},
 		{
 			name:        "Model with commit hash",
 			modelID:     "model",
 			revision:    "abc123",
-			expectedKey: "model/abc123",
+			expectedKey: "model/snapshots/abc123",
 		},
 	}
 
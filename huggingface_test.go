@@ -99,14 +99,14 @@ func TestGetHFCachePath(t *testing.T) {
 			customDir:    "",
 			modelID:      "bert-base-uncased",
 			revision:     "main",
-			expectSubstr: filepath.Join("bert-base-uncased", "main", "tokenizer.json"),
+			expectSubstr: filepath.Join("bert-base-uncased", "snapshots", "main", "tokenizer.json"),
 		},
 		{
 			name:         "Model with organization",
 			customDir:    "",
 			modelID:      "google/flan-t5",
 			revision:     "v1.0",
-			expectSubstr: filepath.Join("google--flan-t5", "v1.0", "tokenizer.json"),
+			expectSubstr: filepath.Join("google--flan-t5", "snapshots", "v1.0", "tokenizer.json"),
 		},
 		{
 			name:         "Custom cache directory",
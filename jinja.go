@@ -0,0 +1,2770 @@
+package tokenizers
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// This file implements the subset of Jinja used by HuggingFace chat templates, with the
+// settings transformers renders them with: trim_blocks, lstrip_blocks and loop controls.
+// Values are nil (none), bool, int, float64, string, []any, *jinjaDict, *jinjaNamespace,
+// jinjaUndefined and callables.
+
+// maxJinjaRange bounds range() so that a template cannot allocate without limit.
+const maxJinjaRange = 1 << 20
+
+var (
+	errJinjaBreak    = errors.New("break outside of a loop")
+	errJinjaContinue = errors.New("continue outside of a loop")
+)
+
+type jinjaSegmentKind int
+
+const (
+	jinjaText jinjaSegmentKind = iota
+	jinjaOutput
+	jinjaStatement
+)
+
+// jinjaSegment is a piece of template source: text, the inside of {{ }} or of {% %}.
+type jinjaSegment struct {
+	kind jinjaSegmentKind
+	text string
+	line int
+}
+
+// splitJinjaTemplate splits src into segments, applying whitespace control and dropping
+// comments.
+func splitJinjaTemplate(src string) ([]jinjaSegment, error) {
+	var segments []jinjaSegment
+	trimNext, trimNewline := false, false
+	line := 1
+	for i := 0; ; {
+		start := nextJinjaTag(src, i)
+		end := start
+		if start < 0 {
+			end = len(src)
+		}
+		text := src[i:end]
+		if trimNext {
+			text = strings.TrimLeft(text, " \t\r\n")
+		} else if trimNewline {
+			if !strings.HasPrefix(text, "\r\n") {
+				text = strings.TrimPrefix(text, "\n")
+			} else {
+				text = text[2:]
+			}
+		}
+		trimNext, trimNewline = false, false
+		if start < 0 {
+			if text != "" {
+				segments = append(segments, jinjaSegment{kind: jinjaText, text: text, line: line})
+			}
+			return segments, nil
+		}
+		tagLine := line + strings.Count(src[i:start], "\n")
+
+		opener := src[start+1]
+		pos := start + 2
+		block := opener != '{' // Statements and comments
+		switch {
+		case pos < len(src) && src[pos] == '-':
+			text = strings.TrimRight(text, " \t\r\n")
+			pos++
+		case pos < len(src) && src[pos] == '+':
+			pos++
+		case block:
+			// lstrip_blocks: drop the indentation of a tag that starts its line.
+			k := 0
+			for start-k > 0 && (src[start-k-1] == ' ' || src[start-k-1] == '\t') {
+				k++
+			}
+			if start-k == 0 || src[start-k-1] == '\n' {
+				text = text[:len(text)-min(k, len(text))]
+			}
+		}
+		if text != "" {
+			segments = append(segments, jinjaSegment{kind: jinjaText, text: text, line: line})
+		}
+
+		closer := map[byte]string{'{': "}}", '%': "%}", '#': "#}"}[opener]
+		j := findJinjaCloser(src, pos, closer, opener != '#')
+		if j < 0 {
+			return nil, errors.Errorf("line %d: unclosed %s", tagLine, src[start:start+2])
+		}
+		inner := src[pos:j]
+		switch {
+		case strings.HasSuffix(inner, "-"):
+			inner = inner[:len(inner)-1]
+			trimNext = true
+		case strings.HasSuffix(inner, "+"):
+			inner = inner[:len(inner)-1]
+		case block:
+			trimNewline = true // trim_blocks
+		}
+		switch opener {
+		case '{':
+			segments = append(segments, jinjaSegment{kind: jinjaOutput, text: inner, line: tagLine})
+		case '%':
+			segments = append(segments, jinjaSegment{kind: jinjaStatement, text: inner, line: tagLine})
+		}
+		line = tagLine + strings.Count(src[start:j], "\n")
+		i = j + len(closer)
+	}
+}
+
+// nextJinjaTag returns the index of the next "{{", "{%" or "{#" from i, or -1.
+func nextJinjaTag(src string, i int) int {
+	for {
+		k := strings.IndexByte(src[i:], '{')
+		if k < 0 || i+k+1 >= len(src) {
+			return -1
+		}
+		switch src[i+k+1] {
+		case '{', '%', '#':
+			return i + k
+		}
+		i += k + 1
+	}
+}
+
+// findJinjaCloser returns the index of closer from pos, skipping string literals if
+// quoted is set, or -1.
+func findJinjaCloser(src string, pos int, closer string, quoted bool) int {
+	var quote byte
+	for j := pos; j < len(src); j++ {
+		c := src[j]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				j++
+			} else if c == quote {
+				quote = 0
+			}
+		case quoted && (c == '\'' || c == '"'):
+			quote = c
+		case strings.HasPrefix(src[j:], closer):
+			return j
+		}
+	}
+	return -1
+}
+
+// Expression tokens.
+
+type jinjaTokenKind int
+
+const (
+	jinjaTokEOF jinjaTokenKind = iota
+	jinjaTokName
+	jinjaTokString
+	jinjaTokInt
+	jinjaTokFloat
+	jinjaTokOp
+)
+
+type jinjaToken struct {
+	kind jinjaTokenKind
+	val  string
+}
+
+var jinjaOperators = []string{"**", "//", "==", "!=", "<=", ">=", "+", "-", "*", "/", "%", "~", "<", ">", "=", "(", ")", "[", "]", "{", "}", ".", ",", ":", "|"}
+
+func lexJinjaExpr(src string, line int) ([]jinjaToken, error) {
+	var toks []jinjaToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '_' || isASCIILetter(c):
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || isASCIILetter(src[j]) || isASCIIDigit(src[j])) {
+				j++
+			}
+			toks = append(toks, jinjaToken{jinjaTokName, src[i:j]})
+			i = j
+		case isASCIIDigit(c):
+			j := i
+			for j < len(src) && (isASCIIDigit(src[j]) || src[j] == '_') {
+				j++
+			}
+			kind := jinjaTokInt
+			if j+1 < len(src) && src[j] == '.' && isASCIIDigit(src[j+1]) {
+				kind = jinjaTokFloat
+				for j++; j < len(src) && (isASCIIDigit(src[j]) || src[j] == '_'); j++ {
+				}
+			}
+			if j < len(src) && (src[j] == 'e' || src[j] == 'E') {
+				k := j + 1
+				if k < len(src) && (src[k] == '+' || src[k] == '-') {
+					k++
+				}
+				if k < len(src) && isASCIIDigit(src[k]) {
+					kind = jinjaTokFloat
+					for j = k; j < len(src) && isASCIIDigit(src[j]); j++ {
+					}
+				}
+			}
+			toks = append(toks, jinjaToken{kind, strings.ReplaceAll(src[i:j], "_", "")})
+			i = j
+		case c == '\'' || c == '"':
+			s, n, err := unquoteJinjaString(src[i:])
+			if err != nil {
+				return nil, errors.Wrapf(err, "line %d", line)
+			}
+			toks = append(toks, jinjaToken{jinjaTokString, s})
+			i += n
+		default:
+			op := ""
+			for _, candidate := range jinjaOperators {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, errors.Errorf("line %d: unexpected character %q", line, c)
+			}
+			toks = append(toks, jinjaToken{jinjaTokOp, op})
+			i += len(op)
+		}
+	}
+	return append(toks, jinjaToken{kind: jinjaTokEOF}), nil
+}
+
+func isASCIILetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isASCIIDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// unquoteJinjaString decodes the string literal at the start of s, with Python escapes,
+// and returns it with the length of the literal.
+func unquoteJinjaString(s string) (string, int, error) {
+	quote := s[0]
+	var sb strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if c == quote {
+			return sb.String(), i + 1, nil
+		}
+		if c != '\\' || i+1 == len(s) {
+			sb.WriteByte(c)
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case 'r':
+			sb.WriteByte('\r')
+		case '0':
+			sb.WriteByte(0)
+		case '\\', '\'', '"':
+			sb.WriteByte(s[i])
+		case 'x', 'u', 'U':
+			size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[i]]
+			if i+size >= len(s) {
+				return "", 0, errors.New("truncated escape in string literal")
+			}
+			code, err := strconv.ParseUint(s[i+1:i+1+size], 16, 32)
+			if err != nil {
+				return "", 0, errors.Errorf("invalid escape \\%s in string literal", s[i:i+1+size])
+			}
+			sb.WriteRune(rune(code))
+			i += size
+		default:
+			sb.WriteByte('\\')
+			sb.WriteByte(s[i])
+		}
+	}
+	return "", 0, errors.New("unterminated string literal")
+}
+
+// Template parsing.
+
+// jinjaTemplate is a parsed template.
+type jinjaTemplate struct {
+	nodes []jinjaNode
+}
+
+func parseJinjaTemplate(src string) (*jinjaTemplate, error) {
+	segments, err := splitJinjaTemplate(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &jinjaTemplateParser{segments: segments}
+	nodes, _, _, err := p.parseBody(0)
+	if err != nil {
+		return nil, err
+	}
+	return &jinjaTemplate{nodes: nodes}, nil
+}
+
+type jinjaTemplateParser struct {
+	segments []jinjaSegment
+	pos      int
+}
+
+// parseBody parses nodes up to a statement with one of the keywords ends, and returns
+// the keyword with a parser for the rest of that statement. openLine is the line of the
+// statement the body belongs to.
+func (p *jinjaTemplateParser) parseBody(openLine int, ends ...string) ([]jinjaNode, string, *jinjaExprParser, error) {
+	var nodes []jinjaNode
+	for p.pos < len(p.segments) {
+		seg := p.segments[p.pos]
+		p.pos++
+		if seg.kind == jinjaText {
+			nodes = append(nodes, jinjaTextNode(seg.text))
+			continue
+		}
+		ep, err := newJinjaExprParser(seg.text, seg.line)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		if seg.kind == jinjaOutput {
+			expr, err := ep.parseExpr()
+			if err != nil {
+				return nil, "", nil, err
+			}
+			if err := ep.expectEnd(); err != nil {
+				return nil, "", nil, err
+			}
+			nodes = append(nodes, &jinjaOutputNode{expr: expr})
+			continue
+		}
+		keyword := ep.next()
+		if keyword.kind != jinjaTokName {
+			return nil, "", nil, ep.errorf("expected a tag name")
+		}
+		if slices.Contains(ends, keyword.val) {
+			return nodes, keyword.val, ep, nil
+		}
+		node, err := p.parseStatement(keyword.val, ep)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(ends) > 0 {
+		return nil, "", nil, errors.Errorf("line %d: missing {%% %s %%}", openLine, ends[len(ends)-1])
+	}
+	return nodes, "", nil, nil
+}
+
+func (p *jinjaTemplateParser) parseStatement(keyword string, ep *jinjaExprParser) (jinjaNode, error) {
+	switch keyword {
+	case "if":
+		node := &jinjaIfNode{}
+		cond, err := ep.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		for {
+			if err := ep.expectEnd(); err != nil {
+				return nil, err
+			}
+			body, end, next, err := p.parseBody(ep.line, "elif", "else", "endif")
+			if err != nil {
+				return nil, err
+			}
+			node.conds = append(node.conds, cond)
+			node.bodies = append(node.bodies, body)
+			ep = next
+			switch end {
+			case "elif":
+				if cond, err = ep.parseExpr(); err != nil {
+					return nil, err
+				}
+				continue
+			case "else":
+				if err := ep.expectEnd(); err != nil {
+					return nil, err
+				}
+				if node.elseBody, _, ep, err = p.parseBody(ep.line, "endif"); err != nil {
+					return nil, err
+				}
+			}
+			return node, ep.expectEnd()
+		}
+
+	case "for":
+		node := &jinjaForNode{}
+		for {
+			name := ep.next()
+			if name.kind != jinjaTokName {
+				return nil, ep.errorf("expected a loop variable")
+			}
+			node.targets = append(node.targets, name.val)
+			if !ep.accept(jinjaTokOp, ",") {
+				break
+			}
+		}
+		if !ep.accept(jinjaTokName, "in") {
+			return nil, ep.errorf("expected 'in'")
+		}
+		var err error
+		if node.iter, err = ep.parseOr(); err != nil {
+			return nil, err
+		}
+		if ep.accept(jinjaTokName, "if") {
+			if node.filter, err = ep.parseOr(); err != nil {
+				return nil, err
+			}
+		}
+		if err := ep.expectEnd(); err != nil {
+			return nil, err
+		}
+		body, end, next, err := p.parseBody(ep.line, "else", "endfor")
+		if err != nil {
+			return nil, err
+		}
+		node.body = body
+		if end == "else" {
+			if err := next.expectEnd(); err != nil {
+				return nil, err
+			}
+			if node.elseBody, _, next, err = p.parseBody(next.line, "endfor"); err != nil {
+				return nil, err
+			}
+		}
+		return node, next.expectEnd()
+
+	case "set":
+		node := &jinjaSetNode{}
+		name := ep.next()
+		if name.kind != jinjaTokName {
+			return nil, ep.errorf("expected a variable name")
+		}
+		node.name = name.val
+		if ep.accept(jinjaTokOp, ".") {
+			attr := ep.next()
+			if attr.kind != jinjaTokName {
+				return nil, ep.errorf("expected an attribute name")
+			}
+			node.attr = attr.val
+		}
+		if ep.accept(jinjaTokOp, "=") {
+			var err error
+			if node.value, err = ep.parseExpr(); err != nil {
+				return nil, err
+			}
+			return node, ep.expectEnd()
+		}
+		// {% set name %}...{% endset %} captures the rendered body.
+		if err := ep.expectEnd(); err != nil {
+			return nil, err
+		}
+		body, _, next, err := p.parseBody(ep.line, "endset")
+		if err != nil {
+			return nil, err
+		}
+		node.body = body
+		return node, next.expectEnd()
+
+	case "break", "continue":
+		if err := ep.expectEnd(); err != nil {
+			return nil, err
+		}
+		if keyword == "break" {
+			return jinjaBreakNode{}, nil
+		}
+		return jinjaContinueNode{}, nil
+
+	case "generation":
+		// Marks assistant output for return_assistant_tokens_mask; rendered as is.
+		if err := ep.expectEnd(); err != nil {
+			return nil, err
+		}
+		body, _, next, err := p.parseBody(ep.line, "endgeneration")
+		if err != nil {
+			return nil, err
+		}
+		return jinjaBlockNode(body), next.expectEnd()
+	}
+	return nil, ep.errorf("unsupported tag %q", keyword)
+}
+
+// jinjaExprParser parses the expressions of one tag.
+type jinjaExprParser struct {
+	toks []jinjaToken
+	pos  int
+	line int
+}
+
+func newJinjaExprParser(src string, line int) (*jinjaExprParser, error) {
+	toks, err := lexJinjaExpr(src, line)
+	if err != nil {
+		return nil, err
+	}
+	return &jinjaExprParser{toks: toks, line: line}, nil
+}
+
+func (ep *jinjaExprParser) peek() jinjaToken {
+	return ep.toks[ep.pos]
+}
+
+func (ep *jinjaExprParser) next() jinjaToken {
+	tok := ep.toks[ep.pos]
+	if tok.kind != jinjaTokEOF {
+		ep.pos++
+	}
+	return tok
+}
+
+func (ep *jinjaExprParser) is(kind jinjaTokenKind, val string) bool {
+	tok := ep.peek()
+	return tok.kind == kind && tok.val == val
+}
+
+func (ep *jinjaExprParser) accept(kind jinjaTokenKind, val string) bool {
+	if ep.is(kind, val) {
+		ep.pos++
+		return true
+	}
+	return false
+}
+
+func (ep *jinjaExprParser) expect(val string) error {
+	if !ep.accept(jinjaTokOp, val) {
+		return ep.errorf("expected %q", val)
+	}
+	return nil
+}
+
+func (ep *jinjaExprParser) expectEnd() error {
+	if ep.peek().kind != jinjaTokEOF {
+		return ep.errorf("unexpected %q", ep.peek().val)
+	}
+	return nil
+}
+
+func (ep *jinjaExprParser) errorf(format string, args ...any) error {
+	return errors.Errorf("line %d: %s", ep.line, fmt.Sprintf(format, args...))
+}
+
+// parseExpr parses a full expression, including "a if cond else b".
+func (ep *jinjaExprParser) parseExpr() (jinjaExpr, error) {
+	expr, err := ep.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !ep.accept(jinjaTokName, "if") {
+		return expr, nil
+	}
+	cond, err := ep.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	node := &jinjaCondExpr{cond: cond, yes: expr}
+	if ep.accept(jinjaTokName, "else") {
+		if node.no, err = ep.parseExpr(); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+func (ep *jinjaExprParser) parseOr() (jinjaExpr, error) {
+	left, err := ep.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for ep.accept(jinjaTokName, "or") {
+		right, err := ep.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &jinjaBinaryExpr{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (ep *jinjaExprParser) parseAnd() (jinjaExpr, error) {
+	left, err := ep.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for ep.accept(jinjaTokName, "and") {
+		right, err := ep.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &jinjaBinaryExpr{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (ep *jinjaExprParser) parseNot() (jinjaExpr, error) {
+	if ep.accept(jinjaTokName, "not") {
+		x, err := ep.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &jinjaUnaryExpr{op: "not", x: x}, nil
+	}
+	return ep.parseCompare()
+}
+
+func (ep *jinjaExprParser) parseCompare() (jinjaExpr, error) {
+	left, err := ep.parseConcat()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := ep.peek()
+		var op string
+		switch {
+		case tok.kind == jinjaTokOp && slices.Contains([]string{"==", "!=", "<", ">", "<=", ">="}, tok.val):
+			op = tok.val
+			ep.pos++
+		case ep.accept(jinjaTokName, "in"):
+			op = "in"
+		case ep.is(jinjaTokName, "not") && ep.toks[ep.pos+1].kind == jinjaTokName && ep.toks[ep.pos+1].val == "in":
+			ep.pos += 2
+			op = "not in"
+		case ep.accept(jinjaTokName, "is"):
+			if left, err = ep.parseTest(left); err != nil {
+				return nil, err
+			}
+			continue
+		default:
+			return left, nil
+		}
+		right, err := ep.parseConcat()
+		if err != nil {
+			return nil, err
+		}
+		left = &jinjaBinaryExpr{op: op, left: left, right: right}
+	}
+}
+
+// parseTest parses the test after "is", e.g. "not defined" or "divisibleby(3)".
+func (ep *jinjaExprParser) parseTest(x jinjaExpr) (jinjaExpr, error) {
+	node := &jinjaTestExpr{x: x, negate: ep.accept(jinjaTokName, "not")}
+	name := ep.next()
+	switch {
+	case name.kind == jinjaTokName:
+		node.name = name.val
+	case name.kind == jinjaTokOp && slices.Contains([]string{"==", "!=", "<", ">", "<=", ">="}, name.val):
+		node.name = name.val
+	default:
+		return nil, ep.errorf("expected a test name")
+	}
+	if ep.is(jinjaTokOp, "(") {
+		args, _, err := ep.parseArgs()
+		if err != nil {
+			return nil, err
+		}
+		node.args = args
+	} else if tok := ep.peek(); tok.kind == jinjaTokString || tok.kind == jinjaTokInt || tok.kind == jinjaTokFloat ||
+		(tok.kind == jinjaTokName && !slices.Contains([]string{"and", "or", "if", "else", "in", "is", "not"}, tok.val)) {
+		// A single argument without parentheses, e.g. "x is divisibleby 3".
+		arg, err := ep.parsePostfix()
+		if err != nil {
+			return nil, err
+		}
+		node.args = []jinjaExpr{arg}
+	}
+	return node, nil
+}
+
+func (ep *jinjaExprParser) parseConcat() (jinjaExpr, error) {
+	return ep.parseBinary([]string{"~"}, ep.parseAdd)
+}
+
+func (ep *jinjaExprParser) parseAdd() (jinjaExpr, error) {
+	return ep.parseBinary([]string{"+", "-"}, ep.parseMul)
+}
+
+func (ep *jinjaExprParser) parseMul() (jinjaExpr, error) {
+	return ep.parseBinary([]string{"*", "/", "//", "%"}, ep.parsePow)
+}
+
+func (ep *jinjaExprParser) parsePow() (jinjaExpr, error) {
+	return ep.parseBinary([]string{"**"}, ep.parseUnary)
+}
+
+func (ep *jinjaExprParser) parseBinary(ops []string, operand func() (jinjaExpr, error)) (jinjaExpr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for tok := ep.peek(); tok.kind == jinjaTokOp && slices.Contains(ops, tok.val); tok = ep.peek() {
+		ep.pos++
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &jinjaBinaryExpr{op: tok.val, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (ep *jinjaExprParser) parseUnary() (jinjaExpr, error) {
+	if tok := ep.peek(); tok.kind == jinjaTokOp && (tok.val == "-" || tok.val == "+") {
+		ep.pos++
+		x, err := ep.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &jinjaUnaryExpr{op: tok.val, x: x}, nil
+	}
+	return ep.parsePostfix()
+}
+
+// parsePostfix parses a primary expression followed by attributes, subscripts, calls and
+// filters.
+func (ep *jinjaExprParser) parsePostfix() (jinjaExpr, error) {
+	x, err := ep.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case ep.accept(jinjaTokOp, "."):
+			name := ep.next()
+			if name.kind != jinjaTokName && name.kind != jinjaTokInt {
+				return nil, ep.errorf("expected an attribute name")
+			}
+			x = &jinjaAttrExpr{x: x, name: name.val}
+		case ep.accept(jinjaTokOp, "["):
+			if x, err = ep.parseSubscript(x); err != nil {
+				return nil, err
+			}
+		case ep.is(jinjaTokOp, "("):
+			args, kwargs, err := ep.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			x = &jinjaCallExpr{fn: x, args: args, kwargs: kwargs}
+		case ep.accept(jinjaTokOp, "|"):
+			name := ep.next()
+			if name.kind != jinjaTokName {
+				return nil, ep.errorf("expected a filter name")
+			}
+			node := &jinjaFilterExpr{x: x, name: name.val}
+			if ep.is(jinjaTokOp, "(") {
+				if node.args, node.kwargs, err = ep.parseArgs(); err != nil {
+					return nil, err
+				}
+			}
+			x = node
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (ep *jinjaExprParser) parseSubscript(x jinjaExpr) (jinjaExpr, error) {
+	var parts [3]jinjaExpr
+	colons := 0
+	for {
+		if !ep.is(jinjaTokOp, ":") && !ep.is(jinjaTokOp, "]") {
+			part, err := ep.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			parts[colons] = part
+		}
+		if ep.accept(jinjaTokOp, "]") {
+			break
+		}
+		if colons == 2 || !ep.accept(jinjaTokOp, ":") {
+			return nil, ep.errorf("expected ']'")
+		}
+		colons++
+	}
+	if colons == 0 {
+		if parts[0] == nil {
+			return nil, ep.errorf("expected an index")
+		}
+		return &jinjaIndexExpr{x: x, index: parts[0]}, nil
+	}
+	return &jinjaSliceExpr{x: x, start: parts[0], stop: parts[1], step: parts[2]}, nil
+}
+
+// parseArgs parses a parenthesized argument list with positional and keyword arguments.
+func (ep *jinjaExprParser) parseArgs() ([]jinjaExpr, []jinjaKwarg, error) {
+	if err := ep.expect("("); err != nil {
+		return nil, nil, err
+	}
+	var args []jinjaExpr
+	var kwargs []jinjaKwarg
+	for !ep.accept(jinjaTokOp, ")") {
+		if len(args)+len(kwargs) > 0 {
+			if err := ep.expect(","); err != nil {
+				return nil, nil, err
+			}
+			if ep.accept(jinjaTokOp, ")") {
+				break
+			}
+		}
+		if ep.peek().kind == jinjaTokName && ep.toks[ep.pos+1].kind == jinjaTokOp && ep.toks[ep.pos+1].val == "=" {
+			name := ep.next().val
+			ep.pos++
+			value, err := ep.parseExpr()
+			if err != nil {
+				return nil, nil, err
+			}
+			kwargs = append(kwargs, jinjaKwarg{name: name, value: value})
+			continue
+		}
+		if len(kwargs) > 0 {
+			return nil, nil, ep.errorf("positional argument after keyword argument")
+		}
+		arg, err := ep.parseExpr()
+		if err != nil {
+			return nil, nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, kwargs, nil
+}
+
+func (ep *jinjaExprParser) parsePrimary() (jinjaExpr, error) {
+	tok := ep.next()
+	switch tok.kind {
+	case jinjaTokString:
+		// Adjacent string literals are concatenated, as in Python.
+		s := tok.val
+		for ep.peek().kind == jinjaTokString {
+			s += ep.next().val
+		}
+		return jinjaLiteral{s}, nil
+	case jinjaTokInt:
+		n, err := strconv.Atoi(tok.val)
+		if err != nil {
+			return nil, ep.errorf("invalid integer %s", tok.val)
+		}
+		return jinjaLiteral{n}, nil
+	case jinjaTokFloat:
+		f, err := strconv.ParseFloat(tok.val, 64)
+		if err != nil {
+			return nil, ep.errorf("invalid number %s", tok.val)
+		}
+		return jinjaLiteral{f}, nil
+	case jinjaTokName:
+		switch tok.val {
+		case "true", "True":
+			return jinjaLiteral{true}, nil
+		case "false", "False":
+			return jinjaLiteral{false}, nil
+		case "none", "None":
+			return jinjaLiteral{nil}, nil
+		}
+		return jinjaName(tok.val), nil
+	case jinjaTokOp:
+		switch tok.val {
+		case "(":
+			x, err := ep.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if ep.is(jinjaTokOp, ",") {
+				// A tuple, which behaves as a list.
+				items := []jinjaExpr{x}
+				for ep.accept(jinjaTokOp, ",") && !ep.is(jinjaTokOp, ")") {
+					item, err := ep.parseExpr()
+					if err != nil {
+						return nil, err
+					}
+					items = append(items, item)
+				}
+				x = jinjaListExpr(items)
+			}
+			return x, ep.expect(")")
+		case "[":
+			var items []jinjaExpr
+			for !ep.accept(jinjaTokOp, "]") {
+				if len(items) > 0 {
+					if err := ep.expect(","); err != nil {
+						return nil, err
+					}
+					if ep.accept(jinjaTokOp, "]") {
+						break
+					}
+				}
+				item, err := ep.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+			}
+			return jinjaListExpr(items), nil
+		case "{":
+			node := &jinjaDictExpr{}
+			for !ep.accept(jinjaTokOp, "}") {
+				if len(node.keys) > 0 {
+					if err := ep.expect(","); err != nil {
+						return nil, err
+					}
+					if ep.accept(jinjaTokOp, "}") {
+						break
+					}
+				}
+				key, err := ep.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				if err := ep.expect(":"); err != nil {
+					return nil, err
+				}
+				value, err := ep.parseExpr()
+				if err != nil {
+					return nil, err
+				}
+				node.keys = append(node.keys, key)
+				node.values = append(node.values, value)
+			}
+			return node, nil
+		}
+	case jinjaTokEOF:
+		return nil, ep.errorf("unexpected end of expression")
+	}
+	return nil, ep.errorf("unexpected %q", tok.val)
+}
+
+// Statement nodes.
+
+type jinjaNode interface {
+	render(r *jinjaRenderer) error
+}
+
+type jinjaTextNode string
+
+type jinjaOutputNode struct {
+	expr jinjaExpr
+}
+
+type jinjaIfNode struct {
+	conds    []jinjaExpr
+	bodies   [][]jinjaNode
+	elseBody []jinjaNode
+}
+
+type jinjaForNode struct {
+	targets  []string
+	iter     jinjaExpr
+	filter   jinjaExpr
+	body     []jinjaNode
+	elseBody []jinjaNode
+}
+
+type jinjaSetNode struct {
+	name  string
+	attr  string // Set for "set ns.attr = value"
+	value jinjaExpr
+	body  []jinjaNode // Set for block assignments
+}
+
+type jinjaBreakNode struct{}
+
+type jinjaContinueNode struct{}
+
+type jinjaBlockNode []jinjaNode
+
+// jinjaRenderer holds the state of one rendering.
+type jinjaRenderer struct {
+	out   strings.Builder
+	scope *jinjaScope
+}
+
+// jinjaScope holds variables; for loop bodies get their own scope, so that assignments
+// in them do not leak, as in Jinja.
+type jinjaScope struct {
+	vars   map[string]any
+	parent *jinjaScope
+}
+
+func (s *jinjaScope) lookup(name string) (any, bool) {
+	for ; s != nil; s = s.parent {
+		if v, ok := s.vars[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// render renders the template with vars.
+func (tmpl *jinjaTemplate) render(vars map[string]any) (string, error) {
+	globals := map[string]any{
+		"range":     jinjaFunc(jinjaRange),
+		"namespace": jinjaFunc(jinjaNewNamespace),
+		"dict":      jinjaFunc(jinjaNewDict),
+	}
+	r := &jinjaRenderer{scope: &jinjaScope{vars: vars, parent: &jinjaScope{vars: globals}}}
+	if err := r.renderNodes(tmpl.nodes); err != nil {
+		return "", err
+	}
+	return r.out.String(), nil
+}
+
+func (r *jinjaRenderer) renderNodes(nodes []jinjaNode) error {
+	for _, node := range nodes {
+		if err := node.render(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n jinjaTextNode) render(r *jinjaRenderer) error {
+	r.out.WriteString(string(n))
+	return nil
+}
+
+func (n *jinjaOutputNode) render(r *jinjaRenderer) error {
+	v, err := n.expr.eval(r)
+	if err != nil {
+		return err
+	}
+	r.out.WriteString(jinjaString(v))
+	return nil
+}
+
+func (n *jinjaIfNode) render(r *jinjaRenderer) error {
+	for i, cond := range n.conds {
+		v, err := cond.eval(r)
+		if err != nil {
+			return err
+		}
+		if jinjaTruthy(v) {
+			return r.renderNodes(n.bodies[i])
+		}
+	}
+	return r.renderNodes(n.elseBody)
+}
+
+func (n *jinjaForNode) render(r *jinjaRenderer) error {
+	iterable, err := n.iter.eval(r)
+	if err != nil {
+		return err
+	}
+	items, err := jinjaIterate(iterable)
+	if err != nil {
+		return err
+	}
+	outer := r.scope
+	defer func() {
+		r.scope = outer
+	}()
+
+	if n.filter != nil {
+		var kept []any
+		for _, item := range items {
+			r.scope = &jinjaScope{vars: map[string]any{}, parent: outer}
+			if err := n.bindTargets(r.scope, item); err != nil {
+				return err
+			}
+			v, err := n.filter.eval(r)
+			if err != nil {
+				return err
+			}
+			if jinjaTruthy(v) {
+				kept = append(kept, item)
+			}
+		}
+		items = kept
+	}
+	if len(items) == 0 {
+		r.scope = outer
+		return r.renderNodes(n.elseBody)
+	}
+
+	for i, item := range items {
+		loop := newJinjaDict()
+		loop.set("index", i+1)
+		loop.set("index0", i)
+		loop.set("revindex", len(items)-i)
+		loop.set("revindex0", len(items)-i-1)
+		loop.set("first", i == 0)
+		loop.set("last", i == len(items)-1)
+		loop.set("length", len(items))
+		if i > 0 {
+			loop.set("previtem", items[i-1])
+		}
+		if i < len(items)-1 {
+			loop.set("nextitem", items[i+1])
+		}
+		r.scope = &jinjaScope{vars: map[string]any{"loop": loop}, parent: outer}
+		if err := n.bindTargets(r.scope, item); err != nil {
+			return err
+		}
+		err := r.renderNodes(n.body)
+		if errors.Is(err, errJinjaBreak) {
+			break
+		}
+		if err != nil && !errors.Is(err, errJinjaContinue) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *jinjaForNode) bindTargets(scope *jinjaScope, item any) error {
+	if len(n.targets) == 1 {
+		scope.vars[n.targets[0]] = item
+		return nil
+	}
+	values, err := jinjaIterate(item)
+	if err != nil {
+		return err
+	}
+	if len(values) != len(n.targets) {
+		return errors.Errorf("cannot unpack %d values into %d loop variables", len(values), len(n.targets))
+	}
+	for i, target := range n.targets {
+		scope.vars[target] = values[i]
+	}
+	return nil
+}
+
+func (n *jinjaSetNode) render(r *jinjaRenderer) error {
+	var value any
+	if n.body != nil {
+		outer := r.out
+		r.out = strings.Builder{}
+		err := r.renderNodes(n.body)
+		value = r.out.String()
+		r.out = outer
+		if err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if value, err = n.value.eval(r); err != nil {
+			return err
+		}
+	}
+	if n.attr == "" {
+		r.scope.vars[n.name] = value
+		return nil
+	}
+	target, _ := r.scope.lookup(n.name)
+	ns, ok := target.(*jinjaNamespace)
+	if !ok {
+		return errors.Errorf("cannot set attribute %q of %s: only namespace attributes can be set", n.attr, jinjaTypeName(target))
+	}
+	ns.attrs.set(n.attr, value)
+	return nil
+}
+
+func (jinjaBreakNode) render(*jinjaRenderer) error {
+	return errJinjaBreak
+}
+
+func (jinjaContinueNode) render(*jinjaRenderer) error {
+	return errJinjaContinue
+}
+
+func (n jinjaBlockNode) render(r *jinjaRenderer) error {
+	return r.renderNodes(n)
+}
+
+// Expressions.
+
+type jinjaExpr interface {
+	eval(r *jinjaRenderer) (any, error)
+}
+
+type jinjaLiteral struct {
+	value any
+}
+
+type jinjaName string
+
+type jinjaListExpr []jinjaExpr
+
+type jinjaDictExpr struct {
+	keys   []jinjaExpr
+	values []jinjaExpr
+}
+
+type jinjaAttrExpr struct {
+	x    jinjaExpr
+	name string
+}
+
+type jinjaIndexExpr struct {
+	x     jinjaExpr
+	index jinjaExpr
+}
+
+type jinjaSliceExpr struct {
+	x                 jinjaExpr
+	start, stop, step jinjaExpr
+}
+
+type jinjaKwarg struct {
+	name  string
+	value jinjaExpr
+}
+
+type jinjaCallExpr struct {
+	fn     jinjaExpr
+	args   []jinjaExpr
+	kwargs []jinjaKwarg
+}
+
+type jinjaFilterExpr struct {
+	x      jinjaExpr
+	name   string
+	args   []jinjaExpr
+	kwargs []jinjaKwarg
+}
+
+type jinjaTestExpr struct {
+	x      jinjaExpr
+	name   string
+	args   []jinjaExpr
+	negate bool
+}
+
+type jinjaUnaryExpr struct {
+	op string
+	x  jinjaExpr
+}
+
+type jinjaBinaryExpr struct {
+	op          string
+	left, right jinjaExpr
+}
+
+type jinjaCondExpr struct {
+	cond, yes, no jinjaExpr
+}
+
+func (e jinjaLiteral) eval(*jinjaRenderer) (any, error) {
+	return e.value, nil
+}
+
+func (e jinjaName) eval(r *jinjaRenderer) (any, error) {
+	if v, ok := r.scope.lookup(string(e)); ok {
+		return v, nil
+	}
+	return jinjaUndefined{name: string(e)}, nil
+}
+
+func (e jinjaListExpr) eval(r *jinjaRenderer) (any, error) {
+	items := make([]any, len(e))
+	for i, item := range e {
+		v, err := item.eval(r)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = v
+	}
+	return items, nil
+}
+
+func (e *jinjaDictExpr) eval(r *jinjaRenderer) (any, error) {
+	d := newJinjaDict()
+	for i, keyExpr := range e.keys {
+		key, err := keyExpr.eval(r)
+		if err != nil {
+			return nil, err
+		}
+		k, ok := key.(string)
+		if !ok {
+			return nil, errors.Errorf("dict keys must be strings, not %s", jinjaTypeName(key))
+		}
+		v, err := e.values[i].eval(r)
+		if err != nil {
+			return nil, err
+		}
+		d.set(k, v)
+	}
+	return d, nil
+}
+
+func (e *jinjaAttrExpr) eval(r *jinjaRenderer) (any, error) {
+	x, err := e.x.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	return jinjaGetAttr(x, e.name)
+}
+
+func (e *jinjaIndexExpr) eval(r *jinjaRenderer) (any, error) {
+	x, err := e.x.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	index, err := e.index.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	return jinjaGetItem(x, index)
+}
+
+func (e *jinjaSliceExpr) eval(r *jinjaRenderer) (any, error) {
+	x, err := e.x.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	var bounds [3]*int
+	for i, part := range []jinjaExpr{e.start, e.stop, e.step} {
+		if part == nil {
+			continue
+		}
+		v, err := part.eval(r)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			continue
+		}
+		n, ok := v.(int)
+		if !ok {
+			return nil, errors.Errorf("slice indices must be integers, not %s", jinjaTypeName(v))
+		}
+		bounds[i] = &n
+	}
+	switch x := x.(type) {
+	case []any:
+		return jinjaSlice(x, bounds)
+	case string:
+		runes, err := jinjaSlice([]rune(x), bounds)
+		return string(runes), err
+	case jinjaUndefined:
+		return nil, x.error()
+	}
+	return nil, errors.Errorf("%s cannot be sliced", jinjaTypeName(x))
+}
+
+// jinjaSlice implements Python slicing of s with optional start, stop and step.
+func jinjaSlice[T any](s []T, bounds [3]*int) ([]T, error) {
+	step := 1
+	if bounds[2] != nil {
+		step = *bounds[2]
+	}
+	if step == 0 {
+		return nil, errors.New("slice step cannot be zero")
+	}
+	n := len(s)
+	clamp := func(i *int, def, lower, upper int) int {
+		if i == nil {
+			return def
+		}
+		v := *i
+		if v < 0 {
+			v += n
+		}
+		return max(lower, min(v, upper))
+	}
+	var out []T
+	if step > 0 {
+		start, stop := clamp(bounds[0], 0, 0, n), clamp(bounds[1], n, 0, n)
+		for i := start; i < stop; i += step {
+			out = append(out, s[i])
+		}
+	} else {
+		start, stop := clamp(bounds[0], n-1, -1, n-1), clamp(bounds[1], -1, -1, n-1)
+		if bounds[1] != nil && *bounds[1] < -n {
+			stop = -1
+		}
+		for i := start; i > stop; i += step {
+			out = append(out, s[i])
+		}
+	}
+	if out == nil {
+		out = []T{}
+	}
+	return out, nil
+}
+
+func (e *jinjaCallExpr) eval(r *jinjaRenderer) (any, error) {
+	fn, err := e.fn.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	args, kwargs, err := evalJinjaArgs(r, e.args, e.kwargs)
+	if err != nil {
+		return nil, err
+	}
+	switch fn := fn.(type) {
+	case jinjaFunc:
+		return fn(args, kwargs)
+	case jinjaMethod:
+		return fn.call(args, kwargs)
+	case jinjaUndefined:
+		return nil, fn.error()
+	}
+	return nil, errors.Errorf("%s is not callable", jinjaTypeName(fn))
+}
+
+func evalJinjaArgs(r *jinjaRenderer, argExprs []jinjaExpr, kwargExprs []jinjaKwarg) ([]any, map[string]any, error) {
+	args := make([]any, len(argExprs))
+	for i, arg := range argExprs {
+		v, err := arg.eval(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		args[i] = v
+	}
+	kwargs := make(map[string]any, len(kwargExprs))
+	for _, kwarg := range kwargExprs {
+		v, err := kwarg.value.eval(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		kwargs[kwarg.name] = v
+	}
+	return args, kwargs, nil
+}
+
+func (e *jinjaFilterExpr) eval(r *jinjaRenderer) (any, error) {
+	x, err := e.x.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	args, kwargs, err := evalJinjaArgs(r, e.args, e.kwargs)
+	if err != nil {
+		return nil, err
+	}
+	v, err := applyJinjaFilter(e.name, x, args, kwargs)
+	return v, errors.Wrapf(err, "filter %s", e.name)
+}
+
+func (e *jinjaTestExpr) eval(r *jinjaRenderer) (any, error) {
+	x, err := e.x.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	args, _, err := evalJinjaArgs(r, e.args, nil)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := applyJinjaTest(e.name, x, args)
+	if err != nil {
+		return nil, err
+	}
+	return ok != e.negate, nil
+}
+
+func (e *jinjaUnaryExpr) eval(r *jinjaRenderer) (any, error) {
+	x, err := e.x.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "not":
+		return !jinjaTruthy(x), nil
+	case "+":
+		if _, ok := jinjaNumber(x); ok {
+			return x, nil
+		}
+	case "-":
+		switch x := x.(type) {
+		case int:
+			return -x, nil
+		case float64:
+			return -x, nil
+		case bool:
+			if x {
+				return -1, nil
+			}
+			return 0, nil
+		}
+	}
+	return nil, errors.Errorf("bad operand type for unary %s: %s", e.op, jinjaTypeName(x))
+}
+
+func (e *jinjaBinaryExpr) eval(r *jinjaRenderer) (any, error) {
+	left, err := e.left.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "and":
+		if !jinjaTruthy(left) {
+			return left, nil
+		}
+		return e.right.eval(r)
+	case "or":
+		if jinjaTruthy(left) {
+			return left, nil
+		}
+		return e.right.eval(r)
+	}
+	right, err := e.right.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	return jinjaBinaryOp(e.op, left, right)
+}
+
+func (e *jinjaCondExpr) eval(r *jinjaRenderer) (any, error) {
+	cond, err := e.cond.eval(r)
+	if err != nil {
+		return nil, err
+	}
+	if jinjaTruthy(cond) {
+		return e.yes.eval(r)
+	}
+	if e.no == nil {
+		return jinjaUndefined{}, nil
+	}
+	return e.no.eval(r)
+}
+
+// Values.
+
+// jinjaUndefined is the value of a missing variable, key or attribute. It renders as an
+// empty string, is false and iterates as an empty sequence; other uses fail.
+type jinjaUndefined struct {
+	name string
+}
+
+func (u jinjaUndefined) error() error {
+	if u.name == "" {
+		return errors.New("value is undefined")
+	}
+	return errors.Errorf("%q is undefined", u.name)
+}
+
+// jinjaDict is a dict that keeps the insertion order of its keys, as Python does.
+type jinjaDict struct {
+	keys   []string
+	values map[string]any
+}
+
+func newJinjaDict() *jinjaDict {
+	return &jinjaDict{values: map[string]any{}}
+}
+
+func (d *jinjaDict) set(key string, value any) {
+	if _, ok := d.values[key]; !ok {
+		d.keys = append(d.keys, key)
+	}
+	d.values[key] = value
+}
+
+func (d *jinjaDict) get(key string) (any, bool) {
+	v, ok := d.values[key]
+	return v, ok
+}
+
+// jinjaNamespace is the mutable object created by namespace().
+type jinjaNamespace struct {
+	attrs *jinjaDict
+}
+
+type jinjaFunc func(args []any, kwargs map[string]any) (any, error)
+
+// jinjaMethod is a method bound to a string or dict, e.g. message.content.strip.
+type jinjaMethod struct {
+	recv any
+	name string
+}
+
+func jinjaGetAttr(x any, name string) (any, error) {
+	switch x := x.(type) {
+	case *jinjaDict:
+		if v, ok := x.get(name); ok {
+			return v, nil
+		}
+		if slices.Contains([]string{"items", "keys", "values", "get"}, name) {
+			return jinjaMethod{recv: x, name: name}, nil
+		}
+	case *jinjaNamespace:
+		if v, ok := x.attrs.get(name); ok {
+			return v, nil
+		}
+	case string:
+		if slices.Contains(jinjaStringMethods, name) {
+			return jinjaMethod{recv: x, name: name}, nil
+		}
+	case jinjaUndefined:
+		return nil, x.error()
+	}
+	return jinjaUndefined{name: name}, nil
+}
+
+func jinjaGetItem(x, key any) (any, error) {
+	switch x := x.(type) {
+	case *jinjaDict:
+		if k, ok := key.(string); ok {
+			if v, ok := x.get(k); ok {
+				return v, nil
+			}
+			return jinjaUndefined{name: k}, nil
+		}
+	case *jinjaNamespace:
+		if k, ok := key.(string); ok {
+			return jinjaGetAttr(x, k)
+		}
+	case []any:
+		if i, ok := key.(int); ok {
+			if i < 0 {
+				i += len(x)
+			}
+			if i < 0 || i >= len(x) {
+				return jinjaUndefined{}, nil
+			}
+			return x[i], nil
+		}
+	case string:
+		if i, ok := key.(int); ok {
+			runes := []rune(x)
+			if i < 0 {
+				i += len(runes)
+			}
+			if i < 0 || i >= len(runes) {
+				return jinjaUndefined{}, nil
+			}
+			return string(runes[i]), nil
+		}
+	case jinjaUndefined:
+		return nil, x.error()
+	}
+	return jinjaUndefined{name: jinjaString(key)}, nil
+}
+
+var jinjaStringMethods = []string{
+	"strip", "lstrip", "rstrip", "upper", "lower", "title", "capitalize",
+	"startswith", "endswith", "split", "replace", "join", "count", "find",
+}
+
+func (m jinjaMethod) call(args []any, kwargs map[string]any) (any, error) {
+	switch recv := m.recv.(type) {
+	case *jinjaDict:
+		switch m.name {
+		case "items":
+			items := make([]any, len(recv.keys))
+			for i, k := range recv.keys {
+				items[i] = []any{k, recv.values[k]}
+			}
+			return items, nil
+		case "keys":
+			keys := make([]any, len(recv.keys))
+			for i, k := range recv.keys {
+				keys[i] = k
+			}
+			return keys, nil
+		case "values":
+			values := make([]any, len(recv.keys))
+			for i, k := range recv.keys {
+				values[i] = recv.values[k]
+			}
+			return values, nil
+		case "get":
+			if len(args) == 0 || len(args) > 2 {
+				return nil, errors.New("get() takes 1 or 2 arguments")
+			}
+			if k, ok := args[0].(string); ok {
+				if v, ok := recv.get(k); ok {
+					return v, nil
+				}
+			}
+			if len(args) == 2 {
+				return args[1], nil
+			}
+			return nil, nil
+		}
+	case string:
+		return jinjaStringMethod(recv, m.name, args)
+	}
+	return nil, errors.Errorf("unknown method %s", m.name)
+}
+
+func jinjaStringMethod(s, name string, args []any) (any, error) {
+	strArg := func(i int) (string, bool, error) {
+		if i >= len(args) || args[i] == nil {
+			return "", false, nil
+		}
+		v, ok := args[i].(string)
+		if !ok {
+			return "", false, errors.Errorf("%s() argument must be a string, not %s", name, jinjaTypeName(args[i]))
+		}
+		return v, true, nil
+	}
+	switch name {
+	case "strip", "lstrip", "rstrip":
+		chars, ok, err := strArg(0)
+		if err != nil {
+			return nil, err
+		}
+		return jinjaStrip(s, name, chars, ok), nil
+	case "upper":
+		return strings.ToUpper(s), nil
+	case "lower":
+		return strings.ToLower(s), nil
+	case "title":
+		return jinjaTitle(s), nil
+	case "capitalize":
+		return jinjaCapitalize(s), nil
+	case "startswith", "endswith":
+		if len(args) != 1 {
+			return nil, errors.Errorf("%s() takes exactly one argument", name)
+		}
+		candidates := []any{args[0]}
+		if list, ok := args[0].([]any); ok {
+			candidates = list
+		}
+		for _, candidate := range candidates {
+			affix, ok := candidate.(string)
+			if !ok {
+				return nil, errors.Errorf("%s() argument must be a string, not %s", name, jinjaTypeName(candidate))
+			}
+			if (name == "startswith" && strings.HasPrefix(s, affix)) || (name == "endswith" && strings.HasSuffix(s, affix)) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "split":
+		sep, ok, err := strArg(0)
+		if err != nil {
+			return nil, err
+		}
+		limit := -1
+		if len(args) > 1 {
+			n, isInt := args[1].(int)
+			if !isInt {
+				return nil, errors.New("split() maxsplit must be an integer")
+			}
+			if n >= 0 {
+				limit = n + 1
+			}
+		}
+		var parts []string
+		switch {
+		case !ok:
+			parts = strings.Fields(s)
+			if limit > 0 && len(parts) > limit {
+				// Keep the remainder of the string in the last part, as Python does.
+				rest := s
+				parts = parts[:0]
+				for len(parts) < limit-1 {
+					rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+					end := strings.IndexFunc(rest, unicode.IsSpace)
+					parts = append(parts, rest[:end])
+					rest = rest[end:]
+				}
+				parts = append(parts, strings.TrimLeftFunc(rest, unicode.IsSpace))
+			}
+		case sep == "":
+			return nil, errors.New("split() separator cannot be empty")
+		default:
+			parts = strings.SplitN(s, sep, limit)
+		}
+		out := make([]any, len(parts))
+		for i, part := range parts {
+			out[i] = part
+		}
+		return out, nil
+	case "replace":
+		old, okOld, err := strArg(0)
+		if err != nil {
+			return nil, err
+		}
+		replacement, okNew, err := strArg(1)
+		if err != nil {
+			return nil, err
+		}
+		if !okOld || !okNew {
+			return nil, errors.New("replace() takes 2 or 3 arguments")
+		}
+		count := -1
+		if len(args) > 2 {
+			n, isInt := args[2].(int)
+			if !isInt {
+				return nil, errors.New("replace() count must be an integer")
+			}
+			count = n
+		}
+		return strings.Replace(s, old, replacement, count), nil
+	case "join":
+		if len(args) != 1 {
+			return nil, errors.New("join() takes exactly one argument")
+		}
+		return jinjaJoin(args[0], s)
+	case "count", "find":
+		sub, ok, err := strArg(0)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errors.Errorf("%s() takes exactly one argument", name)
+		}
+		if name == "count" {
+			return strings.Count(s, sub), nil
+		}
+		if i := strings.Index(s, sub); i >= 0 {
+			return utf8.RuneCountInString(s[:i]), nil
+		}
+		return -1, nil
+	}
+	return nil, errors.Errorf("unknown method %s", name)
+}
+
+func jinjaStrip(s, name, chars string, custom bool) string {
+	cut := unicode.IsSpace
+	if custom {
+		cut = func(r rune) bool { return strings.ContainsRune(chars, r) }
+	}
+	switch name {
+	case "lstrip":
+		return strings.TrimLeftFunc(s, cut)
+	case "rstrip":
+		return strings.TrimRightFunc(s, cut)
+	}
+	return strings.TrimFunc(s, cut)
+}
+
+// jinjaTitle uppercases the first letter of every run of letters, as Python's str.title.
+func jinjaTitle(s string) string {
+	var sb strings.Builder
+	prevLetter := false
+	for _, r := range s {
+		if prevLetter {
+			sb.WriteRune(unicode.ToLower(r))
+		} else {
+			sb.WriteRune(unicode.ToTitle(r))
+		}
+		prevLetter = unicode.IsLetter(r)
+	}
+	return sb.String()
+}
+
+func jinjaCapitalize(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return s
+	}
+	return string(unicode.ToTitle(r)) + strings.ToLower(s[size:])
+}
+
+func jinjaJoin(v any, sep string) (any, error) {
+	items, err := jinjaIterate(v)
+	if err != nil {
+		return nil, err
+	}
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = jinjaString(item)
+	}
+	return strings.Join(parts, sep), nil
+}
+
+// jinjaTruthy returns the truth value of v, as in Python.
+func jinjaTruthy(v any) bool {
+	switch v := v.(type) {
+	case nil, jinjaUndefined:
+		return false
+	case bool:
+		return v
+	case int:
+		return v != 0
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []any:
+		return len(v) > 0
+	case *jinjaDict:
+		return len(v.keys) > 0
+	}
+	return true
+}
+
+// jinjaString converts v to a string, as Python's str does.
+func jinjaString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "None"
+	case jinjaUndefined:
+		return ""
+	case bool:
+		if v {
+			return "True"
+		}
+		return "False"
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return jinjaFormatFloat(v)
+	case string:
+		return v
+	}
+	return jinjaRepr(v)
+}
+
+// jinjaRepr returns the Python representation of v, used for strings inside containers.
+func jinjaRepr(v any) string {
+	switch v := v.(type) {
+	case string:
+		return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`, "\n", `\n`, "\t", `\t`, "\r", `\r`).Replace(v) + "'"
+	case []any:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = jinjaRepr(item)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case *jinjaDict:
+		parts := make([]string, len(v.keys))
+		for i, k := range v.keys {
+			parts[i] = jinjaRepr(k) + ": " + jinjaRepr(v.values[k])
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	case *jinjaNamespace:
+		return "<Namespace " + jinjaRepr(v.attrs) + ">"
+	case jinjaFunc, jinjaMethod:
+		return "<function>"
+	}
+	return jinjaString(v)
+}
+
+// jinjaFormatFloat formats f as Python's repr does.
+func jinjaFormatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	if abs := math.Abs(f); abs != 0 && (abs >= 1e16 || abs < 1e-4) {
+		return strconv.FormatFloat(f, 'e', -1, 64)
+	}
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+func jinjaTypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "NoneType"
+	case jinjaUndefined:
+		return "Undefined"
+	case bool:
+		return "bool"
+	case int:
+		return "int"
+	case float64:
+		return "float"
+	case string:
+		return "str"
+	case []any:
+		return "list"
+	case *jinjaDict:
+		return "dict"
+	case *jinjaNamespace:
+		return "Namespace"
+	}
+	return "function"
+}
+
+func jinjaNumber(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// jinjaIterate returns the items of a list, the characters of a string or the keys of a
+// dict.
+func jinjaIterate(v any) ([]any, error) {
+	switch v := v.(type) {
+	case []any:
+		return v, nil
+	case string:
+		items := make([]any, 0, len(v))
+		for _, r := range v {
+			items = append(items, string(r))
+		}
+		return items, nil
+	case *jinjaDict:
+		items := make([]any, len(v.keys))
+		for i, k := range v.keys {
+			items[i] = k
+		}
+		return items, nil
+	case jinjaUndefined:
+		return nil, nil
+	}
+	return nil, errors.Errorf("%s is not iterable", jinjaTypeName(v))
+}
+
+func jinjaEqual(a, b any) bool {
+	if x, ok := jinjaNumber(a); ok {
+		if y, ok := jinjaNumber(b); ok {
+			return x == y
+		}
+		return false
+	}
+	switch a := a.(type) {
+	case nil:
+		return b == nil
+	case string:
+		s, ok := b.(string)
+		return ok && a == s
+	case []any:
+		other, ok := b.([]any)
+		if !ok || len(a) != len(other) {
+			return false
+		}
+		for i := range a {
+			if !jinjaEqual(a[i], other[i]) {
+				return false
+			}
+		}
+		return true
+	case *jinjaDict:
+		other, ok := b.(*jinjaDict)
+		if !ok || len(a.keys) != len(other.keys) {
+			return false
+		}
+		for k, v := range a.values {
+			if w, ok := other.values[k]; !ok || !jinjaEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	case *jinjaNamespace:
+		return a == b
+	}
+	return false
+}
+
+func jinjaBinaryOp(op string, left, right any) (any, error) {
+	switch op {
+	case "==":
+		return jinjaEqual(left, right), nil
+	case "!=":
+		return !jinjaEqual(left, right), nil
+	case "<", ">", "<=", ">=":
+		c, err := jinjaCompare(left, right, op)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]bool{"<": c < 0, ">": c > 0, "<=": c <= 0, ">=": c >= 0}[op], nil
+	case "in", "not in":
+		found, err := jinjaContains(right, left)
+		if err != nil {
+			return nil, err
+		}
+		return found == (op == "in"), nil
+	case "~":
+		return jinjaString(left) + jinjaString(right), nil
+	}
+
+	if u, ok := left.(jinjaUndefined); ok {
+		return nil, u.error()
+	}
+	if u, ok := right.(jinjaUndefined); ok {
+		return nil, u.error()
+	}
+	switch l := left.(type) {
+	case string:
+		switch r := right.(type) {
+		case string:
+			if op == "+" {
+				return l + r, nil
+			}
+		case int:
+			if op == "*" {
+				return strings.Repeat(l, max(r, 0)), nil
+			}
+		}
+	case []any:
+		switch r := right.(type) {
+		case []any:
+			if op == "+" {
+				return append(append([]any{}, l...), r...), nil
+			}
+		case int:
+			if op == "*" {
+				var out []any
+				for range max(r, 0) {
+					out = append(out, l...)
+				}
+				return out, nil
+			}
+		}
+	}
+
+	x, okx := jinjaNumber(left)
+	y, oky := jinjaNumber(right)
+	if !okx || !oky {
+		return nil, errors.Errorf("unsupported operand types for %s: %s and %s", op, jinjaTypeName(left), jinjaTypeName(right))
+	}
+	_, leftFloat := left.(float64)
+	_, rightFloat := right.(float64)
+	if !leftFloat && !rightFloat && op != "/" {
+		a, b := int(x), int(y)
+		switch op {
+		case "+":
+			return a + b, nil
+		case "-":
+			return a - b, nil
+		case "*":
+			return a * b, nil
+		case "//", "%":
+			if b == 0 {
+				return nil, errors.New("integer division or modulo by zero")
+			}
+			q, m := a/b, a%b
+			if m != 0 && (m < 0) != (b < 0) {
+				q, m = q-1, m+b
+			}
+			if op == "//" {
+				return q, nil
+			}
+			return m, nil
+		case "**":
+			if b >= 0 {
+				result := 1
+				for range b {
+					result *= a
+				}
+				return result, nil
+			}
+			return math.Pow(x, y), nil
+		}
+	}
+	switch op {
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	case "/", "//", "%":
+		if y == 0 {
+			return nil, errors.New("float division by zero")
+		}
+		switch op {
+		case "/":
+			return x / y, nil
+		case "//":
+			return math.Floor(x / y), nil
+		}
+		m := math.Mod(x, y)
+		if m != 0 && (m < 0) != (y < 0) {
+			m += y
+		}
+		return m, nil
+	case "**":
+		return math.Pow(x, y), nil
+	}
+	return nil, errors.Errorf("unsupported operator %s", op)
+}
+
+func jinjaCompare(left, right any, op string) (int, error) {
+	if x, ok := jinjaNumber(left); ok {
+		if y, ok := jinjaNumber(right); ok {
+			switch {
+			case x < y:
+				return -1, nil
+			case x > y:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+	if x, ok := left.(string); ok {
+		if y, ok := right.(string); ok {
+			return strings.Compare(x, y), nil
+		}
+	}
+	return 0, errors.Errorf("'%s' not supported between %s and %s", op, jinjaTypeName(left), jinjaTypeName(right))
+}
+
+func jinjaContains(container, item any) (bool, error) {
+	switch c := container.(type) {
+	case string:
+		s, ok := item.(string)
+		if !ok {
+			return false, errors.Errorf("'in <string>' requires a string, not %s", jinjaTypeName(item))
+		}
+		return strings.Contains(c, s), nil
+	case []any:
+		for _, v := range c {
+			if jinjaEqual(v, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case *jinjaDict:
+		s, ok := item.(string)
+		if !ok {
+			return false, nil
+		}
+		_, found := c.get(s)
+		return found, nil
+	case *jinjaNamespace:
+		return jinjaContains(c.attrs, item)
+	case jinjaUndefined:
+		return false, c.error()
+	}
+	return false, errors.Errorf("argument of type %s is not iterable", jinjaTypeName(container))
+}
+
+// Globals.
+
+func jinjaRange(args []any, _ map[string]any) (any, error) {
+	ints := make([]int, len(args))
+	for i, arg := range args {
+		n, ok := arg.(int)
+		if !ok {
+			return nil, errors.Errorf("range() arguments must be integers, not %s", jinjaTypeName(arg))
+		}
+		ints[i] = n
+	}
+	start, stop, step := 0, 0, 1
+	switch len(ints) {
+	case 1:
+		stop = ints[0]
+	case 2:
+		start, stop = ints[0], ints[1]
+	case 3:
+		start, stop, step = ints[0], ints[1], ints[2]
+	default:
+		return nil, errors.New("range() takes 1 to 3 arguments")
+	}
+	if step == 0 {
+		return nil, errors.New("range() step cannot be zero")
+	}
+	var out []any
+	for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+		if len(out) == maxJinjaRange {
+			return nil, errors.Errorf("range() is limited to %d items", maxJinjaRange)
+		}
+		out = append(out, i)
+	}
+	if out == nil {
+		out = []any{}
+	}
+	return out, nil
+}
+
+func jinjaNewNamespace(args []any, kwargs map[string]any) (any, error) {
+	d, err := jinjaNewDict(args, kwargs)
+	if err != nil {
+		return nil, err
+	}
+	return &jinjaNamespace{attrs: d.(*jinjaDict)}, nil
+}
+
+func jinjaNewDict(args []any, kwargs map[string]any) (any, error) {
+	d := newJinjaDict()
+	if len(args) > 1 {
+		return nil, errors.New("expected at most one positional argument")
+	}
+	if len(args) == 1 {
+		src, ok := args[0].(*jinjaDict)
+		if !ok {
+			return nil, errors.Errorf("expected a dict, not %s", jinjaTypeName(args[0]))
+		}
+		for _, k := range src.keys {
+			d.set(k, src.values[k])
+		}
+	}
+	keys := make([]string, 0, len(kwargs))
+	for k := range kwargs {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		d.set(k, kwargs[k])
+	}
+	return d, nil
+}
+
+// Filters and tests.
+
+// jinjaArg returns the positional argument i, or the keyword argument name, or def.
+func jinjaArg(args []any, kwargs map[string]any, i int, name string, def any) any {
+	if i < len(args) {
+		return args[i]
+	}
+	if v, ok := kwargs[name]; ok {
+		return v
+	}
+	return def
+}
+
+func applyJinjaFilter(name string, x any, args []any, kwargs map[string]any) (any, error) {
+	switch name {
+	case "safe", "e", "escape":
+		// Templates are rendered without autoescaping.
+		return x, nil
+	case "string":
+		return jinjaString(x), nil
+	case "trim":
+		chars, ok := jinjaArg(args, kwargs, 0, "chars", nil).(string)
+		return jinjaStrip(jinjaString(x), "strip", chars, ok), nil
+	case "upper":
+		return strings.ToUpper(jinjaString(x)), nil
+	case "lower":
+		return strings.ToLower(jinjaString(x)), nil
+	case "title":
+		return jinjaTitle(jinjaString(x)), nil
+	case "capitalize":
+		return jinjaCapitalize(jinjaString(x)), nil
+	case "length", "count":
+		switch x := x.(type) {
+		case string:
+			return utf8.RuneCountInString(x), nil
+		case *jinjaNamespace:
+			return len(x.attrs.keys), nil
+		}
+		items, err := jinjaIterate(x)
+		return len(items), err
+	case "default", "d":
+		fallback := jinjaArg(args, kwargs, 0, "default_value", "")
+		boolean := jinjaTruthy(jinjaArg(args, kwargs, 1, "boolean", false))
+		if _, undefined := x.(jinjaUndefined); undefined || (boolean && !jinjaTruthy(x)) {
+			return fallback, nil
+		}
+		return x, nil
+	case "join":
+		sep := jinjaString(jinjaArg(args, kwargs, 0, "d", ""))
+		if attr, ok := jinjaArg(args, kwargs, 1, "attribute", nil).(string); ok {
+			var err error
+			if x, err = jinjaMapAttribute(x, attr); err != nil {
+				return nil, err
+			}
+		}
+		return jinjaJoin(x, sep)
+	case "first", "last":
+		items, err := jinjaIterate(x)
+		if err != nil || len(items) == 0 {
+			return jinjaUndefined{}, err
+		}
+		if name == "first" {
+			return items[0], nil
+		}
+		return items[len(items)-1], nil
+	case "list":
+		items, err := jinjaIterate(x)
+		if items == nil {
+			items = []any{}
+		}
+		return items, err
+	case "items":
+		switch x := x.(type) {
+		case *jinjaDict:
+			return jinjaMethod{recv: x, name: "items"}.call(nil, nil)
+		case jinjaUndefined:
+			return []any{}, nil
+		}
+		return nil, errors.Errorf("expected a dict, not %s", jinjaTypeName(x))
+	case "reverse":
+		if s, ok := x.(string); ok {
+			runes := []rune(s)
+			slices.Reverse(runes)
+			return string(runes), nil
+		}
+		items, err := jinjaIterate(x)
+		if err != nil {
+			return nil, err
+		}
+		reversed := slices.Clone(items)
+		slices.Reverse(reversed)
+		return reversed, nil
+	case "replace":
+		return jinjaStringMethod(jinjaString(x), "replace", args)
+	case "int":
+		def := jinjaArg(args, kwargs, 0, "default", 0)
+		switch v := x.(type) {
+		case int:
+			return v, nil
+		case float64:
+			return int(v), nil
+		case bool:
+			if v {
+				return 1, nil
+			}
+			return 0, nil
+		case string:
+			if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+				return n, nil
+			}
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return int(f), nil
+			}
+		}
+		return def, nil
+	case "float":
+		def := jinjaArg(args, kwargs, 0, "default", 0.0)
+		if f, ok := jinjaNumber(x); ok {
+			return f, nil
+		}
+		if s, ok := x.(string); ok {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+				return f, nil
+			}
+		}
+		return def, nil
+	case "abs":
+		switch v := x.(type) {
+		case int:
+			return max(v, -v), nil
+		case float64:
+			return math.Abs(v), nil
+		}
+		return nil, errors.Errorf("bad operand type for abs(): %s", jinjaTypeName(x))
+	case "indent":
+		return jinjaIndent(jinjaString(x), args, kwargs)
+	case "tojson":
+		indent := 0
+		if n, ok := jinjaArg(args, kwargs, 0, "indent", nil).(int); ok {
+			indent = n
+		}
+		var sb strings.Builder
+		if err := writeJinjaJSON(&sb, x, indent, 0); err != nil {
+			return nil, err
+		}
+		return sb.String(), nil
+	case "map":
+		if attr, ok := kwargs["attribute"].(string); ok {
+			return jinjaMapAttribute(x, attr)
+		}
+		if len(args) == 0 {
+			return nil, errors.New("map requires a filter name or attribute")
+		}
+		filter, ok := args[0].(string)
+		if !ok {
+			return nil, errors.New("map filter name must be a string")
+		}
+		items, err := jinjaIterate(x)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]any, len(items))
+		for i, item := range items {
+			if out[i], err = applyJinjaFilter(filter, item, args[1:], nil); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case "select", "reject", "selectattr", "rejectattr":
+		return jinjaSelect(name, x, args)
+	case "unique":
+		items, err := jinjaIterate(x)
+		if err != nil {
+			return nil, err
+		}
+		var out []any
+		for _, item := range items {
+			if !slices.ContainsFunc(out, func(v any) bool { return jinjaEqual(v, item) }) {
+				out = append(out, item)
+			}
+		}
+		if out == nil {
+			out = []any{}
+		}
+		return out, nil
+	}
+	return nil, errors.Errorf("unsupported filter %q", name)
+}
+
+// jinjaMapAttribute returns the attribute attr of every item of x.
+func jinjaMapAttribute(x any, attr string) (any, error) {
+	items, err := jinjaIterate(x)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]any, len(items))
+	for i, item := range items {
+		if out[i], err = jinjaGetItem(item, attr); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// jinjaSelect implements select, reject, selectattr and rejectattr.
+func jinjaSelect(name string, x any, args []any) (any, error) {
+	items, err := jinjaIterate(x)
+	if err != nil {
+		return nil, err
+	}
+	byAttr := strings.HasSuffix(name, "attr")
+	keep := strings.HasPrefix(name, "select")
+	var attr string
+	if byAttr {
+		if len(args) == 0 {
+			return nil, errors.Errorf("%s requires an attribute name", name)
+		}
+		var ok bool
+		if attr, ok = args[0].(string); !ok {
+			return nil, errors.Errorf("%s attribute name must be a string", name)
+		}
+		args = args[1:]
+	}
+	test := ""
+	if len(args) > 0 {
+		var ok bool
+		if test, ok = args[0].(string); !ok {
+			return nil, errors.Errorf("%s test name must be a string", name)
+		}
+		args = args[1:]
+	}
+
+	out := []any{}
+	for _, item := range items {
+		v := item
+		if byAttr {
+			if v, err = jinjaGetItem(item, attr); err != nil {
+				return nil, err
+			}
+		}
+		ok := jinjaTruthy(v)
+		if test != "" {
+			if ok, err = applyJinjaTest(test, v, args); err != nil {
+				return nil, err
+			}
+		}
+		if ok == keep {
+			out = append(out, item)
+		}
+	}
+	return out, nil
+}
+
+func jinjaIndent(s string, args []any, kwargs map[string]any) (any, error) {
+	prefix := "    "
+	switch width := jinjaArg(args, kwargs, 0, "width", 4).(type) {
+	case int:
+		prefix = strings.Repeat(" ", max(width, 0))
+	case string:
+		prefix = width
+	}
+	first := jinjaTruthy(jinjaArg(args, kwargs, 1, "first", false))
+	blank := jinjaTruthy(jinjaArg(args, kwargs, 2, "blank", false))
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if (i > 0 || first) && (blank || strings.TrimSpace(line) != "") {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// writeJinjaJSON writes v as JSON with the separators of Python's json.dumps, which
+// transformers uses for tojson, keeping non-ASCII characters as they are.
+func writeJinjaJSON(sb *strings.Builder, v any, indent, depth int) error {
+	newline := func(depth int) {
+		if indent > 0 {
+			sb.WriteString("\n" + strings.Repeat(" ", indent*depth))
+		}
+	}
+	itemSep := ", "
+	if indent > 0 {
+		itemSep = ","
+	}
+	switch v := v.(type) {
+	case nil:
+		sb.WriteString("null")
+	case jinjaUndefined:
+		return v.error()
+	case bool:
+		sb.WriteString(strconv.FormatBool(v))
+	case int:
+		sb.WriteString(strconv.Itoa(v))
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			sb.WriteString(map[bool]string{true: "Infinity", false: "NaN"}[math.IsInf(v, 0)])
+			if math.IsInf(v, -1) {
+				return errors.New("cannot serialize -Infinity")
+			}
+			return nil
+		}
+		sb.WriteString(jinjaFormatFloat(v))
+	case string:
+		writeJinjaJSONString(sb, v)
+	case []any:
+		if len(v) == 0 {
+			sb.WriteString("[]")
+			return nil
+		}
+		sb.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				sb.WriteString(itemSep)
+			}
+			newline(depth + 1)
+			if err := writeJinjaJSON(sb, item, indent, depth+1); err != nil {
+				return err
+			}
+		}
+		newline(depth)
+		sb.WriteByte(']')
+	case *jinjaNamespace:
+		return writeJinjaJSON(sb, v.attrs, indent, depth)
+	case *jinjaDict:
+		if len(v.keys) == 0 {
+			sb.WriteString("{}")
+			return nil
+		}
+		sb.WriteByte('{')
+		for i, k := range v.keys {
+			if i > 0 {
+				sb.WriteString(itemSep)
+			}
+			newline(depth + 1)
+			writeJinjaJSONString(sb, k)
+			sb.WriteString(": ")
+			if err := writeJinjaJSON(sb, v.values[k], indent, depth+1); err != nil {
+				return err
+			}
+		}
+		newline(depth)
+		sb.WriteByte('}')
+	default:
+		return errors.Errorf("%s is not JSON serializable", jinjaTypeName(v))
+	}
+	return nil
+}
+
+func writeJinjaJSONString(sb *strings.Builder, s string) {
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\f':
+			sb.WriteString(`\f`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(sb, `\u%04x`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+}
+
+func applyJinjaTest(name string, x any, args []any) (bool, error) {
+	_, undefined := x.(jinjaUndefined)
+	switch name {
+	case "defined":
+		return !undefined, nil
+	case "undefined":
+		return undefined, nil
+	case "none":
+		return x == nil, nil
+	case "boolean":
+		_, ok := x.(bool)
+		return ok, nil
+	case "true", "false":
+		b, ok := x.(bool)
+		return ok && b == (name == "true"), nil
+	case "string":
+		_, ok := x.(string)
+		return ok, nil
+	case "number":
+		switch x.(type) {
+		case int, float64:
+			return true, nil
+		}
+		return false, nil
+	case "integer":
+		_, ok := x.(int)
+		return ok, nil
+	case "float":
+		_, ok := x.(float64)
+		return ok, nil
+	case "mapping":
+		_, ok := x.(*jinjaDict)
+		return ok, nil
+	case "sequence", "iterable":
+		switch x.(type) {
+		case string, []any, *jinjaDict:
+			return true, nil
+		}
+		return false, nil
+	case "callable":
+		switch x.(type) {
+		case jinjaFunc, jinjaMethod:
+			return true, nil
+		}
+		return false, nil
+	case "lower", "upper":
+		s, ok := x.(string)
+		if name == "lower" {
+			return ok && s == strings.ToLower(s), nil
+		}
+		return ok && s == strings.ToUpper(s), nil
+	case "even", "odd", "divisibleby":
+		n, ok := x.(int)
+		if !ok {
+			return false, errors.Errorf("%s test requires an integer, not %s", name, jinjaTypeName(x))
+		}
+		switch name {
+		case "even":
+			return n%2 == 0, nil
+		case "odd":
+			return n%2 != 0, nil
+		}
+		if len(args) != 1 {
+			return false, errors.New("divisibleby test takes one argument")
+		}
+		d, ok := args[0].(int)
+		if !ok || d == 0 {
+			return false, errors.New("divisibleby test requires a non-zero integer")
+		}
+		return n%d == 0, nil
+	case "in":
+		if len(args) != 1 {
+			return false, errors.New("in test takes one argument")
+		}
+		return jinjaContains(args[0], x)
+	case "eq", "equalto", "==", "ne", "!=", "lt", "<", "gt", ">", "le", "<=", "ge", ">=", "lessthan", "greaterthan":
+		if len(args) != 1 {
+			return false, errors.Errorf("%s test takes one argument", name)
+		}
+		op := map[string]string{
+			"eq": "==", "equalto": "==", "ne": "!=", "lt": "<", "lessthan": "<",
+			"gt": ">", "greaterthan": ">", "le": "<=", "ge": ">=",
+		}[name]
+		if op == "" {
+			op = name
+		}
+		v, err := jinjaBinaryOp(op, x, args[0])
+		if err != nil {
+			return false, err
+		}
+		return v.(bool), nil
+	}
+	return false, errors.Errorf("unsupported test %q", name)
+}
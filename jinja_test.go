@@ -0,0 +1,175 @@
+package tokenizers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func renderJinja(t *testing.T, src string, vars map[string]any) string {
+	t.Helper()
+	tmpl, err := parseJinjaTemplate(src)
+	require.NoError(t, err)
+	out, err := tmpl.render(vars)
+	require.NoError(t, err)
+	return out
+}
+
+func TestJinjaWhitespaceControl(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{"Trim blocks", "{% if true %}\nyes\n{% endif %}\nend", "yes\nend"},
+		{"Lstrip blocks", "a\n    {% if true %}b{% endif %}", "a\nb"},
+		{"Lstrip only at line start", "a  {% if true %}b{% endif %}", "a  b"},
+		{"Outputs keep whitespace", "a\n  {{ 'b' }}\nc", "a\n  b\nc"},
+		{"Minus strips", "a  \n {{- 'b' -}} \n c", "abc"},
+		{"Plus disables lstrip", "a\n  {%+ if true %}b{% endif %}", "a\n  b"},
+		{"Comments", "a{# comment {{ x }} #}\nb", "ab"},
+		{"Closers in strings", "{{ '%}' ~ \"}}\" }}", "%}}}"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, renderJinja(t, tc.src, nil))
+		})
+	}
+}
+
+func TestJinjaExpressions(t *testing.T) {
+	message := newJinjaDict()
+	message.set("role", "user")
+	message.set("content", " Hi there ")
+	vars := map[string]any{
+		"messages": []any{message},
+		"n":        7,
+		"tools":    []any{},
+	}
+
+	tests := []struct {
+		src      string
+		expected string
+	}{
+		{"{{ 1 + 2 * 3 }}", "7"},
+		{"{{ (1 + 2) * 3 }}", "9"},
+		{"{{ 7 // 2 }} {{ -7 // 2 }} {{ -7 % 3 }} {{ 7 / 2 }} {{ 2 ** 10 }}", "3 -4 2 3.5 1024"},
+		{"{{ 'a' ~ 1 ~ none ~ true }}", "a1NoneTrue"},
+		{"{{ 'x' * 3 }}{{ [1] + [2] }}", "xxx[1, 2]"},
+		{"{{ 'yes' if n > 5 else 'no' }}", "yes"},
+		{"{{ 'yes' if n is odd and n is divisibleby(7) }}", "yes"},
+		{"{{ n is not defined }} {{ missing is undefined }} {{ none is none }}", "False True True"},
+		{"{{ 'ell' in 'hello' }} {{ 3 not in [1, 2] }} {{ 'role' in messages[0] }}", "True True True"},
+		{"{{ messages[0].role }} {{ messages[0]['role'] }} {{ messages[-1].role }}", "user user user"},
+		{"{{ messages[0].content.strip() }}|{{ messages[0].content | trim }}", "Hi there|Hi there"},
+		{"{{ 'a,b,c'.split(',') }} {{ 'a b  c'.split() | length }}", "['a', 'b', 'c'] 3"},
+		{"{{ 'Hello'[1:3] }}{{ [1, 2, 3][::-1] }}{{ 'abc'[-1] }}", "el[3, 2, 1]c"},
+		{"{{ 'hello world'.title() }} {{ 'hELLO'.capitalize() }} {{ 'x'.upper() }}", "Hello World Hello X"},
+		{"{{ 'abc'.startswith('a') }} {{ 'abc'.endswith(('x', 'c')) }}", "True True"},
+		{"{{ messages | length }} {{ tools | length }} {{ not tools }}", "1 0 True"},
+		{"{{ missing | default('fallback') }} {{ '' | default('x', true) }}", "fallback x"},
+		{"{{ [1, 2, 3] | join(', ') }} {{ messages | map(attribute='role') | join }}", "1, 2, 3 user"},
+		{"{{ messages | selectattr('role', 'equalto', 'user') | list | length }}", "1"},
+		{"{{ messages | rejectattr('role', 'eq', 'user') | list }}", "[]"},
+		{"{{ [1, 2, 3] | first }}{{ [1, 2, 3] | last }}{{ [1, 2] | reverse | list }}", "13[2, 1]"},
+		{"{{ '3' | int + 1 }} {{ 2 | float }} {{ (-4) | abs }} {{ -4 | abs }}", "4 2.0 4 -4"},
+		{"{{ {'b': 1, 'a': [true, none, 'x\"y']} | tojson }}", `{"b": 1, "a": [true, null, "x\"y"]}`},
+		{"{{ {'a': 1} | tojson(indent=2) }}", "{\n  \"a\": 1\n}"},
+		{"{{ 'é' | tojson }}", `"é"`},
+		{"{% for k, v in {'a': 1, 'b': 2}.items() %}{{ k }}={{ v }};{% endfor %}", "a=1;b=2;"},
+		{"{{ {'a': 1}.get('b', 'none') }}", "none"},
+		{"{{ range(3) | list }} {{ range(1, 7, 2) | list }}", "[0, 1, 2] [1, 3, 5]"},
+		{"{{ 'line\\none' }}", "line\none"},
+		{"{{ 0.1 + 0.2 }} {{ 1e-5 }} {{ 1.5E3 }}", "0.30000000000000004 1e-05 1500.0"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.src, func(t *testing.T) {
+			require.Equal(t, tc.expected, renderJinja(t, tc.src, vars))
+		})
+	}
+}
+
+func TestJinjaStatements(t *testing.T) {
+	items := []any{"a", "b", "c"}
+	vars := map[string]any{"items": items}
+
+	tests := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{"If elif else", "{% for i in [1, 2, 3] %}{% if i == 1 %}one{% elif i == 2 %}two{% else %}many{% endif %} {% endfor %}", "one two many "},
+		{"Loop variables", "{% for x in items %}{{ loop.index }}{{ loop.index0 }}{{ loop.revindex }}{{ loop.first }}{{ loop.last }}{{ loop.length }};{% endfor %}", "103TrueFalse3;212FalseFalse3;321FalseTrue3;"},
+		{"Loop filter", "{% for x in items if x != 'b' %}{{ x }}{{ loop.index }}{% endfor %}", "a1c2"},
+		{"Loop else", "{% for x in [] %}{{ x }}{% else %}empty{% endfor %}", "empty"},
+		{"Break and continue", "{% for x in items %}{% if x == 'a' %}{% continue %}{% endif %}{{ x }}{% if x == 'b' %}{% break %}{% endif %}{% endfor %}", "b"},
+		{"Previous and next items", "{% for x in items %}{{ loop.previtem }}{{ loop.nextitem }}|{% endfor %}", "b|ac|b|"},
+		{"Set", "{% set x = items | length %}{% set y = x * 2 %}{{ y }}", "6"},
+		{"Set in a loop is local", "{% set x = 0 %}{% for i in items %}{% set x = x + 1 %}{% endfor %}{{ x }}", "0"},
+		{"Namespace", "{% set ns = namespace(count=0, found=false) %}{% for i in items %}{% set ns.count = ns.count + 1 %}{% endfor %}{{ ns.count }}{{ ns.found }}", "3False"},
+		{"Block set", "{% set greeting %}Hello {{ items[0] }}{% endset %}{{ greeting | upper }}", "HELLO A"},
+		{"Generation block", "{% generation %}text{% endgeneration %}", "text"},
+		{"Nested loops", "{% for x in items %}{% for y in [1, 2] %}{{ x }}{{ y }}{{ loop.index }}{% endfor %}{% endfor %}", "a11a22b11b22c11c22"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, renderJinja(t, tc.src, vars))
+		})
+	}
+}
+
+func TestJinjaErrors(t *testing.T) {
+	parseErrors := []struct {
+		src      string
+		contains string
+	}{
+		{"{{ x ", "line 1: unclosed {{"},
+		{"a\n{% if x %}", "line 2: missing {% endif %}"},
+		{"{% for x in %}{% endfor %}", "unexpected end of expression"},
+		{"{% macro m() %}{% endmacro %}", `unsupported tag "macro"`},
+		{"{{ 'abc }}", "unclosed {{"},
+		{"{{ x y }}", `unexpected "y"`},
+		{"{% endif %}", `unsupported tag "endif"`},
+		// Syntax outside the subset that chat templates use
+		{"{% include 'tools.jinja' %}", `unsupported tag "include"`},
+		{"{% extends 'base.jinja' %}", `unsupported tag "extends"`},
+		{"{% import 'macros.jinja' as m %}", `unsupported tag "import"`},
+		{"{% raw %}{{ x }}{% endraw %}", `unsupported tag "raw"`},
+		{"{% call m() %}{% endcall %}", `unsupported tag "call"`},
+		{"{% filter upper %}x{% endfilter %}", `unsupported tag "filter"`},
+		{"{% with x = 1 %}{{ x }}{% endwith %}", `unsupported tag "with"`},
+		{"{% block content %}{% endblock %}", `unsupported tag "block"`},
+		{"{% for m in messages recursive %}{% endfor %}", `unexpected "recursive"`},
+		{"{{ {1, 2} }}", `expected ":"`},
+	}
+	for _, tc := range parseErrors {
+		t.Run(tc.src, func(t *testing.T) {
+			_, err := parseJinjaTemplate(tc.src)
+			require.ErrorContains(t, err, tc.contains)
+		})
+	}
+
+	renderErrors := []struct {
+		src      string
+		contains string
+	}{
+		{"{{ x.y }}", `"x" is undefined`},
+		{"{{ 1 + 'a' }}", "unsupported operand types for +: int and str"},
+		{"{{ 1 // 0 }}", "integer division or modulo by zero"},
+		{"{{ x | nofilter }}", `unsupported filter "nofilter"`},
+		{"{{ [2, 1] | sort }}", `unsupported filter "sort"`},
+		{"{{ x is nothing }}", `unsupported test "nothing"`},
+		{"{% set x = 1 %}{% set x.y = 2 %}", "only namespace attributes can be set"},
+		{"{% for a, b in [1] %}{% endfor %}", "int is not iterable"},
+		{"{{ range(2000000) }}", "range() is limited"},
+		{"{% break %}", "break outside of a loop"},
+	}
+	for _, tc := range renderErrors {
+		t.Run(tc.src, func(t *testing.T) {
+			tmpl, err := parseJinjaTemplate(tc.src)
+			require.NoError(t, err)
+			_, err = tmpl.render(map[string]any{})
+			require.ErrorContains(t, err, tc.contains)
+		})
+	}
+}
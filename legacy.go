@@ -0,0 +1,232 @@
+package tokenizers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// Special tokens of BERT vocabularies, added as special tokens when present.
+var wordPieceSpecialTokens = []string{"[PAD]", "[UNK]", "[CLS]", "[SEP]", "[MASK]"}
+
+// Special tokens of GPT-2 and RoBERTa vocabularies, added as special tokens when present.
+var bpeSpecialTokens = []string{"<|endoftext|>", "<s>", "<pad>", "</s>", "<unk>", "<mask>"}
+
+// Files tried, in order, when a HuggingFace model has no tokenizer.json.
+var sentencePieceModelFiles = []string{"tokenizer.model", "spiece.model", "sentencepiece.bpe.model"}
+
+// FromWordPieceVocab loads a BERT tokenizer from a vocab.txt file, which lists one token
+// per line with the line number as its ID. lowercase must match the model, e.g. true for
+// bert-base-uncased. opts are applied as for FromBytes.
+func FromWordPieceVocab(vocabFile string, lowercase bool, opts ...TokenizerOption) (*Tokenizer, error) {
+	vocab, err := readLegacyFile(vocabFile, "vocabulary")
+	if err != nil {
+		return nil, err
+	}
+	pipeline, err := NewWordPiecePipeline(vocab, lowercase)
+	if err != nil {
+		return nil, err
+	}
+	return pipeline.Build(opts...)
+}
+
+// FromBPEFiles loads a GPT-2 style byte-level BPE tokenizer from a vocab.json file,
+// mapping tokens to IDs, and a merges.txt file, listing one merge per line in priority
+// order. opts are applied as for FromBytes.
+func FromBPEFiles(vocabFile, mergesFile string, opts ...TokenizerOption) (*Tokenizer, error) {
+	vocab, err := readLegacyFile(vocabFile, "vocabulary")
+	if err != nil {
+		return nil, err
+	}
+	merges, err := readLegacyFile(mergesFile, "merges")
+	if err != nil {
+		return nil, err
+	}
+	pipeline, err := NewBPEPipeline(vocab, merges)
+	if err != nil {
+		return nil, err
+	}
+	return pipeline.Build(opts...)
+}
+
+// FromSentencePieceModel loads a tokenizer from a SentencePiece .model file, such as the
+// tokenizer.model of Llama or the spiece.model of T5. Unigram and BPE models are
+// supported. opts are applied as for FromBytes.
+func FromSentencePieceModel(modelFile string, opts ...TokenizerOption) (*Tokenizer, error) {
+	model, err := readLegacyFile(modelFile, "SentencePiece model")
+	if err != nil {
+		return nil, err
+	}
+	pipeline, err := NewSentencePiecePipeline(model)
+	if err != nil {
+		return nil, err
+	}
+	return pipeline.Build(opts...)
+}
+
+func readLegacyFile(path, kind string) ([]byte, error) {
+	if path == "" {
+		return nil, errors.Errorf("%s file path cannot be empty", kind)
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, errors.Errorf("%s file does not exist at path: %s", kind, path)
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to access %s file: %s", kind, path)
+	}
+	data, err := os.ReadFile(path) // #nosec G304 -- path is intentionally caller-provided and pre-validated by os.Stat.
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s file: %s", kind, path)
+	}
+	return data, nil
+}
+
+// NewWordPiecePipeline returns the pipeline of a BERT tokenizer with the vocabulary of a
+// vocab.txt file: BERT normalization and pre-tokenization, a WordPiece model with [UNK]
+// and, when the vocabulary has them, [CLS] and [SEP] around the sequences. Use it
+// instead of FromWordPieceVocab to change components before building.
+func NewWordPiecePipeline(vocab []byte, lowercase bool) (*Pipeline, error) {
+	tokens := make(map[string]uint32)
+	scanner := bufio.NewScanner(bytes.NewReader(vocab))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(vocab)+1)
+	var id uint32
+	for scanner.Scan() {
+		// Like the Rust library, keep blank lines so that the IDs match the line numbers.
+		tokens[strings.TrimRightFunc(scanner.Text(), unicode.IsSpace)] = id
+		id++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read vocabulary")
+	}
+
+	pipeline := NewPipeline(WordPieceModel{Vocab: tokens}).
+		WithNormalizer(BertNormalizer{Lowercase: lowercase}).
+		WithPreTokenizer(BertPreTokenizer{}).
+		WithDecoder(WordPieceDecoder{Cleanup: true})
+	clsID, hasCls := tokens["[CLS]"]
+	sepID, hasSep := tokens["[SEP]"]
+	if hasCls && hasSep {
+		pipeline.WithPostProcessor(BertProcessing{Cls: "[CLS]", ClsID: clsID, Sep: "[SEP]", SepID: sepID})
+	}
+	for _, token := range wordPieceSpecialTokens {
+		if _, ok := tokens[token]; ok {
+			pipeline.WithAddedTokens(NewAddedToken(token, true))
+		}
+	}
+	return pipeline, nil
+}
+
+// NewBPEPipeline returns the pipeline of a GPT-2 style byte-level BPE tokenizer with the
+// contents of vocab.json and merges.txt files. Well-known special tokens of the
+// vocabulary, such as <|endoftext|> and <s>, are added as special tokens. Use it instead
+// of FromBPEFiles to change components before building, e.g. to add RobertaProcessing.
+func NewBPEPipeline(vocab, merges []byte) (*Pipeline, error) {
+	var tokens map[string]uint32
+	if err := json.Unmarshal(vocab, &tokens); err != nil {
+		return nil, errors.Wrap(err, "invalid vocabulary format")
+	}
+
+	var pairs [][2]string
+	for i, line := range strings.Split(string(merges), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || (i == 0 && strings.HasPrefix(line, "#version")) {
+			continue
+		}
+		parts := strings.Split(line, " ")
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid merge on line %d: %q", i+1, line)
+		}
+		pairs = append(pairs, [2]string{parts[0], parts[1]})
+	}
+
+	pipeline := NewPipeline(BPEModel{Vocab: tokens, Merges: pairs}).
+		WithPreTokenizer(ByteLevelPreTokenizer{}).
+		WithDecoder(ByteLevelDecoder{})
+	for _, token := range bpeSpecialTokens {
+		if _, ok := tokens[token]; ok {
+			pipeline.WithAddedTokens(NewAddedToken(token, true))
+		}
+	}
+	return pipeline, nil
+}
+
+// downloadLegacyTokenizerFromHF builds the tokenizer.json of a HuggingFace model that
+// only ships the files of its original tokenizer: a SentencePiece model, vocab.json and
+// merges.txt, or vocab.txt, tried in that order. The files are kept in the cache of the
+// model revision, and fetched at the commit that the first of them was downloaded from.
+func downloadLegacyTokenizerFromHF(ctx context.Context, modelID string, config *HFConfig) ([]byte, error) {
+	for _, filename := range sentencePieceModelFiles {
+		model, err := loadHFFile(ctx, modelID, filename, config)
+		if errors.Is(err, ErrHFFileNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		pipeline, err := NewSentencePiecePipeline(model)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to convert %s", filename)
+		}
+		return pipeline.JSON()
+	}
+
+	vocab, err := loadHFFile(ctx, modelID, "vocab.json", config)
+	if err == nil {
+		merges, err := loadHFFile(ctx, modelID, "merges.txt", pinHFConfig(config, cachedHFCommit(modelID, config)))
+		if err != nil {
+			return nil, err
+		}
+		pipeline, err := NewBPEPipeline(vocab, merges)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to convert vocab.json and merges.txt")
+		}
+		return pipeline.JSON()
+	}
+	if !errors.Is(err, ErrHFFileNotFound) {
+		return nil, err
+	}
+
+	vocab, err = loadHFFile(ctx, modelID, "vocab.txt", config)
+	if err != nil {
+		return nil, err
+	}
+	lowercase, err := hfDoLowerCase(ctx, modelID, pinHFConfig(config, cachedHFCommit(modelID, config)))
+	if err != nil {
+		return nil, err
+	}
+	pipeline, err := NewWordPiecePipeline(vocab, lowercase)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to convert vocab.txt")
+	}
+	return pipeline.JSON()
+}
+
+// hfDoLowerCase reads do_lower_case from the tokenizer_config.json of a model, defaulting
+// to true as the BERT tokenizer of transformers does. The file is cached with the other
+// files of the revision, where newHFTokenizer finds it.
+func hfDoLowerCase(ctx context.Context, modelID string, config *HFConfig) (bool, error) {
+	cacheDir := filepath.Dir(getHFCacheFilePath(config.CacheDir, modelID, config.Revision, hfTokenizerConfigFile))
+	data, err := loadHFMetadataFile(ctx, modelID, config, cacheDir, hfTokenizerConfigFile)
+	if err != nil {
+		return false, err
+	}
+	if data == nil {
+		return true, nil
+	}
+	var tokenizerConfig struct {
+		DoLowerCase *bool `json:"do_lower_case"`
+	}
+	if err := json.Unmarshal(data, &tokenizerConfig); err != nil {
+		return false, errors.Wrap(err, "invalid tokenizer_config.json format")
+	}
+	if tokenizerConfig.DoLowerCase == nil {
+		return true, nil
+	}
+	return *tokenizerConfig.DoLowerCase, nil
+}
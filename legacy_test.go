@@ -0,0 +1,270 @@
+package tokenizers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWordPiecePipeline(t *testing.T) {
+	vocab := "[PAD]\n[UNK]\r\n[CLS]\n[SEP]\n\nhello\n##s\n"
+	pipeline, err := NewWordPiecePipeline([]byte(vocab), false)
+	require.NoError(t, err)
+	data, err := pipeline.JSON()
+	require.NoError(t, err)
+
+	var config struct {
+		AddedTokens []struct {
+			ID      uint32 `json:"id"`
+			Content string `json:"content"`
+			Special bool   `json:"special"`
+		} `json:"added_tokens"`
+		Normalizer struct {
+			Type      string `json:"type"`
+			Lowercase bool   `json:"lowercase"`
+		} `json:"normalizer"`
+		Model struct {
+			Type  string            `json:"type"`
+			Vocab map[string]uint32 `json:"vocab"`
+		} `json:"model"`
+		PostProcessor struct {
+			Type string `json:"type"`
+			Sep  []any  `json:"sep"`
+		} `json:"post_processor"`
+	}
+	require.NoError(t, json.Unmarshal(data, &config))
+	require.Equal(t, "BertNormalizer", config.Normalizer.Type)
+	require.False(t, config.Normalizer.Lowercase)
+	require.Equal(t, "WordPiece", config.Model.Type)
+	require.Equal(t, uint32(1), config.Model.Vocab["[UNK]"], "Carriage returns are stripped")
+	require.Equal(t, uint32(5), config.Model.Vocab["hello"], "Blank lines keep their ID")
+	require.Equal(t, uint32(6), config.Model.Vocab["##s"])
+	require.Equal(t, "BertProcessing", config.PostProcessor.Type)
+	require.Equal(t, []any{"[SEP]", float64(3)}, config.PostProcessor.Sep)
+	require.Len(t, config.AddedTokens, 4)
+	for _, token := range config.AddedTokens {
+		require.True(t, token.Special, token.Content)
+	}
+
+	t.Run("Without [CLS] and [SEP]", func(t *testing.T) {
+		pipeline, err := NewWordPiecePipeline([]byte("[UNK]\nhello\n"), true)
+		require.NoError(t, err)
+		data, err := pipeline.JSON()
+		require.NoError(t, err)
+		require.Contains(t, string(data), `"post_processor":null`)
+	})
+
+	t.Run("Without [UNK]", func(t *testing.T) {
+		pipeline, err := NewWordPiecePipeline([]byte("hello\n"), true)
+		require.NoError(t, err)
+		_, err = pipeline.JSON()
+		require.ErrorContains(t, err, `unknown token "[UNK]" is missing from the vocabulary`)
+	})
+}
+
+func TestNewBPEPipeline(t *testing.T) {
+	vocab, err := os.ReadFile("testdata/bpe-vocab.json")
+	require.NoError(t, err)
+	merges, err := os.ReadFile("testdata/bpe-merges.txt")
+	require.NoError(t, err)
+
+	pipeline, err := NewBPEPipeline(vocab, merges)
+	require.NoError(t, err)
+	data, err := pipeline.JSON()
+	require.NoError(t, err)
+
+	var config struct {
+		AddedTokens []struct {
+			Content string `json:"content"`
+			Special bool   `json:"special"`
+		} `json:"added_tokens"`
+		PreTokenizer struct {
+			Type string `json:"type"`
+		} `json:"pre_tokenizer"`
+		Model struct {
+			Type   string      `json:"type"`
+			Merges [][2]string `json:"merges"`
+		} `json:"model"`
+	}
+	require.NoError(t, json.Unmarshal(data, &config))
+	require.Equal(t, "ByteLevel", config.PreTokenizer.Type)
+	require.Equal(t, "BPE", config.Model.Type)
+	require.Len(t, config.Model.Merges, 9, "The #version line is skipped")
+	require.Equal(t, [2]string{"h", "e"}, config.Model.Merges[0])
+	require.Len(t, config.AddedTokens, 1)
+	require.Equal(t, "<|endoftext|>", config.AddedTokens[0].Content)
+	require.True(t, config.AddedTokens[0].Special)
+
+	t.Run("Invalid vocabulary", func(t *testing.T) {
+		_, err := NewBPEPipeline([]byte("[]"), merges)
+		require.ErrorContains(t, err, "invalid vocabulary format")
+	})
+
+	t.Run("Invalid merge", func(t *testing.T) {
+		_, err := NewBPEPipeline(vocab, []byte("#version: 0.2\nh e\nhe ll o\n"))
+		require.ErrorContains(t, err, `invalid merge on line 3: "he ll o"`)
+	})
+
+	t.Run("Merge outside the vocabulary", func(t *testing.T) {
+		pipeline, err := NewBPEPipeline(vocab, []byte("h x\n"))
+		require.NoError(t, err)
+		_, err = pipeline.JSON()
+		require.ErrorContains(t, err, `references token "x" missing from the vocabulary`)
+	})
+}
+
+func TestLegacyFileErrors(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	_, err := FromWordPieceVocab("", true)
+	require.ErrorContains(t, err, "vocabulary file path cannot be empty")
+
+	_, err = FromWordPieceVocab(missing, true)
+	require.ErrorContains(t, err, "vocabulary file does not exist at path")
+
+	_, err = FromBPEFiles("testdata/bpe-vocab.json", missing)
+	require.ErrorContains(t, err, "merges file does not exist at path")
+
+	_, err = FromSentencePieceModel(missing)
+	require.ErrorContains(t, err, "SentencePiece model file does not exist at path")
+}
+
+func TestFromLegacyFiles(t *testing.T) {
+	libpath := checkLibraryExists(t)
+
+	t.Run("WordPiece", func(t *testing.T) {
+		tok, err := FromWordPieceVocab("testdata/vocab.txt", true, WithLibraryPath(libpath))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = tok.Close()
+		})
+
+		res, err := tok.Encode("Héllo, World", WithAddSpecialTokens(), WithReturnTokens())
+		require.NoError(t, err)
+		require.Equal(t, []string{"[CLS]", "hello", ",", "world", "[SEP]"}, res.Tokens)
+
+		text, err := tok.Decode(res.IDs, true)
+		require.NoError(t, err)
+		require.Equal(t, "hello, world", text)
+	})
+
+	t.Run("BPE", func(t *testing.T) {
+		tok, err := FromBPEFiles("testdata/bpe-vocab.json", "testdata/bpe-merges.txt", WithLibraryPath(libpath))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = tok.Close()
+		})
+
+		res, err := tok.Encode("hello world<|endoftext|>", WithAddSpecialTokens(), WithReturnTokens())
+		require.NoError(t, err)
+		require.Equal(t, []string{"hello", "Ġworld", "<|endoftext|>"}, res.Tokens)
+		require.Equal(t, []uint32{12, 17, 0}, res.IDs)
+
+		text, err := tok.Decode(res.IDs, true)
+		require.NoError(t, err)
+		require.Equal(t, "hello world", text)
+	})
+}
+
+func TestDownloadLegacyTokenizerFromHF(t *testing.T) {
+	vocab, err := os.ReadFile("testdata/vocab.txt")
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, filepath.Base(r.URL.Path))
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/cased-model/resolve/main/vocab.txt":
+			_, _ = w.Write(vocab)
+		case "/cased-model/resolve/main/tokenizer_config.json":
+			_, _ = w.Write([]byte(`{"do_lower_case": false}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	config := &HFConfig{
+		baseURL:    server.URL,
+		Revision:   "main",
+		Timeout:    5 * time.Second,
+		MaxRetries: 3,
+		CacheDir:   t.TempDir(),
+	}
+
+	data, err := downloadLegacyTokenizerFromHF(t.Context(), "cased-model", config)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"tokenizer.model", "spiece.model", "sentencepiece.bpe.model",
+		"vocab.json", "vocab.txt", "tokenizer_config.json",
+	}, requested, "Every file is requested once, in order")
+	require.FileExists(t, getHFCacheFilePath(config.CacheDir, "cased-model", "main", "vocab.txt"), "Legacy files are cached")
+	require.FileExists(t, getHFCacheFilePath(config.CacheDir, "cased-model", "main", hfTokenizerConfigFile),
+		"tokenizer_config.json is cached with the legacy files")
+
+	var tokenizerConfig struct {
+		Normalizer struct {
+			Lowercase bool `json:"lowercase"`
+		} `json:"normalizer"`
+		Model struct {
+			Type string `json:"type"`
+		} `json:"model"`
+	}
+	require.NoError(t, json.Unmarshal(data, &tokenizerConfig))
+	require.Equal(t, "WordPiece", tokenizerConfig.Model.Type)
+	require.False(t, tokenizerConfig.Normalizer.Lowercase, "do_lower_case is read from tokenizer_config.json")
+
+	t.Run("Cached tokenizer_config.json", func(t *testing.T) {
+		mu.Lock()
+		requested = nil
+		mu.Unlock()
+
+		lowercase, err := hfDoLowerCase(t.Context(), "cased-model", config)
+		require.NoError(t, err)
+		require.False(t, lowercase)
+		require.Empty(t, requested, "do_lower_case is read from the cache")
+	})
+
+	t.Run("No legacy files", func(t *testing.T) {
+		_, err := downloadLegacyTokenizerFromHF(t.Context(), "missing-model", config)
+		require.ErrorIs(t, err, ErrHFFileNotFound)
+	})
+
+	t.Run("FromHuggingFace falls back", func(t *testing.T) {
+		libpath := checkLibraryExists(t)
+		cacheDir := t.TempDir()
+
+		tok, err := FromHuggingFace("cased-model",
+			WithLibraryPath(libpath),
+			WithHFBaseURL(server.URL),
+			WithHFCacheDir(cacheDir),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = tok.Close()
+		})
+
+		res, err := tok.Encode("hello, world", WithReturnTokens())
+		require.NoError(t, err)
+		require.Equal(t, []string{"hello", ",", "world"}, res.Tokens)
+		require.FileExists(t, getHFCachePath(cacheDir, "cased-model", "main"), "The converted tokenizer is cached")
+	})
+
+	t.Run("FromHuggingFace keeps the tokenizer.json error", func(t *testing.T) {
+		_, err := FromHuggingFace("missing-model",
+			WithHFBaseURL(server.URL),
+			WithHFCacheDir(t.TempDir()),
+		)
+		require.ErrorContains(t, err, "model or tokenizer.json not found")
+	})
+}
@@ -43,6 +43,12 @@ func closeLibrary(handle uintptr) error {
 }
 
 func symbolExists(handle uintptr, name string) error {
-	_, err := purego.Dlsym(handle, name)
+	_, err := lookupSymbol(handle, name)
 	return err
 }
+
+// lookupSymbol returns the address of the exported function name, for calls through
+// purego.SyscallN.
+func lookupSymbol(handle uintptr, name string) (uintptr, error) {
+	return purego.Dlsym(handle, name)
+}
@@ -0,0 +1,118 @@
+package tokenizers
+
+import (
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+// Offset is a [Start, End) byte span in the original input.
+type Offset struct {
+	Start uint32
+	End   uint32
+}
+
+// PreToken is a word produced by the pre-tokenizer, with its span in the input.
+type PreToken struct {
+	Text   string
+	Offset Offset
+}
+
+// NormalizedBuffer mirrors the Rust NormalizedBuffer struct.
+type NormalizedBuffer struct {
+	Text    *byte
+	Offsets *uintptr // 2 * Len values
+	Len     uintptr  // Length of Text in bytes
+}
+
+// PreTokenBuffer mirrors the Rust PreTokenBuffer struct.
+type PreTokenBuffer struct {
+	Tokens  **byte
+	Offsets *uintptr // 2 * Len values
+	Len     uintptr
+}
+
+// Normalize runs the tokenizer's normalizer on text, e.g. to index or highlight text the
+// way the model sees it. The returned offsets align the normalized text to text: for
+// each byte of the normalized text, the span of text it was produced from. Without a
+// normalizer, text is returned unchanged.
+func (t *Tokenizer) Normalize(text string) (string, []Offset, error) {
+	unlock, err := t.beginOperation()
+	if err != nil {
+		return "", nil, err
+	}
+	defer unlock()
+
+	if t.normalize == nil || t.tokenizerh == nil {
+		return "", nil, errors.New("normalize function is not initialized or tokenizer is not loaded")
+	}
+
+	var buf NormalizedBuffer
+	err = t.callNative("normalize", func() int32 {
+		return t.normalize(t.tokenizerh, text, &buf)
+	})
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to normalize text")
+	}
+	defer t.freeNormalized(&buf)
+
+	if buf.Text == nil {
+		return "", nil, errors.New("normalize returned null text")
+	}
+	normalized := goStringFromPtr(unsafe.Pointer(buf.Text)) // #nosec G103 -- Pointer points to FFI-managed null-terminated bytes.
+	return normalized, offsetsFromFlat(buf.Offsets, buf.Len), nil
+}
+
+// PreTokenize runs the tokenizer's pre-tokenizer on text, returning the words the model
+// would tokenize and their spans in text. Like the HuggingFace pre_tokenize_str, it does
+// not normalize text first. Without a pre-tokenizer, text is returned as one word.
+func (t *Tokenizer) PreTokenize(text string) ([]PreToken, error) {
+	unlock, err := t.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if t.preTokenize == nil || t.tokenizerh == nil {
+		return nil, errors.New("pre_tokenize function is not initialized or tokenizer is not loaded")
+	}
+
+	var buf PreTokenBuffer
+	err = t.callNative("pre_tokenize", func() int32 {
+		return t.preTokenize(t.tokenizerh, text, &buf)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to pre-tokenize text")
+	}
+	defer t.freePreTokens(&buf)
+
+	preTokens := make([]PreToken, buf.Len)
+	if buf.Len == 0 {
+		return preTokens, nil
+	}
+	tokens := unsafe.Slice(buf.Tokens, buf.Len) // #nosec G103 -- Token pointer array is returned from trusted Rust FFI.
+	offsets := offsetsFromFlat(buf.Offsets, buf.Len)
+	for i, p := range tokens {
+		preTokens[i] = PreToken{
+			Text:   goStringFromPtr(unsafe.Pointer(p)), // #nosec G103 -- Pointer points to FFI-managed null-terminated token bytes.
+			Offset: offsets[i],
+		}
+	}
+	return preTokens, nil
+}
+
+// offsetsFromFlat converts n [start, end) pairs returned by the library into Offsets.
+func offsetsFromFlat(flat *uintptr, n uintptr) []Offset {
+	offsets := make([]Offset, n)
+	if flat == nil || n == 0 {
+		return offsets
+	}
+	values := unsafe.Slice(flat, n*2) // #nosec G103 -- Offset array is returned from trusted Rust FFI.
+	for i := range offsets {
+		offsets[i] = Offset{
+			Start: uint32(values[2*i]),   // #nosec G115 -- Offsets are bounded by the input length.
+			End:   uint32(values[2*i+1]), // #nosec G115 -- Offsets are bounded by the input length.
+		}
+	}
+	return offsets
+}
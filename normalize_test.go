@@ -0,0 +1,63 @@
+package tokenizers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOffsetsFromFlat(t *testing.T) {
+	require.Empty(t, offsetsFromFlat(nil, 0))
+
+	flat := []uintptr{0, 1, 1, 3}
+	require.Equal(t, []Offset{{0, 1}, {1, 3}}, offsetsFromFlat(&flat[0], 2))
+}
+
+func TestNormalizeAndPreTokenize(t *testing.T) {
+	libpath := checkLibraryExists(t)
+	tok, err := FromFile("./tokenizer.json", WithLibraryPath(libpath))
+	require.NoError(t, err, "Failed to load tokenizer from file")
+	t.Cleanup(func() {
+		_ = tok.Close()
+	})
+
+	t.Run("Normalize", func(t *testing.T) {
+		normalized, offsets, err := tok.Normalize("Héllo World")
+		require.NoError(t, err)
+		require.Equal(t, "hello world", normalized)
+		require.Len(t, offsets, len(normalized))
+		require.Equal(t, Offset{0, 1}, offsets[0])
+		require.Equal(t, Offset{1, 3}, offsets[1], "The accented character spans two bytes of the input")
+		require.Equal(t, Offset{7, 8}, offsets[6])
+	})
+
+	t.Run("PreTokenize", func(t *testing.T) {
+		preTokens, err := tok.PreTokenize("Hello, world!")
+		require.NoError(t, err)
+		require.Equal(t, []PreToken{
+			{Text: "Hello", Offset: Offset{0, 5}},
+			{Text: ",", Offset: Offset{5, 6}},
+			{Text: "world", Offset: Offset{7, 12}},
+			{Text: "!", Offset: Offset{12, 13}},
+		}, preTokens)
+	})
+
+	t.Run("Empty input", func(t *testing.T) {
+		normalized, offsets, err := tok.Normalize("")
+		require.NoError(t, err)
+		require.Empty(t, normalized)
+		require.Empty(t, offsets)
+
+		preTokens, err := tok.PreTokenize("")
+		require.NoError(t, err)
+		require.Empty(t, preTokens)
+	})
+}
+
+func TestNormalizeClosedTokenizer(t *testing.T) {
+	tok := &Tokenizer{closed: true}
+	_, _, err := tok.Normalize("hello")
+	require.ErrorIs(t, err, ErrTokenizerClosed)
+	_, err = tok.PreTokenize("hello")
+	require.ErrorIs(t, err, ErrTokenizerClosed)
+}
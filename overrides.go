@@ -0,0 +1,256 @@
+package tokenizers
+
+import (
+	"container/list"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+// encodeOverrides holds the per-call truncation and padding overrides of an encode
+// call. It is comparable so that it can key the cache of tokenizer variants.
+type encodeOverrides struct {
+	maxLength         uintptr
+	truncationSide    TruncationDirection
+	hasTruncationSide bool
+	padToLength       uintptr
+	noPadding         bool
+}
+
+// WithMaxLength truncates the encodings of this call to at most n tokens, regardless of
+// the truncation configured on the tokenizer. The tokenizer's truncation direction,
+// strategy and stride are kept when it has truncation enabled.
+//
+// Each combination of overrides is served by a copy of the tokenizer, and only the
+// most recently used copies are kept, so a few fixed lengths are cheaper than a
+// different n on every call.
+func WithMaxLength(n uintptr) EncodeOption {
+	return func(eo *EncodeOptions) error {
+		if n == 0 {
+			return errors.New("max length must be greater than 0")
+		}
+		eo.overrides.maxLength = n
+		return nil
+	}
+}
+
+// WithTruncationSide sets the side truncated in this call. It only has an effect when
+// truncation is enabled on the tokenizer or with WithMaxLength.
+func WithTruncationSide(direction TruncationDirection) EncodeOption {
+	return func(eo *EncodeOptions) error {
+		if direction != TruncationDirectionLeft && direction != TruncationDirectionRight {
+			return errors.Errorf("invalid truncation direction: %d", direction)
+		}
+		eo.overrides.truncationSide = direction
+		eo.overrides.hasTruncationSide = true
+		return nil
+	}
+}
+
+// WithPadToLength pads the encodings of this call to n tokens, using the pad token and
+// direction of the tokenizer. It replaces WithNoPadding.
+func WithPadToLength(n uintptr) EncodeOption {
+	return func(eo *EncodeOptions) error {
+		if n == 0 {
+			return errors.New("pad length must be greater than 0")
+		}
+		eo.overrides.padToLength = n
+		eo.overrides.noPadding = false
+		return nil
+	}
+}
+
+// WithNoPadding disables the tokenizer's padding for this call. It replaces
+// WithPadToLength.
+func WithNoPadding() EncodeOption {
+	return func(eo *EncodeOptions) error {
+		eo.overrides.noPadding = true
+		eo.overrides.padToLength = 0
+		return nil
+	}
+}
+
+// maxEncodeVariants bounds the number of tokenizer copies kept for per-call overrides.
+// Each copy holds a full native tokenizer, so when a new combination of overrides
+// exceeds the bound, the least recently used copy is freed.
+const maxEncodeVariants = 8
+
+// encodeVariant is a tokenizer copy with per-call overrides applied.
+type encodeVariant struct {
+	overrides encodeOverrides
+	h         unsafe.Pointer
+	refs      int  // Encode calls using the copy
+	evicted   bool // Removed from the cache; freed once refs drops to 0
+}
+
+// handleFor returns the native tokenizer to encode with: the tokenizer itself, or a
+// copy with the per-call overrides of options applied. Copies are created on first use
+// of each distinct combination of overrides and cached, up to maxEncodeVariants, until
+// the tokenizer's settings change, so shared state is never mutated by an encode call.
+// A non-nil variant must be passed to releaseVariant once the call is done with h.
+// It must be called with lifecycleMu held.
+func (t *Tokenizer) handleFor(options *EncodeOptions) (h unsafe.Pointer, variant *encodeVariant, err error) {
+	if options.overrides == (encodeOverrides{}) {
+		return t.tokenizerh, nil, nil
+	}
+	if v := t.acquireVariant(options.overrides); v != nil {
+		return v.h, v, nil
+	}
+
+	// The copy is made without holding variantsMu, so that calls with cached overrides
+	// are not held up by it.
+	h, err = t.newVariant(options.overrides)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	t.variantsMu.Lock()
+	defer t.variantsMu.Unlock()
+	if elem, ok := t.variants[options.overrides]; ok {
+		// Another call made the same copy meanwhile.
+		t.freeTokenizer(h)
+		v := elem.Value.(*encodeVariant)
+		v.refs++
+		t.variantsLRU.MoveToFront(elem)
+		return v.h, v, nil
+	}
+	if t.variants == nil {
+		t.variants = make(map[encodeOverrides]*list.Element)
+		t.variantsLRU = list.New()
+	}
+	v := &encodeVariant{overrides: options.overrides, h: h, refs: 1}
+	t.variants[options.overrides] = t.variantsLRU.PushFront(v)
+	for t.variantsLRU.Len() > maxEncodeVariants {
+		t.evictVariant(t.variantsLRU.Back())
+	}
+	return h, v, nil
+}
+
+// acquireVariant returns the cached copy for overrides, marked as in use, or nil.
+func (t *Tokenizer) acquireVariant(overrides encodeOverrides) *encodeVariant {
+	t.variantsMu.Lock()
+	defer t.variantsMu.Unlock()
+	elem, ok := t.variants[overrides]
+	if !ok {
+		return nil
+	}
+	v := elem.Value.(*encodeVariant)
+	v.refs++
+	t.variantsLRU.MoveToFront(elem)
+	return v
+}
+
+// releaseVariant marks a copy returned by handleFor as no longer in use by the call,
+// freeing it if it was evicted meanwhile.
+func (t *Tokenizer) releaseVariant(v *encodeVariant) {
+	if v == nil {
+		return
+	}
+	t.variantsMu.Lock()
+	defer t.variantsMu.Unlock()
+	v.refs--
+	if v.evicted && v.refs == 0 {
+		t.freeTokenizer(v.h)
+	}
+}
+
+// evictVariant removes a copy from the cache. Copies still in use by a call are freed
+// by releaseVariant. It must be called with variantsMu held.
+func (t *Tokenizer) evictVariant(elem *list.Element) {
+	v := t.variantsLRU.Remove(elem).(*encodeVariant)
+	delete(t.variants, v.overrides)
+	v.evicted = true
+	if v.refs == 0 {
+		t.freeTokenizer(v.h)
+	}
+}
+
+// newVariant creates a copy of the tokenizer with overrides applied.
+func (t *Tokenizer) newVariant(overrides encodeOverrides) (unsafe.Pointer, error) {
+	if t.cloneTokenizer == nil {
+		return nil, errors.New("clone_tokenizer function is not initialized")
+	}
+	var result TokenizerResult
+	err := t.callNative("clone_tokenizer", func() int32 {
+		return t.cloneTokenizer(t.tokenizerh, &result)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to copy tokenizer for encode overrides")
+	}
+	if err := t.applyOverrides(result.Tokenizer, overrides); err != nil {
+		t.freeTokenizer(result.Tokenizer)
+		return nil, errors.Wrap(err, "failed to apply encode overrides")
+	}
+	return result.Tokenizer, nil
+}
+
+// applyOverrides sets the truncation and padding of the tokenizer copy h, starting
+// from the settings it inherited.
+func (t *Tokenizer) applyOverrides(h unsafe.Pointer, o encodeOverrides) error {
+	if o.maxLength > 0 || o.hasTruncationSide {
+		var trunc TruncationOptions
+		if err := t.callNative("get_truncation", func() int32 { return t.getTruncation(h, &trunc) }); err != nil {
+			return err
+		}
+		if o.maxLength > 0 {
+			if !trunc.Enabled {
+				trunc = TruncationOptions{
+					Enabled:   true,
+					Direction: TruncationDirectionDefault,
+					Strategy:  TruncationStrategyDefault,
+				}
+			}
+			trunc.MaxLen = o.maxLength
+		}
+		if o.hasTruncationSide {
+			trunc.Direction = o.truncationSide
+		}
+		if trunc.Enabled {
+			if err := t.callNative("set_truncation", func() int32 { return t.setTruncation(h, &trunc) }); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch {
+	case o.noPadding:
+		pad := PaddingOptions{Version: PaddingOptionsVersion}
+		return t.callNative("set_padding", func() int32 { return t.setPadding(h, &pad) })
+	case o.padToLength > 0:
+		var current PaddingOptions
+		if err := t.callNative("get_padding", func() int32 { return t.getPadding(h, &current) }); err != nil {
+			return err
+		}
+		if current.PadToken != nil {
+			t.freeString(unsafe.Pointer(current.PadToken)) // #nosec G103 -- Pad token string is allocated by the Rust FFI layer.
+		}
+		// A null pad token keeps the pad token, id and type id of the tokenizer.
+		pad := PaddingOptions{
+			Version:         PaddingOptionsVersion,
+			Enabled:         true,
+			Direction:       current.Direction,
+			Strategy:        PaddingStrategy{Tag: PaddingStrategyFixed, FixedSize: o.padToLength},
+			PadToMultipleOf: current.PadToMultipleOf,
+		}
+		return t.callNative("set_padding", func() int32 { return t.setPadding(h, &pad) })
+	}
+	return nil
+}
+
+// freeVariants releases the cached tokenizer copies. It must be called with lifecycleMu
+// held for writing, whenever the settings the copies were derived from change; no
+// copy is in use by a call then.
+func (t *Tokenizer) freeVariants() {
+	t.variantsMu.Lock()
+	variants := t.variantsLRU
+	t.variants = nil
+	t.variantsLRU = nil
+	t.variantsMu.Unlock()
+
+	if t.freeTokenizer == nil || variants == nil {
+		return
+	}
+	for elem := variants.Front(); elem != nil; elem = elem.Next() {
+		t.freeTokenizer(elem.Value.(*encodeVariant).h)
+	}
+}
@@ -0,0 +1,157 @@
+package tokenizers
+
+import (
+	"sync"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeOverrideOptions(t *testing.T) {
+	var eo EncodeOptions
+	require.Error(t, WithMaxLength(0)(&eo))
+	require.Error(t, WithPadToLength(0)(&eo))
+	require.Error(t, WithTruncationSide(TruncationDirection(9))(&eo))
+
+	require.NoError(t, WithMaxLength(64)(&eo))
+	require.NoError(t, WithTruncationSide(TruncationDirectionLeft)(&eo))
+	require.NoError(t, WithPadToLength(64)(&eo))
+	require.NoError(t, WithNoPadding()(&eo))
+	require.Equal(t, encodeOverrides{
+		maxLength:         64,
+		truncationSide:    TruncationDirectionLeft,
+		hasTruncationSide: true,
+		noPadding:         true,
+	}, eo.overrides, "WithNoPadding should replace WithPadToLength")
+}
+
+func TestHandleForWithoutOverrides(t *testing.T) {
+	tok := &Tokenizer{}
+	h, variant, err := tok.handleFor(&EncodeOptions{ReturnTokens: true})
+	require.NoError(t, err)
+	require.Nil(t, h)
+	require.Nil(t, variant)
+	require.Empty(t, tok.variants, "No copy should be made without overrides")
+}
+
+func TestEncodeVariantsAreBounded(t *testing.T) {
+	clones := 0
+	live := map[unsafe.Pointer]bool{}
+	tok := &Tokenizer{
+		tokenizerh: unsafe.Pointer(new(byte)),
+		cloneTokenizer: func(_ unsafe.Pointer, result *TokenizerResult) int32 {
+			clones++
+			result.Tokenizer = unsafe.Pointer(new(byte))
+			live[result.Tokenizer] = true
+			return SUCCESS
+		},
+		getTruncation: func(unsafe.Pointer, *TruncationOptions) int32 { return SUCCESS },
+		setTruncation: func(unsafe.Pointer, *TruncationOptions) int32 { return SUCCESS },
+		freeTokenizer: func(h unsafe.Pointer) { delete(live, h) },
+	}
+	maxLength := func(n uintptr) *EncodeOptions {
+		var eo EncodeOptions
+		require.NoError(t, WithMaxLength(n)(&eo))
+		return &eo
+	}
+
+	for n := uintptr(1); n <= 100; n++ {
+		_, v, err := tok.handleFor(maxLength(n))
+		require.NoError(t, err)
+		tok.releaseVariant(v)
+	}
+	require.Len(t, tok.variants, maxEncodeVariants, "Distinct max lengths should not grow the cache")
+	require.Len(t, live, maxEncodeVariants, "Evicted copies should be freed")
+
+	_, v, err := tok.handleFor(maxLength(100))
+	require.NoError(t, err)
+	tok.releaseVariant(v)
+	require.Equal(t, 100, clones, "Cached copies should be reused")
+
+	t.Run("Copies in use are freed on release", func(t *testing.T) {
+		h, v, err := tok.handleFor(maxLength(1000))
+		require.NoError(t, err)
+		for n := uintptr(2000); n < 2000+maxEncodeVariants; n++ {
+			_, other, err := tok.handleFor(maxLength(n))
+			require.NoError(t, err)
+			tok.releaseVariant(other)
+		}
+		require.NotContains(t, tok.variants, v.overrides, "The copy should be evicted")
+		require.True(t, live[h], "An evicted copy should not be freed while in use")
+		tok.releaseVariant(v)
+		require.False(t, live[h])
+	})
+
+	tok.freeVariants()
+	require.Empty(t, live)
+}
+
+func TestEncodeOverrides(t *testing.T) {
+	libpath := checkLibraryExists(t)
+	tok, err := FromFile("./tokenizer.json", WithLibraryPath(libpath))
+	require.NoError(t, err, "Failed to load tokenizer from file")
+	t.Cleanup(func() {
+		_ = tok.Close()
+	})
+	text := "The quick brown fox jumps over the lazy dog"
+
+	t.Run("Max length without padding", func(t *testing.T) {
+		res, err := tok.Encode(text, WithAddSpecialTokens(), WithMaxLength(6), WithNoPadding())
+		require.NoError(t, err)
+		require.Len(t, res.IDs, 6)
+		require.Equal(t, uint32(102), res.IDs[5], "[SEP] should be kept after truncation")
+	})
+
+	t.Run("Truncation side", func(t *testing.T) {
+		right, err := tok.Encode(text, WithMaxLength(6), WithNoPadding())
+		require.NoError(t, err)
+		left, err := tok.Encode(text, WithMaxLength(6), WithNoPadding(), WithTruncationSide(TruncationDirectionLeft))
+		require.NoError(t, err)
+		require.NotEqual(t, right.IDs, left.IDs)
+	})
+
+	t.Run("Pad to length", func(t *testing.T) {
+		res, err := tok.Encode("Hello", WithPadToLength(16), WithReturnAttentionMask())
+		require.NoError(t, err)
+		require.Len(t, res.IDs, 16)
+		require.Equal(t, uint32(0), res.AttentionMask[15])
+	})
+
+	t.Run("Shared settings are untouched", func(t *testing.T) {
+		res, err := tok.Encode(text)
+		require.NoError(t, err)
+		require.Len(t, res.IDs, 128)
+		trunc, err := tok.GetTruncation()
+		require.NoError(t, err)
+		require.Equal(t, uintptr(128), trunc.MaxLength)
+	})
+
+	t.Run("Concurrent callers", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := range 16 {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				length := uintptr(4 + i%3)
+				res, err := tok.Encode(text, WithMaxLength(length), WithNoPadding())
+				if !(err == nil && len(res.IDs) == int(length)) {
+					t.Errorf("unexpected result for max length %d: %v", length, err)
+				}
+			}(i)
+		}
+		wg.Wait()
+	})
+
+	t.Run("Copies follow runtime settings", func(t *testing.T) {
+		res, err := tok.Encode("Hello", WithPadToLength(16))
+		require.NoError(t, err)
+		require.Equal(t, uint32(0), res.IDs[15])
+
+		require.NoError(t, tok.SetPadding(PaddingParams{Direction: PaddingDirectionLeft}))
+		require.Empty(t, tok.variants, "Changing settings should drop the cached copies")
+		res, err = tok.Encode("Hello", WithPadToLength(16))
+		require.NoError(t, err)
+		require.Equal(t, uint32(0), res.IDs[0], "The copy should inherit left padding")
+	})
+}
@@ -0,0 +1,932 @@
+package tokenizers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Pipeline assembles a tokenizer from its components, the programmatic counterpart of a
+// tokenizer.json file. Only the model is required:
+//
+//	tok, err := tokenizers.NewPipeline(tokenizers.WordPieceModel{Vocab: vocab}).
+//		WithNormalizer(tokenizers.SequenceNormalizer{Normalizers: []tokenizers.Normalizer{
+//			tokenizers.NFDNormalizer{}, tokenizers.LowercaseNormalizer{}, tokenizers.StripAccentsNormalizer{},
+//		}}).
+//		WithPreTokenizer(tokenizers.WhitespacePreTokenizer{}).
+//		WithPostProcessor(tokenizers.BertProcessing{Cls: "[CLS]", ClsID: 101, Sep: "[SEP]", SepID: 102}).
+//		WithDecoder(tokenizers.WordPieceDecoder{Cleanup: true}).
+//		Build()
+//
+// Components are validated when the pipeline is built, and errors name the component
+// that is invalid.
+type Pipeline struct {
+	model         Model
+	normalizer    Normalizer
+	preTokenizer  PreTokenizer
+	postProcessor PostProcessor
+	decoder       Decoder
+	addedTokens   []AddedToken
+}
+
+// NewPipeline starts a pipeline around model.
+func NewPipeline(model Model) *Pipeline {
+	return &Pipeline{model: model}
+}
+
+// WithNormalizer sets the normalizer, which cleans up the input, e.g. by lowercasing it.
+func (p *Pipeline) WithNormalizer(n Normalizer) *Pipeline {
+	p.normalizer = n
+	return p
+}
+
+// WithPreTokenizer sets the pre-tokenizer, which splits the normalized input into the
+// words the model tokenizes.
+func (p *Pipeline) WithPreTokenizer(pt PreTokenizer) *Pipeline {
+	p.preTokenizer = pt
+	return p
+}
+
+// WithPostProcessor sets the post-processor, which adds special tokens such as [CLS]
+// and [SEP] around the encoded sequences.
+func (p *Pipeline) WithPostProcessor(pp PostProcessor) *Pipeline {
+	p.postProcessor = pp
+	return p
+}
+
+// WithDecoder sets the decoder, which turns tokens back into text.
+func (p *Pipeline) WithDecoder(d Decoder) *Pipeline {
+	p.decoder = d
+	return p
+}
+
+// WithAddedTokens adds tokens that are matched before the model runs. Tokens in the
+// model vocabulary keep their ID; other tokens get the next free IDs.
+func (p *Pipeline) WithAddedTokens(tokens ...AddedToken) *Pipeline {
+	p.addedTokens = append(p.addedTokens, tokens...)
+	return p
+}
+
+// JSON validates the pipeline and returns it in the tokenizer.json format.
+func (p *Pipeline) JSON() ([]byte, error) {
+	if p.model == nil {
+		return nil, errors.New("invalid model: a model is required")
+	}
+	model, err := p.model.modelConfig()
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid model %s", componentName(p.model))
+	}
+	vocab := p.model.vocab()
+
+	addedTokens, err := addedTokensConfig(p.addedTokens, vocab)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid added tokens")
+	}
+	for _, token := range addedTokens {
+		vocab[token["content"].(string)] = token["id"].(uint32)
+	}
+
+	config := map[string]any{
+		"version":        "1.0",
+		"truncation":     nil,
+		"padding":        nil,
+		"added_tokens":   addedTokens,
+		"normalizer":     nil,
+		"pre_tokenizer":  nil,
+		"model":          model,
+		"post_processor": nil,
+		"decoder":        nil,
+	}
+	if p.normalizer != nil {
+		if config["normalizer"], err = p.normalizer.normalizerConfig(); err != nil {
+			return nil, errors.Wrapf(err, "invalid normalizer %s", componentName(p.normalizer))
+		}
+	}
+	if p.preTokenizer != nil {
+		if config["pre_tokenizer"], err = p.preTokenizer.preTokenizerConfig(); err != nil {
+			return nil, errors.Wrapf(err, "invalid pre-tokenizer %s", componentName(p.preTokenizer))
+		}
+	}
+	if p.postProcessor != nil {
+		if config["post_processor"], err = p.postProcessor.postProcessorConfig(vocab); err != nil {
+			return nil, errors.Wrapf(err, "invalid post-processor %s", componentName(p.postProcessor))
+		}
+	}
+	if p.decoder != nil {
+		if config["decoder"], err = p.decoder.decoderConfig(); err != nil {
+			return nil, errors.Wrapf(err, "invalid decoder %s", componentName(p.decoder))
+		}
+	}
+	return json.Marshal(config)
+}
+
+// componentName returns the type name of a component, e.g. "WordPieceModel".
+func componentName(component any) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", component), "tokenizers.")
+}
+
+// Build validates the pipeline and creates the tokenizer, applying opts as FromBytes does.
+func (p *Pipeline) Build(opts ...TokenizerOption) (*Tokenizer, error) {
+	config, err := p.JSON()
+	if err != nil {
+		return nil, err
+	}
+	return FromBytes(config, opts...)
+}
+
+func addedTokensConfig(tokens []AddedToken, vocab map[string]uint32) ([]map[string]any, error) {
+	var nextID uint32
+	for _, id := range vocab {
+		if id >= nextID {
+			nextID = id + 1
+		}
+	}
+	configs := make([]map[string]any, 0, len(tokens))
+	seen := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		if token.Content == "" {
+			return nil, errors.New("added token content cannot be empty")
+		}
+		if seen[token.Content] {
+			return nil, errors.Errorf("duplicate added token %q", token.Content)
+		}
+		seen[token.Content] = true
+
+		id, ok := vocab[token.Content]
+		if !ok {
+			id = nextID
+			nextID++
+		}
+		configs = append(configs, map[string]any{
+			"id":          id,
+			"content":     token.Content,
+			"single_word": token.SingleWord,
+			"lstrip":      token.LStrip,
+			"rstrip":      token.RStrip,
+			"normalized":  token.Normalized,
+			"special":     token.Special,
+		})
+	}
+	return configs, nil
+}
+
+// Normalizer is a normalization component of a Pipeline.
+type Normalizer interface {
+	normalizerConfig() (map[string]any, error)
+}
+
+// NFCNormalizer applies Unicode NFC normalization.
+type NFCNormalizer struct{}
+
+// NFDNormalizer applies Unicode NFD normalization, which StripAccentsNormalizer relies on.
+type NFDNormalizer struct{}
+
+// NFKCNormalizer applies Unicode NFKC normalization.
+type NFKCNormalizer struct{}
+
+// LowercaseNormalizer lowercases the input.
+type LowercaseNormalizer struct{}
+
+// StripAccentsNormalizer removes combining marks. Run NFDNormalizer first so that
+// accented characters are decomposed into a base character and a mark.
+type StripAccentsNormalizer struct{}
+
+// StripNormalizer removes whitespace from the ends of the input.
+type StripNormalizer struct {
+	Left  bool
+	Right bool
+}
+
+// BertNormalizer cleans up control characters, puts spaces around CJK characters and,
+// with Lowercase, lowercases and strips accents, as BERT does.
+type BertNormalizer struct {
+	Lowercase bool
+}
+
+// PrecompiledNormalizer applies the normalization rules compiled into a SentencePiece
+// model, such as NFKC with SentencePiece's own adjustments.
+type PrecompiledNormalizer struct {
+	CharsMap []byte
+}
+
+// ReplaceNormalizer replaces every match of Pattern, a string or, with Regex, a regular
+// expression in the Oniguruma syntax used by the Rust library, with Content.
+type ReplaceNormalizer struct {
+	Pattern string
+	Regex   bool
+	Content string
+}
+
+// SequenceNormalizer applies Normalizers in order.
+type SequenceNormalizer struct {
+	Normalizers []Normalizer
+}
+
+func (NFCNormalizer) normalizerConfig() (map[string]any, error) {
+	return map[string]any{"type": "NFC"}, nil
+}
+
+func (NFDNormalizer) normalizerConfig() (map[string]any, error) {
+	return map[string]any{"type": "NFD"}, nil
+}
+
+func (NFKCNormalizer) normalizerConfig() (map[string]any, error) {
+	return map[string]any{"type": "NFKC"}, nil
+}
+
+func (LowercaseNormalizer) normalizerConfig() (map[string]any, error) {
+	return map[string]any{"type": "Lowercase"}, nil
+}
+
+func (StripAccentsNormalizer) normalizerConfig() (map[string]any, error) {
+	return map[string]any{"type": "StripAccents"}, nil
+}
+
+func (n StripNormalizer) normalizerConfig() (map[string]any, error) {
+	return map[string]any{"type": "Strip", "strip_left": n.Left, "strip_right": n.Right}, nil
+}
+
+func (n BertNormalizer) normalizerConfig() (map[string]any, error) {
+	return map[string]any{
+		"type":                 "BertNormalizer",
+		"clean_text":           true,
+		"handle_chinese_chars": true,
+		"strip_accents":        nil, // Follows lowercase
+		"lowercase":            n.Lowercase,
+	}, nil
+}
+
+func (n PrecompiledNormalizer) normalizerConfig() (map[string]any, error) {
+	if len(n.CharsMap) == 0 {
+		return nil, errors.New("precompiled chars map cannot be empty")
+	}
+	// []byte is encoded as base64, the format of tokenizer.json.
+	return map[string]any{"type": "Precompiled", "precompiled_charsmap": n.CharsMap}, nil
+}
+
+func (n ReplaceNormalizer) normalizerConfig() (map[string]any, error) {
+	if n.Pattern == "" {
+		return nil, errors.New("pattern cannot be empty")
+	}
+	return map[string]any{"type": "Replace", "pattern": splitPattern(n.Pattern, n.Regex), "content": n.Content}, nil
+}
+
+func (n SequenceNormalizer) normalizerConfig() (map[string]any, error) {
+	if len(n.Normalizers) == 0 {
+		return nil, errors.New("at least one normalizer is required")
+	}
+	normalizers := make([]map[string]any, len(n.Normalizers))
+	for i, child := range n.Normalizers {
+		if child == nil {
+			return nil, errors.Errorf("normalizer %d is nil", i)
+		}
+		config, err := child.normalizerConfig()
+		if err != nil {
+			return nil, errors.Wrapf(err, "normalizer %d %s", i, componentName(child))
+		}
+		normalizers[i] = config
+	}
+	return map[string]any{"type": "Sequence", "normalizers": normalizers}, nil
+}
+
+// PreTokenizer is a pre-tokenization component of a Pipeline.
+type PreTokenizer interface {
+	preTokenizerConfig() (map[string]any, error)
+}
+
+// WhitespacePreTokenizer splits on whitespace and between word and punctuation characters.
+type WhitespacePreTokenizer struct{}
+
+// BertPreTokenizer splits on whitespace and punctuation, as BERT does.
+type BertPreTokenizer struct{}
+
+// ByteLevelPreTokenizer splits with the GPT-2 regex and maps every byte to a visible
+// character, so that byte-level BPE models can represent any input.
+type ByteLevelPreTokenizer struct {
+	AddPrefixSpace bool // Add a space before the first word, so it is tokenized like the others
+	// DisableRegex skips the GPT-2 regex, for use after a SplitPreTokenizer with the
+	// pattern of the model.
+	DisableRegex bool
+}
+
+// PrependScheme controls when MetaspacePreTokenizer and MetaspaceDecoder prepend the
+// replacement character.
+type PrependScheme string
+
+const (
+	PrependSchemeAlways PrependScheme = "always" // Before every split
+	PrependSchemeFirst  PrependScheme = "first"  // Before the first split only
+	PrependSchemeNever  PrependScheme = "never"
+)
+
+// MetaspacePreTokenizer replaces spaces with a visible character and splits on it, as
+// SentencePiece does.
+type MetaspacePreTokenizer struct {
+	Replacement   rune          // Defaults to '▁'
+	PrependScheme PrependScheme // Defaults to PrependSchemeAlways
+	// DisableSplit keeps the input in one piece instead of splitting it on the
+	// replacement character, as SentencePiece BPE models such as Llama expect.
+	DisableSplit bool
+}
+
+// DigitsPreTokenizer splits numbers from the surrounding text.
+type DigitsPreTokenizer struct {
+	IndividualDigits bool // Split every digit, instead of keeping runs of digits together
+}
+
+// SplitBehavior controls what SplitPreTokenizer does with the matches of its pattern.
+type SplitBehavior string
+
+const (
+	SplitBehaviorRemoved            SplitBehavior = "Removed"
+	SplitBehaviorIsolated           SplitBehavior = "Isolated"
+	SplitBehaviorMergedWithPrevious SplitBehavior = "MergedWithPrevious"
+	SplitBehaviorMergedWithNext     SplitBehavior = "MergedWithNext"
+	SplitBehaviorContiguous         SplitBehavior = "Contiguous"
+)
+
+// SplitPreTokenizer splits on a string or, with Regex, on a regular expression in the
+// Oniguruma syntax used by the Rust library.
+type SplitPreTokenizer struct {
+	Pattern  string
+	Regex    bool
+	Behavior SplitBehavior // Defaults to SplitBehaviorIsolated
+	Invert   bool          // Split on everything but the matches
+}
+
+// SequencePreTokenizer applies PreTokenizers in order.
+type SequencePreTokenizer struct {
+	PreTokenizers []PreTokenizer
+}
+
+func (WhitespacePreTokenizer) preTokenizerConfig() (map[string]any, error) {
+	return map[string]any{"type": "Whitespace"}, nil
+}
+
+func (BertPreTokenizer) preTokenizerConfig() (map[string]any, error) {
+	return map[string]any{"type": "BertPreTokenizer"}, nil
+}
+
+func (pt ByteLevelPreTokenizer) preTokenizerConfig() (map[string]any, error) {
+	return map[string]any{
+		"type":             "ByteLevel",
+		"add_prefix_space": pt.AddPrefixSpace,
+		"trim_offsets":     true,
+		"use_regex":        !pt.DisableRegex,
+	}, nil
+}
+
+func (pt MetaspacePreTokenizer) preTokenizerConfig() (map[string]any, error) {
+	return metaspaceConfig(pt.Replacement, pt.PrependScheme, !pt.DisableSplit)
+}
+
+func (pt DigitsPreTokenizer) preTokenizerConfig() (map[string]any, error) {
+	return map[string]any{"type": "Digits", "individual_digits": pt.IndividualDigits}, nil
+}
+
+func (pt SplitPreTokenizer) preTokenizerConfig() (map[string]any, error) {
+	if pt.Pattern == "" {
+		return nil, errors.New("pattern cannot be empty")
+	}
+	behavior := pt.Behavior
+	switch behavior {
+	case "":
+		behavior = SplitBehaviorIsolated
+	case SplitBehaviorRemoved, SplitBehaviorIsolated, SplitBehaviorMergedWithPrevious,
+		SplitBehaviorMergedWithNext, SplitBehaviorContiguous:
+	default:
+		return nil, errors.Errorf("invalid behavior %q", behavior)
+	}
+	return map[string]any{
+		"type":     "Split",
+		"pattern":  splitPattern(pt.Pattern, pt.Regex),
+		"behavior": behavior,
+		"invert":   pt.Invert,
+	}, nil
+}
+
+func (pt SequencePreTokenizer) preTokenizerConfig() (map[string]any, error) {
+	if len(pt.PreTokenizers) == 0 {
+		return nil, errors.New("at least one pre-tokenizer is required")
+	}
+	preTokenizers := make([]map[string]any, len(pt.PreTokenizers))
+	for i, child := range pt.PreTokenizers {
+		if child == nil {
+			return nil, errors.Errorf("pre-tokenizer %d is nil", i)
+		}
+		config, err := child.preTokenizerConfig()
+		if err != nil {
+			return nil, errors.Wrapf(err, "pre-tokenizer %d %s", i, componentName(child))
+		}
+		preTokenizers[i] = config
+	}
+	return map[string]any{"type": "Sequence", "pretokenizers": preTokenizers}, nil
+}
+
+// splitPattern returns the tokenizer.json form of a string or regex pattern.
+func splitPattern(pattern string, regex bool) map[string]string {
+	if regex {
+		return map[string]string{"Regex": pattern}
+	}
+	return map[string]string{"String": pattern}
+}
+
+// metaspaceConfig is shared by the Metaspace pre-tokenizer and decoder, which must agree.
+func metaspaceConfig(replacement rune, scheme PrependScheme, split bool) (map[string]any, error) {
+	if replacement == 0 {
+		replacement = '▁'
+	}
+	switch scheme {
+	case "":
+		scheme = PrependSchemeAlways
+	case PrependSchemeAlways, PrependSchemeFirst, PrependSchemeNever:
+	default:
+		return nil, errors.Errorf("invalid prepend scheme %q", scheme)
+	}
+	return map[string]any{
+		"type":           "Metaspace",
+		"replacement":    string(replacement),
+		"prepend_scheme": scheme,
+		"split":          split,
+	}, nil
+}
+
+// Model is the tokenization model of a Pipeline.
+type Model interface {
+	modelConfig() (map[string]any, error)
+	// vocab returns a copy of the token to ID mapping of the model.
+	vocab() map[string]uint32
+}
+
+// BPEModel is a byte-pair encoding model, as used by GPT-2, RoBERTa and Llama.
+type BPEModel struct {
+	Vocab  map[string]uint32
+	Merges [][2]string // In priority order; both parts and their merge must be in Vocab
+	// UnkToken replaces characters missing from Vocab. Without it they are dropped,
+	// which is fine for byte-level models.
+	UnkToken                string
+	ContinuingSubwordPrefix string
+	EndOfWordSuffix         string
+	FuseUnk                 bool // Merge consecutive unknown tokens into one
+	ByteFallback            bool // Use <0xXX> byte tokens instead of UnkToken, as Llama does
+	// IgnoreMerges maps a pre-tokenized word that is in Vocab straight to its token,
+	// before any merges, as tiktoken and Llama 3 do.
+	IgnoreMerges bool
+}
+
+func (m BPEModel) modelConfig() (map[string]any, error) {
+	if err := validateVocab(m.Vocab); err != nil {
+		return nil, err
+	}
+	for i, merge := range m.Merges {
+		for _, part := range merge {
+			if _, ok := m.Vocab[part]; !ok {
+				return nil, errors.Errorf("merge %d %q references token %q missing from the vocabulary", i, merge, part)
+			}
+		}
+		merged := merge[0] + strings.TrimPrefix(merge[1], m.ContinuingSubwordPrefix)
+		if _, ok := m.Vocab[merged]; !ok {
+			return nil, errors.Errorf("merge %d %q produces token %q missing from the vocabulary", i, merge, merged)
+		}
+	}
+	if m.UnkToken != "" {
+		if _, ok := m.Vocab[m.UnkToken]; !ok {
+			return nil, errors.Errorf("unknown token %q is missing from the vocabulary", m.UnkToken)
+		}
+	}
+	merges := m.Merges
+	if merges == nil {
+		merges = [][2]string{}
+	}
+	return map[string]any{
+		"type":                      "BPE",
+		"dropout":                   nil,
+		"unk_token":                 nullIfEmpty(m.UnkToken),
+		"continuing_subword_prefix": nullIfEmpty(m.ContinuingSubwordPrefix),
+		"end_of_word_suffix":        nullIfEmpty(m.EndOfWordSuffix),
+		"fuse_unk":                  m.FuseUnk,
+		"byte_fallback":             m.ByteFallback,
+		"ignore_merges":             m.IgnoreMerges,
+		"vocab":                     m.Vocab,
+		"merges":                    merges,
+	}, nil
+}
+
+func (m BPEModel) vocab() map[string]uint32 {
+	return copyVocab(m.Vocab)
+}
+
+// WordPieceModel is a WordPiece model, as used by BERT.
+type WordPieceModel struct {
+	Vocab                   map[string]uint32
+	UnkToken                string // Defaults to "[UNK]"; must be in Vocab
+	ContinuingSubwordPrefix string // Defaults to "##"
+	MaxInputCharsPerWord    int    // Longer words become UnkToken; defaults to 100
+}
+
+func (m WordPieceModel) modelConfig() (map[string]any, error) {
+	if err := validateVocab(m.Vocab); err != nil {
+		return nil, err
+	}
+	unk := defaultString(m.UnkToken, "[UNK]")
+	if _, ok := m.Vocab[unk]; !ok {
+		return nil, errors.Errorf("unknown token %q is missing from the vocabulary", unk)
+	}
+	if m.MaxInputCharsPerWord < 0 {
+		return nil, errors.New("max input chars per word cannot be negative")
+	}
+	maxChars := m.MaxInputCharsPerWord
+	if maxChars == 0 {
+		maxChars = 100
+	}
+	return map[string]any{
+		"type":                      "WordPiece",
+		"unk_token":                 unk,
+		"continuing_subword_prefix": defaultString(m.ContinuingSubwordPrefix, "##"),
+		"max_input_chars_per_word":  maxChars,
+		"vocab":                     m.Vocab,
+	}, nil
+}
+
+func (m WordPieceModel) vocab() map[string]uint32 {
+	return copyVocab(m.Vocab)
+}
+
+// UnigramPiece is a token of a UnigramModel with its log probability.
+type UnigramPiece struct {
+	Piece string
+	Score float64
+}
+
+// UnigramModel is a Unigram language model, as used by SentencePiece models such as T5.
+// The ID of a piece is its index in Vocab.
+type UnigramModel struct {
+	Vocab        []UnigramPiece
+	UnkToken     string // Piece used for unknown input; must be in Vocab
+	ByteFallback bool   // Use <0xXX> byte pieces instead of UnkToken
+}
+
+func (m UnigramModel) modelConfig() (map[string]any, error) {
+	if len(m.Vocab) == 0 {
+		return nil, errors.New("vocabulary cannot be empty")
+	}
+	vocab := make([][2]any, len(m.Vocab))
+	seen := make(map[string]bool, len(m.Vocab))
+	unkID := -1
+	for i, piece := range m.Vocab {
+		if piece.Piece == "" {
+			return nil, errors.Errorf("piece %d is empty", i)
+		}
+		if seen[piece.Piece] {
+			return nil, errors.Errorf("duplicate piece %q", piece.Piece)
+		}
+		seen[piece.Piece] = true
+		if piece.Piece == m.UnkToken {
+			unkID = i
+		}
+		vocab[i] = [2]any{piece.Piece, piece.Score}
+	}
+	config := map[string]any{
+		"type":          "Unigram",
+		"unk_id":        nil,
+		"vocab":         vocab,
+		"byte_fallback": m.ByteFallback,
+	}
+	if m.UnkToken != "" {
+		if unkID < 0 {
+			return nil, errors.Errorf("unknown token %q is missing from the vocabulary", m.UnkToken)
+		}
+		config["unk_id"] = unkID
+	}
+	return config, nil
+}
+
+func (m UnigramModel) vocab() map[string]uint32 {
+	vocab := make(map[string]uint32, len(m.Vocab))
+	for i, piece := range m.Vocab {
+		vocab[piece.Piece] = uint32(i) // #nosec G115 -- Vocabulary indices fit in uint32.
+	}
+	return vocab
+}
+
+// WordLevelModel maps whole words to IDs.
+type WordLevelModel struct {
+	Vocab    map[string]uint32
+	UnkToken string // Defaults to "<unk>"; must be in Vocab
+}
+
+func (m WordLevelModel) modelConfig() (map[string]any, error) {
+	if err := validateVocab(m.Vocab); err != nil {
+		return nil, err
+	}
+	unk := defaultString(m.UnkToken, "<unk>")
+	if _, ok := m.Vocab[unk]; !ok {
+		return nil, errors.Errorf("unknown token %q is missing from the vocabulary", unk)
+	}
+	return map[string]any{"type": "WordLevel", "vocab": m.Vocab, "unk_token": unk}, nil
+}
+
+func (m WordLevelModel) vocab() map[string]uint32 {
+	return copyVocab(m.Vocab)
+}
+
+func validateVocab(vocab map[string]uint32) error {
+	if len(vocab) == 0 {
+		return errors.New("vocabulary cannot be empty")
+	}
+	tokens := make(map[uint32]string, len(vocab))
+	for token, id := range vocab {
+		if other, ok := tokens[id]; ok {
+			// Report the pair in a stable order.
+			first, second := min(token, other), max(token, other)
+			return errors.Errorf("tokens %q and %q share ID %d", first, second, id)
+		}
+		tokens[id] = token
+	}
+	return nil
+}
+
+func copyVocab(vocab map[string]uint32) map[string]uint32 {
+	out := make(map[string]uint32, len(vocab))
+	for token, id := range vocab {
+		out[token] = id
+	}
+	return out
+}
+
+// PostProcessor is a post-processing component of a Pipeline. Special tokens it adds
+// must have the same ID in the model vocabulary or the added tokens.
+type PostProcessor interface {
+	postProcessorConfig(vocab map[string]uint32) (map[string]any, error)
+}
+
+// BertProcessing adds [CLS] and [SEP] as BERT expects: "[CLS] A [SEP]" and
+// "[CLS] A [SEP] B [SEP]", with type ID 1 for the second sequence.
+type BertProcessing struct {
+	Cls   string
+	ClsID uint32
+	Sep   string
+	SepID uint32
+}
+
+// RobertaProcessing adds <s> and </s> as RoBERTa expects: "<s> A </s>" and
+// "<s> A </s> </s> B </s>".
+type RobertaProcessing struct {
+	Cls            string
+	ClsID          uint32
+	Sep            string
+	SepID          uint32
+	TrimOffsets    bool // Exclude the leading space of byte-level tokens from their offsets
+	AddPrefixSpace bool // Must match the ByteLevelPreTokenizer
+}
+
+// TemplateProcessing adds special tokens following templates such as "[CLS] $A [SEP]"
+// and "[CLS] $A [SEP] $B:1 [SEP]:1". $A and $B stand for the sequences, and a ":N"
+// suffix sets the type ID of a piece. Every special token in the templates must be
+// listed in SpecialTokens.
+type TemplateProcessing struct {
+	Single        string
+	Pair          string // Defaults to the single template followed by "$B:1"
+	SpecialTokens map[string]uint32
+}
+
+func (pp BertProcessing) postProcessorConfig(vocab map[string]uint32) (map[string]any, error) {
+	if err := checkSpecialToken(pp.Cls, pp.ClsID, vocab); err != nil {
+		return nil, err
+	}
+	if err := checkSpecialToken(pp.Sep, pp.SepID, vocab); err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"type": "BertProcessing",
+		"cls":  []any{pp.Cls, pp.ClsID},
+		"sep":  []any{pp.Sep, pp.SepID},
+	}, nil
+}
+
+func (pp RobertaProcessing) postProcessorConfig(vocab map[string]uint32) (map[string]any, error) {
+	if err := checkSpecialToken(pp.Cls, pp.ClsID, vocab); err != nil {
+		return nil, err
+	}
+	if err := checkSpecialToken(pp.Sep, pp.SepID, vocab); err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"type":             "RobertaProcessing",
+		"cls":              []any{pp.Cls, pp.ClsID},
+		"sep":              []any{pp.Sep, pp.SepID},
+		"trim_offsets":     pp.TrimOffsets,
+		"add_prefix_space": pp.AddPrefixSpace,
+	}, nil
+}
+
+func (pp TemplateProcessing) postProcessorConfig(vocab map[string]uint32) (map[string]any, error) {
+	tokens := make([]string, 0, len(pp.SpecialTokens))
+	for token := range pp.SpecialTokens {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	specialTokens := make(map[string]any, len(tokens))
+	for _, token := range tokens {
+		id := pp.SpecialTokens[token]
+		if err := checkSpecialToken(token, id, vocab); err != nil {
+			return nil, err
+		}
+		specialTokens[token] = map[string]any{"id": token, "ids": []uint32{id}, "tokens": []string{token}}
+	}
+
+	single, err := parseTemplate(pp.Single, pp.SpecialTokens, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "single template")
+	}
+	config := map[string]any{
+		"type":           "TemplateProcessing",
+		"single":         single,
+		"pair":           nil,
+		"special_tokens": specialTokens,
+	}
+	if pp.Pair != "" {
+		if config["pair"], err = parseTemplate(pp.Pair, pp.SpecialTokens, true); err != nil {
+			return nil, errors.Wrap(err, "pair template")
+		}
+	} else {
+		config["pair"] = append(single, map[string]any{"Sequence": map[string]any{"id": "B", "type_id": 1}})
+	}
+	return config, nil
+}
+
+// parseTemplate converts a template string into the pieces of tokenizer.json.
+func parseTemplate(template string, specialTokens map[string]uint32, pair bool) ([]map[string]any, error) {
+	fields := strings.Fields(template)
+	if len(fields) == 0 {
+		return nil, errors.New("template cannot be empty")
+	}
+	pieces := make([]map[string]any, 0, len(fields))
+	var hasA, hasB bool
+	for _, field := range fields {
+		name, typeID := field, uint64(0)
+		if i := strings.LastIndexByte(field, ':'); i > 0 {
+			parsed, err := strconv.ParseUint(field[i+1:], 10, 32)
+			if err != nil {
+				return nil, errors.Errorf("invalid type ID in %q", field)
+			}
+			name, typeID = field[:i], parsed
+		}
+
+		if strings.HasPrefix(name, "$") {
+			sequence := "A"
+			switch rest := name[1:]; rest {
+			case "", "A", "a":
+			case "B", "b":
+				sequence = "B"
+			default:
+				parsed, err := strconv.ParseUint(rest, 10, 32)
+				if err != nil {
+					return nil, errors.Errorf("invalid sequence %q", field)
+				}
+				typeID = parsed
+			}
+			if sequence == "A" {
+				hasA = true
+			} else {
+				hasB = true
+			}
+			pieces = append(pieces, map[string]any{"Sequence": map[string]any{"id": sequence, "type_id": typeID}})
+			continue
+		}
+
+		if _, ok := specialTokens[name]; !ok {
+			return nil, errors.Errorf("special token %q is not listed in SpecialTokens", name)
+		}
+		pieces = append(pieces, map[string]any{"SpecialToken": map[string]any{"id": name, "type_id": typeID}})
+	}
+
+	switch {
+	case !hasA:
+		return nil, errors.New("template must contain $A")
+	case pair && !hasB:
+		return nil, errors.New("pair template must contain $B")
+	case !pair && hasB:
+		return nil, errors.New("single template cannot contain $B")
+	}
+	return pieces, nil
+}
+
+func checkSpecialToken(token string, id uint32, vocab map[string]uint32) error {
+	if token == "" {
+		return errors.New("special token cannot be empty")
+	}
+	vocabID, ok := vocab[token]
+	if !ok {
+		return errors.Errorf("special token %q is missing from the vocabulary and added tokens", token)
+	}
+	if vocabID != id {
+		return errors.Errorf("special token %q has ID %d, but %d in the vocabulary", token, id, vocabID)
+	}
+	return nil
+}
+
+// Decoder is a decoding component of a Pipeline.
+type Decoder interface {
+	decoderConfig() (map[string]any, error)
+}
+
+// ByteLevelDecoder reverts the byte mapping of ByteLevelPreTokenizer.
+type ByteLevelDecoder struct{}
+
+// WordPieceDecoder joins WordPiece tokens, removing the continuing subword prefix.
+type WordPieceDecoder struct {
+	Prefix  string // Defaults to "##"
+	Cleanup bool   // Remove spaces before punctuation and in English contractions, as BERT does
+}
+
+// MetaspaceDecoder reverts MetaspacePreTokenizer; use the same settings for both.
+type MetaspaceDecoder struct {
+	Replacement   rune          // Defaults to '▁'
+	PrependScheme PrependScheme // Defaults to PrependSchemeAlways
+}
+
+// ByteFallbackDecoder turns <0xXX> byte tokens back into the characters they encode.
+type ByteFallbackDecoder struct{}
+
+// FuseDecoder joins all tokens into one, so that following decoders see the whole text.
+type FuseDecoder struct{}
+
+// SequenceDecoder applies Decoders in order.
+type SequenceDecoder struct {
+	Decoders []Decoder
+}
+
+// BPEDecoder joins BPE tokens, turning the end-of-word suffix into spaces.
+type BPEDecoder struct {
+	Suffix string // Defaults to "</w>"
+}
+
+func (ByteLevelDecoder) decoderConfig() (map[string]any, error) {
+	return map[string]any{
+		"type":             "ByteLevel",
+		"add_prefix_space": true,
+		"trim_offsets":     true,
+		"use_regex":        true,
+	}, nil
+}
+
+func (d WordPieceDecoder) decoderConfig() (map[string]any, error) {
+	return map[string]any{
+		"type":    "WordPiece",
+		"prefix":  defaultString(d.Prefix, "##"),
+		"cleanup": d.Cleanup,
+	}, nil
+}
+
+func (d MetaspaceDecoder) decoderConfig() (map[string]any, error) {
+	return metaspaceConfig(d.Replacement, d.PrependScheme, true)
+}
+
+func (ByteFallbackDecoder) decoderConfig() (map[string]any, error) {
+	return map[string]any{"type": "ByteFallback"}, nil
+}
+
+func (FuseDecoder) decoderConfig() (map[string]any, error) {
+	return map[string]any{"type": "Fuse"}, nil
+}
+
+func (d SequenceDecoder) decoderConfig() (map[string]any, error) {
+	if len(d.Decoders) == 0 {
+		return nil, errors.New("at least one decoder is required")
+	}
+	decoders := make([]map[string]any, len(d.Decoders))
+	for i, child := range d.Decoders {
+		if child == nil {
+			return nil, errors.Errorf("decoder %d is nil", i)
+		}
+		config, err := child.decoderConfig()
+		if err != nil {
+			return nil, errors.Wrapf(err, "decoder %d %s", i, componentName(child))
+		}
+		decoders[i] = config
+	}
+	return map[string]any{"type": "Sequence", "decoders": decoders}, nil
+}
+
+func (d BPEDecoder) decoderConfig() (map[string]any, error) {
+	return map[string]any{"type": "BPEDecoder", "suffix": defaultString(d.Suffix, "</w>")}, nil
+}
+
+func defaultString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func nullIfEmpty(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,195 @@
+package tokenizers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func bertPipeline() *Pipeline {
+	vocab := map[string]uint32{
+		"[PAD]": 0, "[UNK]": 1, "[CLS]": 2, "[SEP]": 3,
+		"hello": 4, "world": 5, ",": 6, "wor": 7, "##ld": 8,
+	}
+	return NewPipeline(WordPieceModel{Vocab: vocab}).
+		WithNormalizer(SequenceNormalizer{Normalizers: []Normalizer{NFDNormalizer{}, LowercaseNormalizer{}, StripAccentsNormalizer{}}}).
+		WithPreTokenizer(WhitespacePreTokenizer{}).
+		WithPostProcessor(BertProcessing{Cls: "[CLS]", ClsID: 2, Sep: "[SEP]", SepID: 3}).
+		WithDecoder(WordPieceDecoder{Cleanup: true})
+}
+
+func TestPipelineJSON(t *testing.T) {
+	data, err := bertPipeline().WithAddedTokens(NewAddedToken("<doc>", true)).JSON()
+	require.NoError(t, err)
+
+	var config struct {
+		AddedTokens []struct {
+			ID      uint32 `json:"id"`
+			Content string `json:"content"`
+		} `json:"added_tokens"`
+		Normalizer struct {
+			Type        string           `json:"type"`
+			Normalizers []map[string]any `json:"normalizers"`
+		} `json:"normalizer"`
+		Model struct {
+			Type     string `json:"type"`
+			UnkToken string `json:"unk_token"`
+		} `json:"model"`
+		PostProcessor struct {
+			Cls []any `json:"cls"`
+		} `json:"post_processor"`
+	}
+	require.NoError(t, json.Unmarshal(data, &config))
+	require.Equal(t, "Sequence", config.Normalizer.Type)
+	require.Len(t, config.Normalizer.Normalizers, 3)
+	require.Equal(t, "WordPiece", config.Model.Type)
+	require.Equal(t, "[UNK]", config.Model.UnkToken)
+	require.Equal(t, []any{"[CLS]", float64(2)}, config.PostProcessor.Cls)
+	require.Len(t, config.AddedTokens, 1)
+	require.Equal(t, uint32(9), config.AddedTokens[0].ID, "New added tokens get the next free ID")
+}
+
+func TestPipelineValidation(t *testing.T) {
+	vocab := map[string]uint32{"<unk>": 0, "a": 1, "b": 2, "ab": 3}
+	tests := []struct {
+		name     string
+		pipeline *Pipeline
+		contains string
+	}{
+		{
+			name:     "Missing model",
+			pipeline: NewPipeline(nil),
+			contains: "a model is required",
+		},
+		{
+			name:     "Empty vocabulary",
+			pipeline: NewPipeline(WordLevelModel{}),
+			contains: "invalid model WordLevelModel: vocabulary cannot be empty",
+		},
+		{
+			name:     "Duplicate IDs",
+			pipeline: NewPipeline(WordLevelModel{Vocab: map[string]uint32{"<unk>": 0, "a": 0}}),
+			contains: `tokens "<unk>" and "a" share ID 0`,
+		},
+		{
+			name:     "Unknown token missing",
+			pipeline: NewPipeline(WordPieceModel{Vocab: vocab}),
+			contains: `unknown token "[UNK]" is missing from the vocabulary`,
+		},
+		{
+			name:     "Merge out of vocabulary",
+			pipeline: NewPipeline(BPEModel{Vocab: vocab, Merges: [][2]string{{"a", "c"}}}),
+			contains: `references token "c"`,
+		},
+		{
+			name:     "Merge result out of vocabulary",
+			pipeline: NewPipeline(BPEModel{Vocab: vocab, Merges: [][2]string{{"b", "a"}}}),
+			contains: `produces token "ba"`,
+		},
+		{
+			name:     "Unigram unknown token",
+			pipeline: NewPipeline(UnigramModel{Vocab: []UnigramPiece{{"a", -1}}, UnkToken: "<unk>"}),
+			contains: "invalid model UnigramModel",
+		},
+		{
+			name: "Nested normalizer",
+			pipeline: NewPipeline(WordLevelModel{Vocab: vocab}).
+				WithNormalizer(SequenceNormalizer{Normalizers: []Normalizer{LowercaseNormalizer{}, SequenceNormalizer{}}}),
+			contains: "invalid normalizer SequenceNormalizer: normalizer 1 SequenceNormalizer: at least one normalizer is required",
+		},
+		{
+			name: "Split behavior",
+			pipeline: NewPipeline(WordLevelModel{Vocab: vocab}).
+				WithPreTokenizer(SplitPreTokenizer{Pattern: "-", Behavior: "Dropped"}),
+			contains: `invalid pre-tokenizer SplitPreTokenizer: invalid behavior "Dropped"`,
+		},
+		{
+			name: "Metaspace prepend scheme",
+			pipeline: NewPipeline(WordLevelModel{Vocab: vocab}).
+				WithDecoder(MetaspaceDecoder{PrependScheme: "sometimes"}),
+			contains: "invalid decoder MetaspaceDecoder",
+		},
+		{
+			name: "Special token ID mismatch",
+			pipeline: NewPipeline(WordLevelModel{Vocab: vocab}).
+				WithPostProcessor(BertProcessing{Cls: "a", ClsID: 2, Sep: "b", SepID: 2}),
+			contains: `special token "a" has ID 2, but 1 in the vocabulary`,
+		},
+		{
+			name: "Template without special token",
+			pipeline: NewPipeline(WordLevelModel{Vocab: vocab}).
+				WithPostProcessor(TemplateProcessing{Single: "<s> $A"}),
+			contains: `special token "<s>" is not listed in SpecialTokens`,
+		},
+		{
+			name: "Pair template without $B",
+			pipeline: NewPipeline(WordLevelModel{Vocab: vocab}).
+				WithPostProcessor(TemplateProcessing{Single: "$A a", Pair: "$A a", SpecialTokens: map[string]uint32{"a": 1}}),
+			contains: "pair template must contain $B",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.pipeline.JSON()
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tt.contains)
+		})
+	}
+}
+
+func TestParseTemplate(t *testing.T) {
+	specialTokens := map[string]uint32{"[CLS]": 101, "[SEP]": 102}
+	pieces, err := parseTemplate("[CLS] $A [SEP] $B:1 [SEP]:1", specialTokens, true)
+	require.NoError(t, err)
+	require.Equal(t, []map[string]any{
+		{"SpecialToken": map[string]any{"id": "[CLS]", "type_id": uint64(0)}},
+		{"Sequence": map[string]any{"id": "A", "type_id": uint64(0)}},
+		{"SpecialToken": map[string]any{"id": "[SEP]", "type_id": uint64(0)}},
+		{"Sequence": map[string]any{"id": "B", "type_id": uint64(1)}},
+		{"SpecialToken": map[string]any{"id": "[SEP]", "type_id": uint64(1)}},
+	}, pieces)
+
+	_, err = parseTemplate("$A:x", specialTokens, false)
+	require.ErrorContains(t, err, "invalid type ID")
+}
+
+func TestPipelineBuild(t *testing.T) {
+	libpath := checkLibraryExists(t)
+
+	t.Run("WordPiece", func(t *testing.T) {
+		tok, err := bertPipeline().Build(WithLibraryPath(libpath))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = tok.Close()
+		})
+
+		res, err := tok.Encode("Héllo, World", WithAddSpecialTokens(), WithReturnTokens())
+		require.NoError(t, err)
+		require.Equal(t, []string{"[CLS]", "hello", ",", "world", "[SEP]"}, res.Tokens)
+
+		text, err := tok.Decode(res.IDs, true)
+		require.NoError(t, err)
+		require.Equal(t, "hello, world", text)
+	})
+
+	t.Run("BPE with template", func(t *testing.T) {
+		vocab := map[string]uint32{"<s>": 0, "</s>": 1, "a": 2, "b": 3, "ab": 4}
+		tok, err := NewPipeline(BPEModel{Vocab: vocab, Merges: [][2]string{{"a", "b"}}}).
+			WithPreTokenizer(WhitespacePreTokenizer{}).
+			WithPostProcessor(TemplateProcessing{
+				Single:        "<s> $A </s>",
+				Pair:          "<s> $A </s> $B:1 </s>:1",
+				SpecialTokens: map[string]uint32{"<s>": 0, "</s>": 1},
+			}).
+			Build(WithLibraryPath(libpath))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = tok.Close()
+		})
+
+		res, err := tok.Encode("ab a", WithAddSpecialTokens())
+		require.NoError(t, err)
+		require.Equal(t, []uint32{0, 4, 2, 1}, res.IDs)
+	})
+}
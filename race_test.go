@@ -0,0 +1,7 @@
+//go:build race
+
+package tokenizers
+
+// raceEnabled reports whether tests run with the race detector, which allocates on
+// its own and so breaks allocation counts.
+const raceEnabled = true
@@ -0,0 +1,295 @@
+package tokenizers
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Piece types of the SentencePiece model proto.
+const (
+	spPieceNormal      = 1
+	spPieceUnknown     = 2
+	spPieceControl     = 3
+	spPieceUserDefined = 4
+	spPieceByte        = 6 // After UNUSED, the last type
+)
+
+// Model types of the SentencePiece trainer spec.
+const (
+	spModelUnigram = 1
+	spModelBPE     = 2
+)
+
+// Protobuf wire types used by the SentencePiece model proto.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// sentencePieceModel holds the parts of a SentencePiece ModelProto needed to build a
+// tokenizer.
+type sentencePieceModel struct {
+	pieces                 []sentencePiece
+	modelType              uint64
+	byteFallback           bool
+	precompiledCharsMap    []byte
+	addDummyPrefix         bool
+	removeExtraWhitespaces bool
+}
+
+type sentencePiece struct {
+	piece string
+	score float32
+	typ   uint64
+}
+
+// NewSentencePiecePipeline returns the pipeline of a tokenizer with the contents of a
+// SentencePiece .model file, reproducing its normalization and whitespace handling.
+// Unknown and control pieces, such as <unk> and <s>, are added as special tokens; they
+// are not added around the sequences. Use it instead of FromSentencePieceModel to
+// change components before building, e.g. to add TemplateProcessing.
+func NewSentencePiecePipeline(model []byte) (*Pipeline, error) {
+	spm, err := parseSentencePieceModel(model)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid SentencePiece model")
+	}
+	if len(spm.pieces) == 0 {
+		return nil, errors.New("invalid SentencePiece model: no pieces")
+	}
+
+	unkToken := ""
+	var addedTokens []AddedToken
+	for _, piece := range spm.pieces {
+		switch piece.typ {
+		case spPieceUnknown:
+			unkToken = piece.piece
+			addedTokens = append(addedTokens, NewAddedToken(piece.piece, true))
+		case spPieceControl:
+			addedTokens = append(addedTokens, NewAddedToken(piece.piece, true))
+		case spPieceUserDefined:
+			addedTokens = append(addedTokens, AddedToken{Content: piece.piece})
+		}
+	}
+
+	prependScheme := PrependSchemeNever
+	if spm.addDummyPrefix {
+		prependScheme = PrependSchemeAlways
+	}
+	var pipeline *Pipeline
+	switch spm.modelType {
+	case spModelUnigram:
+		vocab := make([]UnigramPiece, len(spm.pieces))
+		for i, piece := range spm.pieces {
+			vocab[i] = UnigramPiece{Piece: piece.piece, Score: float64(piece.score)}
+		}
+		pipeline = NewPipeline(UnigramModel{Vocab: vocab, UnkToken: unkToken, ByteFallback: spm.byteFallback}).
+			WithPreTokenizer(MetaspacePreTokenizer{PrependScheme: prependScheme})
+	case spModelBPE:
+		vocab := make(map[string]uint32, len(spm.pieces))
+		for i, piece := range spm.pieces {
+			vocab[piece.piece] = uint32(i) // #nosec G115 -- Piece indices fit in uint32.
+		}
+		// SentencePiece BPE merges whole sentences, so the input is not split on spaces.
+		pipeline = NewPipeline(BPEModel{
+			Vocab:        vocab,
+			Merges:       sentencePieceMerges(spm.pieces, vocab),
+			UnkToken:     unkToken,
+			FuseUnk:      true,
+			ByteFallback: spm.byteFallback,
+		}).WithPreTokenizer(MetaspacePreTokenizer{PrependScheme: prependScheme, DisableSplit: true})
+	default:
+		return nil, errors.Errorf("unsupported SentencePiece model type %d: only Unigram and BPE models are supported", spm.modelType)
+	}
+
+	var normalizers []Normalizer
+	if len(spm.precompiledCharsMap) > 0 {
+		normalizers = append(normalizers, PrecompiledNormalizer{CharsMap: spm.precompiledCharsMap})
+	}
+	if spm.removeExtraWhitespaces {
+		normalizers = append(normalizers,
+			StripNormalizer{Left: true, Right: true},
+			ReplaceNormalizer{Pattern: " {2,}", Regex: true, Content: " "},
+		)
+	}
+	if len(normalizers) > 0 {
+		pipeline.WithNormalizer(SequenceNormalizer{Normalizers: normalizers})
+	}
+
+	decoder := Decoder(MetaspaceDecoder{PrependScheme: prependScheme})
+	if spm.byteFallback {
+		// Byte pieces are joined before the metaspace is reverted, so that characters
+		// spanning several of them decode.
+		decoder = SequenceDecoder{Decoders: []Decoder{ByteFallbackDecoder{}, FuseDecoder{}, decoder}}
+	}
+	return pipeline.WithDecoder(decoder).WithAddedTokens(addedTokens...), nil
+}
+
+// sentencePieceMerges derives BPE merges from the pieces of a SentencePiece BPE model,
+// which only stores the merged pieces. As in the conversion of transformers, every split
+// of a piece into two pieces of the vocabulary is a merge, ranked by the score of the
+// piece.
+func sentencePieceMerges(pieces []sentencePiece, vocab map[string]uint32) [][2]string {
+	type merge struct {
+		left, right string
+		score       float32
+	}
+	var merges []merge
+	for _, piece := range pieces {
+		runes := []rune(piece.piece)
+		var local []merge
+		for i := 1; i < len(runes); i++ {
+			left, right := string(runes[:i]), string(runes[i:])
+			_, hasLeft := vocab[left]
+			_, hasRight := vocab[right]
+			if hasLeft && hasRight {
+				local = append(local, merge{left, right, piece.score})
+			}
+		}
+		sort.SliceStable(local, func(i, j int) bool {
+			if vocab[local[i].left] != vocab[local[j].left] {
+				return vocab[local[i].left] < vocab[local[j].left]
+			}
+			return vocab[local[i].right] < vocab[local[j].right]
+		})
+		merges = append(merges, local...)
+	}
+	sort.SliceStable(merges, func(i, j int) bool {
+		return merges[i].score > merges[j].score
+	})
+	pairs := make([][2]string, len(merges))
+	for i, m := range merges {
+		pairs[i] = [2]string{m.left, m.right}
+	}
+	return pairs
+}
+
+// parseSentencePieceModel decodes the fields of a serialized SentencePiece ModelProto
+// that are needed to build a tokenizer; other fields are skipped.
+func parseSentencePieceModel(data []byte) (*sentencePieceModel, error) {
+	spm := &sentencePieceModel{
+		modelType:              spModelUnigram,
+		addDummyPrefix:         true,
+		removeExtraWhitespaces: true,
+	}
+	err := parseProtoMessage(data, func(field uint64, value protoValue) error {
+		switch field {
+		case 1: // pieces
+			piece, err := parseSentencePiece(value.bytes)
+			if err != nil {
+				return errors.Wrapf(err, "piece %d", len(spm.pieces))
+			}
+			spm.pieces = append(spm.pieces, piece)
+		case 2: // trainer_spec
+			return errors.Wrap(parseProtoMessage(value.bytes, func(field uint64, value protoValue) error {
+				switch field {
+				case 3: // model_type
+					spm.modelType = value.varint
+				case 35: // byte_fallback
+					spm.byteFallback = value.varint != 0
+				}
+				return nil
+			}), "trainer spec")
+		case 3: // normalizer_spec
+			return errors.Wrap(parseProtoMessage(value.bytes, func(field uint64, value protoValue) error {
+				switch field {
+				case 2: // precompiled_charsmap
+					spm.precompiledCharsMap = value.bytes
+				case 3: // add_dummy_prefix
+					spm.addDummyPrefix = value.varint != 0
+				case 4: // remove_extra_whitespaces
+					spm.removeExtraWhitespaces = value.varint != 0
+				}
+				return nil
+			}), "normalizer spec")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return spm, nil
+}
+
+func parseSentencePiece(data []byte) (sentencePiece, error) {
+	piece := sentencePiece{typ: spPieceNormal}
+	err := parseProtoMessage(data, func(field uint64, value protoValue) error {
+		switch field {
+		case 1: // piece
+			piece.piece = string(value.bytes)
+		case 2: // score
+			piece.score = math.Float32frombits(uint32(value.varint)) // #nosec G115 -- fixed32 values fit in uint32.
+		case 3: // type
+			piece.typ = value.varint
+		}
+		return nil
+	})
+	if err != nil {
+		return piece, err
+	}
+	if piece.piece == "" {
+		return piece, errors.New("piece cannot be empty")
+	}
+	if piece.typ < spPieceNormal || piece.typ > spPieceByte {
+		return piece, errors.Errorf("unknown type %d of piece %q", piece.typ, piece.piece)
+	}
+	return piece, nil
+}
+
+// protoValue is a field value of a protobuf message: varint holds varint and fixed
+// values, bytes holds length-delimited ones.
+type protoValue struct {
+	varint uint64
+	bytes  []byte
+}
+
+// parseProtoMessage calls fn with every field of a serialized protobuf message, in order.
+func parseProtoMessage(data []byte, fn func(field uint64, value protoValue) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errors.New("malformed field key")
+		}
+		data = data[n:]
+		field, wireType := key>>3, key&7
+
+		var value protoValue
+		switch wireType {
+		case wireVarint:
+			value.varint, n = binary.Uvarint(data)
+			if n <= 0 {
+				return errors.Errorf("malformed varint in field %d", field)
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return errors.Errorf("truncated fixed64 in field %d", field)
+			}
+			value.varint = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case wireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return errors.Errorf("truncated bytes in field %d", field)
+			}
+			value.bytes = data[n : n+int(length)] // #nosec G115 -- length is bounded by len(data).
+			data = data[n+int(length):]           // #nosec G115 -- length is bounded by len(data).
+		case wireFixed32:
+			if len(data) < 4 {
+				return errors.Errorf("truncated fixed32 in field %d", field)
+			}
+			value.varint = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		default:
+			return errors.Errorf("unsupported wire type %d in field %d", wireType, field)
+		}
+		if err := fn(field, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
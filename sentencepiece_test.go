@@ -0,0 +1,211 @@
+package tokenizers
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// protoField encodes a varint, fixed32 (float32) or bytes (string, []byte) field.
+func protoField(field uint64, value any) []byte {
+	var b []byte
+	switch v := value.(type) {
+	case uint64:
+		b = binary.AppendUvarint(b, field<<3|wireVarint)
+		b = binary.AppendUvarint(b, v)
+	case float32:
+		b = binary.AppendUvarint(b, field<<3|wireFixed32)
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(v))
+	case string:
+		return protoField(field, []byte(v))
+	case []byte:
+		b = binary.AppendUvarint(b, field<<3|wireBytes)
+		b = binary.AppendUvarint(b, uint64(len(v)))
+		b = append(b, v...)
+	}
+	return b
+}
+
+func protoMessage(fields ...[]byte) []byte {
+	var b []byte
+	for _, field := range fields {
+		b = append(b, field...)
+	}
+	return b
+}
+
+// testSentencePieceModel returns a serialized ModelProto with the given pieces, the
+// first of which is the unknown piece, and model type.
+func testSentencePieceModel(modelType uint64, byteFallback bool, pieces []string, scores []float32) []byte {
+	fields := [][]byte{protoField(1, protoMessage(
+		protoField(1, pieces[0]), protoField(2, float32(0)), protoField(3, uint64(spPieceUnknown)),
+	))}
+	for i, piece := range pieces[1:] {
+		typ := uint64(spPieceNormal)
+		if piece == "<s>" || piece == "</s>" {
+			typ = spPieceControl
+		}
+		fields = append(fields, protoField(1, protoMessage(
+			protoField(1, piece), protoField(2, scores[i]), protoField(3, typ),
+		)))
+	}
+	trainerSpec := [][]byte{protoField(3, modelType)}
+	if byteFallback {
+		trainerSpec = append(trainerSpec, protoField(35, uint64(1)))
+	}
+	fields = append(fields,
+		protoField(2, protoMessage(trainerSpec...)),
+		// A normalizer spec with remove_extra_whitespaces off and a name, which is skipped.
+		protoField(3, protoMessage(protoField(1, "identity"), protoField(4, uint64(0)))),
+	)
+	return protoMessage(fields...)
+}
+
+func TestParseSentencePieceModel(t *testing.T) {
+	model := testSentencePieceModel(spModelBPE, true,
+		[]string{"<unk>", "<s>", "▁", "a"}, []float32{0, -1, -2.5})
+
+	spm, err := parseSentencePieceModel(model)
+	require.NoError(t, err)
+	require.Equal(t, []sentencePiece{
+		{piece: "<unk>", score: 0, typ: spPieceUnknown},
+		{piece: "<s>", score: 0, typ: spPieceControl},
+		{piece: "▁", score: -1, typ: spPieceNormal},
+		{piece: "a", score: -2.5, typ: spPieceNormal},
+	}, spm.pieces)
+	require.Equal(t, uint64(spModelBPE), spm.modelType)
+	require.True(t, spm.byteFallback)
+	require.True(t, spm.addDummyPrefix, "add_dummy_prefix defaults to true")
+	require.False(t, spm.removeExtraWhitespaces)
+
+	t.Run("Defaults", func(t *testing.T) {
+		spm, err := parseSentencePieceModel(protoField(1, protoMessage(protoField(1, "a"))))
+		require.NoError(t, err)
+		require.Equal(t, uint64(spModelUnigram), spm.modelType)
+		require.Equal(t, uint64(spPieceNormal), spm.pieces[0].typ)
+		require.True(t, spm.removeExtraWhitespaces)
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		_, err := parseSentencePieceModel(model[:len(model)-1])
+		require.ErrorContains(t, err, "truncated bytes in field 3")
+
+		_, err = parseSentencePieceModel([]byte{0x0b})
+		require.ErrorContains(t, err, "unsupported wire type 3 in field 1")
+
+		_, err = parseSentencePieceModel(protoField(1, protoMessage(protoField(2, float32(1)))))
+		require.ErrorContains(t, err, "piece 0: piece cannot be empty")
+	})
+}
+
+func TestNewSentencePiecePipeline(t *testing.T) {
+	t.Run("Unigram", func(t *testing.T) {
+		model := testSentencePieceModel(spModelUnigram, false,
+			[]string{"<unk>", "</s>", "▁hello", "▁world"}, []float32{0, -1, -2})
+		pipeline, err := NewSentencePiecePipeline(model)
+		require.NoError(t, err)
+		data, err := pipeline.JSON()
+		require.NoError(t, err)
+
+		var config struct {
+			AddedTokens []struct {
+				ID      uint32 `json:"id"`
+				Content string `json:"content"`
+			} `json:"added_tokens"`
+			Normalizer    any            `json:"normalizer"`
+			Model         map[string]any `json:"model"`
+			Decoder       map[string]any `json:"decoder"`
+			PostProcessor any            `json:"post_processor"`
+		}
+		require.NoError(t, json.Unmarshal(data, &config))
+		require.Nil(t, config.Normalizer, "Extra whitespace is kept as the model asks")
+		require.Equal(t, "Unigram", config.Model["type"])
+		require.Equal(t, float64(0), config.Model["unk_id"])
+		require.Equal(t, "Metaspace", config.Decoder["type"])
+		require.Nil(t, config.PostProcessor)
+		require.Len(t, config.AddedTokens, 2)
+		require.Equal(t, "</s>", config.AddedTokens[1].Content)
+		require.Equal(t, uint32(1), config.AddedTokens[1].ID)
+	})
+
+	t.Run("BPE", func(t *testing.T) {
+		model := testSentencePieceModel(spModelBPE, true,
+			[]string{"<unk>", "▁", "a", "b", "ab", "▁ab"}, []float32{0, 0, 0, -1, -2})
+		pipeline, err := NewSentencePiecePipeline(model)
+		require.NoError(t, err)
+		data, err := pipeline.JSON()
+		require.NoError(t, err)
+
+		var config struct {
+			PreTokenizer map[string]any `json:"pre_tokenizer"`
+			Model        struct {
+				Merges       [][2]string `json:"merges"`
+				ByteFallback bool        `json:"byte_fallback"`
+			} `json:"model"`
+			Decoder struct {
+				Type     string           `json:"type"`
+				Decoders []map[string]any `json:"decoders"`
+			} `json:"decoder"`
+		}
+		require.NoError(t, json.Unmarshal(data, &config))
+		require.Equal(t, false, config.PreTokenizer["split"])
+		require.Equal(t, [][2]string{{"a", "b"}, {"▁", "ab"}}, config.Model.Merges)
+		require.True(t, config.Model.ByteFallback)
+		require.Equal(t, "Sequence", config.Decoder.Type)
+		require.Len(t, config.Decoder.Decoders, 3)
+	})
+
+	t.Run("Unsupported model type", func(t *testing.T) {
+		model := testSentencePieceModel(3, false, []string{"<unk>", "a"}, []float32{0})
+		_, err := NewSentencePiecePipeline(model)
+		require.ErrorContains(t, err, "unsupported SentencePiece model type 3")
+	})
+
+	t.Run("No pieces", func(t *testing.T) {
+		_, err := NewSentencePiecePipeline(nil)
+		require.ErrorContains(t, err, "no pieces")
+	})
+}
+
+func TestSentencePieceMerges(t *testing.T) {
+	pieces := []sentencePiece{
+		{piece: "a"}, {piece: "b"}, {piece: "c"},
+		{piece: "ab", score: -1}, {piece: "bc", score: -1}, {piece: "abc", score: -0.5},
+	}
+	vocab := map[string]uint32{"a": 0, "b": 1, "c": 2, "ab": 3, "bc": 4, "abc": 5}
+
+	// Merges are ranked by score, with ties in vocabulary order; the splits of one piece
+	// are ordered by the IDs of their parts.
+	require.Equal(t, [][2]string{
+		{"a", "bc"}, {"ab", "c"},
+		{"a", "b"}, {"b", "c"},
+	}, sentencePieceMerges(pieces, vocab))
+}
+
+func TestFromSentencePieceModel(t *testing.T) {
+	libpath := checkLibraryExists(t)
+
+	path := filepath.Join(t.TempDir(), "tokenizer.model")
+	model := testSentencePieceModel(spModelUnigram, false,
+		[]string{"<unk>", "▁hello", "▁world", "▁"}, []float32{-1, -1, -5})
+	require.NoError(t, os.WriteFile(path, model, 0o600))
+
+	tok, err := FromSentencePieceModel(path, WithLibraryPath(libpath))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = tok.Close()
+	})
+
+	res, err := tok.Encode("hello world", WithReturnTokens())
+	require.NoError(t, err)
+	require.Equal(t, []string{"▁hello", "▁world"}, res.Tokens)
+
+	text, err := tok.Decode(res.IDs, true)
+	require.NoError(t, err)
+	require.Equal(t, "hello world", text)
+}
@@ -0,0 +1,52 @@
+package tokenizers
+
+import (
+	"os"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+// ToJSON serializes the tokenizer to the tokenizer.json format, including the truncation,
+// padding and added tokens configured on it. The result can be loaded with FromBytes, or
+// by the HuggingFace libraries.
+func (t *Tokenizer) ToJSON(pretty bool) ([]byte, error) {
+	unlock, err := t.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if t.toJSON == nil || t.tokenizerh == nil {
+		return nil, errors.New("to_json function is not initialized or tokenizer is not loaded")
+	}
+
+	var cStrPtr unsafe.Pointer
+	err = t.callNative("to_json", func() int32 {
+		return t.toJSON(t.tokenizerh, pretty, &cStrPtr)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to serialize tokenizer")
+	}
+	if cStrPtr == nil {
+		return nil, errors.New("to_json returned null pointer")
+	}
+	defer t.freeString(cStrPtr)
+	return []byte(goStringFromPtr(cStrPtr)), nil
+}
+
+// Save writes the tokenizer to path in the pretty-printed tokenizer.json format, so that
+// it can be reloaded with FromFile.
+func (t *Tokenizer) Save(path string) error {
+	if path == "" {
+		return errors.New("path cannot be empty")
+	}
+	data, err := t.ToJSON(true)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return errors.Wrapf(err, "failed to write tokenizer to %s", path)
+	}
+	return nil
+}
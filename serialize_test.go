@@ -0,0 +1,80 @@
+package tokenizers
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestToJSON(t *testing.T) {
+	libpath := checkLibraryExists(t)
+	tok, err := FromFile("./tokenizer.json", WithLibraryPath(libpath))
+	require.NoError(t, err, "Failed to load tokenizer from file")
+	t.Cleanup(func() {
+		_ = tok.Close()
+	})
+
+	compact, err := tok.ToJSON(false)
+	require.NoError(t, err)
+	require.True(t, json.Valid(compact))
+
+	pretty, err := tok.ToJSON(true)
+	require.NoError(t, err)
+	require.Greater(t, len(pretty), len(compact))
+
+	var config map[string]any
+	require.NoError(t, json.Unmarshal(compact, &config))
+	require.Contains(t, config, "model")
+	require.Contains(t, config, "truncation")
+}
+
+func TestSaveRoundTrip(t *testing.T) {
+	libpath := checkLibraryExists(t)
+	tok, err := FromFile("./tokenizer.json", WithLibraryPath(libpath))
+	require.NoError(t, err, "Failed to load tokenizer from file")
+	t.Cleanup(func() {
+		_ = tok.Close()
+	})
+
+	require.NoError(t, tok.SetTruncation(TruncationParams{MaxLength: 16, Direction: TruncationDirectionLeft}))
+	require.NoError(t, tok.DisablePadding())
+	_, err = tok.AddTokens([]AddedToken{NewAddedToken("<doc>", false)})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "tokenizer.json")
+	require.NoError(t, tok.Save(path))
+
+	reloaded, err := FromFile(path, WithLibraryPath(libpath))
+	require.NoError(t, err, "Saved tokenizer should load with FromFile")
+	t.Cleanup(func() {
+		_ = reloaded.Close()
+	})
+
+	trunc, err := reloaded.GetTruncation()
+	require.NoError(t, err)
+	require.NotNil(t, trunc)
+	require.Equal(t, uintptr(16), trunc.MaxLength)
+	require.Equal(t, TruncationDirectionLeft, trunc.Direction)
+
+	pad, err := reloaded.GetPadding()
+	require.NoError(t, err)
+	require.Nil(t, pad)
+
+	_, ok := reloaded.TokenToID("<doc>")
+	require.True(t, ok, "Added tokens should be saved")
+
+	want, err := tok.Encode("hello <doc> world", WithAddSpecialTokens())
+	require.NoError(t, err)
+	got, err := reloaded.Encode("hello <doc> world", WithAddSpecialTokens())
+	require.NoError(t, err)
+	require.Equal(t, want.IDs, got.IDs)
+}
+
+func TestSerializeClosedTokenizer(t *testing.T) {
+	tok := &Tokenizer{closed: true}
+	_, err := tok.ToJSON(false)
+	require.ErrorIs(t, err, ErrTokenizerClosed)
+	require.ErrorIs(t, tok.Save(filepath.Join(t.TempDir(), "tokenizer.json")), ErrTokenizerClosed)
+}
@@ -0,0 +1,217 @@
+package tokenizers
+
+import (
+	"runtime"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+// TruncationParams describes how encodings are truncated.
+type TruncationParams struct {
+	MaxLength uintptr
+	Direction TruncationDirection
+	Strategy  TruncationStrategy
+	Stride    uintptr // Overlap between overflowing windows, must be smaller than MaxLength
+}
+
+func validateTruncationParams(params TruncationParams) error {
+	if params.MaxLength == 0 {
+		return errors.New("truncation max length must be greater than 0")
+	}
+	if params.Stride >= params.MaxLength {
+		return errors.Errorf("truncation stride (%d) must be smaller than max length (%d)", params.Stride, params.MaxLength)
+	}
+	return nil
+}
+
+func validatePaddingParams(params PaddingParams) error {
+	if params.Direction != PaddingDirectionRight && params.Direction != PaddingDirectionLeft {
+		return errors.Errorf("invalid padding direction: %d", params.Direction)
+	}
+	if params.Strategy.Tag == PaddingStrategyFixed && params.Strategy.FixedSize == 0 {
+		return errors.New("fixed padding size must be greater than 0")
+	}
+	return nil
+}
+
+// beginExclusiveOperation is like beginOperation but waits for all in-flight calls to
+// finish and blocks new ones, for calls that mutate the native tokenizer. It fails with
+// ErrDecodeStreamsOpen while decode streams are open, as they borrow the tokenizer.
+func (t *Tokenizer) beginExclusiveOperation() (func(), error) {
+	t.lifecycleMu.Lock()
+	if t.closed {
+		t.lifecycleMu.Unlock()
+		return nil, ErrTokenizerClosed
+	}
+	t.streamsMu.Lock()
+	open := len(t.decodeStreams)
+	t.streamsMu.Unlock()
+	if open > 0 {
+		t.lifecycleMu.Unlock()
+		return nil, ErrDecodeStreamsOpen
+	}
+	return t.lifecycleMu.Unlock, nil
+}
+
+// SetTruncation enables truncation, or replaces the current truncation settings, on a
+// loaded tokenizer. It waits for in-flight calls to finish; calls made afterwards use
+// the new settings.
+func (t *Tokenizer) SetTruncation(params TruncationParams) error {
+	if err := validateTruncationParams(params); err != nil {
+		return err
+	}
+	return t.applyTruncation(TruncationOptions{
+		Enabled:   true,
+		MaxLen:    params.MaxLength,
+		Strategy:  params.Strategy,
+		Direction: params.Direction,
+		Stride:    params.Stride,
+	})
+}
+
+// DisableTruncation turns truncation off on a loaded tokenizer.
+func (t *Tokenizer) DisableTruncation() error {
+	return t.applyTruncation(TruncationOptions{})
+}
+
+func (t *Tokenizer) applyTruncation(opts TruncationOptions) error {
+	unlock, err := t.beginExclusiveOperation()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if t.setTruncation == nil || t.tokenizerh == nil {
+		return errors.New("set_truncation function is not initialized or tokenizer is not loaded")
+	}
+	err = t.callNative("set_truncation", func() int32 {
+		return t.setTruncation(t.tokenizerh, &opts)
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to set truncation")
+	}
+	t.freeVariants()
+
+	t.TruncationEnabled = opts.Enabled
+	t.TruncationMaxLength = opts.MaxLen
+	t.TruncationDirection = opts.Direction
+	t.TruncationStrategy = opts.Strategy
+	t.TruncationStride = opts.Stride
+	return nil
+}
+
+// GetTruncation returns the current truncation settings, or nil if the tokenizer does
+// not truncate.
+func (t *Tokenizer) GetTruncation() (*TruncationParams, error) {
+	unlock, err := t.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if t.getTruncation == nil || t.tokenizerh == nil {
+		return nil, errors.New("get_truncation function is not initialized or tokenizer is not loaded")
+	}
+	var opts TruncationOptions
+	err = t.callNative("get_truncation", func() int32 {
+		return t.getTruncation(t.tokenizerh, &opts)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get truncation")
+	}
+	if !opts.Enabled {
+		return nil, nil
+	}
+	return &TruncationParams{
+		MaxLength: opts.MaxLen,
+		Direction: opts.Direction,
+		Strategy:  opts.Strategy,
+		Stride:    opts.Stride,
+	}, nil
+}
+
+// SetPadding enables padding, or replaces the current padding settings, on a loaded
+// tokenizer. As with WithPaddingOptions, an empty PadToken keeps the current pad token,
+// id and type id. It waits for in-flight calls to finish; calls made afterwards use the
+// new settings.
+func (t *Tokenizer) SetPadding(params PaddingParams) error {
+	if err := validatePaddingParams(params); err != nil {
+		return err
+	}
+	opts, padToken := paddingOptionsFromParams(params)
+	err := t.applyPadding(opts, &params)
+	runtime.KeepAlive(padToken)
+	return err
+}
+
+// DisablePadding turns padding off on a loaded tokenizer.
+func (t *Tokenizer) DisablePadding() error {
+	return t.applyPadding(PaddingOptions{Version: PaddingOptionsVersion}, nil)
+}
+
+// applyPadding sends opts to the library and records params, or nil if padding is
+// disabled, on the tokenizer.
+func (t *Tokenizer) applyPadding(opts PaddingOptions, params *PaddingParams) error {
+	unlock, err := t.beginExclusiveOperation()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if t.setPadding == nil || t.tokenizerh == nil {
+		return errors.New("set_padding function is not initialized or tokenizer is not loaded")
+	}
+	err = t.callNative("set_padding", func() int32 {
+		return t.setPadding(t.tokenizerh, &opts)
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to set padding")
+	}
+	t.freeVariants()
+
+	t.PaddingEnabled = params != nil
+	if params != nil {
+		t.PaddingStrategy = params.Strategy
+		t.paddingParams = *params
+	}
+	return nil
+}
+
+// GetPadding returns the current padding settings, including the pad token, or nil if
+// the tokenizer does not pad.
+func (t *Tokenizer) GetPadding() (*PaddingParams, error) {
+	unlock, err := t.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if t.getPadding == nil || t.tokenizerh == nil {
+		return nil, errors.New("get_padding function is not initialized or tokenizer is not loaded")
+	}
+	var opts PaddingOptions
+	err = t.callNative("get_padding", func() int32 {
+		return t.getPadding(t.tokenizerh, &opts)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get padding")
+	}
+	if opts.PadToken != nil {
+		defer t.freeString(unsafe.Pointer(opts.PadToken)) // #nosec G103 -- Pad token string is allocated by the Rust FFI layer.
+	}
+	if !opts.Enabled {
+		return nil, nil
+	}
+	params := &PaddingParams{
+		Strategy:        opts.Strategy,
+		Direction:       opts.Direction,
+		PadToMultipleOf: opts.PadToMultipleOf,
+		PadID:           opts.PadID,
+		PadTypeID:       opts.PadTypeID,
+	}
+	if opts.PadToken != nil {
+		params.PadToken = goStringFromPtr(unsafe.Pointer(opts.PadToken)) // #nosec G103 -- Pointer points to FFI-managed null-terminated bytes.
+	}
+	return params, nil
+}
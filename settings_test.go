@@ -0,0 +1,88 @@
+package tokenizers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTruncationParams(t *testing.T) {
+	require.Error(t, validateTruncationParams(TruncationParams{}))
+	require.Error(t, validateTruncationParams(TruncationParams{MaxLength: 8, Stride: 8}))
+	require.NoError(t, validateTruncationParams(TruncationParams{MaxLength: 8, Stride: 2}))
+}
+
+func TestRuntimeSettingsOnClosedTokenizer(t *testing.T) {
+	tok := &Tokenizer{closed: true}
+	require.ErrorIs(t, tok.SetTruncation(TruncationParams{MaxLength: 8}), ErrTokenizerClosed)
+	require.ErrorIs(t, tok.DisableTruncation(), ErrTokenizerClosed)
+	require.ErrorIs(t, tok.SetPadding(PaddingParams{}), ErrTokenizerClosed)
+	require.ErrorIs(t, tok.DisablePadding(), ErrTokenizerClosed)
+	_, err := tok.GetTruncation()
+	require.ErrorIs(t, err, ErrTokenizerClosed)
+	_, err = tok.GetPadding()
+	require.ErrorIs(t, err, ErrTokenizerClosed)
+}
+
+func TestRuntimeTruncationAndPadding(t *testing.T) {
+	libpath := checkLibraryExists(t)
+	tok, err := FromFile("./tokenizer.json", WithLibraryPath(libpath))
+	require.NoError(t, err, "Failed to load tokenizer from file")
+	t.Cleanup(func() {
+		_ = tok.Close()
+	})
+	text := "The quick brown fox jumps over the lazy dog"
+
+	t.Run("Settings from tokenizer.json", func(t *testing.T) {
+		trunc, err := tok.GetTruncation()
+		require.NoError(t, err)
+		require.NotNil(t, trunc)
+		require.Equal(t, uintptr(128), trunc.MaxLength)
+
+		pad, err := tok.GetPadding()
+		require.NoError(t, err)
+		require.NotNil(t, pad)
+		require.Equal(t, "[PAD]", pad.PadToken)
+		require.Equal(t, PaddingStrategy{Tag: PaddingStrategyFixed, FixedSize: 128}, pad.Strategy)
+	})
+
+	t.Run("Disable and set", func(t *testing.T) {
+		require.NoError(t, tok.DisablePadding())
+		pad, err := tok.GetPadding()
+		require.NoError(t, err)
+		require.Nil(t, pad)
+
+		require.NoError(t, tok.SetTruncation(TruncationParams{MaxLength: 6, Direction: TruncationDirectionRight}))
+		res, err := tok.Encode(text, WithAddSpecialTokens())
+		require.NoError(t, err)
+		require.Len(t, res.IDs, 6)
+
+		require.NoError(t, tok.DisableTruncation())
+		trunc, err := tok.GetTruncation()
+		require.NoError(t, err)
+		require.Nil(t, trunc)
+		require.False(t, tok.TruncationEnabled)
+		res, err = tok.Encode(text, WithAddSpecialTokens())
+		require.NoError(t, err)
+		require.Greater(t, len(res.IDs), 6)
+
+		require.NoError(t, tok.SetPadding(PaddingParams{
+			Strategy:  PaddingStrategy{Tag: PaddingStrategyFixed, FixedSize: 32},
+			Direction: PaddingDirectionLeft,
+		}))
+		pad, err = tok.GetPadding()
+		require.NoError(t, err)
+		require.NotNil(t, pad)
+		require.Equal(t, PaddingDirectionLeft, pad.Direction)
+		require.Equal(t, "[PAD]", pad.PadToken, "The pad token should be kept when none is given")
+		res, err = tok.Encode(text, WithAddSpecialTokens())
+		require.NoError(t, err)
+		require.Len(t, res.IDs, 32)
+		require.Equal(t, uint32(0), res.IDs[0], "Left padding should put [PAD] first")
+	})
+
+	t.Run("Invalid params", func(t *testing.T) {
+		require.Error(t, tok.SetTruncation(TruncationParams{}))
+		require.Error(t, tok.SetPadding(PaddingParams{Strategy: PaddingStrategy{Tag: PaddingStrategyFixed}}))
+	})
+}
@@ -1,9 +1,37 @@
 mod build;
 
+use std::collections::HashSet;
 use std::ffi::CStr;
 use std::ptr;
+use tokenizers::decoders::wordpiece::WordPiece as WordPieceDecoder;
+use tokenizers::models::bpe::{BpeTrainer, BPE};
+use tokenizers::models::unigram::{Unigram, UnigramTrainer};
+use tokenizers::models::wordlevel::{WordLevel, WordLevelTrainer};
+use tokenizers::models::wordpiece::{WordPiece, WordPieceTrainer};
+use tokenizers::models::TrainerWrapper;
+use tokenizers::pre_tokenizers::bert::BertPreTokenizer;
+use tokenizers::pre_tokenizers::byte_level::ByteLevel;
+use tokenizers::pre_tokenizers::metaspace::Metaspace;
+use tokenizers::pre_tokenizers::whitespace::Whitespace;
+use tokenizers::tokenizer::normalizer::Range;
 use tokenizers::tokenizer::Tokenizer;
-use tokenizers::{PaddingParams, PaddingStrategy, TruncationStrategy};
+use tokenizers::{
+    AddedToken, DecodeStream, DecoderWrapper, Encoding, ModelWrapper, NormalizedString, Normalizer,
+    NormalizerWrapper, OffsetReferential, OffsetType, PaddingDirection, PaddingParams,
+    PaddingStrategy, PostProcessorWrapper, PreTokenizedString, PreTokenizer, PreTokenizerWrapper,
+    TruncationDirection, TruncationParams, TruncationStrategy,
+};
+
+// A decode stream borrowing a `Tokenizer` owned by the caller. The 'static lifetime is not
+// enforced by Rust: the caller must free the stream before freeing or modifying the tokenizer.
+type TokenizerDecodeStream = DecodeStream<
+    'static,
+    ModelWrapper,
+    NormalizerWrapper,
+    PreTokenizerWrapper,
+    PostProcessorWrapper,
+    DecoderWrapper,
+>;
 
 // Error codes - expanded for better error reporting
 const SUCCESS: i32 = 0;
@@ -21,6 +49,29 @@ const ERROR_DECODE_FAILED: i32 = -11;
 const ERROR_CSTRING_CONVERSION_FAILED: i32 = -12;
 const ERROR_INVALID_IDS: i32 = -13;
 const ERROR_INVALID_OPTIONS: i32 = -14;
+const ERROR_BUFFER_TOO_SMALL: i32 = -15;
+const ERROR_SERIALIZATION_FAILED: i32 = -16;
+const ERROR_TRAINING_FAILED: i32 = -17;
+
+thread_local! {
+    // Detail of the last failure on this thread, e.g. the serde error of an invalid
+    // tokenizer.json. Read (and cleared) by `take_last_error_message`.
+    static LAST_ERROR: std::cell::RefCell<Option<std::ffi::CString>> =
+        const { std::cell::RefCell::new(None) };
+}
+
+/// Records `err` as the detail of the failure reported by `code` and returns `code`.
+fn fail(code: i32, err: impl std::fmt::Display) -> i32 {
+    let message = std::ffi::CString::new(err.to_string().replace('\0', " ")).ok();
+    LAST_ERROR.with(|last| *last.borrow_mut() = message);
+    code
+}
+
+/// Discards the detail of an earlier failure. Every export that returns an error code
+/// calls it first, so that a failure without detail never reports a stale message.
+fn clear_last_error() {
+    LAST_ERROR.with(|last| *last.borrow_mut() = None);
+}
 
 #[repr(C)]
 pub struct TruncationOptions {
@@ -31,10 +82,25 @@ pub struct TruncationOptions {
     stride: usize,
 }
 
+// Layout version of `PaddingOptions`; bump when fields are added or changed.
+const PADDING_OPTIONS_VERSION: u32 = 1;
+
+#[repr(C)]
+pub struct PaddingStrategyOptions {
+    tag: isize,        // 0 = BatchLongest, 1 = Fixed
+    fixed_size: usize, // only used when tag == 1
+}
+
 #[repr(C)]
 pub struct PaddingOptions {
+    version: u32, // must be PADDING_OPTIONS_VERSION
     enabled: bool,
-    strategy: PaddingStrategy,
+    direction: u8, // 0 = Right, 1 = Left
+    strategy: PaddingStrategyOptions,
+    pad_to_multiple_of: usize, // 0 = disabled
+    pad_id: u32,
+    pad_type_id: u32,
+    pad_token: *const libc::c_char, // null keeps the tokenizer's pad token, id and type id
 }
 
 #[repr(C)]
@@ -52,7 +118,31 @@ pub struct Buffer {
     attention_mask: *mut u32,
     tokens: *mut *mut libc::c_char,
     offsets: *mut usize,
+    word_ids: *mut i32,     // -1 for tokens without a word (e.g. special tokens)
+    sequence_ids: *mut i32, // -1 for tokens without a sequence (e.g. special tokens)
     len: usize,
+    overflowing: *mut Buffer,
+    overflowing_len: usize,
+}
+
+impl Buffer {
+    /// Returns a buffer with every field unset, used as the starting point for
+    /// allocation and to reset a buffer after its contents were freed.
+    fn empty() -> Buffer {
+        Buffer {
+            ids: ptr::null_mut(),
+            type_ids: ptr::null_mut(),
+            special_tokens_mask: ptr::null_mut(),
+            attention_mask: ptr::null_mut(),
+            tokens: ptr::null_mut(),
+            offsets: ptr::null_mut(),
+            word_ids: ptr::null_mut(),
+            sequence_ids: ptr::null_mut(),
+            len: 0,
+            overflowing: ptr::null_mut(),
+            overflowing_len: 0,
+        }
+    }
 }
 
 #[repr(C)]
@@ -63,6 +153,80 @@ pub struct EncodeOptions {
     return_special_tokens_mask: bool,
     return_attention_mask: bool,
     return_offsets: bool,
+    return_overflowing: bool,
+    return_word_ids: bool,
+    return_sequence_ids: bool,
+}
+
+// Caller-owned destination arrays for `encode_into`. Every non-null array must hold at
+// least `capacity` elements; `len` receives the number of tokens of the encoding.
+#[repr(C)]
+pub struct EncodeIntoBuffers {
+    ids: *mut u32,
+    attention_mask: *mut u32,
+    type_ids: *mut u32,
+    ids_i64: *mut i64,
+    attention_mask_i64: *mut i64,
+    type_ids_i64: *mut i64,
+    capacity: usize,
+    len: usize,
+}
+
+#[repr(C)]
+pub struct AddedTokenOptions {
+    content: *const libc::c_char,
+    single_word: bool,
+    lstrip: bool,
+    rstrip: bool,
+    normalized: bool,
+    special: bool,
+}
+
+// Model trained by `train_tokenizer`, with the trainer settings. Zero and null values
+// keep the trainer defaults; settings that do not apply to the model are ignored.
+#[repr(C)]
+pub struct TrainerOptions {
+    model: u8, // 0 = BPE, 1 = WordPiece, 2 = Unigram, 3 = WordLevel
+    vocab_size: usize,
+    min_frequency: u64, // BPE, WordPiece, WordLevel
+    special_tokens: *const *const libc::c_char,
+    special_tokens_len: usize,
+    initial_alphabet: *const u32, // Unicode scalar values; BPE, WordPiece, Unigram
+    initial_alphabet_len: usize,
+    limit_alphabet: usize,                          // BPE, WordPiece
+    unk_token: *const libc::c_char,                 // WordPiece, Unigram, WordLevel
+    continuing_subword_prefix: *const libc::c_char, // BPE, WordPiece
+    end_of_word_suffix: *const libc::c_char,        // BPE, WordPiece
+}
+
+// Normalized text with, for each of its bytes, the [start, end) byte span in the original
+// text that it was produced from.
+#[repr(C)]
+pub struct NormalizedBuffer {
+    text: *mut libc::c_char,
+    offsets: *mut usize, // 2 * len values
+    len: usize,          // Length of text in bytes
+}
+
+// Pre-tokens with their [start, end) byte spans in the original text.
+#[repr(C)]
+pub struct PreTokenBuffer {
+    tokens: *mut *mut libc::c_char,
+    offsets: *mut usize, // 2 * len values
+    len: usize,
+}
+
+#[repr(C)]
+pub struct VocabBuffer {
+    tokens: *mut *mut libc::c_char,
+    ids: *mut u32,
+    len: usize,
+}
+
+#[repr(C)]
+pub struct StringArray {
+    strings: *mut *mut libc::c_char,
+    len: usize,
 }
 
 // Result structures for functions that can fail
@@ -81,6 +245,93 @@ pub struct VocabSizeResult {
     size: u32,
 }
 
+/// Builds `TruncationParams` from the FFI truncation options.
+/// Unknown direction and strategy values fall back to the crate defaults.
+fn truncation_params_from_options(opts: &TruncationOptions) -> TruncationParams {
+    let direction = match opts.direction {
+        0 => TruncationDirection::Left,
+        1 => TruncationDirection::Right,
+        _ => TruncationDirection::default(),
+    };
+
+    let strategy = match opts.strategy {
+        0 => TruncationStrategy::LongestFirst,
+        1 => TruncationStrategy::OnlyFirst,
+        2 => TruncationStrategy::OnlySecond,
+        _ => TruncationStrategy::default(),
+    };
+
+    TruncationParams {
+        direction,
+        max_length: opts.max_len,
+        strategy,
+        stride: opts.stride,
+    }
+}
+
+/// Builds `PaddingParams` from the FFI padding options.
+/// Fields not covered by the options (and the pad token, id and type id when no
+/// pad token is given) are taken from `base`, falling back to the crate defaults.
+///
+/// # Safety
+///
+/// - `opts.pad_token` must be null or a valid pointer to a null-terminated C string
+unsafe fn padding_params_from_options(
+    opts: &PaddingOptions,
+    base: Option<&PaddingParams>,
+) -> Result<PaddingParams, i32> {
+    if opts.version != PADDING_OPTIONS_VERSION {
+        return Err(fail(
+            ERROR_INVALID_OPTIONS,
+            format!(
+                "padding options version {} is not supported, expected {}",
+                opts.version, PADDING_OPTIONS_VERSION
+            ),
+        ));
+    }
+
+    let mut params = base.cloned().unwrap_or_default();
+
+    params.strategy = match opts.strategy.tag {
+        0 => PaddingStrategy::BatchLongest,
+        1 => PaddingStrategy::Fixed(opts.strategy.fixed_size),
+        tag => {
+            return Err(fail(
+                ERROR_INVALID_OPTIONS,
+                format!("unknown padding strategy: {tag}"),
+            ))
+        }
+    };
+
+    params.direction = match opts.direction {
+        0 => PaddingDirection::Right,
+        1 => PaddingDirection::Left,
+        direction => {
+            return Err(fail(
+                ERROR_INVALID_OPTIONS,
+                format!("unknown padding direction: {direction}"),
+            ))
+        }
+    };
+
+    params.pad_to_multiple_of = if opts.pad_to_multiple_of == 0 {
+        None
+    } else {
+        Some(opts.pad_to_multiple_of)
+    };
+
+    if !opts.pad_token.is_null() {
+        params.pad_token = match CStr::from_ptr(opts.pad_token).to_str() {
+            Ok(s) => s.to_string(),
+            Err(e) => return Err(fail(ERROR_INVALID_UTF8, e)),
+        };
+        params.pad_id = opts.pad_id;
+        params.pad_type_id = opts.pad_type_id;
+    }
+
+    Ok(params)
+}
+
 /// Creates a tokenizer from bytes with the given options.
 ///
 /// # Safety
@@ -96,484 +347,1812 @@ pub unsafe extern "C" fn from_bytes(
     opts: *const TokenizerOptions,
     out: &mut TokenizerResult,
 ) -> i32 {
+    clear_last_error();
     if bytes.is_null() {
-        return ERROR_NULL_OUTPUT;
+        return fail(ERROR_NULL_INPUT, "input is null");
     }
 
     if opts.is_null() {
-        return ERROR_INVALID_OPTIONS;
+        return fail(ERROR_INVALID_OPTIONS, "options are null");
     }
 
     let bytes_slice = std::slice::from_raw_parts(bytes, len as usize);
     let mut tok = match Tokenizer::from_bytes(bytes_slice) {
         Ok(t) => t,
-        Err(_) => {
-            return ERROR_TOKENIZER_CREATION_FAILED;
-        }
+        Err(e) => return fail(ERROR_TOKENIZER_CREATION_FAILED, e),
     };
 
-    let opts = &*opts;
-    tok.set_encode_special_tokens(opts.add_special_tokens);
-
-    if opts.trunc.enabled {
-        use tokenizers::tokenizer::{TruncationDirection, TruncationParams};
+    let rc = apply_tokenizer_options(&mut tok, &*opts);
+    if rc != SUCCESS {
+        return rc;
+    }
 
-        let dir = match opts.trunc.direction {
-            0 => TruncationDirection::Left,
-            1 => TruncationDirection::Right,
-            _ => TruncationDirection::default(),
-        };
+    out.tokenizer = Box::into_raw(Box::new(tok));
+    SUCCESS
+}
 
-        let strat = match opts.trunc.strategy {
-            0 => TruncationStrategy::LongestFirst,
-            1 => TruncationStrategy::OnlyFirst,
-            2 => TruncationStrategy::OnlySecond,
-            _ => TruncationStrategy::default(),
-        };
+/// Applies the load-time options shared by the tokenizer constructors.
+/// Returns 0 on success, negative error code on failure.
+///
+/// # Safety
+///
+/// - `opts.pad.pad_token` must be null or a valid pointer to a null-terminated C string
+unsafe fn apply_tokenizer_options(tok: &mut Tokenizer, opts: &TokenizerOptions) -> i32 {
+    tok.set_encode_special_tokens(opts.add_special_tokens);
 
-        if tok
-            .with_truncation(Some(TruncationParams {
-                direction: dir,
-                max_length: opts.trunc.max_len,
-                strategy: strat,
-                stride: opts.trunc.stride,
-            }))
-            .is_err()
-        {
-            return ERROR_TRUNCATION_FAILED;
+    if opts.trunc.enabled {
+        if let Err(e) = tok.with_truncation(Some(truncation_params_from_options(&opts.trunc))) {
+            return fail(ERROR_TRUNCATION_FAILED, e);
         }
     }
 
     if opts.pad.enabled {
-        tok.with_padding(Some(PaddingParams {
-            strategy: opts.pad.strategy.clone(),
-            ..Default::default()
-        }));
+        match padding_params_from_options(&opts.pad, tok.get_padding()) {
+            Ok(params) => {
+                tok.with_padding(Some(params));
+            }
+            Err(code) => return code,
+        }
     }
 
-    out.tokenizer = Box::into_raw(Box::new(tok));
     SUCCESS
 }
 
-/// Creates a tokenizer from a file path.
+/// Replaces the truncation settings of an existing tokenizer; disabled options remove
+/// truncation. Returns 0 on success, negative error code on failure.
 ///
 /// # Safety
 ///
-/// - `config` must be a valid pointer to a null-terminated C string
-/// - The returned tokenizer pointer must be freed using `free_tokenizer` when no longer needed
+/// - `ptr` must be a valid pointer to a `Tokenizer` created by `from_bytes` or `from_file`
+/// - `opts` must be a valid pointer to a `TruncationOptions` struct
+/// - No other call may use the tokenizer concurrently
 #[no_mangle]
-pub unsafe extern "C" fn from_file(config: *const libc::c_char, out: &mut TokenizerResult) -> i32 {
-    if config.is_null() {
-        return ERROR_NULL_INPUT;
+pub unsafe extern "C" fn set_truncation(
+    ptr: *mut Tokenizer,
+    opts: *const TruncationOptions,
+) -> i32 {
+    clear_last_error();
+    if opts.is_null() {
+        return fail(ERROR_INVALID_OPTIONS, "options are null");
     }
 
-    let config_cstr = CStr::from_ptr(config);
-    let config_str = match config_cstr.to_str() {
-        Ok(s) => s,
-        Err(_) => {
-            return ERROR_INVALID_PATH;
-        }
+    let tokenizer: &mut Tokenizer = match ptr.as_mut() {
+        Some(t) => t,
+        None => return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null"),
     };
 
-    let config_path = std::path::Path::new(config_str);
+    let opts = &*opts;
+    let params = if opts.enabled {
+        Some(truncation_params_from_options(opts))
+    } else {
+        None
+    };
 
-    match Tokenizer::from_file(config_path) {
-        Ok(tok) => {
-            out.tokenizer = Box::into_raw(Box::new(tok));
-            SUCCESS
-        }
-        Err(e) => {
-            // Try to determine if it's a file not found error
-            if e.to_string().contains("No such file") || e.to_string().contains("not found") {
-                ERROR_FILE_NOT_FOUND
-            } else {
-                ERROR_TOKENIZER_CREATION_FAILED
-            }
-        }
+    match tokenizer.with_truncation(params) {
+        Ok(_) => SUCCESS,
+        Err(e) => fail(ERROR_TRUNCATION_FAILED, e),
     }
 }
 
-/// Encodes a message using the tokenizer.
+/// Reads the truncation settings of a tokenizer. `enabled` is false if the tokenizer
+/// does not truncate.
+///
+/// # Safety
+///
+/// - `ptr` must be a valid pointer to a `Tokenizer` created by `from_bytes` or `from_file`
+/// - `out` must be a valid pointer to a `TruncationOptions` struct
+#[no_mangle]
+pub unsafe extern "C" fn get_truncation(ptr: *mut Tokenizer, out: *mut TruncationOptions) -> i32 {
+    clear_last_error();
+    if out.is_null() {
+        return fail(ERROR_NULL_OUTPUT, "output is null");
+    }
+
+    let tokenizer: &Tokenizer = match ptr.as_ref() {
+        Some(t) => t,
+        None => return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null"),
+    };
+
+    let opts = match tokenizer.get_truncation() {
+        Some(params) => TruncationOptions {
+            enabled: true,
+            max_len: params.max_length,
+            strategy: match params.strategy {
+                TruncationStrategy::LongestFirst => 0,
+                TruncationStrategy::OnlyFirst => 1,
+                TruncationStrategy::OnlySecond => 2,
+            },
+            direction: match params.direction {
+                TruncationDirection::Left => 0,
+                TruncationDirection::Right => 1,
+            },
+            stride: params.stride,
+        },
+        None => TruncationOptions {
+            enabled: false,
+            max_len: 0,
+            strategy: 0,
+            direction: 0,
+            stride: 0,
+        },
+    };
+    ptr::write(out, opts);
+    SUCCESS
+}
+
+/// Replaces the padding settings of an existing tokenizer; disabled options remove
+/// padding. Settings not covered by the options are kept from the current padding.
 /// Returns 0 on success, negative error code on failure.
 ///
 /// # Safety
 ///
 /// - `ptr` must be a valid pointer to a `Tokenizer` created by `from_bytes` or `from_file`
-/// - `message` must be a valid pointer to a null-terminated C string
-/// - `options` must be a valid pointer to an `EncodeOptions` struct
-/// - `out` must be a valid pointer to a `Buffer` struct that will receive the results
-/// - The caller is responsible for freeing the buffer using `free_buffer`
+/// - `opts` must be a valid pointer to a `PaddingOptions` struct
+/// - No other call may use the tokenizer concurrently
 #[no_mangle]
-pub unsafe extern "C" fn encode(
-    ptr: *mut Tokenizer,
-    message: *const libc::c_char,
-    options: *const EncodeOptions,
-    out: *mut Buffer,
-) -> i32 {
-    if ptr.is_null() {
-        return ERROR_INVALID_TOKENIZER_REF;
+pub unsafe extern "C" fn set_padding(ptr: *mut Tokenizer, opts: *const PaddingOptions) -> i32 {
+    clear_last_error();
+    if opts.is_null() {
+        return fail(ERROR_INVALID_OPTIONS, "options are null");
     }
 
-    if message.is_null() {
-        return ERROR_NULL_INPUT;
+    let tokenizer: &mut Tokenizer = match ptr.as_mut() {
+        Some(t) => t,
+        None => return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null"),
+    };
+
+    let opts = &*opts;
+    if !opts.enabled {
+        tokenizer.with_padding(None);
+        return SUCCESS;
     }
 
-    if options.is_null() {
-        return ERROR_INVALID_OPTIONS;
+    match padding_params_from_options(opts, tokenizer.get_padding()) {
+        Ok(params) => {
+            tokenizer.with_padding(Some(params));
+            SUCCESS
+        }
+        Err(code) => code,
     }
+}
 
+/// Reads the padding settings of a tokenizer. `enabled` is false if the tokenizer
+/// does not pad.
+///
+/// # Safety
+///
+/// - `ptr` must be a valid pointer to a `Tokenizer` created by `from_bytes` or `from_file`
+/// - `out` must be a valid pointer to a `PaddingOptions` struct
+/// - The caller is responsible for freeing a non-null `pad_token` using `free_string`
+#[no_mangle]
+pub unsafe extern "C" fn get_padding(ptr: *mut Tokenizer, out: *mut PaddingOptions) -> i32 {
+    clear_last_error();
     if out.is_null() {
-        return ERROR_NULL_OUTPUT;
+        return fail(ERROR_NULL_OUTPUT, "output is null");
     }
 
     let tokenizer: &Tokenizer = match ptr.as_ref() {
         Some(t) => t,
-        None => return ERROR_INVALID_TOKENIZER_REF,
+        None => return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null"),
     };
 
-    let message_cstr = CStr::from_ptr(message);
-    let message_str = match message_cstr.to_str() {
+    let mut opts = PaddingOptions {
+        version: PADDING_OPTIONS_VERSION,
+        enabled: false,
+        direction: 0,
+        strategy: PaddingStrategyOptions {
+            tag: 0,
+            fixed_size: 0,
+        },
+        pad_to_multiple_of: 0,
+        pad_id: 0,
+        pad_type_id: 0,
+        pad_token: ptr::null(),
+    };
+
+    if let Some(params) = tokenizer.get_padding() {
+        let pad_token = match std::ffi::CString::new(params.pad_token.as_str()) {
+            Ok(s) => s,
+            Err(e) => return fail(ERROR_CSTRING_CONVERSION_FAILED, e),
+        };
+        opts.enabled = true;
+        opts.direction = match params.direction {
+            PaddingDirection::Right => 0,
+            PaddingDirection::Left => 1,
+        };
+        opts.strategy = match params.strategy {
+            PaddingStrategy::BatchLongest => PaddingStrategyOptions {
+                tag: 0,
+                fixed_size: 0,
+            },
+            PaddingStrategy::Fixed(size) => PaddingStrategyOptions {
+                tag: 1,
+                fixed_size: size,
+            },
+        };
+        opts.pad_to_multiple_of = params.pad_to_multiple_of.unwrap_or(0);
+        opts.pad_id = params.pad_id;
+        opts.pad_type_id = params.pad_type_id;
+        opts.pad_token = pad_token.into_raw();
+    }
+
+    ptr::write(out, opts);
+    SUCCESS
+}
+
+/// Creates an independent copy of a tokenizer, e.g. to change its truncation or padding
+/// without affecting callers of the original.
+///
+/// # Safety
+///
+/// - `ptr` must be a valid pointer to a `Tokenizer` created by `from_bytes` or `from_file`
+/// - The returned tokenizer pointer must be freed using `free_tokenizer` when no longer needed
+#[no_mangle]
+pub unsafe extern "C" fn clone_tokenizer(ptr: *mut Tokenizer, out: &mut TokenizerResult) -> i32 {
+    clear_last_error();
+    let tokenizer: &Tokenizer = match ptr.as_ref() {
+        Some(t) => t,
+        None => return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null"),
+    };
+
+    out.tokenizer = Box::into_raw(Box::new(tokenizer.clone()));
+    SUCCESS
+}
+
+/// Creates a tokenizer from a file path.
+///
+/// # Safety
+///
+/// - `config` must be a valid pointer to a null-terminated C string
+/// - The returned tokenizer pointer must be freed using `free_tokenizer` when no longer needed
+#[no_mangle]
+pub unsafe extern "C" fn from_file(config: *const libc::c_char, out: &mut TokenizerResult) -> i32 {
+    clear_last_error();
+    if config.is_null() {
+        return fail(ERROR_NULL_INPUT, "input is null");
+    }
+
+    let config_cstr = CStr::from_ptr(config);
+    let config_str = match config_cstr.to_str() {
         Ok(s) => s,
-        Err(_) => return ERROR_INVALID_UTF8,
+        Err(e) => return fail(ERROR_INVALID_PATH, e),
     };
 
-    let options = &*options;
+    let config_path = std::path::Path::new(config_str);
 
-    let encoding = match tokenizer.encode(message_str, options.add_special_tokens) {
-        Ok(enc) => enc,
-        Err(_) => return ERROR_ENCODING_FAILED,
+    match Tokenizer::from_file(config_path) {
+        Ok(tok) => {
+            out.tokenizer = Box::into_raw(Box::new(tok));
+            SUCCESS
+        }
+        Err(e) => {
+            // Try to determine if it's a file not found error
+            let code =
+                if e.to_string().contains("No such file") || e.to_string().contains("not found") {
+                    ERROR_FILE_NOT_FOUND
+                } else {
+                    ERROR_TOKENIZER_CREATION_FAILED
+                };
+            fail(code, e)
+        }
+    }
+}
+
+/// Copies the fields of an `Encoding` requested by `options` into a `Buffer`
+/// whose memory is owned by the caller and must be released with `free_buffer`.
+/// On failure every partially allocated field is freed before returning.
+///
+/// # Safety
+///
+/// The returned buffer holds raw allocations that must be freed exactly once.
+unsafe fn encoding_to_buffer(encoding: &Encoding, options: &EncodeOptions) -> Result<Buffer, i32> {
+    let mut buffer = Buffer {
+        len: encoding.get_ids().len(),
+        ..Buffer::empty()
     };
 
     // Prepare IDs (always needed)
     let mut vec_ids = encoding.get_ids().to_vec();
     vec_ids.shrink_to_fit();
-    let ids = vec_ids.as_mut_ptr();
-    let len = vec_ids.len();
+    buffer.ids = vec_ids.as_mut_ptr();
     std::mem::forget(vec_ids);
 
     // Prepare type IDs if requested
-    let mut type_ids: *mut u32 = ptr::null_mut();
     if options.return_type_ids {
         let mut vec_type_ids = encoding.get_type_ids().to_vec();
         vec_type_ids.shrink_to_fit();
-        type_ids = vec_type_ids.as_mut_ptr();
+        buffer.type_ids = vec_type_ids.as_mut_ptr();
         std::mem::forget(vec_type_ids);
     }
 
     // Prepare tokens if requested
-    let mut tokens: *mut *mut libc::c_char = ptr::null_mut();
     if options.return_tokens {
         let mut vec_tokens = Vec::with_capacity(encoding.get_tokens().len());
         for token in encoding.get_tokens() {
             match std::ffi::CString::new(token.as_str()) {
                 Ok(cstr) => vec_tokens.push(cstr.into_raw()),
-                Err(_) => {
-                    // Clean up already allocated tokens
+                Err(e) => {
+                    // Clean up already allocated tokens and fields
                     for allocated_token in vec_tokens {
                         drop(std::ffi::CString::from_raw(allocated_token));
                     }
-                    return ERROR_CSTRING_CONVERSION_FAILED;
+                    free_buffer_contents(buffer);
+                    return Err(fail(ERROR_CSTRING_CONVERSION_FAILED, e));
                 }
             }
         }
         vec_tokens.shrink_to_fit();
-        tokens = vec_tokens.as_mut_ptr();
+        buffer.tokens = vec_tokens.as_mut_ptr();
         std::mem::forget(vec_tokens);
     }
 
     // Prepare special tokens mask if requested
-    let mut special_tokens_mask: *mut u32 = ptr::null_mut();
     if options.return_special_tokens_mask {
         let mut vec_special_tokens_mask = encoding.get_special_tokens_mask().to_vec();
         vec_special_tokens_mask.shrink_to_fit();
-        special_tokens_mask = vec_special_tokens_mask.as_mut_ptr();
+        buffer.special_tokens_mask = vec_special_tokens_mask.as_mut_ptr();
         std::mem::forget(vec_special_tokens_mask);
     }
 
     // Prepare attention mask if requested
-    let mut attention_mask: *mut u32 = ptr::null_mut();
     if options.return_attention_mask {
         let mut vec_attention_mask = encoding.get_attention_mask().to_vec();
         vec_attention_mask.shrink_to_fit();
-        attention_mask = vec_attention_mask.as_mut_ptr();
+        buffer.attention_mask = vec_attention_mask.as_mut_ptr();
         std::mem::forget(vec_attention_mask);
     }
 
     // Prepare offsets if requested
-    let mut offsets: *mut usize = ptr::null_mut();
     if options.return_offsets {
-        let vec_offsets_tuples = encoding.get_offsets().to_vec();
-        let mut vec_offsets = Vec::with_capacity(vec_offsets_tuples.len() * 2);
-        for (start, end) in vec_offsets_tuples {
+        let offsets = encoding.get_offsets();
+        let mut vec_offsets = Vec::with_capacity(offsets.len() * 2);
+        for &(start, end) in offsets {
             vec_offsets.push(start);
             vec_offsets.push(end);
         }
         vec_offsets.shrink_to_fit();
-        offsets = vec_offsets.as_mut_ptr();
+        buffer.offsets = vec_offsets.as_mut_ptr();
         std::mem::forget(vec_offsets);
     }
 
-    *out = Buffer {
-        ids,
-        type_ids,
-        special_tokens_mask,
-        attention_mask,
-        tokens,
-        offsets,
-        len,
-    };
+    // Prepare word IDs if requested
+    if options.return_word_ids {
+        let mut vec_word_ids: Vec<i32> = encoding
+            .get_word_ids()
+            .iter()
+            .map(|w| match w {
+                Some(w) => *w as i32,
+                None => -1,
+            })
+            .collect();
+        vec_word_ids.shrink_to_fit();
+        buffer.word_ids = vec_word_ids.as_mut_ptr();
+        std::mem::forget(vec_word_ids);
+    }
 
-    SUCCESS
+    // Prepare sequence IDs if requested
+    if options.return_sequence_ids {
+        let mut vec_sequence_ids: Vec<i32> = encoding
+            .get_sequence_ids()
+            .into_iter()
+            .map(|s| match s {
+                Some(s) => s as i32,
+                None => -1,
+            })
+            .collect();
+        vec_sequence_ids.shrink_to_fit();
+        buffer.sequence_ids = vec_sequence_ids.as_mut_ptr();
+        std::mem::forget(vec_sequence_ids);
+    }
+
+    // Prepare overflowing windows (produced by truncation with stride) if requested
+    if options.return_overflowing && !encoding.get_overflowing().is_empty() {
+        let mut vec_overflowing = Vec::with_capacity(encoding.get_overflowing().len());
+        for overflow in encoding.get_overflowing() {
+            match encoding_to_buffer(overflow, options) {
+                Ok(child) => vec_overflowing.push(child),
+                Err(code) => {
+                    for child in vec_overflowing {
+                        free_buffer_contents(child);
+                    }
+                    free_buffer_contents(buffer);
+                    return Err(code);
+                }
+            }
+        }
+        vec_overflowing.shrink_to_fit();
+        buffer.overflowing_len = vec_overflowing.len();
+        buffer.overflowing = vec_overflowing.as_mut_ptr();
+        std::mem::forget(vec_overflowing);
+    }
+
+    Ok(buffer)
 }
 
-/// Encodes multiple sequence pairs using the tokenizer in parallel.
-/// Returns 0 on success, negative error code on failure.
+/// Converts a batch of encodings into buffers and writes them to `out`.
+/// Buffers are only written once all of them were allocated successfully,
+/// so a failure part-way through never leaks or leaves `out` half-initialized.
 ///
 /// # Safety
 ///
-/// - `ptr` must be a valid pointer to a `Tokenizer` created by `from_bytes` or `from_file`
-/// - `sequences` must be a valid pointer to at least `count` pointers to null-terminated C strings
-/// - `pairs` must be a valid pointer to at least `count` pointers to null-terminated C strings
-/// - `options` must be a valid pointer to an `EncodeOptions` struct
-/// - `out` must be a valid pointer to an array of at least `count` `Buffer` structs
-/// - The caller is responsible for freeing each buffer using `free_buffer`
-#[no_mangle]
-pub unsafe extern "C" fn encode_batch_pairs(
-    ptr: *mut Tokenizer,
-    sequences: *const *const libc::c_char,
-    pairs: *const *const libc::c_char,
-    count: usize,
-    options: *const EncodeOptions,
+/// - `out` must be a valid pointer to an array of at least `encodings.len()` `Buffer` structs
+unsafe fn write_encodings(
+    encodings: &[Encoding],
+    options: &EncodeOptions,
     out: *mut Buffer,
 ) -> i32 {
-    if ptr.is_null() {
-        return ERROR_INVALID_TOKENIZER_REF;
-    }
+    // Two-phase allocation to prevent memory leaks on error:
+    // Phase 1: Allocate all buffers into temp storage with proper error handling
+    // Phase 2: Only write to output if ALL allocations succeed
+    let mut temp_buffers: Vec<Buffer> = Vec::with_capacity(encodings.len());
 
-    if sequences.is_null() || pairs.is_null() {
-        return ERROR_NULL_INPUT;
+    for encoding in encodings.iter() {
+        match encoding_to_buffer(encoding, options) {
+            Ok(buffer) => temp_buffers.push(buffer),
+            Err(code) => {
+                // Clean up all previously allocated buffers in temp_buffers
+                for buffer in temp_buffers {
+                    free_buffer_contents(buffer);
+                }
+                return code;
+            }
+        }
+    }
+
+    // Phase 2: All allocations succeeded, now write to output
+    for (i, buffer) in temp_buffers.into_iter().enumerate() {
+        ptr::write(out.add(i), buffer);
+    }
+
+    SUCCESS
+}
+
+/// Encodes a message using the tokenizer.
+/// Returns 0 on success, negative error code on failure.
+///
+/// # Safety
+///
+/// - `ptr` must be a valid pointer to a `Tokenizer` created by `from_bytes` or `from_file`
+/// - `message` must be a valid pointer to a null-terminated C string
+/// - `options` must be a valid pointer to an `EncodeOptions` struct
+/// - `out` must be a valid pointer to a `Buffer` struct that will receive the results
+/// - The caller is responsible for freeing the buffer using `free_buffer`
+#[no_mangle]
+pub unsafe extern "C" fn encode(
+    ptr: *mut Tokenizer,
+    message: *const libc::c_char,
+    options: *const EncodeOptions,
+    out: *mut Buffer,
+) -> i32 {
+    clear_last_error();
+    if ptr.is_null() {
+        return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null");
+    }
+
+    if message.is_null() {
+        return fail(ERROR_NULL_INPUT, "input is null");
+    }
+
+    if options.is_null() {
+        return fail(ERROR_INVALID_OPTIONS, "encode options are null");
+    }
+
+    if out.is_null() {
+        return fail(ERROR_NULL_OUTPUT, "output is null");
+    }
+
+    let tokenizer: &Tokenizer = match ptr.as_ref() {
+        Some(t) => t,
+        None => return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null"),
+    };
+
+    let message_cstr = CStr::from_ptr(message);
+    let message_str = match message_cstr.to_str() {
+        Ok(s) => s,
+        Err(e) => return fail(ERROR_INVALID_UTF8, e),
+    };
+
+    let options = &*options;
+
+    let encoding = match tokenizer.encode(message_str, options.add_special_tokens) {
+        Ok(enc) => enc,
+        Err(e) => return fail(ERROR_ENCODING_FAILED, e),
+    };
+
+    match encoding_to_buffer(&encoding, options) {
+        Ok(buffer) => {
+            *out = buffer;
+            SUCCESS
+        }
+        Err(code) => code,
+    }
+}
+
+/// Copies `src` into whichever of `dst` and `dst_i64` are non-null.
+///
+/// # Safety
+///
+/// Non-null destinations must be valid for writes of `src.len()` elements.
+unsafe fn copy_into(src: &[u32], dst: *mut u32, dst_i64: *mut i64) {
+    if !dst.is_null() {
+        ptr::copy_nonoverlapping(src.as_ptr(), dst, src.len());
+    }
+    if !dst_i64.is_null() {
+        for (i, v) in src.iter().enumerate() {
+            *dst_i64.add(i) = *v as i64;
+        }
+    }
+}
+
+/// Encodes a message directly into caller-owned arrays, without allocating a `Buffer`.
+/// Returns 0 on success, negative error code on failure. If the encoding has more than
+/// `capacity` tokens, `ERROR_BUFFER_TOO_SMALL` is returned and `len` is set to the
+/// required capacity.
+///
+/// # Safety
+///
+/// - `ptr` must be a valid pointer to a `Tokenizer` created by `from_bytes` or `from_file`
+/// - `message` must be a valid pointer to at least `message_len` bytes of UTF-8, and may
+///   be null only if `message_len` is 0; it need not be null-terminated
+/// - `options` must be a valid pointer to an `EncodeOptions` struct
+/// - `out` must be a valid pointer to an `EncodeIntoBuffers` struct whose non-null
+///   arrays hold at least `capacity` elements
+#[no_mangle]
+pub unsafe extern "C" fn encode_into(
+    ptr: *mut Tokenizer,
+    message: *const u8,
+    message_len: usize,
+    options: *const EncodeOptions,
+    out: *mut EncodeIntoBuffers,
+) -> i32 {
+    clear_last_error();
+    if ptr.is_null() {
+        return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null");
+    }
+
+    if message.is_null() && message_len > 0 {
+        return fail(ERROR_NULL_INPUT, "input is null");
+    }
+
+    if options.is_null() {
+        return fail(ERROR_INVALID_OPTIONS, "encode options are null");
+    }
+
+    if out.is_null() {
+        return fail(ERROR_NULL_OUTPUT, "output is null");
+    }
+
+    let tokenizer: &Tokenizer = match ptr.as_ref() {
+        Some(t) => t,
+        None => return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null"),
+    };
+
+    // The message is passed with its length so that callers need not copy it into a
+    // null-terminated string.
+    let message_bytes: &[u8] = if message_len == 0 {
+        &[]
+    } else {
+        std::slice::from_raw_parts(message, message_len)
+    };
+    let message_str = match std::str::from_utf8(message_bytes) {
+        Ok(s) => s,
+        Err(e) => return fail(ERROR_INVALID_UTF8, e),
+    };
+
+    let options = &*options;
+
+    let encoding = match tokenizer.encode(message_str, options.add_special_tokens) {
+        Ok(enc) => enc,
+        Err(e) => return fail(ERROR_ENCODING_FAILED, e),
+    };
+
+    let out = &mut *out;
+    let len = encoding.get_ids().len();
+    out.len = len;
+    if len > out.capacity {
+        return fail(
+            ERROR_BUFFER_TOO_SMALL,
+            format!(
+                "encoding has {} tokens but capacity is {}",
+                len, out.capacity
+            ),
+        );
+    }
+
+    copy_into(encoding.get_ids(), out.ids, out.ids_i64);
+    copy_into(
+        encoding.get_attention_mask(),
+        out.attention_mask,
+        out.attention_mask_i64,
+    );
+    copy_into(encoding.get_type_ids(), out.type_ids, out.type_ids_i64);
+    SUCCESS
+}
+
+/// Encodes multiple single sequences using the tokenizer in parallel.
+/// Tokenizer-level padding (e.g. `BatchLongest`) is applied across the whole batch.
+/// Returns 0 on success, negative error code on failure.
+///
+/// # Safety
+///
+/// - `ptr` must be a valid pointer to a `Tokenizer` created by `from_bytes` or `from_file`
+/// - `messages` must be a valid pointer to at least `count` pointers to null-terminated C strings
+/// - `options` must be a valid pointer to an `EncodeOptions` struct
+/// - `out` must be a valid pointer to an array of at least `count` `Buffer` structs
+/// - The caller is responsible for freeing each buffer using `free_buffer`
+#[no_mangle]
+pub unsafe extern "C" fn encode_batch(
+    ptr: *mut Tokenizer,
+    messages: *const *const libc::c_char,
+    count: usize,
+    options: *const EncodeOptions,
+    out: *mut Buffer,
+) -> i32 {
+    clear_last_error();
+    if ptr.is_null() {
+        return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null");
+    }
+
+    if messages.is_null() {
+        return fail(ERROR_NULL_INPUT, "input is null");
+    }
+
+    if options.is_null() {
+        return fail(ERROR_INVALID_OPTIONS, "encode options are null");
+    }
+
+    if out.is_null() {
+        return fail(ERROR_NULL_OUTPUT, "output is null");
+    }
+
+    if count == 0 {
+        return SUCCESS; // Nothing to encode
+    }
+
+    let tokenizer: &Tokenizer = match ptr.as_ref() {
+        Some(t) => t,
+        None => return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null"),
+    };
+
+    let options = &*options;
+
+    // Convert C string array to Rust Vec of &str
+    let mut inputs: Vec<&str> = Vec::with_capacity(count);
+
+    for i in 0..count {
+        let msg_ptr = *messages.add(i);
+        if msg_ptr.is_null() {
+            return fail(ERROR_NULL_INPUT, "input is null");
+        }
+
+        match CStr::from_ptr(msg_ptr).to_str() {
+            Ok(s) => inputs.push(s),
+            Err(e) => return fail(ERROR_INVALID_UTF8, e),
+        }
+    }
+
+    // Encode all sequences in parallel
+    let encodings = match tokenizer.encode_batch(inputs, options.add_special_tokens) {
+        Ok(encs) => encs,
+        Err(e) => return fail(ERROR_ENCODING_FAILED, e),
+    };
+
+    write_encodings(&encodings, options, out)
+}
+
+/// Encodes multiple sequence pairs using the tokenizer in parallel.
+/// Returns 0 on success, negative error code on failure.
+///
+/// # Safety
+///
+/// - `ptr` must be a valid pointer to a `Tokenizer` created by `from_bytes` or `from_file`
+/// - `sequences` must be a valid pointer to at least `count` pointers to null-terminated C strings
+/// - `pairs` must be a valid pointer to at least `count` pointers to null-terminated C strings
+/// - `options` must be a valid pointer to an `EncodeOptions` struct
+/// - `out` must be a valid pointer to an array of at least `count` `Buffer` structs
+/// - The caller is responsible for freeing each buffer using `free_buffer`
+#[no_mangle]
+pub unsafe extern "C" fn encode_batch_pairs(
+    ptr: *mut Tokenizer,
+    sequences: *const *const libc::c_char,
+    pairs: *const *const libc::c_char,
+    count: usize,
+    options: *const EncodeOptions,
+    out: *mut Buffer,
+) -> i32 {
+    clear_last_error();
+    if ptr.is_null() {
+        return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null");
+    }
+
+    if sequences.is_null() || pairs.is_null() {
+        return fail(ERROR_NULL_INPUT, "input is null");
     }
 
     if options.is_null() {
-        return ERROR_INVALID_OPTIONS;
+        return fail(ERROR_INVALID_OPTIONS, "encode options are null");
+    }
+
+    if out.is_null() {
+        return fail(ERROR_NULL_OUTPUT, "output is null");
+    }
+
+    if count == 0 {
+        return SUCCESS; // Nothing to encode
+    }
+
+    let tokenizer: &Tokenizer = match ptr.as_ref() {
+        Some(t) => t,
+        None => return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null"),
+    };
+
+    let options = &*options;
+
+    // Convert C string arrays to Rust Vec of tuples
+    let mut inputs: Vec<(&str, &str)> = Vec::with_capacity(count);
+
+    for i in 0..count {
+        let seq_ptr = *sequences.add(i);
+        let pair_ptr = *pairs.add(i);
+
+        if seq_ptr.is_null() || pair_ptr.is_null() {
+            return fail(ERROR_NULL_INPUT, "input is null");
+        }
+
+        let seq_cstr = CStr::from_ptr(seq_ptr);
+        let pair_cstr = CStr::from_ptr(pair_ptr);
+
+        let seq_str = match seq_cstr.to_str() {
+            Ok(s) => s,
+            Err(e) => return fail(ERROR_INVALID_UTF8, e),
+        };
+
+        let pair_str = match pair_cstr.to_str() {
+            Ok(s) => s,
+            Err(e) => return fail(ERROR_INVALID_UTF8, e),
+        };
+
+        inputs.push((seq_str, pair_str));
+    }
+
+    // Encode all pairs in parallel
+    let encodings = match tokenizer.encode_batch(inputs, options.add_special_tokens) {
+        Ok(encs) => encs,
+        Err(e) => return fail(ERROR_ENCODING_FAILED, e),
+    };
+
+    write_encodings(&encodings, options, out)
+}
+
+/// Decodes token IDs back to text.
+///
+/// # Safety
+///
+/// - `ptr` must be a valid pointer to a `Tokenizer` created by `from_bytes` or `from_file`
+/// - `ids` must be a valid pointer to at least `len` `u32` values
+/// - The returned string must be freed using `free_string` when no longer needed
+#[no_mangle]
+pub unsafe extern "C" fn decode(
+    ptr: *mut Tokenizer,
+    ids: *const u32,
+    len: u32,
+    skip_special_tokens: bool,
+    out: *mut *mut libc::c_char,
+) -> i32 {
+    clear_last_error();
+    if ptr.is_null() {
+        return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null");
     }
+    if ids.is_null() || len == 0 {
+        return fail(ERROR_INVALID_IDS, "token IDs are null or empty");
+    }
+    if out.is_null() {
+        return fail(ERROR_NULL_OUTPUT, "output is null");
+    }
+
+    let tokenizer: &Tokenizer = match ptr.as_ref() {
+        Some(t) => t,
+        None => return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null"),
+    };
 
+    let ids_slice = std::slice::from_raw_parts(ids, len as usize);
+    let string = match tokenizer.decode(ids_slice, skip_special_tokens) {
+        Ok(s) => s,
+        Err(e) => return fail(ERROR_DECODE_FAILED, e),
+    };
+
+    // CString::new fails if `string` contains interior NULs.
+    let c_string = match std::ffi::CString::new(string) {
+        Ok(s) => s,
+        Err(e) => return fail(ERROR_CSTRING_CONVERSION_FAILED, e),
+    };
+
+    // Transfer ownership to caller; they must free with `decode_free`.
+    let raw = c_string.into_raw();
+    ptr::write(out, raw);
+    SUCCESS
+}
+
+/// Decodes multiple sequences of token IDs in parallel.
+/// Returns 0 on success, negative error code on failure.
+///
+/// Unlike `decode`, zero-length sequences are accepted and decode to an empty string.
+///
+/// # Safety
+///
+/// - `ptr` must be a valid pointer to a `Tokenizer` created by `from_bytes` or `from_file`
+/// - `ids` must be a valid pointer to at least `count` pointers to token ID arrays;
+///   an entry may be null only if its length is 0
+/// - `lens` must be a valid pointer to at least `count` lengths
+/// - `out` must be a valid pointer to a `StringArray` struct
+/// - The caller is responsible for freeing the array using `free_string_array`
+#[no_mangle]
+pub unsafe extern "C" fn decode_batch(
+    ptr: *mut Tokenizer,
+    ids: *const *const u32,
+    lens: *const usize,
+    count: usize,
+    skip_special_tokens: bool,
+    out: *mut StringArray,
+) -> i32 {
+    clear_last_error();
+    if ptr.is_null() {
+        return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null");
+    }
+    if out.is_null() {
+        return fail(ERROR_NULL_OUTPUT, "output is null");
+    }
+    if count == 0 {
+        ptr::write(
+            out,
+            StringArray {
+                strings: ptr::null_mut(),
+                len: 0,
+            },
+        );
+        return SUCCESS;
+    }
+    if ids.is_null() || lens.is_null() {
+        return fail(ERROR_INVALID_IDS, "token IDs are null");
+    }
+
+    let tokenizer: &Tokenizer = match ptr.as_ref() {
+        Some(t) => t,
+        None => return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null"),
+    };
+
+    let mut sequences: Vec<&[u32]> = Vec::with_capacity(count);
+    for i in 0..count {
+        let seq_ptr = *ids.add(i);
+        let seq_len = *lens.add(i);
+        if seq_len == 0 {
+            sequences.push(&[]);
+            continue;
+        }
+        if seq_ptr.is_null() {
+            return fail(
+                ERROR_INVALID_IDS,
+                format!("token IDs of sequence {i} are null"),
+            );
+        }
+        sequences.push(std::slice::from_raw_parts(seq_ptr, seq_len));
+    }
+
+    let decoded = match tokenizer.decode_batch(&sequences, skip_special_tokens) {
+        Ok(d) => d,
+        Err(e) => return fail(ERROR_DECODE_FAILED, e),
+    };
+
+    let mut vec_strings: Vec<*mut libc::c_char> = Vec::with_capacity(decoded.len());
+    for string in decoded {
+        match std::ffi::CString::new(string) {
+            Ok(cstr) => vec_strings.push(cstr.into_raw()),
+            Err(e) => {
+                for allocated in vec_strings {
+                    drop(std::ffi::CString::from_raw(allocated));
+                }
+                return fail(ERROR_CSTRING_CONVERSION_FAILED, e);
+            }
+        }
+    }
+
+    vec_strings.shrink_to_fit();
+    let len = vec_strings.len();
+    let strings = vec_strings.as_mut_ptr();
+    std::mem::forget(vec_strings);
+
+    ptr::write(out, StringArray { strings, len });
+    SUCCESS
+}
+
+/// Frees a string array returned by `decode_batch`, including every string in it.
+///
+/// # Safety
+///
+/// - `arr` must be either null or a valid pointer to a `StringArray` previously filled by this library
+/// - After calling this function, the array contents are invalid and must not be used
+#[no_mangle]
+pub unsafe extern "C" fn free_string_array(arr: *mut StringArray) {
+    if arr.is_null() {
+        return;
+    }
+
+    let arr = &mut *arr;
+
+    if !arr.strings.is_null() {
+        let strings = Vec::from_raw_parts(arr.strings, arr.len, arr.len);
+        for s in strings {
+            if !s.is_null() {
+                drop(std::ffi::CString::from_raw(s));
+            }
+        }
+    }
+
+    arr.strings = ptr::null_mut();
+    arr.len = 0;
+}
+
+/// Creates an incremental decoder that turns a stream of token IDs into text,
+/// emitting only text that can no longer change (e.g. complete UTF-8 sequences).
+/// Returns 0 on success, negative error code on failure.
+///
+/// # Safety
+///
+/// - `ptr` must be a valid pointer to a `Tokenizer` created by `from_bytes` or `from_file`
+/// - `out` must be a valid pointer to a stream pointer
+/// - The tokenizer must outlive the stream; free the stream with `free_decode_stream`
+///   before freeing the tokenizer
+/// - The tokenizer must not be modified (`set_truncation`, `set_padding`, `add_tokens`)
+///   while the stream exists, as the stream holds a shared reference to it
+#[no_mangle]
+pub unsafe extern "C" fn new_decode_stream(
+    ptr: *mut Tokenizer,
+    skip_special_tokens: bool,
+    out: *mut *mut TokenizerDecodeStream,
+) -> i32 {
+    clear_last_error();
+    if ptr.is_null() {
+        return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null");
+    }
+    if out.is_null() {
+        return fail(ERROR_NULL_OUTPUT, "output is null");
+    }
+
+    let tokenizer: &'static Tokenizer = match ptr.as_ref() {
+        Some(t) => t,
+        None => return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null"),
+    };
+
+    let stream = Box::new(tokenizer.decode_stream(skip_special_tokens));
+    ptr::write(out, Box::into_raw(stream));
+    SUCCESS
+}
+
+/// Feeds one token ID to a decode stream. On success `out` is set to the newly
+/// finalized text, or to null if the token did not complete any text yet.
+/// Returns 0 on success, negative error code on failure.
+///
+/// # Safety
+///
+/// - `stream` must be a valid pointer returned by `new_decode_stream`
+/// - `out` must be a valid pointer to a string pointer
+/// - The caller is responsible for freeing a non-null result using `free_string`
+#[no_mangle]
+pub unsafe extern "C" fn decode_stream_step(
+    stream: *mut TokenizerDecodeStream,
+    id: u32,
+    out: *mut *mut libc::c_char,
+) -> i32 {
+    clear_last_error();
+    if stream.is_null() {
+        return fail(ERROR_NULL_INPUT, "input is null");
+    }
+    if out.is_null() {
+        return fail(ERROR_NULL_OUTPUT, "output is null");
+    }
+
+    let stream = &mut *stream;
+    let chunk = match stream.step(id) {
+        Ok(chunk) => chunk,
+        Err(e) => return fail(ERROR_DECODE_FAILED, e),
+    };
+
+    let raw = match chunk {
+        Some(string) => match std::ffi::CString::new(string) {
+            Ok(s) => s.into_raw(),
+            Err(e) => return fail(ERROR_CSTRING_CONVERSION_FAILED, e),
+        },
+        None => ptr::null_mut(),
+    };
+    ptr::write(out, raw);
+    SUCCESS
+}
+
+/// Frees a decode stream created by `new_decode_stream`.
+///
+/// # Safety
+///
+/// - `stream` must be either null or a valid pointer returned by `new_decode_stream`
+/// - This function must only be called once per stream
+#[no_mangle]
+pub unsafe extern "C" fn free_decode_stream(stream: *mut TokenizerDecodeStream) {
+    if stream.is_null() {
+        return;
+    }
+    drop(Box::from_raw(stream));
+}
+
+/// # Safety
+/// /// - `ptr` must be a valid pointer to a `Tokenizer` created by `from_bytes` or `from_file`
+/// Gets the vocabulary size of the tokenizer.
+#[no_mangle]
+pub unsafe extern "C" fn vocab_size(ptr: *mut Tokenizer, out: *mut i32) -> i32 {
+    clear_last_error();
+    if ptr.is_null() {
+        return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null");
+    }
+
+    let tokenizer: &Tokenizer = match ptr.as_ref() {
+        Some(t) => t,
+        None => {
+            return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null");
+        }
+    };
+    let size = tokenizer.get_vocab_size(true) as i32;
+    ptr::write(out, size);
+    SUCCESS
+}
+
+/// Looks up the ID of a token, including added tokens.
+/// `found` is set to false when the token is not part of the vocabulary.
+///
+/// # Safety
+///
+/// - `ptr` must be a valid pointer to a `Tokenizer` created by `from_bytes` or `from_file`
+/// - `token` must be a valid pointer to a null-terminated C string
+/// - `out` and `found` must be valid pointers
+#[no_mangle]
+pub unsafe extern "C" fn token_to_id(
+    ptr: *mut Tokenizer,
+    token: *const libc::c_char,
+    out: *mut u32,
+    found: *mut bool,
+) -> i32 {
+    clear_last_error();
+    if ptr.is_null() {
+        return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null");
+    }
+    if token.is_null() {
+        return fail(ERROR_NULL_INPUT, "input is null");
+    }
+    if out.is_null() || found.is_null() {
+        return fail(ERROR_NULL_OUTPUT, "output is null");
+    }
+
+    let tokenizer: &Tokenizer = match ptr.as_ref() {
+        Some(t) => t,
+        None => return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null"),
+    };
+
+    let token_str = match CStr::from_ptr(token).to_str() {
+        Ok(s) => s,
+        Err(e) => return fail(ERROR_INVALID_UTF8, e),
+    };
+
+    match tokenizer.token_to_id(token_str) {
+        Some(id) => {
+            ptr::write(out, id);
+            ptr::write(found, true);
+        }
+        None => {
+            ptr::write(out, 0);
+            ptr::write(found, false);
+        }
+    }
+    SUCCESS
+}
+
+/// Looks up the token for an ID, including added tokens.
+/// `out` is set to null when the ID is not part of the vocabulary.
+///
+/// # Safety
+///
+/// - `ptr` must be a valid pointer to a `Tokenizer` created by `from_bytes` or `from_file`
+/// - `out` must be a valid pointer
+/// - A non-null returned string must be freed using `free_string`
+#[no_mangle]
+pub unsafe extern "C" fn id_to_token(
+    ptr: *mut Tokenizer,
+    id: u32,
+    out: *mut *mut libc::c_char,
+) -> i32 {
+    clear_last_error();
+    if ptr.is_null() {
+        return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null");
+    }
+    if out.is_null() {
+        return fail(ERROR_NULL_OUTPUT, "output is null");
+    }
+
+    let tokenizer: &Tokenizer = match ptr.as_ref() {
+        Some(t) => t,
+        None => return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null"),
+    };
+
+    let token = match tokenizer.id_to_token(id) {
+        Some(token) => token,
+        None => {
+            ptr::write(out, ptr::null_mut());
+            return SUCCESS;
+        }
+    };
+
+    match std::ffi::CString::new(token) {
+        Ok(c_string) => {
+            ptr::write(out, c_string.into_raw());
+            SUCCESS
+        }
+        Err(e) => fail(ERROR_CSTRING_CONVERSION_FAILED, e),
+    }
+}
+
+/// Exports the vocabulary as parallel arrays of tokens and IDs.
+///
+/// # Safety
+///
+/// - `ptr` must be a valid pointer to a `Tokenizer` created by `from_bytes` or `from_file`
+/// - `out` must be a valid pointer to a `VocabBuffer` struct
+/// - The caller is responsible for freeing the buffer using `free_vocab`
+#[no_mangle]
+pub unsafe extern "C" fn get_vocab(
+    ptr: *mut Tokenizer,
+    with_added_tokens: bool,
+    out: *mut VocabBuffer,
+) -> i32 {
+    clear_last_error();
+    if ptr.is_null() {
+        return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null");
+    }
+    if out.is_null() {
+        return fail(ERROR_NULL_OUTPUT, "output is null");
+    }
+
+    let tokenizer: &Tokenizer = match ptr.as_ref() {
+        Some(t) => t,
+        None => return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null"),
+    };
+
+    let vocab = tokenizer.get_vocab(with_added_tokens);
+    let mut vec_tokens: Vec<*mut libc::c_char> = Vec::with_capacity(vocab.len());
+    let mut vec_ids: Vec<u32> = Vec::with_capacity(vocab.len());
+
+    for (token, id) in vocab {
+        match std::ffi::CString::new(token) {
+            Ok(cstr) => {
+                vec_tokens.push(cstr.into_raw());
+                vec_ids.push(id);
+            }
+            Err(e) => {
+                for allocated_token in vec_tokens {
+                    drop(std::ffi::CString::from_raw(allocated_token));
+                }
+                return fail(ERROR_CSTRING_CONVERSION_FAILED, e);
+            }
+        }
+    }
+
+    vec_tokens.shrink_to_fit();
+    vec_ids.shrink_to_fit();
+    let len = vec_ids.len();
+    let tokens = vec_tokens.as_mut_ptr();
+    let ids = vec_ids.as_mut_ptr();
+    std::mem::forget(vec_tokens);
+    std::mem::forget(vec_ids);
+
+    ptr::write(out, VocabBuffer { tokens, ids, len });
+    SUCCESS
+}
+
+/// Frees a vocabulary buffer returned by `get_vocab`.
+///
+/// # Safety
+///
+/// - `buf` must be either null or a valid pointer to a `VocabBuffer` previously filled by `get_vocab`
+/// - After calling this function, the buffer contents are invalid and must not be used
+#[no_mangle]
+pub unsafe extern "C" fn free_vocab(buf: *mut VocabBuffer) {
+    if buf.is_null() {
+        return;
+    }
+
+    let buf = &mut *buf;
+
+    if !buf.ids.is_null() {
+        drop(Vec::from_raw_parts(buf.ids, buf.len, buf.len));
+    }
+
+    if !buf.tokens.is_null() {
+        let strings = Vec::from_raw_parts(buf.tokens, buf.len, buf.len);
+        for s in strings {
+            drop(std::ffi::CString::from_raw(s));
+        }
+    }
+
+    buf.ids = ptr::null_mut();
+    buf.tokens = ptr::null_mut();
+    buf.len = 0;
+}
+
+/// Adds tokens to the vocabulary of a tokenizer. When `special` is true the tokens are
+/// registered as special tokens (skipped when decoding with `skip_special_tokens`).
+/// `out` receives the number of tokens that were not already in the vocabulary.
+/// Returns 0 on success, negative error code on failure.
+///
+/// # Safety
+///
+/// - `ptr` must be a valid pointer to a `Tokenizer` created by `from_bytes` or `from_file`
+/// - `tokens` must be a valid pointer to at least `count` `AddedTokenOptions` structs whose
+///   `content` is a valid pointer to a null-terminated C string
+/// - `out` must be a valid pointer to a `usize`
+/// - No other call may use the tokenizer concurrently
+#[no_mangle]
+pub unsafe extern "C" fn add_tokens(
+    ptr: *mut Tokenizer,
+    tokens: *const AddedTokenOptions,
+    count: usize,
+    special: bool,
+    out: *mut usize,
+) -> i32 {
+    clear_last_error();
+    if out.is_null() {
+        return fail(ERROR_NULL_OUTPUT, "output is null");
+    }
+    if tokens.is_null() && count > 0 {
+        return fail(ERROR_NULL_INPUT, "input is null");
+    }
+
+    let tokenizer: &mut Tokenizer = match ptr.as_mut() {
+        Some(t) => t,
+        None => return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null"),
+    };
+
+    let mut added_tokens: Vec<AddedToken> = Vec::with_capacity(count);
+    for i in 0..count {
+        let opts = &*tokens.add(i);
+        if opts.content.is_null() {
+            return fail(ERROR_NULL_INPUT, "input is null");
+        }
+        let content = match CStr::from_ptr(opts.content).to_str() {
+            Ok(s) => s,
+            Err(e) => return fail(ERROR_INVALID_UTF8, e),
+        };
+        added_tokens.push(
+            AddedToken::from(content, opts.special)
+                .single_word(opts.single_word)
+                .lstrip(opts.lstrip)
+                .rstrip(opts.rstrip)
+                .normalized(opts.normalized),
+        );
+    }
+
+    let added = if special {
+        tokenizer.add_special_tokens(&added_tokens)
+    } else {
+        tokenizer.add_tokens(&added_tokens)
+    };
+    ptr::write(out, added);
+    SUCCESS
+}
+
+/// Serializes a tokenizer, including its current truncation, padding and added tokens,
+/// to the tokenizer.json format.
+///
+/// # Safety
+///
+/// - `ptr` must be a valid pointer to a `Tokenizer` created by `from_bytes` or `from_file`
+/// - `out` must be a valid pointer
+/// - The returned string must be freed using `free_string`
+#[no_mangle]
+pub unsafe extern "C" fn to_json(
+    ptr: *mut Tokenizer,
+    pretty: bool,
+    out: *mut *mut libc::c_char,
+) -> i32 {
+    clear_last_error();
     if out.is_null() {
-        return ERROR_NULL_OUTPUT;
-    }
-
-    if count == 0 {
-        return SUCCESS; // Nothing to encode
+        return fail(ERROR_NULL_OUTPUT, "output is null");
     }
 
     let tokenizer: &Tokenizer = match ptr.as_ref() {
         Some(t) => t,
-        None => return ERROR_INVALID_TOKENIZER_REF,
+        None => return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null"),
     };
 
-    let options = &*options;
-
-    // Convert C string arrays to Rust Vec of tuples
-    let mut inputs: Vec<(&str, &str)> = Vec::with_capacity(count);
-
-    for i in 0..count {
-        let seq_ptr = *sequences.add(i);
-        let pair_ptr = *pairs.add(i);
+    let json = match tokenizer.to_string(pretty) {
+        Ok(json) => json,
+        Err(e) => return fail(ERROR_SERIALIZATION_FAILED, e),
+    };
 
-        if seq_ptr.is_null() || pair_ptr.is_null() {
-            return ERROR_NULL_INPUT;
+    match std::ffi::CString::new(json) {
+        Ok(c_string) => {
+            ptr::write(out, c_string.into_raw());
+            SUCCESS
         }
+        Err(e) => fail(ERROR_CSTRING_CONVERSION_FAILED, e),
+    }
+}
 
-        let seq_cstr = CStr::from_ptr(seq_ptr);
-        let pair_cstr = CStr::from_ptr(pair_ptr);
-
-        let seq_str = match seq_cstr.to_str() {
-            Ok(s) => s,
-            Err(_) => return ERROR_INVALID_UTF8,
-        };
-
-        let pair_str = match pair_cstr.to_str() {
-            Ok(s) => s,
-            Err(_) => return ERROR_INVALID_UTF8,
-        };
-
-        inputs.push((seq_str, pair_str));
+/// Runs the tokenizer's normalizer, if any, on `message`.
+/// Returns 0 on success, negative error code on failure.
+///
+/// # Safety
+///
+/// - `ptr` must be a valid pointer to a `Tokenizer` created by `from_bytes` or `from_file`
+/// - `message` must be a valid pointer to a null-terminated C string
+/// - `out` must be a valid pointer to a `NormalizedBuffer` struct
+/// - The caller is responsible for freeing the buffer using `free_normalized`
+#[no_mangle]
+pub unsafe extern "C" fn normalize(
+    ptr: *mut Tokenizer,
+    message: *const libc::c_char,
+    out: *mut NormalizedBuffer,
+) -> i32 {
+    clear_last_error();
+    if message.is_null() {
+        return fail(ERROR_NULL_INPUT, "input is null");
+    }
+    if out.is_null() {
+        return fail(ERROR_NULL_OUTPUT, "output is null");
     }
 
-    // Encode all pairs in parallel
-    let encodings = match tokenizer.encode_batch(inputs, options.add_special_tokens) {
-        Ok(encs) => encs,
-        Err(_) => return ERROR_ENCODING_FAILED,
+    let tokenizer: &Tokenizer = match ptr.as_ref() {
+        Some(t) => t,
+        None => return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null"),
     };
 
-    // Two-phase allocation to prevent memory leaks on error:
-    // Phase 1: Allocate all buffers into temp storage with proper error handling
-    // Phase 2: Only write to output if ALL allocations succeed
-    let mut temp_buffers: Vec<Buffer> = Vec::with_capacity(encodings.len());
+    let message = match CStr::from_ptr(message).to_str() {
+        Ok(s) => s,
+        Err(e) => return fail(ERROR_INVALID_UTF8, e),
+    };
 
-    for encoding in encodings.iter() {
-        // Prepare IDs (always needed)
-        let mut vec_ids = encoding.get_ids().to_vec();
-        vec_ids.shrink_to_fit();
-        let ids = vec_ids.as_mut_ptr();
-        let len = vec_ids.len();
-        std::mem::forget(vec_ids);
-
-        // Prepare type IDs if requested
-        let mut type_ids: *mut u32 = ptr::null_mut();
-        if options.return_type_ids {
-            let mut vec_type_ids = encoding.get_type_ids().to_vec();
-            vec_type_ids.shrink_to_fit();
-            type_ids = vec_type_ids.as_mut_ptr();
-            std::mem::forget(vec_type_ids);
-        }
-
-        // Prepare tokens if requested
-        let mut tokens: *mut *mut libc::c_char = ptr::null_mut();
-        if options.return_tokens {
-            let mut vec_tokens = Vec::with_capacity(encoding.get_tokens().len());
-            for token in encoding.get_tokens() {
-                match std::ffi::CString::new(token.as_str()) {
-                    Ok(cstr) => vec_tokens.push(cstr.into_raw()),
-                    Err(_) => {
-                        // Clean up current iteration's tokens
-                        for allocated_token in vec_tokens {
-                            drop(std::ffi::CString::from_raw(allocated_token));
-                        }
-                        // Clean up all previously allocated buffers in temp_buffers
-                        for buffer in temp_buffers {
-                            free_buffer_contents(buffer);
-                        }
-                        return ERROR_CSTRING_CONVERSION_FAILED;
-                    }
-                }
-            }
-            vec_tokens.shrink_to_fit();
-            tokens = vec_tokens.as_mut_ptr();
-            std::mem::forget(vec_tokens);
-        }
-
-        // Prepare special tokens mask if requested
-        let mut special_tokens_mask: *mut u32 = ptr::null_mut();
-        if options.return_special_tokens_mask {
-            let mut vec_special_tokens_mask = encoding.get_special_tokens_mask().to_vec();
-            vec_special_tokens_mask.shrink_to_fit();
-            special_tokens_mask = vec_special_tokens_mask.as_mut_ptr();
-            std::mem::forget(vec_special_tokens_mask);
-        }
-
-        // Prepare attention mask if requested
-        let mut attention_mask: *mut u32 = ptr::null_mut();
-        if options.return_attention_mask {
-            let mut vec_attention_mask = encoding.get_attention_mask().to_vec();
-            vec_attention_mask.shrink_to_fit();
-            attention_mask = vec_attention_mask.as_mut_ptr();
-            std::mem::forget(vec_attention_mask);
-        }
-
-        // Prepare offsets if requested
-        let mut offsets: *mut usize = ptr::null_mut();
-        if options.return_offsets {
-            let vec_offsets_tuples = encoding.get_offsets().to_vec();
-            let mut vec_offsets = Vec::with_capacity(vec_offsets_tuples.len() * 2);
-            for (start, end) in vec_offsets_tuples {
-                vec_offsets.push(start);
-                vec_offsets.push(end);
-            }
-            vec_offsets.shrink_to_fit();
-            offsets = vec_offsets.as_mut_ptr();
-            std::mem::forget(vec_offsets);
+    let mut normalized = NormalizedString::from(message);
+    if let Some(normalizer) = tokenizer.get_normalizer() {
+        if let Err(e) = normalizer.normalize(&mut normalized) {
+            return fail(ERROR_ENCODING_FAILED, e);
         }
-
-        // Store buffer in temp storage instead of writing directly to output
-        temp_buffers.push(Buffer {
-            ids,
-            type_ids,
-            special_tokens_mask,
-            attention_mask,
-            tokens,
-            offsets,
-            len,
-        });
     }
 
-    // Phase 2: All allocations succeeded, now write to output
-    for (i, buffer) in temp_buffers.into_iter().enumerate() {
-        ptr::write(out.add(i), buffer);
+    let text = normalized.get();
+    let mut offsets: Vec<usize> = Vec::with_capacity(text.len() * 2);
+    for (start, c) in text.char_indices() {
+        let end = start + c.len_utf8();
+        let (orig_start, orig_end) = normalized
+            .convert_offsets(Range::Normalized(start..end))
+            .map_or((0, 0), |r| (r.start, r.end));
+        for _ in start..end {
+            offsets.push(orig_start);
+            offsets.push(orig_end);
+        }
     }
 
+    let c_text = match std::ffi::CString::new(text) {
+        Ok(s) => s,
+        Err(e) => return fail(ERROR_CSTRING_CONVERSION_FAILED, e),
+    };
+
+    offsets.shrink_to_fit();
+    ptr::write(
+        out,
+        NormalizedBuffer {
+            text: c_text.into_raw(),
+            offsets: offsets.as_mut_ptr(),
+            len: text.len(),
+        },
+    );
+    std::mem::forget(offsets);
     SUCCESS
 }
 
-/// Decodes token IDs back to text.
+/// Frees a buffer returned by `normalize`.
+///
+/// # Safety
+///
+/// - `buf` must be either null or a valid pointer to a `NormalizedBuffer` filled by `normalize`
+/// - This function must only be called once per buffer
+#[no_mangle]
+pub unsafe extern "C" fn free_normalized(buf: *mut NormalizedBuffer) {
+    if buf.is_null() {
+        return;
+    }
+    let buf = &mut *buf;
+    if !buf.text.is_null() {
+        drop(std::ffi::CString::from_raw(buf.text));
+    }
+    if !buf.offsets.is_null() {
+        drop(Vec::from_raw_parts(buf.offsets, buf.len * 2, buf.len * 2));
+    }
+    buf.text = ptr::null_mut();
+    buf.offsets = ptr::null_mut();
+    buf.len = 0;
+}
+
+/// Runs the tokenizer's pre-tokenizer, if any, on `message` without normalizing it first.
+/// Returns 0 on success, negative error code on failure.
 ///
 /// # Safety
 ///
 /// - `ptr` must be a valid pointer to a `Tokenizer` created by `from_bytes` or `from_file`
-/// - `ids` must be a valid pointer to at least `len` `u32` values
-/// - The returned string must be freed using `free_string` when no longer needed
+/// - `message` must be a valid pointer to a null-terminated C string
+/// - `out` must be a valid pointer to a `PreTokenBuffer` struct
+/// - The caller is responsible for freeing the buffer using `free_pre_tokens`
 #[no_mangle]
-pub unsafe extern "C" fn decode(
+pub unsafe extern "C" fn pre_tokenize(
     ptr: *mut Tokenizer,
-    ids: *const u32,
-    len: u32,
-    skip_special_tokens: bool,
-    out: *mut *mut libc::c_char,
+    message: *const libc::c_char,
+    out: *mut PreTokenBuffer,
 ) -> i32 {
-    if ptr.is_null() {
-        return ERROR_INVALID_TOKENIZER_REF;
-    }
-    if ids.is_null() || len == 0 {
-        return ERROR_INVALID_IDS;
+    clear_last_error();
+    if message.is_null() {
+        return fail(ERROR_NULL_INPUT, "input is null");
     }
     if out.is_null() {
-        return ERROR_NULL_OUTPUT;
+        return fail(ERROR_NULL_OUTPUT, "output is null");
     }
 
     let tokenizer: &Tokenizer = match ptr.as_ref() {
         Some(t) => t,
-        None => return ERROR_INVALID_TOKENIZER_REF,
+        None => return fail(ERROR_INVALID_TOKENIZER_REF, "tokenizer is null"),
     };
 
-    let ids_slice = std::slice::from_raw_parts(ids, len as usize);
-    let string = match tokenizer.decode(ids_slice, skip_special_tokens) {
+    let message = match CStr::from_ptr(message).to_str() {
         Ok(s) => s,
-        Err(_) => return ERROR_DECODE_FAILED,
+        Err(e) => return fail(ERROR_INVALID_UTF8, e),
     };
 
-    // CString::new fails if `string` contains interior NULs.
-    let c_string = match std::ffi::CString::new(string) {
-        Ok(s) => s,
-        Err(_) => return ERROR_CSTRING_CONVERSION_FAILED,
-    };
+    let mut pre_tokenized = PreTokenizedString::from(message);
+    if let Some(pre_tokenizer) = tokenizer.get_pre_tokenizer() {
+        if let Err(e) = pre_tokenizer.pre_tokenize(&mut pre_tokenized) {
+            return fail(ERROR_ENCODING_FAILED, e);
+        }
+    }
 
-    // Transfer ownership to caller; they must free with `decode_free`.
-    let raw = c_string.into_raw();
-    ptr::write(out, raw);
+    let splits = pre_tokenized.get_splits(OffsetReferential::Original, OffsetType::Byte);
+    let mut tokens: Vec<*mut libc::c_char> = Vec::with_capacity(splits.len());
+    let mut offsets: Vec<usize> = Vec::with_capacity(splits.len() * 2);
+    for (token, (start, end), _) in splits {
+        match std::ffi::CString::new(token) {
+            Ok(s) => tokens.push(s.into_raw()),
+            Err(e) => {
+                for t in tokens {
+                    drop(std::ffi::CString::from_raw(t));
+                }
+                return fail(ERROR_CSTRING_CONVERSION_FAILED, e);
+            }
+        }
+        offsets.push(start);
+        offsets.push(end);
+    }
+
+    tokens.shrink_to_fit();
+    offsets.shrink_to_fit();
+    ptr::write(
+        out,
+        PreTokenBuffer {
+            tokens: tokens.as_mut_ptr(),
+            offsets: offsets.as_mut_ptr(),
+            len: tokens.len(),
+        },
+    );
+    std::mem::forget(tokens);
+    std::mem::forget(offsets);
     SUCCESS
 }
+
+/// Frees a buffer returned by `pre_tokenize`.
+///
 /// # Safety
-/// /// - `ptr` must be a valid pointer to a `Tokenizer` created by `from_bytes` or `from_file`
-/// Gets the vocabulary size of the tokenizer.
+///
+/// - `buf` must be either null or a valid pointer to a `PreTokenBuffer` filled by `pre_tokenize`
+/// - This function must only be called once per buffer
 #[no_mangle]
-pub unsafe extern "C" fn vocab_size(ptr: *mut Tokenizer, out: *mut i32) -> i32 {
-    if ptr.is_null() {
-        return ERROR_INVALID_TOKENIZER_REF;
+pub unsafe extern "C" fn free_pre_tokens(buf: *mut PreTokenBuffer) {
+    if buf.is_null() {
+        return;
+    }
+    let buf = &mut *buf;
+    if !buf.tokens.is_null() {
+        let tokens = Vec::from_raw_parts(buf.tokens, buf.len, buf.len);
+        for t in tokens {
+            drop(std::ffi::CString::from_raw(t));
+        }
+    }
+    if !buf.offsets.is_null() {
+        drop(Vec::from_raw_parts(buf.offsets, buf.len * 2, buf.len * 2));
     }
+    buf.tokens = ptr::null_mut();
+    buf.offsets = ptr::null_mut();
+    buf.len = 0;
+}
 
-    let tokenizer: &Tokenizer = match ptr.as_ref() {
-        Some(t) => t,
-        None => {
-            return ERROR_INVALID_TOKENIZER_REF;
+/// Reads `count` null-terminated C strings.
+///
+/// # Safety
+///
+/// - `strings` must be null (only if `count` is 0) or a valid pointer to at least `count`
+///   valid pointers to null-terminated C strings
+unsafe fn c_str_array<'a>(
+    strings: *const *const libc::c_char,
+    count: usize,
+) -> Result<Vec<&'a str>, i32> {
+    if count == 0 {
+        return Ok(Vec::new());
+    }
+    if strings.is_null() {
+        return Err(fail(ERROR_NULL_INPUT, "input is null"));
+    }
+    let mut out = Vec::with_capacity(count);
+    for i in 0..count {
+        let s = *strings.add(i);
+        if s.is_null() {
+            return Err(fail(ERROR_NULL_INPUT, "input is null"));
+        }
+        match CStr::from_ptr(s).to_str() {
+            Ok(s) => out.push(s),
+            Err(e) => return Err(fail(ERROR_INVALID_UTF8, e)),
+        }
+    }
+    Ok(out)
+}
+
+/// Reads an optional null-terminated C string.
+///
+/// # Safety
+///
+/// - `s` must be null or a valid pointer to a null-terminated C string
+unsafe fn optional_c_str(s: *const libc::c_char) -> Result<Option<String>, i32> {
+    if s.is_null() {
+        return Ok(None);
+    }
+    match CStr::from_ptr(s).to_str() {
+        Ok(s) => Ok(Some(s.to_string())),
+        Err(e) => Err(fail(ERROR_INVALID_UTF8, e)),
+    }
+}
+
+/// Builds an untrained tokenizer for `opts.model` with its trainer. The pipeline follows
+/// the usual setup of each model: byte-level BPE as in GPT-2, WordPiece as in BERT,
+/// Unigram with SentencePiece's metaspace, and WordLevel on whitespace-split words.
+///
+/// # Safety
+///
+/// - The pointers in `opts` must be valid as documented on `train_tokenizer`
+unsafe fn new_trainer(opts: &TrainerOptions) -> Result<(Tokenizer, TrainerWrapper), i32> {
+    let special_tokens: Vec<AddedToken> =
+        c_str_array(opts.special_tokens, opts.special_tokens_len)?
+            .into_iter()
+            .map(|token| AddedToken::from(token, true))
+            .collect();
+
+    let mut alphabet: HashSet<char> = HashSet::new();
+    if opts.initial_alphabet_len > 0 {
+        if opts.initial_alphabet.is_null() {
+            return Err(fail(ERROR_NULL_INPUT, "input is null"));
+        }
+        for &c in std::slice::from_raw_parts(opts.initial_alphabet, opts.initial_alphabet_len) {
+            match char::from_u32(c) {
+                Some(c) => {
+                    alphabet.insert(c);
+                }
+                None => {
+                    return Err(fail(
+                        ERROR_INVALID_OPTIONS,
+                        format!("invalid character in initial alphabet: {c:#x}"),
+                    ))
+                }
+            }
+        }
+    }
+
+    let unk_token = optional_c_str(opts.unk_token)?;
+    let prefix = optional_c_str(opts.continuing_subword_prefix)?;
+    let suffix = optional_c_str(opts.end_of_word_suffix)?;
+    let limit_alphabet = (opts.limit_alphabet > 0).then_some(opts.limit_alphabet);
+
+    match opts.model {
+        0 => {
+            alphabet.extend(ByteLevel::alphabet());
+            let mut builder = BpeTrainer::builder()
+                .show_progress(false)
+                .min_frequency(opts.min_frequency)
+                .special_tokens(special_tokens)
+                .initial_alphabet(alphabet);
+            if opts.vocab_size > 0 {
+                builder = builder.vocab_size(opts.vocab_size);
+            }
+            if let Some(limit) = limit_alphabet {
+                builder = builder.limit_alphabet(limit);
+            }
+            if let Some(prefix) = prefix {
+                builder = builder.continuing_subword_prefix(prefix);
+            }
+            if let Some(suffix) = suffix {
+                builder = builder.end_of_word_suffix(suffix);
+            }
+
+            let mut tok = Tokenizer::new(BPE::default());
+            tok.with_pre_tokenizer(Some(ByteLevel::new(false, true, true)));
+            tok.with_decoder(Some(ByteLevel::default()));
+            Ok((tok, builder.build().into()))
+        }
+        1 => {
+            let prefix = prefix.unwrap_or_else(|| "##".to_string());
+            let mut builder = WordPieceTrainer::builder()
+                .show_progress(false)
+                .min_frequency(opts.min_frequency)
+                .special_tokens(special_tokens)
+                .initial_alphabet(alphabet)
+                .continuing_subword_prefix(prefix.clone());
+            if opts.vocab_size > 0 {
+                builder = builder.vocab_size(opts.vocab_size);
+            }
+            if let Some(limit) = limit_alphabet {
+                builder = builder.limit_alphabet(limit);
+            }
+            if let Some(suffix) = suffix {
+                builder = builder.end_of_word_suffix(suffix);
+            }
+
+            let mut model = WordPiece::builder().continuing_subword_prefix(prefix.clone());
+            if let Some(unk) = unk_token {
+                model = model.unk_token(unk);
+            }
+            let model = match model.build() {
+                Ok(m) => m,
+                Err(e) => return Err(fail(ERROR_INVALID_OPTIONS, e)),
+            };
+
+            let mut tok = Tokenizer::new(model);
+            tok.with_pre_tokenizer(Some(BertPreTokenizer {}));
+            tok.with_decoder(Some(WordPieceDecoder::new(prefix, true)));
+            Ok((tok, builder.build().into()))
+        }
+        2 => {
+            let mut builder = UnigramTrainer::builder();
+            builder
+                .show_progress(false)
+                .special_tokens(special_tokens)
+                .initial_alphabet(alphabet)
+                .unk_token(unk_token);
+            if opts.vocab_size > 0 {
+                match u32::try_from(opts.vocab_size) {
+                    Ok(size) => {
+                        builder.vocab_size(size);
+                    }
+                    Err(e) => return Err(fail(ERROR_INVALID_OPTIONS, e)),
+                }
+            }
+            let trainer = match builder.build() {
+                Ok(t) => t,
+                Err(e) => return Err(fail(ERROR_INVALID_OPTIONS, e)),
+            };
+
+            let mut tok = Tokenizer::new(Unigram::default());
+            tok.with_pre_tokenizer(Some(Metaspace::default()));
+            tok.with_decoder(Some(Metaspace::default()));
+            Ok((tok, trainer.into()))
+        }
+        3 => {
+            let mut builder = WordLevelTrainer::builder();
+            builder
+                .show_progress(false)
+                .min_frequency(opts.min_frequency)
+                .special_tokens(special_tokens);
+            if opts.vocab_size > 0 {
+                builder.vocab_size(opts.vocab_size);
+            }
+            let trainer = match builder.build() {
+                Ok(t) => t,
+                Err(e) => return Err(fail(ERROR_INVALID_OPTIONS, e)),
+            };
+
+            let mut model = WordLevel::builder();
+            if let Some(unk) = unk_token {
+                model = model.unk_token(unk);
+            }
+            let model = match model.build() {
+                Ok(m) => m,
+                Err(e) => return Err(fail(ERROR_INVALID_OPTIONS, e)),
+            };
+
+            let mut tok = Tokenizer::new(model);
+            tok.with_pre_tokenizer(Some(Whitespace {}));
+            Ok((tok, trainer.into()))
+        }
+        _ => Err(fail(
+            ERROR_INVALID_OPTIONS,
+            format!("unknown model type: {}", opts.model),
+        )),
+    }
+}
+
+/// Trains a new tokenizer on the lines of `files` followed by `texts`, then applies the
+/// load-time options as `from_bytes` does.
+/// Returns 0 on success, negative error code on failure.
+///
+/// # Safety
+///
+/// - `opts` must be a valid pointer to a `TrainerOptions` struct whose string pointers are
+///   null or valid null-terminated C strings, and whose arrays hold at least their length
+/// - `files` and `texts` must be null (only if their count is 0) or valid pointers to
+///   `files_len` and `texts_len` null-terminated C strings
+/// - `tok_opts` must be a valid pointer to a `TokenizerOptions` struct
+/// - The returned tokenizer pointer must be freed using `free_tokenizer` when no longer needed
+#[no_mangle]
+pub unsafe extern "C" fn train_tokenizer(
+    opts: *const TrainerOptions,
+    files: *const *const libc::c_char,
+    files_len: usize,
+    texts: *const *const libc::c_char,
+    texts_len: usize,
+    tok_opts: *const TokenizerOptions,
+    out: &mut TokenizerResult,
+) -> i32 {
+    clear_last_error();
+    if opts.is_null() || tok_opts.is_null() {
+        return fail(ERROR_INVALID_OPTIONS, "options are null");
+    }
+
+    let files = match c_str_array(files, files_len) {
+        Ok(f) => f,
+        Err(code) => return code,
+    };
+    let texts = match c_str_array(texts, texts_len) {
+        Ok(t) => t,
+        Err(code) => return code,
+    };
+    if files.is_empty() && texts.is_empty() {
+        return fail(ERROR_NULL_INPUT, "no training files or texts");
+    }
+
+    let (mut tok, mut trainer) = match new_trainer(&*opts) {
+        Ok(pair) => pair,
+        Err(code) => return code,
+    };
+
+    let trained = if texts.is_empty() {
+        let files = files.into_iter().map(str::to_string).collect();
+        tok.train_from_files(&mut trainer, files).map(|_| ())
+    } else {
+        // Files are read up front so that their lines can be chained with the texts.
+        let mut contents = Vec::with_capacity(files.len());
+        for file in files {
+            match std::fs::read_to_string(file) {
+                Ok(c) => contents.push(c),
+                Err(e) => return fail(ERROR_FILE_NOT_FOUND, format!("{file}: {e}")),
+            }
         }
+        let lines = contents
+            .iter()
+            .flat_map(|c| c.lines())
+            .chain(texts.iter().copied());
+        tok.train(&mut trainer, lines).map(|_| ())
     };
-    let size = tokenizer.get_vocab_size(true) as i32;
-    ptr::write(out, size);
+    if let Err(e) = trained {
+        return fail(ERROR_TRAINING_FAILED, e);
+    }
+
+    let rc = apply_tokenizer_options(&mut tok, &*tok_opts);
+    if rc != SUCCESS {
+        return rc;
+    }
+
+    out.tokenizer = Box::into_raw(Box::new(tok));
     SUCCESS
 }
 
 /// Internal helper to free buffer contents without dereferencing through pointer.
-/// Used for cleanup in error paths of encode_batch_pairs.
+/// Used by `free_buffer` and for cleanup in error paths of the encode functions.
+/// Overflowing buffers are freed recursively.
 unsafe fn free_buffer_contents(buf: Buffer) {
     // Free the memory allocated for the fields in the Buffer struct
     if !buf.ids.is_null() {
@@ -606,6 +2185,22 @@ unsafe fn free_buffer_contents(buf: Buffer) {
             drop(std::ffi::CString::from_raw(s));
         }
     }
+
+    if !buf.word_ids.is_null() {
+        drop(Vec::from_raw_parts(buf.word_ids, buf.len, buf.len));
+    }
+
+    if !buf.sequence_ids.is_null() {
+        drop(Vec::from_raw_parts(buf.sequence_ids, buf.len, buf.len));
+    }
+
+    if !buf.overflowing.is_null() {
+        let children =
+            Vec::from_raw_parts(buf.overflowing, buf.overflowing_len, buf.overflowing_len);
+        for child in children {
+            free_buffer_contents(child);
+        }
+    }
 }
 
 /// Frees a tokenizer instance.
@@ -636,39 +2231,8 @@ pub unsafe extern "C" fn free_buffer(buf: *mut Buffer) {
         return;
     }
 
-    let buf = &mut *buf;
-
-    // Free the memory allocated for the fields in the Buffer struct
-    if !buf.ids.is_null() {
-        drop(Vec::from_raw_parts(buf.ids, buf.len, buf.len));
-    }
-
-    if !buf.type_ids.is_null() {
-        drop(Vec::from_raw_parts(buf.type_ids, buf.len, buf.len));
-    }
-
-    if !buf.special_tokens_mask.is_null() {
-        drop(Vec::from_raw_parts(
-            buf.special_tokens_mask,
-            buf.len,
-            buf.len,
-        ));
-    }
-
-    if !buf.attention_mask.is_null() {
-        drop(Vec::from_raw_parts(buf.attention_mask, buf.len, buf.len));
-    }
-
-    if !buf.offsets.is_null() {
-        drop(Vec::from_raw_parts(buf.offsets, buf.len * 2, buf.len * 2));
-    }
-
-    if !buf.tokens.is_null() {
-        let strings = Vec::from_raw_parts(buf.tokens, buf.len, buf.len);
-        for s in strings {
-            drop(std::ffi::CString::from_raw(s));
-        }
-    }
+    // Reset the caller's struct so a repeated call cannot double free
+    free_buffer_contents(std::mem::replace(&mut *buf, Buffer::empty()));
 }
 
 /// Frees a string returned by decode.
@@ -686,6 +2250,18 @@ pub unsafe extern "C" fn free_string(ptr: *mut libc::c_char) {
     drop(std::ffi::CString::from_raw(ptr));
 }
 
+/// Returns the detailed message of the last failure on the calling thread and clears it,
+/// or null if the last failure recorded no detail.
+/// The caller is responsible for freeing a non-null result using `free_string`.
+#[no_mangle]
+pub extern "C" fn take_last_error_message() -> *mut libc::c_char {
+    LAST_ERROR.with(|last| {
+        last.borrow_mut()
+            .take()
+            .map_or(ptr::null_mut(), |message| message.into_raw())
+    })
+}
+
 /// Gets a human-readable error message for the given error code.
 /// The returned string is static and should not be freed.
 #[no_mangle]
@@ -708,13 +2284,16 @@ pub extern "C" fn get_error_message(code: i32) -> *const libc::c_char {
         }
         ERROR_INVALID_IDS => "Invalid or empty token IDs\0",
         ERROR_INVALID_OPTIONS => "Invalid options parameter\0",
+        ERROR_BUFFER_TOO_SMALL => "Destination buffer is too small\0",
+        ERROR_SERIALIZATION_FAILED => "Failed to serialize tokenizer\0",
+        ERROR_TRAINING_FAILED => "Failed to train tokenizer\0",
         _ => "Unknown error\0",
     };
 
     message.as_ptr() as *const libc::c_char
 }
 
-/// Returns the library's version string (e.g., "0.1.0").
+/// Returns the library's version string (e.g., "0.2.0").
 /// This version is used for ABI compatibility checking.
 /// The version follows semantic versioning and should be updated when:
 /// - Breaking changes are made to the FFI interface (major version bump)
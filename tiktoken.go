@@ -0,0 +1,174 @@
+package tokenizers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Split patterns of the tiktoken encodings, for FromTiktoken.
+const (
+	TiktokenPatternCL100K = `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+`
+	TiktokenPatternO200K  = `[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*(?i:'s|'t|'re|'ve|'m|'ll|'d)?` +
+		`|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n/]*|\s*[\r\n]+|\s+(?!\S)|\s+`
+)
+
+// FromTiktoken loads a byte-level BPE tokenizer from a tiktoken rank file, such as
+// cl100k_base.tiktoken, which lists one base64-encoded token and its rank per line. The
+// input is split with pattern, e.g. TiktokenPatternCL100K, before byte-pair encoding.
+// specialTokens maps special tokens such as "<|endoftext|>" to their IDs; they are
+// matched in the input as whole tokens. opts are applied as for FromBytes.
+func FromTiktoken(ranksFile, pattern string, specialTokens map[string]uint32, opts ...TokenizerOption) (*Tokenizer, error) {
+	ranks, err := readLegacyFile(ranksFile, "tiktoken ranks")
+	if err != nil {
+		return nil, err
+	}
+	pipeline, err := NewTiktokenPipeline(ranks, pattern, specialTokens)
+	if err != nil {
+		return nil, err
+	}
+	return pipeline.Build(opts...)
+}
+
+// NewTiktokenPipeline returns the pipeline of a byte-level BPE tokenizer with the
+// contents of a tiktoken rank file. The ID of a token is its rank, and merges are derived
+// from the ranks as tiktoken applies them; pieces that are tokens themselves are not
+// merged. Use it instead of FromTiktoken to change components before building.
+func NewTiktokenPipeline(ranks []byte, pattern string, specialTokens map[string]uint32) (*Pipeline, error) {
+	if pattern == "" {
+		return nil, errors.New("split pattern cannot be empty")
+	}
+	tokenRanks, err := parseTiktokenRanks(ranks)
+	if err != nil {
+		return nil, err
+	}
+
+	vocab := make(map[string]uint32, len(tokenRanks)+len(specialTokens))
+	for token, rank := range tokenRanks {
+		vocab[byteLevelString([]byte(token))] = rank
+	}
+	// Special tokens are part of the vocabulary so that they keep their IDs.
+	names := make([]string, 0, len(specialTokens))
+	for token := range specialTokens {
+		names = append(names, token)
+	}
+	sort.Strings(names)
+	addedTokens := make([]AddedToken, 0, len(names))
+	for _, token := range names {
+		id := specialTokens[token]
+		if other, ok := vocab[token]; ok && other != id {
+			return nil, errors.Errorf("special token %q with ID %d is also a token with rank %d", token, id, other)
+		}
+		vocab[token] = id
+		addedTokens = append(addedTokens, NewAddedToken(token, true))
+	}
+
+	// tiktoken looks up a whole piece in the ranks before merging its bytes, so a token
+	// that the merge order cannot reach is still produced.
+	model := BPEModel{Vocab: vocab, Merges: tiktokenMerges(tokenRanks), IgnoreMerges: true}
+	return NewPipeline(model).
+		WithPreTokenizer(SequencePreTokenizer{PreTokenizers: []PreTokenizer{
+			SplitPreTokenizer{Pattern: pattern, Regex: true, Behavior: SplitBehaviorIsolated},
+			ByteLevelPreTokenizer{DisableRegex: true},
+		}}).
+		WithDecoder(ByteLevelDecoder{}).
+		WithAddedTokens(addedTokens...), nil
+}
+
+// parseTiktokenRanks maps the tokens of a tiktoken rank file, as raw bytes, to their ranks.
+func parseTiktokenRanks(data []byte) (map[string]uint32, error) {
+	ranks := make(map[string]uint32)
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		if len(line) == 0 {
+			continue
+		}
+		fields := strings.Fields(string(line))
+		if len(fields) != 2 {
+			return nil, errors.Errorf("invalid rank on line %d: %q", i+1, line)
+		}
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil || len(token) == 0 {
+			return nil, errors.Errorf("invalid token on line %d: %q", i+1, fields[0])
+		}
+		rank, err := strconv.ParseUint(fields[1], 10, 32)
+		if err != nil {
+			return nil, errors.Errorf("invalid rank on line %d: %q", i+1, fields[1])
+		}
+		if _, ok := ranks[string(token)]; ok {
+			return nil, errors.Errorf("duplicate token %q on line %d", fields[0], i+1)
+		}
+		ranks[string(token)] = uint32(rank) // #nosec G115 -- ParseUint limits rank to 32 bits.
+	}
+	if len(ranks) == 0 {
+		return nil, errors.New("tiktoken ranks cannot be empty")
+	}
+	return ranks, nil
+}
+
+// tiktokenMerges derives BPE merges from tiktoken ranks, which only store the merged
+// tokens. As in the conversion of transformers, every split of a token into two tokens
+// with ranks is a merge, ranked by the rank of the token.
+func tiktokenMerges(ranks map[string]uint32) [][2]string {
+	tokens := make([]string, 0, len(ranks))
+	for token := range ranks {
+		tokens = append(tokens, token)
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return ranks[tokens[i]] < ranks[tokens[j]]
+	})
+
+	var merges [][2]string
+	for _, token := range tokens {
+		var local [][2]string
+		for i := 1; i < len(token); i++ {
+			left, right := token[:i], token[i:]
+			_, hasLeft := ranks[left]
+			_, hasRight := ranks[right]
+			if hasLeft && hasRight {
+				local = append(local, [2]string{left, right})
+			}
+		}
+		sort.SliceStable(local, func(i, j int) bool {
+			if ranks[local[i][0]] != ranks[local[j][0]] {
+				return ranks[local[i][0]] < ranks[local[j][0]]
+			}
+			return ranks[local[i][1]] < ranks[local[j][1]]
+		})
+		for _, merge := range local {
+			merges = append(merges, [2]string{byteLevelString([]byte(merge[0])), byteLevelString([]byte(merge[1]))})
+		}
+	}
+	return merges
+}
+
+// byteLevelAlphabet maps every byte to the character that represents it in byte-level
+// BPE vocabularies: printable characters stand for themselves, and the other bytes are
+// mapped, in order, to the characters from U+0100.
+var byteLevelAlphabet = func() [256]rune {
+	var alphabet [256]rune
+	next := rune(0x100)
+	for b := range 256 {
+		if ('!' <= b && b <= '~') || ('¡' <= b && b <= '¬') || ('®' <= b && b <= 'ÿ') {
+			alphabet[b] = rune(b)
+		} else {
+			alphabet[b] = next
+			next++
+		}
+	}
+	return alphabet
+}()
+
+// byteLevelString returns the byte-level BPE form of token.
+func byteLevelString(token []byte) string {
+	var sb strings.Builder
+	for _, b := range token {
+		sb.WriteRune(byteLevelAlphabet[b])
+	}
+	return sb.String()
+}
@@ -0,0 +1,133 @@
+package tokenizers
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestByteLevelString(t *testing.T) {
+	require.Equal(t, "helloĠworldĊ", byteLevelString([]byte("hello world\n")))
+	require.Equal(t, "ĀŃ", byteLevelString([]byte{0x00, 0xad}))
+	require.Equal(t, "Ã©", byteLevelString([]byte("é")), "Multi-byte characters map byte by byte")
+}
+
+func TestParseTiktokenRanks(t *testing.T) {
+	ranks, err := parseTiktokenRanks([]byte("aGk= 7\r\n\nIA== 3\n"))
+	require.NoError(t, err)
+	require.Equal(t, map[string]uint32{"hi": 7, " ": 3}, ranks)
+
+	tests := []struct {
+		name     string
+		data     string
+		contains string
+	}{
+		{"Empty", "\n", "tiktoken ranks cannot be empty"},
+		{"Missing rank", "aGk=\n", `invalid rank on line 1: "aGk="`},
+		{"Invalid base64", "IA== 0\n!!! 1\n", `invalid token on line 2: "!!!"`},
+		{"Invalid rank", "aGk= -1\n", `invalid rank on line 1: "-1"`},
+		{"Duplicate token", "aGk= 0\naGk= 1\n", `duplicate token "aGk=" on line 2`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseTiktokenRanks([]byte(tc.data))
+			require.ErrorContains(t, err, tc.contains)
+		})
+	}
+}
+
+func TestTiktokenMerges(t *testing.T) {
+	ranks := map[string]uint32{"a": 0, "b": 1, "c": 2, " ": 3, "ab": 4, "bc": 5, "abc": 6, " a": 7}
+
+	// Merges follow the rank of the merged token; the splits of one token are ordered by
+	// the ranks of their parts.
+	require.Equal(t, [][2]string{
+		{"a", "b"}, {"b", "c"},
+		{"a", "bc"}, {"ab", "c"},
+		{"Ġ", "a"},
+	}, tiktokenMerges(ranks))
+}
+
+func TestNewTiktokenPipeline(t *testing.T) {
+	ranks, err := os.ReadFile("testdata/test.tiktoken")
+	require.NoError(t, err)
+
+	pipeline, err := NewTiktokenPipeline(ranks, TiktokenPatternCL100K, map[string]uint32{"<|endoftext|>": 300})
+	require.NoError(t, err)
+	data, err := pipeline.JSON()
+	require.NoError(t, err)
+
+	var config struct {
+		AddedTokens []struct {
+			ID      uint32 `json:"id"`
+			Content string `json:"content"`
+			Special bool   `json:"special"`
+		} `json:"added_tokens"`
+		PreTokenizer struct {
+			PreTokenizers []map[string]any `json:"pretokenizers"`
+		} `json:"pre_tokenizer"`
+		Model struct {
+			Vocab        map[string]uint32 `json:"vocab"`
+			Merges       [][2]string       `json:"merges"`
+			IgnoreMerges bool              `json:"ignore_merges"`
+		} `json:"model"`
+	}
+	require.NoError(t, json.Unmarshal(data, &config))
+	require.Len(t, config.Model.Vocab, 267)
+	require.Equal(t, uint32(32), config.Model.Vocab["Ġ"])
+	require.Equal(t, uint32(264), config.Model.Vocab["Ġworld"])
+	require.Len(t, config.Model.Merges, 9)
+	require.Equal(t, [2]string{"Ġwor", "ld"}, config.Model.Merges[8])
+	require.True(t, config.Model.IgnoreMerges, "Pieces in the ranks are looked up before merging, as in tiktoken")
+	require.Len(t, config.PreTokenizer.PreTokenizers, 2)
+	require.Equal(t, false, config.PreTokenizer.PreTokenizers[1]["use_regex"])
+	require.Len(t, config.AddedTokens, 1)
+	require.Equal(t, uint32(300), config.AddedTokens[0].ID, "Special tokens keep their IDs")
+	require.True(t, config.AddedTokens[0].Special)
+
+	t.Run("Empty pattern", func(t *testing.T) {
+		_, err := NewTiktokenPipeline(ranks, "", nil)
+		require.ErrorContains(t, err, "split pattern cannot be empty")
+	})
+
+	t.Run("Special token with a rank", func(t *testing.T) {
+		_, err := NewTiktokenPipeline(ranks, TiktokenPatternCL100K, map[string]uint32{"hello": 1000})
+		require.ErrorContains(t, err, `special token "hello" with ID 1000 is also a token with rank 259`)
+	})
+}
+
+func TestFromTiktoken(t *testing.T) {
+	_, err := FromTiktoken("testdata/missing.tiktoken", TiktokenPatternCL100K, nil)
+	require.ErrorContains(t, err, "tiktoken ranks file does not exist at path")
+
+	libpath := checkLibraryExists(t)
+
+	for name, pattern := range map[string]string{"cl100k": TiktokenPatternCL100K, "o200k": TiktokenPatternO200K} {
+		t.Run(name, func(t *testing.T) {
+			tok, err := FromTiktoken("testdata/test.tiktoken", pattern, map[string]uint32{"<|endoftext|>": 300}, WithLibraryPath(libpath))
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				_ = tok.Close()
+			})
+
+			res, err := tok.Encode("hello world<|endoftext|>", WithReturnTokens())
+			require.NoError(t, err)
+			require.Equal(t, []uint32{259, 264, 300}, res.IDs)
+			require.Equal(t, []string{"hello", "Ġworld", "<|endoftext|>"}, res.Tokens)
+
+			res, err = tok.Encode("héllo\n")
+			require.NoError(t, err)
+			require.Equal(t, []uint32{104, 195, 169, 257, 111, 10}, res.IDs, "Unmerged bytes keep their byte rank")
+
+			res, err = tok.Encode("xyz xy")
+			require.NoError(t, err)
+			require.Equal(t, []uint32{265, 32, 120, 121}, res.IDs, "A piece with a rank but no merges is one token")
+
+			text, err := tok.Decode([]uint32{259, 264, 300, 195, 169}, false)
+			require.NoError(t, err)
+			require.Equal(t, "hello world<|endoftext|>é", text)
+		})
+	}
+}
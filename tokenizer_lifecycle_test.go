@@ -58,12 +58,18 @@ func TestTokenizerMethodsReturnErrTokenizerClosed(t *testing.T) {
 	_, err = tok.Encode("Hello again")
 	require.ErrorIs(t, err, ErrTokenizerClosed)
 
+	_, err = tok.EncodeBatch([]string{"query", "document"})
+	require.ErrorIs(t, err, ErrTokenizerClosed)
+
 	_, err = tok.EncodePairs([]string{"query"}, []string{"document"})
 	require.ErrorIs(t, err, ErrTokenizerClosed)
 
 	_, err = tok.Decode(encoding.IDs, false)
 	require.ErrorIs(t, err, ErrTokenizerClosed)
 
+	_, err = tok.DecodeBatch([][]uint32{encoding.IDs}, false)
+	require.ErrorIs(t, err, ErrTokenizerClosed)
+
 	_, err = tok.VocabSize()
 	require.ErrorIs(t, err, ErrTokenizerClosed)
 
@@ -0,0 +1,315 @@
+package tokenizers
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// SpecialTokens are the special tokens that tokenizer_config.json and
+// special_tokens_map.json of a model declare. Tokens the model does not declare are empty.
+type SpecialTokens struct {
+	BOSToken                string
+	EOSToken                string
+	UnkToken                string
+	PadToken                string
+	SepToken                string
+	ClsToken                string
+	MaskToken               string
+	AdditionalSpecialTokens []string
+}
+
+// merge returns s with the tokens that other declares replaced.
+func (s SpecialTokens) merge(other SpecialTokens) SpecialTokens {
+	for _, field := range []struct{ dst, src *string }{
+		{&s.BOSToken, &other.BOSToken},
+		{&s.EOSToken, &other.EOSToken},
+		{&s.UnkToken, &other.UnkToken},
+		{&s.PadToken, &other.PadToken},
+		{&s.SepToken, &other.SepToken},
+		{&s.ClsToken, &other.ClsToken},
+		{&s.MaskToken, &other.MaskToken},
+	} {
+		if *field.src != "" {
+			*field.dst = *field.src
+		}
+	}
+	if other.AdditionalSpecialTokens != nil {
+		s.AdditionalSpecialTokens = other.AdditionalSpecialTokens
+	}
+	return s
+}
+
+// hfTokenizerConfig holds the fields of tokenizer_config.json that the tokenizer uses.
+type hfTokenizerConfig struct {
+	ChatTemplate   string
+	SpecialTokens  SpecialTokens
+	ModelMaxLength int    // 0 if the model declares no limit
+	PaddingSide    string // "left" or "right"
+	TruncationSide string // "left" or "right"
+	AddBOSToken    *bool
+	AddEOSToken    *bool
+}
+
+// hfSpecialTokenFields holds the special token fields shared by tokenizer_config.json and
+// special_tokens_map.json.
+type hfSpecialTokenFields struct {
+	BOSToken                json.RawMessage   `json:"bos_token"`
+	EOSToken                json.RawMessage   `json:"eos_token"`
+	UnkToken                json.RawMessage   `json:"unk_token"`
+	PadToken                json.RawMessage   `json:"pad_token"`
+	SepToken                json.RawMessage   `json:"sep_token"`
+	ClsToken                json.RawMessage   `json:"cls_token"`
+	MaskToken               json.RawMessage   `json:"mask_token"`
+	AdditionalSpecialTokens []json.RawMessage `json:"additional_special_tokens"`
+}
+
+// parse returns the special tokens, where each is either a string or an added token
+// object. filename is used in errors.
+func (f hfSpecialTokenFields) parse(filename string) (SpecialTokens, error) {
+	var tokens SpecialTokens
+	for _, field := range []struct {
+		name  string
+		raw   json.RawMessage
+		value *string
+	}{
+		{"bos_token", f.BOSToken, &tokens.BOSToken},
+		{"eos_token", f.EOSToken, &tokens.EOSToken},
+		{"unk_token", f.UnkToken, &tokens.UnkToken},
+		{"pad_token", f.PadToken, &tokens.PadToken},
+		{"sep_token", f.SepToken, &tokens.SepToken},
+		{"cls_token", f.ClsToken, &tokens.ClsToken},
+		{"mask_token", f.MaskToken, &tokens.MaskToken},
+	} {
+		token, err := parseHFSpecialToken(field.raw)
+		if err != nil {
+			return SpecialTokens{}, errors.Wrapf(err, "invalid %s in %s", field.name, filename)
+		}
+		*field.value = token
+	}
+	if f.AdditionalSpecialTokens != nil {
+		tokens.AdditionalSpecialTokens = make([]string, 0, len(f.AdditionalSpecialTokens))
+		for _, raw := range f.AdditionalSpecialTokens {
+			token, err := parseHFSpecialToken(raw)
+			if err != nil {
+				return SpecialTokens{}, errors.Wrapf(err, "invalid additional_special_tokens in %s", filename)
+			}
+			tokens.AdditionalSpecialTokens = append(tokens.AdditionalSpecialTokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+// parseHFTokenizerConfig parses tokenizer_config.json. chat_template is either a template
+// or a list of named templates, of which the "default" one is used.
+func parseHFTokenizerConfig(data []byte) (*hfTokenizerConfig, error) {
+	var raw struct {
+		hfSpecialTokenFields
+		ChatTemplate   json.RawMessage `json:"chat_template"`
+		ModelMaxLength json.Number     `json:"model_max_length"`
+		PaddingSide    *string         `json:"padding_side"`
+		TruncationSide *string         `json:"truncation_side"`
+		AddBOSToken    *bool           `json:"add_bos_token"`
+		AddEOSToken    *bool           `json:"add_eos_token"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, errors.Wrap(err, "invalid tokenizer_config.json format")
+	}
+
+	config := &hfTokenizerConfig{AddBOSToken: raw.AddBOSToken, AddEOSToken: raw.AddEOSToken}
+	if len(raw.ChatTemplate) > 0 && string(raw.ChatTemplate) != "null" {
+		if err := json.Unmarshal(raw.ChatTemplate, &config.ChatTemplate); err != nil {
+			var named []struct {
+				Name     string `json:"name"`
+				Template string `json:"template"`
+			}
+			if err := json.Unmarshal(raw.ChatTemplate, &named); err != nil {
+				return nil, errors.New("invalid chat_template in tokenizer_config.json")
+			}
+			for _, tmpl := range named {
+				if tmpl.Name == "default" {
+					config.ChatTemplate = tmpl.Template
+				}
+			}
+		}
+	}
+
+	tokens, err := raw.parse(hfTokenizerConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	config.SpecialTokens = tokens
+
+	// transformers stores a very large number, which does not fit an int, for models
+	// without a limit.
+	if raw.ModelMaxLength != "" {
+		if n, err := strconv.ParseInt(raw.ModelMaxLength.String(), 10, 64); err == nil && n > 0 && n <= maxModelMaxLength {
+			config.ModelMaxLength = int(n)
+		}
+	}
+
+	for _, side := range []struct {
+		name  string
+		value *string
+		dst   *string
+	}{
+		{"padding_side", raw.PaddingSide, &config.PaddingSide},
+		{"truncation_side", raw.TruncationSide, &config.TruncationSide},
+	} {
+		if side.value == nil {
+			continue
+		}
+		if *side.value != "left" && *side.value != "right" {
+			return nil, errors.Errorf("invalid %s in tokenizer_config.json: %q", side.name, *side.value)
+		}
+		*side.dst = *side.value
+	}
+	return config, nil
+}
+
+// maxModelMaxLength is the largest model_max_length taken as a limit.
+const maxModelMaxLength = 1<<31 - 1
+
+// parseHFSpecialTokensMap parses special_tokens_map.json.
+func parseHFSpecialTokensMap(data []byte) (SpecialTokens, error) {
+	var raw hfSpecialTokenFields
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return SpecialTokens{}, errors.Wrap(err, "invalid special_tokens_map.json format")
+	}
+	return raw.parse(hfSpecialTokensMapFile)
+}
+
+// parseHFSpecialToken returns the content of a special token given as a string or as an
+// added token object.
+func parseHFSpecialToken(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	var token string
+	if err := json.Unmarshal(raw, &token); err == nil {
+		return token, nil
+	}
+	var added struct {
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal(raw, &added); err != nil {
+		return "", err
+	}
+	return added.Content, nil
+}
+
+// WithTokenizerConfig sets the contents of the tokenizer_config.json that accompanies the
+// tokenizer, which provides the chat template of ApplyChatTemplate, SpecialTokens and
+// ModelMaxLength. FromHuggingFace loads it from the model repository unless this option
+// is given.
+func WithTokenizerConfig(data []byte) TokenizerOption {
+	return func(t *Tokenizer) error {
+		config, err := parseHFTokenizerConfig(data)
+		if err != nil {
+			return err
+		}
+		t.tokenizerConfig = config
+		return nil
+	}
+}
+
+// WithSpecialTokensMap sets the contents of the special_tokens_map.json that accompanies
+// the tokenizer. Its tokens take precedence over those of tokenizer_config.json, as in
+// transformers. FromHuggingFace loads it from the model repository unless this option is
+// given.
+func WithSpecialTokensMap(data []byte) TokenizerOption {
+	return func(t *Tokenizer) error {
+		tokens, err := parseHFSpecialTokensMap(data)
+		if err != nil {
+			return err
+		}
+		t.specialTokensMap = &tokens
+		return nil
+	}
+}
+
+// SpecialTokens returns the special tokens declared by tokenizer_config.json and
+// special_tokens_map.json. Tokens that are not declared, or all of them if neither file
+// was loaded, are empty.
+func (t *Tokenizer) SpecialTokens() SpecialTokens {
+	var tokens SpecialTokens
+	if t.tokenizerConfig != nil {
+		tokens = t.tokenizerConfig.SpecialTokens
+	}
+	if t.specialTokensMap != nil {
+		tokens = tokens.merge(*t.specialTokensMap)
+	}
+	tokens.AdditionalSpecialTokens = append([]string(nil), tokens.AdditionalSpecialTokens...)
+	return tokens
+}
+
+// ModelMaxLength returns the maximum input length of the model, from model_max_length in
+// tokenizer_config.json, or 0 if it is unknown or the model declares no limit.
+func (t *Tokenizer) ModelMaxLength() int {
+	if t.tokenizerConfig == nil {
+		return 0
+	}
+	return t.tokenizerConfig.ModelMaxLength
+}
+
+// applyTokenizerConfig configures the tokenizer as transformers does from its
+// tokenizer_config.json: truncation to model_max_length on truncation_side, padding of
+// batches with the pad token on padding_side, and adding special tokens when encoding,
+// unless add_bos_token and add_eos_token are both false. Truncation and padding that are
+// already enabled, by options or by tokenizer.json, are kept, as is adding special tokens
+// if it was chosen with WithDefaultEncodingOptions.
+func (t *Tokenizer) applyTokenizerConfig() error {
+	config := t.tokenizerConfig
+	if config == nil {
+		return nil
+	}
+	truncation, err := t.GetTruncation()
+	if err != nil {
+		return err
+	}
+	if config.ModelMaxLength > 0 && truncation == nil {
+		direction := TruncationDirectionRight
+		if config.TruncationSide == "left" {
+			direction = TruncationDirectionLeft
+		}
+		err := t.SetTruncation(TruncationParams{
+			MaxLength: uintptr(config.ModelMaxLength),
+			Direction: direction,
+			Strategy:  TruncationStrategyLongestFirst,
+		})
+		if err != nil {
+			return errors.Wrap(err, "failed to apply model_max_length")
+		}
+	}
+	padding, err := t.GetPadding()
+	if err != nil {
+		return err
+	}
+	if padToken := t.SpecialTokens().PadToken; padToken != "" && padding == nil {
+		if padID, ok := t.TokenToID(padToken); ok {
+			direction := PaddingDirectionRight
+			if config.PaddingSide == "left" {
+				direction = PaddingDirectionLeft
+			}
+			err := t.SetPadding(PaddingParams{
+				Strategy:  PaddingStrategy{Tag: PaddingStrategyBatchLongest},
+				Direction: direction,
+				PadToken:  padToken,
+				PadID:     padID,
+			})
+			if err != nil {
+				return errors.Wrap(err, "failed to apply pad_token")
+			}
+		}
+	}
+	if !t.addSpecialTokensSet {
+		addBOS := config.AddBOSToken == nil || *config.AddBOSToken
+		addEOS := config.AddEOSToken == nil || *config.AddEOSToken
+		t.defaultEncodingOpts.AddSpecialTokens = addBOS || addEOS
+	}
+	return nil
+}
@@ -0,0 +1,365 @@
+package tokenizers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHFTokenizerConfig(t *testing.T) {
+	config, err := parseHFTokenizerConfig([]byte(mistralConfig))
+	require.NoError(t, err)
+	require.Contains(t, config.ChatTemplate, "[INST]")
+	require.Equal(t, "<s>", config.SpecialTokens.BOSToken, "Added token objects are read by content")
+	require.Equal(t, "</s>", config.SpecialTokens.EOSToken)
+	require.Empty(t, config.SpecialTokens.PadToken)
+	require.Zero(t, config.ModelMaxLength)
+	require.Nil(t, config.AddBOSToken)
+
+	t.Run("Settings", func(t *testing.T) {
+		config, err := parseHFTokenizerConfig([]byte(`{
+			"model_max_length": 512,
+			"padding_side": "left",
+			"truncation_side": "right",
+			"add_bos_token": true,
+			"add_eos_token": false,
+			"pad_token": "[PAD]",
+			"additional_special_tokens": ["<a>", {"content": "<b>"}]
+		}`))
+		require.NoError(t, err)
+		require.Equal(t, 512, config.ModelMaxLength)
+		require.Equal(t, "left", config.PaddingSide)
+		require.Equal(t, "right", config.TruncationSide)
+		require.True(t, *config.AddBOSToken)
+		require.False(t, *config.AddEOSToken)
+		require.Equal(t, "[PAD]", config.SpecialTokens.PadToken)
+		require.Equal(t, []string{"<a>", "<b>"}, config.SpecialTokens.AdditionalSpecialTokens)
+	})
+
+	t.Run("No length limit", func(t *testing.T) {
+		config, err := parseHFTokenizerConfig([]byte(`{"model_max_length": 1000000000000000019884624838656}`))
+		require.NoError(t, err)
+		require.Zero(t, config.ModelMaxLength)
+	})
+
+	t.Run("Named templates", func(t *testing.T) {
+		config, err := parseHFTokenizerConfig([]byte(`{"chat_template": [
+			{"name": "tool_use", "template": "tools"},
+			{"name": "default", "template": "default"}
+		], "pad_token": null}`))
+		require.NoError(t, err)
+		require.Equal(t, "default", config.ChatTemplate)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := parseHFTokenizerConfig([]byte(`[]`))
+		require.ErrorContains(t, err, "invalid tokenizer_config.json format")
+
+		_, err = parseHFTokenizerConfig([]byte(`{"chat_template": 1}`))
+		require.ErrorContains(t, err, "invalid chat_template in tokenizer_config.json")
+
+		_, err = parseHFTokenizerConfig([]byte(`{"eos_token": 1}`))
+		require.ErrorContains(t, err, "invalid eos_token in tokenizer_config.json")
+
+		_, err = parseHFTokenizerConfig([]byte(`{"padding_side": "top"}`))
+		require.ErrorContains(t, err, `invalid padding_side in tokenizer_config.json: "top"`)
+	})
+}
+
+func TestSpecialTokens(t *testing.T) {
+	tok := &Tokenizer{}
+	require.Equal(t, SpecialTokens{}, tok.SpecialTokens())
+	require.Zero(t, tok.ModelMaxLength())
+
+	require.NoError(t, WithTokenizerConfig([]byte(`{
+		"bos_token": "<s>", "eos_token": "</s>", "unk_token": "<unk>", "model_max_length": 2048
+	}`))(tok))
+	require.NoError(t, WithSpecialTokensMap([]byte(`{
+		"eos_token": {"content": "<|end|>", "lstrip": false},
+		"pad_token": "<pad>",
+		"additional_special_tokens": ["<tool>"]
+	}`))(tok))
+
+	require.Equal(t, SpecialTokens{
+		BOSToken:                "<s>",
+		EOSToken:                "<|end|>",
+		UnkToken:                "<unk>",
+		PadToken:                "<pad>",
+		AdditionalSpecialTokens: []string{"<tool>"},
+	}, tok.SpecialTokens(), "special_tokens_map.json takes precedence")
+	require.Equal(t, 2048, tok.ModelMaxLength())
+
+	err := WithSpecialTokensMap([]byte(`{"pad_token": 0}`))(tok)
+	require.ErrorContains(t, err, "invalid pad_token in special_tokens_map.json")
+}
+
+func TestLoadHFTokenizerConfig(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/chat-model/resolve/main/tokenizer_config.json":
+			_, _ = w.Write([]byte(chatMLConfig))
+		case "/chat-model/resolve/main/special_tokens_map.json":
+			_, _ = w.Write([]byte(`{"pad_token": "<|endoftext|>"}`))
+		case "/jinja-model/resolve/main/tokenizer_config.json":
+			_, _ = w.Write([]byte(`{"eos_token": "</s>"}`))
+		case "/jinja-model/resolve/main/chat_template.jinja":
+			_, _ = w.Write([]byte("{{ messages[0].content }}{{ eos_token }}"))
+		case "/base-model/resolve/main/tokenizer_config.json":
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	config := &HFConfig{
+		baseURL:    server.URL,
+		Revision:   "main",
+		Timeout:    5 * time.Second,
+		MaxRetries: 3,
+	}
+	load := func(t *testing.T, modelID, cacheDir string) (*hfTokenizerConfig, *SpecialTokens, []string) {
+		t.Helper()
+		mu.Lock()
+		requested = nil
+		mu.Unlock()
+		tokenizerConfig, specialTokensMap, err := loadHFTokenizerConfig(t.Context(), modelID, config, cacheDir)
+		require.NoError(t, err)
+		mu.Lock()
+		defer mu.Unlock()
+		return tokenizerConfig, specialTokensMap, requested
+	}
+
+	t.Run("Downloaded and cached", func(t *testing.T) {
+		cacheDir := t.TempDir()
+		tokenizerConfig, specialTokensMap, requests := load(t, "chat-model", cacheDir)
+		require.Contains(t, tokenizerConfig.ChatTemplate, "<|im_start|>")
+		require.Equal(t, "<|endoftext|>", specialTokensMap.PadToken)
+		require.Equal(t, []string{
+			"/chat-model/resolve/main/tokenizer_config.json",
+			"/chat-model/resolve/main/special_tokens_map.json",
+		}, requests)
+		require.FileExists(t, filepath.Join(cacheDir, "tokenizer_config.json"))
+		require.FileExists(t, filepath.Join(cacheDir, "special_tokens_map.json"))
+
+		tokenizerConfig, specialTokensMap, requests = load(t, "chat-model", cacheDir)
+		require.Contains(t, tokenizerConfig.ChatTemplate, "<|im_start|>")
+		require.Equal(t, "<|endoftext|>", specialTokensMap.PadToken)
+		require.Empty(t, requests, "The cached files are used")
+	})
+
+	t.Run("Template from chat_template.jinja", func(t *testing.T) {
+		cacheDir := t.TempDir()
+		tokenizerConfig, specialTokensMap, requests := load(t, "jinja-model", cacheDir)
+		require.Equal(t, "{{ messages[0].content }}{{ eos_token }}", tokenizerConfig.ChatTemplate)
+		require.Nil(t, specialTokensMap)
+		require.Len(t, requests, 3)
+		data, err := os.ReadFile(filepath.Join(cacheDir, "chat_template.jinja"))
+		require.NoError(t, err)
+		require.Equal(t, tokenizerConfig.ChatTemplate, string(data))
+
+		tokenizerConfig, _, requests = load(t, "jinja-model", cacheDir)
+		require.Equal(t, "{{ messages[0].content }}{{ eos_token }}", tokenizerConfig.ChatTemplate)
+		require.Empty(t, requests)
+	})
+
+	t.Run("Without optional files", func(t *testing.T) {
+		cacheDir := t.TempDir()
+		tokenizerConfig, _, requests := load(t, "base-model", cacheDir)
+		require.Empty(t, tokenizerConfig.ChatTemplate)
+		require.Len(t, requests, 3, "Optional files are looked up with a fresh config")
+
+		_, _, requests = load(t, "base-model", cacheDir)
+		require.Empty(t, requests, "A cached config without optional files stays offline")
+	})
+
+	t.Run("Missing config", func(t *testing.T) {
+		cacheDir := t.TempDir()
+		tokenizerConfig, specialTokensMap, requests := load(t, "missing-model", cacheDir)
+		require.Nil(t, tokenizerConfig)
+		require.Nil(t, specialTokensMap)
+		require.Len(t, requests, 2)
+		require.FileExists(t, filepath.Join(cacheDir, hfNotFoundDir, "tokenizer_config.json"))
+		require.FileExists(t, filepath.Join(cacheDir, hfNotFoundDir, "special_tokens_map.json"))
+
+		_, _, requests = load(t, "missing-model", cacheDir)
+		require.Empty(t, requests, "Files the model does not have are not requested again")
+	})
+
+	t.Run("Cache with only tokenizer.json", func(t *testing.T) {
+		// Caches of earlier versions hold tokenizer.json only.
+		cacheDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(cacheDir, "tokenizer.json"), []byte(mockTokenizerJSON), 0600))
+		tokenizerConfig, specialTokensMap, requests := load(t, "chat-model", cacheDir)
+		require.Contains(t, tokenizerConfig.ChatTemplate, "<|im_start|>")
+		require.Equal(t, "<|endoftext|>", specialTokensMap.PadToken)
+		require.Equal(t, []string{
+			"/chat-model/resolve/main/tokenizer_config.json",
+			"/chat-model/resolve/main/special_tokens_map.json",
+		}, requests, "Files missing from the cache are fetched")
+	})
+
+	t.Run("FromHuggingFace with an old cache", func(t *testing.T) {
+		libpath := checkLibraryExists(t)
+		cacheDir := t.TempDir()
+		oldDir := filepath.Join(cacheDir, "models", "chat-model", "main")
+		require.NoError(t, os.MkdirAll(oldDir, 0750))
+		require.NoError(t, os.WriteFile(filepath.Join(oldDir, "tokenizer.json"), []byte(mockTokenizerJSON), 0600))
+
+		tok, err := FromHuggingFace("chat-model",
+			WithLibraryPath(libpath),
+			WithHFBaseURL(server.URL),
+			WithHFCacheDir(cacheDir),
+			WithHFUseLocalCache(false),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = tok.Close()
+		})
+		text, err := tok.ApplyChatTemplate([]ChatMessage{{Role: "user", Content: "Hello"}}, false)
+		require.NoError(t, err)
+		require.Contains(t, text, "<|im_start|>user\nHello<|im_end|>")
+		require.Equal(t, "<|endoftext|>", tok.SpecialTokens().PadToken)
+	})
+
+	t.Run("Partially cached", func(t *testing.T) {
+		cacheDir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(cacheDir, "tokenizer_config.json"), []byte(chatMLConfig), 0600))
+		_, specialTokensMap, requests := load(t, "chat-model", cacheDir)
+		require.Equal(t, "<|endoftext|>", specialTokensMap.PadToken)
+		require.Equal(t, []string{"/chat-model/resolve/main/special_tokens_map.json"}, requests,
+			"Only the file that is neither cached nor recorded as missing is fetched")
+	})
+
+	t.Run("Offline", func(t *testing.T) {
+		offline := *config
+		offline.OfflineMode = true
+		tokenizerConfig, specialTokensMap, err := loadHFTokenizerConfig(t.Context(), "chat-model", &offline, t.TempDir())
+		require.NoError(t, err)
+		require.Nil(t, tokenizerConfig)
+		require.Nil(t, specialTokensMap)
+	})
+}
+
+func TestApplyTokenizerConfig(t *testing.T) {
+	libpath := checkLibraryExists(t)
+
+	tokenizerConfig := []byte(`{
+		"model_max_length": 4,
+		"truncation_side": "left",
+		"padding_side": "left",
+		"pad_token": "[PAD]"
+	}`)
+	tok, err := FromWordPieceVocab("testdata/vocab.txt", true,
+		WithLibraryPath(libpath),
+		WithTokenizerConfig(tokenizerConfig),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = tok.Close()
+	})
+	require.NoError(t, tok.applyTokenizerConfig())
+
+	truncation, err := tok.GetTruncation()
+	require.NoError(t, err)
+	require.Equal(t, uintptr(4), truncation.MaxLength)
+	require.Equal(t, TruncationDirectionLeft, truncation.Direction)
+
+	padding, err := tok.GetPadding()
+	require.NoError(t, err)
+	require.Equal(t, PaddingDirectionLeft, padding.Direction)
+	require.Equal(t, "[PAD]", padding.PadToken)
+
+	res, err := tok.Encode("hello world hello", WithReturnTokens())
+	require.NoError(t, err)
+	require.Equal(t, []string{"[CLS]", "world", "hello", "[SEP]"}, res.Tokens, "Special tokens are added and the start is truncated")
+
+	t.Run("Options take precedence", func(t *testing.T) {
+		tok, err := FromWordPieceVocab("testdata/vocab.txt", true,
+			WithLibraryPath(libpath),
+			WithTokenizerConfig(tokenizerConfig),
+			WithTruncation(8, TruncationDirectionRight, TruncationStrategyLongestFirst),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = tok.Close()
+		})
+		require.NoError(t, tok.applyTokenizerConfig())
+
+		truncation, err := tok.GetTruncation()
+		require.NoError(t, err)
+		require.Equal(t, uintptr(8), truncation.MaxLength)
+	})
+
+	t.Run("Settings of tokenizer.json are kept", func(t *testing.T) {
+		tok, err := FromFile("./tokenizer.json",
+			WithLibraryPath(libpath),
+			WithTokenizerConfig(tokenizerConfig),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = tok.Close()
+		})
+		require.NoError(t, tok.applyTokenizerConfig())
+
+		truncation, err := tok.GetTruncation()
+		require.NoError(t, err)
+		require.Equal(t, uintptr(128), truncation.MaxLength)
+		require.Equal(t, TruncationDirectionRight, truncation.Direction)
+
+		padding, err := tok.GetPadding()
+		require.NoError(t, err)
+		require.Equal(t, PaddingStrategy{Tag: PaddingStrategyFixed, FixedSize: 128}, padding.Strategy)
+		require.Equal(t, PaddingDirectionRight, padding.Direction)
+	})
+
+	t.Run("Special tokens chosen by the caller are kept", func(t *testing.T) {
+		tok, err := FromWordPieceVocab("testdata/vocab.txt", true,
+			WithLibraryPath(libpath),
+			WithTokenizerConfig([]byte(`{"add_bos_token": false, "add_eos_token": false}`)),
+			WithDefaultEncodingOptions(WithAddSpecialTokens()),
+		)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = tok.Close()
+		})
+		require.NoError(t, tok.applyTokenizerConfig())
+		require.True(t, tok.defaultEncodingOpts.AddSpecialTokens)
+	})
+}
+
+func TestWithDefaultEncodingOptions(t *testing.T) {
+	tok := &Tokenizer{}
+	require.NoError(t, WithDefaultEncodingOptions(WithReturnAttentionMask(), WithReturnTokens())(tok))
+	require.Equal(t, EncodeOptions{ReturnAttentionMask: true, ReturnTokens: true}, tok.defaultEncodingOpts)
+	require.False(t, tok.addSpecialTokensSet, "Options that leave AddSpecialTokens alone do not choose it")
+
+	require.NoError(t, WithDefaultEncodingOptions(WithAddSpecialTokens())(tok))
+	require.True(t, tok.defaultEncodingOpts.AddSpecialTokens)
+	require.True(t, tok.addSpecialTokensSet)
+
+	tok = &Tokenizer{}
+	withoutSpecialTokens := func(eo *EncodeOptions) error {
+		eo.AddSpecialTokens = false
+		return nil
+	}
+	require.NoError(t, WithDefaultEncodingOptions(withoutSpecialTokens)(tok))
+	require.True(t, tok.addSpecialTokensSet, "Turning special tokens off is a choice as well")
+
+	err := WithDefaultEncodingOptions(func(*EncodeOptions) error {
+		return errors.New("bad option")
+	})(&Tokenizer{})
+	require.ErrorContains(t, err, "failed to apply default encoding option: bad option")
+}
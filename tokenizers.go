@@ -1,8 +1,10 @@
 package tokenizers
 
 import (
+	"container/list"
 	"math"
 	"os"
+	"runtime"
 	"sync"
 	"unsafe"
 
@@ -11,22 +13,28 @@ import (
 	"github.com/pkg/errors"
 )
 
+const SUCCESS = 0
+
+// Error codes returned by the native library. Each code is also an error value that
+// can be matched with errors.Is against errors returned by this package.
 const (
-	SUCCESS                    = 0
-	ErrInvalidUTF8             = -1
-	ErrEncodingFailed          = -2
-	ErrNullOutput              = -3
-	ErrInvalidTokenizerRef     = -4
-	ErrNullInput               = -5
-	ErrTokenizerCreationFailed = -6
-	ErrInvalidPath             = -7
-	ErrFileNotFound            = -8
-	ErrTruncationFailed        = -9
-	ErrPaddingFailed           = -10
-	ErrDecodeFailed            = -11
-	ErrCStringConversionFailed = -12
-	ErrInvalidIDs              = -13
-	ErrInvalidOptions          = -14
+	ErrInvalidUTF8             ErrorCode = -1
+	ErrEncodingFailed          ErrorCode = -2
+	ErrNullOutput              ErrorCode = -3
+	ErrInvalidTokenizerRef     ErrorCode = -4
+	ErrNullInput               ErrorCode = -5
+	ErrTokenizerCreationFailed ErrorCode = -6
+	ErrInvalidPath             ErrorCode = -7
+	ErrFileNotFound            ErrorCode = -8
+	ErrTruncationFailed        ErrorCode = -9
+	ErrPaddingFailed           ErrorCode = -10
+	ErrDecodeFailed            ErrorCode = -11
+	ErrCStringConversionFailed ErrorCode = -12
+	ErrInvalidIDs              ErrorCode = -13
+	ErrInvalidOptions          ErrorCode = -14
+	ErrBufferTooSmall          ErrorCode = -15
+	ErrSerializationFailed     ErrorCode = -16
+	ErrTrainingFailed          ErrorCode = -17
 )
 
 // ErrTokenizerClosed is returned when an operation is attempted on a closed tokenizer.
@@ -35,7 +43,7 @@ var ErrTokenizerClosed = errors.New("tokenizer is closed")
 // AbiCompatibilityConstraint defines the required version range for ABI compatibility.
 // The library version from Cargo.toml is used as the ABI version.
 // Update this constraint when making breaking changes to the FFI interface.
-const AbiCompatibilityConstraint = "^0.1.x"
+const AbiCompatibilityConstraint = "^0.2.x"
 
 // result structs
 
@@ -83,6 +91,30 @@ type PaddingStrategy struct {
 	FixedSize uintptr // Only valid if Tag == PaddingStrategyFixed
 }
 
+type PaddingDirection uint8
+
+const (
+	PaddingDirectionRight PaddingDirection = iota
+	PaddingDirectionLeft
+)
+const PaddingDirectionDefault PaddingDirection = PaddingDirectionRight
+
+// PaddingOptionsVersion is the layout version of PaddingOptions expected by the Rust library.
+const PaddingOptionsVersion uint32 = 1
+
+// PaddingParams describes how encodings are padded.
+// The zero value pads on the right using the tokenizer's pad token.
+type PaddingParams struct {
+	Strategy        PaddingStrategy
+	Direction       PaddingDirection
+	PadToMultipleOf uintptr // Round padded lengths up to a multiple of this value (0 disables)
+	// PadToken is the token used for padding. When empty the pad token of the
+	// tokenizer.json (or "[PAD]") is kept and PadID/PadTypeID are ignored.
+	PadToken  string
+	PadID     uint32
+	PadTypeID uint32
+}
+
 type EncodeOptions struct {
 	AddSpecialTokens        bool
 	ReturnTypeIDs           bool
@@ -90,6 +122,12 @@ type EncodeOptions struct {
 	ReturnSpecialTokensMask bool
 	ReturnAttentionMask     bool
 	ReturnOffsets           bool
+	ReturnOverflowing       bool
+	ReturnWordIDs           bool
+	ReturnSequenceIDs       bool
+
+	// Go-only per-call overrides; not part of the C struct read by the library.
+	overrides encodeOverrides
 }
 
 type Buffer struct {
@@ -99,7 +137,17 @@ type Buffer struct {
 	AttentionMask     *uint32
 	Tokens            **byte
 	Offsets           *uintptr
+	WordIDs           *int32 // -1 for tokens without a word
+	SequenceIDs       *int32 // -1 for tokens without a sequence
 	Len               uintptr
+	Overflowing       *Buffer // Array of OverflowingLen buffers, one per overflowing window
+	OverflowingLen    uintptr
+}
+
+// StringArray mirrors the Rust StringArray struct: an array of null-terminated strings.
+type StringArray struct {
+	Strings **byte
+	Len     uintptr
 }
 
 type EncodeResult struct {
@@ -109,6 +157,16 @@ type EncodeResult struct {
 	AttentionMask     []uint32
 	Tokens            []string
 	Offsets           []uint32
+	// WordIDs maps each token to the index of the word it belongs to, or -1 for special tokens.
+	WordIDs []int32
+	// SequenceIDs maps each token to the input sequence it belongs to (0 or 1 for pairs), or -1 for special tokens.
+	SequenceIDs []int32
+	// Overflowing holds the windows that did not fit into the truncated encoding.
+	// It is only populated when truncation is enabled and WithReturnOverflowing is used.
+	// Consecutive windows overlap by the truncation stride and keep offsets into the original text.
+	Overflowing []*EncodeResult
+
+	padding batchPadding // Padding of the tokenizer, set by the batch APIs for BatchEncoding
 }
 
 type TruncationOptions struct {
@@ -119,8 +177,14 @@ type TruncationOptions struct {
 	Stride    uintptr
 }
 type PaddingOptions struct {
-	Enabled  bool
-	Strategy PaddingStrategy
+	Version         uint32 // Must be PaddingOptionsVersion
+	Enabled         bool
+	Direction       PaddingDirection
+	Strategy        PaddingStrategy
+	PadToMultipleOf uintptr
+	PadID           uint32
+	PadTypeID       uint32
+	PadToken        *byte // Null-terminated; nil keeps the tokenizer's pad token
 }
 type TokenizerOptions struct {
 	AddSpecialTokens bool
@@ -137,6 +201,9 @@ func WithReturnAllAttributes() EncodeOption {
 		eo.ReturnAttentionMask = true
 		eo.ReturnTokens = true
 		eo.ReturnOffsets = true
+		eo.ReturnOverflowing = true
+		eo.ReturnWordIDs = true
+		eo.ReturnSequenceIDs = true
 		eo.AddSpecialTokens = true
 		return nil
 	}
@@ -184,8 +251,52 @@ func WithReturnOffsets() EncodeOption {
 	}
 }
 
+// WithReturnOverflowing returns the tokens cut off by truncation as EncodeResult.Overflowing windows.
+func WithReturnOverflowing() EncodeOption {
+	return func(eo *EncodeOptions) error {
+		eo.ReturnOverflowing = true
+		return nil
+	}
+}
+
+// WithReturnWordIDs returns the word index of every token in EncodeResult.WordIDs.
+func WithReturnWordIDs() EncodeOption {
+	return func(eo *EncodeOptions) error {
+		eo.ReturnWordIDs = true
+		return nil
+	}
+}
+
+// WithReturnSequenceIDs returns the input sequence of every token in EncodeResult.SequenceIDs.
+func WithReturnSequenceIDs() EncodeOption {
+	return func(eo *EncodeOptions) error {
+		eo.ReturnSequenceIDs = true
+		return nil
+	}
+}
+
 type TokenizerOption func(t *Tokenizer) error
 
+// WithDefaultEncodingOptions sets the encoding options that Encode, EncodeBatch and the
+// other encoding methods start from; the options of each call are applied on top. Adding
+// special tokens, if chosen here, is not changed by WithHFApplyTokenizerConfig.
+func WithDefaultEncodingOptions(opts ...EncodeOption) TokenizerOption {
+	return func(t *Tokenizer) error {
+		for _, opt := range opts {
+			if err := opt(&t.defaultEncodingOpts); err != nil {
+				return errors.Wrap(err, "failed to apply default encoding option")
+			}
+			// An option chooses AddSpecialTokens if it leaves the same value whatever the
+			// value before it.
+			without, with := EncodeOptions{}, EncodeOptions{AddSpecialTokens: true}
+			if opt(&without) == nil && opt(&with) == nil && without.AddSpecialTokens == with.AddSpecialTokens {
+				t.addSpecialTokensSet = true
+			}
+		}
+		return nil
+	}
+}
+
 // WithLibraryPath sets the path to the shared library for the tokenizer. This must be the path to the .so/dylib/dll file that contains the tokenizer implementation.
 func WithLibraryPath(path string) TokenizerOption {
 	return func(t *Tokenizer) error {
@@ -213,6 +324,17 @@ func WithTruncation(maxLen uintptr, direction TruncationDirection, strategy Trun
 	}
 }
 
+// WithTruncationStride sets the number of tokens shared between consecutive overflowing
+// windows when truncation is enabled. Use it together with WithTruncation and
+// WithReturnOverflowing to get all sliding windows of a long text from one Encode call.
+// The stride must be smaller than the truncation max length.
+func WithTruncationStride(stride uintptr) TokenizerOption {
+	return func(t *Tokenizer) error {
+		t.TruncationStride = stride
+		return nil
+	}
+}
+
 func WithPadding(enabled bool, strategy PaddingStrategy) TokenizerOption {
 	return func(t *Tokenizer) error {
 		t.PaddingEnabled = enabled
@@ -221,30 +343,84 @@ func WithPadding(enabled bool, strategy PaddingStrategy) TokenizerOption {
 	}
 }
 
+// WithPaddingOptions enables padding with full control over the pad token, id,
+// type id, direction and pad-to-multiple-of. Models without a [PAD] token
+// (e.g. GPT-2 or Llama) typically need an explicit PadToken and left padding.
+func WithPaddingOptions(params PaddingParams) TokenizerOption {
+	return func(t *Tokenizer) error {
+		if err := validatePaddingParams(params); err != nil {
+			return err
+		}
+		t.PaddingEnabled = true
+		t.PaddingStrategy = params.Strategy
+		t.paddingParams = params
+		return nil
+	}
+}
+
 type Tokenizer struct {
-	lifecycleMu         sync.RWMutex
-	closed              bool
-	LibraryPath         string // Path to the shared library
-	libh                uintptr
-	tokenizerh          unsafe.Pointer // Pointer to the tokenizer instance
-	fromFile            func(config string, result *TokenizerResult) int32
-	fromBytes           func(config []byte, bytesLen uint32, opts *TokenizerOptions, result *TokenizerResult) int32
-	encode              func(ptr unsafe.Pointer, message string, options *EncodeOptions, buffer *Buffer) int32
-	encodeBatchPairs    func(ptr unsafe.Pointer, sequences **byte, pairs **byte, count uintptr, options *EncodeOptions, buffer *Buffer) int32
-	freeTokenizer       func(ptr unsafe.Pointer)
-	freeBuffer          func(buffer *Buffer)
-	freeString          func(ptr unsafe.Pointer)
-	decode              func(ptr unsafe.Pointer, ids *uint32, len uint32, skipSpecialTokens bool, result *unsafe.Pointer) int32
-	vocabSize           func(ptr unsafe.Pointer, size *uint32) int32
-	getVersion          func() string
-	defaultEncodingOpts EncodeOptions
-	TruncationEnabled   bool
-	TruncationDirection TruncationDirection
-	TruncationStrategy  TruncationStrategy
-	TruncationMaxLength uintptr // Maximum length for truncation
-	PaddingEnabled      bool
-	PaddingStrategy     PaddingStrategy // Strategy for padding
-	hfConfig            *HFConfig       // HuggingFace configuration
+	lifecycleMu          sync.RWMutex
+	closed               bool
+	LibraryPath          string // Path to the shared library
+	libh                 uintptr
+	tokenizerh           unsafe.Pointer // Pointer to the tokenizer instance
+	fromFile             func(config string, result *TokenizerResult) int32
+	fromBytes            func(config []byte, bytesLen uint32, opts *TokenizerOptions, result *TokenizerResult) int32
+	encode               func(ptr unsafe.Pointer, message string, options *EncodeOptions, buffer *Buffer) int32
+	encodeBatch          func(ptr unsafe.Pointer, messages **byte, count uintptr, options *EncodeOptions, buffer *Buffer) int32
+	encodeBatchPairs     func(ptr unsafe.Pointer, sequences **byte, pairs **byte, count uintptr, options *EncodeOptions, buffer *Buffer) int32
+	encodeInto           uintptr // Address of encode_into, called with purego.SyscallN
+	freeTokenizer        func(ptr unsafe.Pointer)
+	toJSON               func(ptr unsafe.Pointer, pretty bool, result *unsafe.Pointer) int32
+	trainTokenizer       func(opts *TrainerOptions, files **byte, filesLen uintptr, texts **byte, textsLen uintptr, tOpts *TokenizerOptions, result *TokenizerResult) int32
+	cloneTokenizer       func(ptr unsafe.Pointer, result *TokenizerResult) int32
+	variantsMu           sync.Mutex
+	variants             map[encodeOverrides]*list.Element // Copies with per-call overrides applied, freed on Close
+	variantsLRU          *list.List                        // Elements of variants, most recently used first
+	freeBuffer           func(buffer *Buffer)
+	freeString           func(ptr unsafe.Pointer)
+	decode               func(ptr unsafe.Pointer, ids *uint32, len uint32, skipSpecialTokens bool, result *unsafe.Pointer) int32
+	decodeBatch          func(ptr unsafe.Pointer, ids **uint32, lens *uintptr, count uintptr, skipSpecialTokens bool, result *StringArray) int32
+	freeStringArray      func(arr *StringArray)
+	vocabSize            func(ptr unsafe.Pointer, size *uint32) int32
+	tokenToID            func(ptr unsafe.Pointer, token string, id *uint32, found *bool) int32
+	idToToken            func(ptr unsafe.Pointer, id uint32, result *unsafe.Pointer) int32
+	addTokens            func(ptr unsafe.Pointer, tokens *AddedTokenOptions, count uintptr, special bool, added *uintptr) int32
+	setTruncation        func(ptr unsafe.Pointer, opts *TruncationOptions) int32
+	getTruncation        func(ptr unsafe.Pointer, opts *TruncationOptions) int32
+	setPadding           func(ptr unsafe.Pointer, opts *PaddingOptions) int32
+	getPadding           func(ptr unsafe.Pointer, opts *PaddingOptions) int32
+	getVocab             func(ptr unsafe.Pointer, withAddedTokens bool, buffer *VocabBuffer) int32
+	normalize            func(ptr unsafe.Pointer, message string, buffer *NormalizedBuffer) int32
+	freeNormalized       func(buffer *NormalizedBuffer)
+	preTokenize          func(ptr unsafe.Pointer, message string, buffer *PreTokenBuffer) int32
+	freePreTokens        func(buffer *PreTokenBuffer)
+	freeVocab            func(buffer *VocabBuffer)
+	newDecodeStream      func(ptr unsafe.Pointer, skipSpecialTokens bool, stream *unsafe.Pointer) int32
+	decodeStreamStep     func(stream unsafe.Pointer, id uint32, result *unsafe.Pointer) int32
+	freeDecodeStream     func(stream unsafe.Pointer)
+	streamsMu            sync.Mutex
+	decodeStreams        map[*DecodeStream]struct{} // Open streams, freed on Close
+	takeLastErrorMessage func() unsafe.Pointer
+	getErrorMessage      func(code int32) string
+	getVersion           func() string
+	defaultEncodingOpts  EncodeOptions
+	addSpecialTokensSet  bool // AddSpecialTokens was chosen with WithDefaultEncodingOptions
+	TruncationEnabled    bool
+	TruncationDirection  TruncationDirection
+	TruncationStrategy   TruncationStrategy
+	TruncationMaxLength  uintptr // Maximum length for truncation
+	TruncationStride     uintptr // Overlap between overflowing windows
+	PaddingEnabled       bool
+	PaddingStrategy      PaddingStrategy    // Strategy for padding
+	paddingParams        PaddingParams      // Additional padding parameters set by WithPaddingOptions
+	hfConfig             *HFConfig          // HuggingFace configuration
+	tokenizerConfig      *hfTokenizerConfig // Parsed tokenizer_config.json, if loaded
+	chatTemplate         string             // Chat template set by WithChatTemplate
+	chatTemplateMu       sync.Mutex
+	parsedChatTemplate   *jinjaTemplate // Parse of parsedChatSource, reused by ApplyChatTemplate
+	parsedChatSource     string
+	specialTokensMap     *SpecialTokens // Parsed special_tokens_map.json, if loaded
 
 }
 
@@ -283,7 +459,21 @@ func FromFile(configFile string, opts ...TokenizerOption) (*Tokenizer, error) {
 }
 
 func FromBytes(config []byte, opts ...TokenizerOption) (*Tokenizer, error) {
+	configLen, err := intToUint32Bounded(len(config), "config length")
+	if err != nil {
+		return nil, err
+	}
+	return newTokenizer(opts, func(t *Tokenizer, tOpts *TokenizerOptions, result *TokenizerResult) error {
+		err := t.callNative("from_bytes", func() int32 {
+			return t.fromBytes(config, configLen, tOpts, result)
+		})
+		return errors.Wrapf(err, "failed to create tokenizer from bytes")
+	})
+}
 
+// newTokenizer applies opts, loads the shared library and creates the native tokenizer
+// with create, which receives the load-time options to pass to the library.
+func newTokenizer(opts []TokenizerOption, create func(t *Tokenizer, tOpts *TokenizerOptions, result *TokenizerResult) error) (*Tokenizer, error) {
 	tokenizer := &Tokenizer{
 		defaultEncodingOpts: EncodeOptions{
 			ReturnTokens: true,
@@ -298,6 +488,15 @@ func FromBytes(config []byte, opts ...TokenizerOption) (*Tokenizer, error) {
 			return nil, errors.Wrapf(err, "failed to apply tokenizer option")
 		}
 	}
+	if tokenizer.TruncationStride > 0 {
+		if !tokenizer.TruncationEnabled {
+			return nil, errors.New("truncation stride requires truncation to be enabled")
+		}
+		if tokenizer.TruncationStride >= tokenizer.TruncationMaxLength {
+			return nil, errors.Errorf("truncation stride (%d) must be smaller than max length (%d)", tokenizer.TruncationStride, tokenizer.TruncationMaxLength)
+		}
+	}
+
 	libh, err := LoadTokenizerLibrary(tokenizer.LibraryPath)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to load shared library")
@@ -305,13 +504,40 @@ func FromBytes(config []byte, opts ...TokenizerOption) (*Tokenizer, error) {
 	tokenizer.libh = libh
 	purego.RegisterLibFunc(&tokenizer.fromFile, tokenizer.libh, "from_file")
 	purego.RegisterLibFunc(&tokenizer.fromBytes, tokenizer.libh, "from_bytes")
+	purego.RegisterLibFunc(&tokenizer.trainTokenizer, tokenizer.libh, "train_tokenizer")
 	purego.RegisterLibFunc(&tokenizer.encode, tokenizer.libh, "encode")
+	purego.RegisterLibFunc(&tokenizer.encodeBatch, tokenizer.libh, "encode_batch")
 	purego.RegisterLibFunc(&tokenizer.encodeBatchPairs, tokenizer.libh, "encode_batch_pairs")
+	if tokenizer.encodeInto, err = lookupSymbol(tokenizer.libh, "encode_into"); err != nil {
+		return nil, errors.Wrap(err, "failed to look up encode_into")
+	}
 	purego.RegisterLibFunc(&tokenizer.freeBuffer, tokenizer.libh, "free_buffer")
 	purego.RegisterLibFunc(&tokenizer.freeTokenizer, tokenizer.libh, "free_tokenizer")
+	purego.RegisterLibFunc(&tokenizer.cloneTokenizer, tokenizer.libh, "clone_tokenizer")
+	purego.RegisterLibFunc(&tokenizer.toJSON, tokenizer.libh, "to_json")
 	purego.RegisterLibFunc(&tokenizer.freeString, tokenizer.libh, "free_string")
 	purego.RegisterLibFunc(&tokenizer.decode, tokenizer.libh, "decode")
+	purego.RegisterLibFunc(&tokenizer.decodeBatch, tokenizer.libh, "decode_batch")
+	purego.RegisterLibFunc(&tokenizer.freeStringArray, tokenizer.libh, "free_string_array")
 	purego.RegisterLibFunc(&tokenizer.vocabSize, tokenizer.libh, "vocab_size")
+	purego.RegisterLibFunc(&tokenizer.tokenToID, tokenizer.libh, "token_to_id")
+	purego.RegisterLibFunc(&tokenizer.idToToken, tokenizer.libh, "id_to_token")
+	purego.RegisterLibFunc(&tokenizer.addTokens, tokenizer.libh, "add_tokens")
+	purego.RegisterLibFunc(&tokenizer.getVocab, tokenizer.libh, "get_vocab")
+	purego.RegisterLibFunc(&tokenizer.setTruncation, tokenizer.libh, "set_truncation")
+	purego.RegisterLibFunc(&tokenizer.getTruncation, tokenizer.libh, "get_truncation")
+	purego.RegisterLibFunc(&tokenizer.setPadding, tokenizer.libh, "set_padding")
+	purego.RegisterLibFunc(&tokenizer.getPadding, tokenizer.libh, "get_padding")
+	purego.RegisterLibFunc(&tokenizer.freeVocab, tokenizer.libh, "free_vocab")
+	purego.RegisterLibFunc(&tokenizer.normalize, tokenizer.libh, "normalize")
+	purego.RegisterLibFunc(&tokenizer.freeNormalized, tokenizer.libh, "free_normalized")
+	purego.RegisterLibFunc(&tokenizer.preTokenize, tokenizer.libh, "pre_tokenize")
+	purego.RegisterLibFunc(&tokenizer.freePreTokens, tokenizer.libh, "free_pre_tokens")
+	purego.RegisterLibFunc(&tokenizer.newDecodeStream, tokenizer.libh, "new_decode_stream")
+	purego.RegisterLibFunc(&tokenizer.decodeStreamStep, tokenizer.libh, "decode_stream_step")
+	purego.RegisterLibFunc(&tokenizer.freeDecodeStream, tokenizer.libh, "free_decode_stream")
+	purego.RegisterLibFunc(&tokenizer.takeLastErrorMessage, tokenizer.libh, "take_last_error_message")
+	purego.RegisterLibFunc(&tokenizer.getErrorMessage, tokenizer.libh, "get_error_message")
 	purego.RegisterLibFunc(&tokenizer.getVersion, tokenizer.libh, "get_version")
 
 	// Initialize library version for HuggingFace User-Agent
@@ -328,25 +554,22 @@ func FromBytes(config []byte, opts ...TokenizerOption) (*Tokenizer, error) {
 				MaxLen:    tokenizer.TruncationMaxLength,
 				Direction: tokenizer.TruncationDirection,
 				Strategy:  tokenizer.TruncationStrategy,
+				Stride:    tokenizer.TruncationStride,
 			},
 		}
 	}
+	var padToken []byte
 	if tokenizer.PaddingEnabled {
-		tOpts.Pad = PaddingOptions{
-			Enabled:  tokenizer.PaddingEnabled,
-			Strategy: tokenizer.PaddingStrategy,
-		}
+		params := tokenizer.paddingParams
+		params.Strategy = tokenizer.PaddingStrategy
+		tOpts.Pad, padToken = paddingOptionsFromParams(params)
 	}
-	configLen, err := intToUint32Bounded(len(config), "config length")
+	var result TokenizerResult
+	err = create(tokenizer, tOpts, &result)
+	runtime.KeepAlive(padToken)
 	if err != nil {
 		return nil, err
 	}
-	var result TokenizerResult
-	errCode := tokenizer.fromBytes(config, configLen, tOpts, &result)
-	if errCode != SUCCESS {
-		lastError := getErrorForCode(errCode)
-		return nil, errors.Wrapf(lastError, "failed to create tokenizer from bytes")
-	}
 	tokenizer.tokenizerh = result.Tokenizer
 
 	if err = tokenizer.abiCheck(constraint); err != nil {
@@ -358,6 +581,26 @@ func FromBytes(config []byte, opts ...TokenizerOption) (*Tokenizer, error) {
 	return tokenizer, nil
 }
 
+// paddingOptionsFromParams converts PaddingParams into the FFI PaddingOptions struct.
+// The returned byte slice backs PadToken and must be kept alive until the FFI call returns.
+func paddingOptionsFromParams(params PaddingParams) (PaddingOptions, []byte) {
+	opts := PaddingOptions{
+		Version:         PaddingOptionsVersion,
+		Enabled:         true,
+		Direction:       params.Direction,
+		Strategy:        params.Strategy,
+		PadToMultipleOf: params.PadToMultipleOf,
+		PadID:           params.PadID,
+		PadTypeID:       params.PadTypeID,
+	}
+	var padToken []byte
+	if params.PadToken != "" {
+		padToken = append([]byte(params.PadToken), 0)
+		opts.PadToken = &padToken[0]
+	}
+	return opts, padToken
+}
+
 // abiCheck check the ABI version of the Rust lib to check for compatibility
 func (t *Tokenizer) abiCheck(constraint *semver.Constraints) error {
 	if constraint == nil {
@@ -411,17 +654,46 @@ func (t *Tokenizer) Close() (err error) {
 	freeTokenizer := t.freeTokenizer
 	libh := t.libh
 
+	// Decode streams borrow the tokenizer, so they must be freed first.
+	t.closeDecodeStreams()
+	t.freeVariants()
+
 	t.tokenizerh = nil
 	t.libh = 0
 	t.fromFile = nil
 	t.fromBytes = nil
+	t.trainTokenizer = nil
 	t.encode = nil
+	t.encodeBatch = nil
 	t.encodeBatchPairs = nil
+	t.encodeInto = 0
 	t.freeTokenizer = nil
+	t.cloneTokenizer = nil
+	t.toJSON = nil
 	t.freeBuffer = nil
 	t.freeString = nil
 	t.decode = nil
+	t.decodeBatch = nil
+	t.freeStringArray = nil
 	t.vocabSize = nil
+	t.tokenToID = nil
+	t.idToToken = nil
+	t.addTokens = nil
+	t.getVocab = nil
+	t.setTruncation = nil
+	t.getTruncation = nil
+	t.setPadding = nil
+	t.getPadding = nil
+	t.freeVocab = nil
+	t.normalize = nil
+	t.freeNormalized = nil
+	t.preTokenize = nil
+	t.freePreTokens = nil
+	t.newDecodeStream = nil
+	t.decodeStreamStep = nil
+	t.freeDecodeStream = nil
+	t.takeLastErrorMessage = nil
+	t.getErrorMessage = nil
 	t.getVersion = nil
 
 	t.lifecycleMu.Unlock()
@@ -456,15 +728,27 @@ func (t *Tokenizer) Encode(message string, opts ...EncodeOption) (*EncodeResult,
 			return nil, errors.Wrap(err, "failed to apply encoding option")
 		}
 	}
+	h, variant, err := t.handleFor(&options)
+	if err != nil {
+		return nil, err
+	}
+	defer t.releaseVariant(variant)
 	var buff Buffer
-	rc := t.encode(t.tokenizerh, message, &options, &buff)
-	if rc < 0 {
-		lastError := getErrorForCode(rc)
-		return nil, errors.Wrap(lastError, "failed to encode message")
+	err = t.callNative("encode", func() int32 {
+		return t.encode(h, message, &options, &buff)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode message")
 	}
 	defer func() {
 		t.freeBuffer(&buff)
 	}()
+	return encodeResultFromBuffer(&buff)
+}
+
+// encodeResultFromBuffer copies the contents of a Rust-owned Buffer into a new EncodeResult.
+// The buffer must still be freed by the caller.
+func encodeResultFromBuffer(buff *Buffer) (*EncodeResult, error) {
 	result := &EncodeResult{}
 	if buff.IDs != nil {
 		result.IDs = append([]uint32(nil), unsafe.Slice(buff.IDs, buff.Len)...) // #nosec G103 -- FFI buffer originates from trusted Rust library memory.
@@ -472,7 +756,7 @@ func (t *Tokenizer) Encode(message string, opts ...EncodeOption) (*EncodeResult,
 	if buff.TypeIDs != nil {
 		result.TypeIDs = append([]uint32(nil), unsafe.Slice(buff.TypeIDs, buff.Len)...) // #nosec G103 -- FFI buffer originates from trusted Rust library memory.
 	}
-	specialTokensMask, attentionMask := MasksFromBuf(buff)
+	specialTokensMask, attentionMask := MasksFromBuf(*buff)
 	if specialTokensMask != nil {
 		result.SpecialTokensMask = make([]uint32, 0, len(specialTokensMask))
 		result.SpecialTokensMask = append(result.SpecialTokensMask, specialTokensMask...)
@@ -481,7 +765,7 @@ func (t *Tokenizer) Encode(message string, opts ...EncodeOption) (*EncodeResult,
 		result.AttentionMask = make([]uint32, 0, len(attentionMask))
 		result.AttentionMask = append(result.AttentionMask, attentionMask...)
 	}
-	result.Tokens = TokensFromBuf(buff)
+	result.Tokens = TokensFromBuf(*buff)
 	if buff.Offsets != nil {
 		offsets := unsafe.Slice((*[2]uint)(unsafe.Pointer(buff.Offsets)), buff.Len) // #nosec G103 -- Offset buffer pointer is owned by the Rust FFI layer.
 		result.Offsets = make([]uint32, 0, len(offsets)*2)
@@ -497,13 +781,152 @@ func (t *Tokenizer) Encode(message string, opts ...EncodeOption) (*EncodeResult,
 			result.Offsets = append(result.Offsets, start, end)
 		}
 	}
+	if buff.WordIDs != nil {
+		result.WordIDs = append([]int32(nil), unsafe.Slice(buff.WordIDs, buff.Len)...) // #nosec G103 -- FFI buffer originates from trusted Rust library memory.
+	}
+	if buff.SequenceIDs != nil {
+		result.SequenceIDs = append([]int32(nil), unsafe.Slice(buff.SequenceIDs, buff.Len)...) // #nosec G103 -- FFI buffer originates from trusted Rust library memory.
+	}
+	if buff.Overflowing != nil && buff.OverflowingLen > 0 {
+		overflowing := unsafe.Slice(buff.Overflowing, buff.OverflowingLen) // #nosec G103 -- Overflowing buffer array is owned by the Rust FFI layer.
+		result.Overflowing = make([]*EncodeResult, 0, len(overflowing))
+		for i := range overflowing {
+			window, err := encodeResultFromBuffer(&overflowing[i])
+			if err != nil {
+				return nil, err
+			}
+			result.Overflowing = append(result.Overflowing, window)
+		}
+	}
 
 	return result, nil
 }
 
+// toCStrings converts Go strings to null-terminated byte slices and returns
+// pointers to their first bytes. The returned backing slices must be kept
+// alive until the FFI call using the pointers returns.
+func toCStrings(strs []string) ([]*byte, [][]byte) {
+	ptrs := make([]*byte, len(strs))
+	backing := make([][]byte, len(strs))
+	for i := range strs {
+		// Append null terminator and keep reference to prevent GC
+		backing[i] = append([]byte(strs[i]), 0)
+		ptrs[i] = &backing[i][0]
+	}
+	return ptrs, backing
+}
+
+// EncodeBatch encodes multiple independent sequences in a single FFI call.
+// The sequences are encoded in parallel by the Rust library, and tokenizer-level
+// padding such as PaddingStrategyBatchLongest is applied across the whole batch.
+// The attention mask and type IDs are always returned, for the matrices of BatchEncoding.
+func (t *Tokenizer) EncodeBatch(messages []string, opts ...EncodeOption) (BatchEncoding, error) {
+	unlock, err := t.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if t.encodeBatch == nil || t.tokenizerh == nil {
+		return nil, errors.New("encode_batch function is not initialized or tokenizer is not loaded")
+	}
+
+	if len(messages) == 0 {
+		return BatchEncoding{}, nil
+	}
+
+	options := t.defaultEncodingOpts
+	for _, opt := range opts {
+		if err := opt(&options); err != nil {
+			return nil, errors.Wrap(err, "failed to apply encoding option")
+		}
+	}
+	options.ReturnAttentionMask = true
+	options.ReturnTypeIDs = true
+
+	h, variant, err := t.handleFor(&options)
+	if err != nil {
+		return nil, err
+	}
+	defer t.releaseVariant(variant)
+
+	// Go strings are not null-terminated, but Rust's CStr::from_ptr() expects them to be
+	cMessages, cMessageBytes := toCStrings(messages)
+
+	buffers := make([]Buffer, len(messages))
+
+	err = t.callNative("encode_batch", func() int32 {
+		return t.encodeBatch(
+			h,
+			(**byte)(unsafe.Pointer(&cMessages[0])), // #nosec G103 -- Passing stable Go-managed C-string pointers to FFI.
+			uintptr(len(messages)),
+			&options,
+			&buffers[0],
+		)
+	})
+	runtime.KeepAlive(cMessageBytes)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode batch")
+	}
+	defer func() {
+		for i := range buffers {
+			t.freeBuffer(&buffers[i])
+		}
+	}()
+
+	return t.encodeResultsFromBuffers(h, buffers)
+}
+
+// encodeResultsFromBuffers converts a batch of Rust-owned buffers, encoded with the
+// native tokenizer h, into EncodeResults.
+func (t *Tokenizer) encodeResultsFromBuffers(h unsafe.Pointer, buffers []Buffer) (BatchEncoding, error) {
+	padding, err := t.batchPaddingOf(h)
+	if err != nil {
+		return nil, err
+	}
+	results := make(BatchEncoding, len(buffers))
+	for i := range buffers {
+		result, err := encodeResultFromBuffer(&buffers[i])
+		if err != nil {
+			return nil, err
+		}
+		result.padding = padding
+		results[i] = result
+	}
+	return results, nil
+}
+
+// batchPaddingOf returns the pad ID, pad type ID and direction of the native tokenizer
+// h, or ID 0 and type ID 0 on the right if it does not pad.
+func (t *Tokenizer) batchPaddingOf(h unsafe.Pointer) (batchPadding, error) {
+	if t.getPadding == nil {
+		return batchPadding{}, nil
+	}
+	var opts PaddingOptions
+	err := t.callNative("get_padding", func() int32 {
+		return t.getPadding(h, &opts)
+	})
+	if err != nil {
+		return batchPadding{}, errors.Wrap(err, "failed to get padding")
+	}
+	if opts.PadToken != nil {
+		t.freeString(unsafe.Pointer(opts.PadToken)) // #nosec G103 -- Pad token string is allocated by the Rust FFI layer.
+	}
+	if !opts.Enabled {
+		return batchPadding{}, nil
+	}
+	return batchPadding{
+		id:     opts.PadID,
+		typeID: opts.PadTypeID,
+		left:   opts.Direction == PaddingDirectionLeft,
+	}, nil
+}
+
 // EncodePairs encodes multiple sequence pairs in parallel.
 // This is useful for reranking tasks where you need to encode query-document pairs.
-func (t *Tokenizer) EncodePairs(sequences []string, pairs []string, opts ...EncodeOption) ([]*EncodeResult, error) {
+// The attention mask and type IDs are always returned, for the matrices of BatchEncoding.
+func (t *Tokenizer) EncodePairs(sequences []string, pairs []string, opts ...EncodeOption) (BatchEncoding, error) {
 	unlock, err := t.beginOperation()
 	if err != nil {
 		return nil, err
@@ -519,7 +942,7 @@ func (t *Tokenizer) EncodePairs(sequences []string, pairs []string, opts ...Enco
 	}
 
 	if len(sequences) == 0 {
-		return []*EncodeResult{}, nil
+		return BatchEncoding{}, nil
 	}
 
 	options := t.defaultEncodingOpts
@@ -528,37 +951,38 @@ func (t *Tokenizer) EncodePairs(sequences []string, pairs []string, opts ...Enco
 			return nil, errors.Wrap(err, "failed to apply encoding option")
 		}
 	}
+	options.ReturnAttentionMask = true
+	options.ReturnTypeIDs = true
+
+	h, variant, err := t.handleFor(&options)
+	if err != nil {
+		return nil, err
+	}
+	defer t.releaseVariant(variant)
 
 	// Convert Go strings to null-terminated C strings
 	// Go strings are not null-terminated, but Rust's CStr::from_ptr() expects them to be
-	cSequences := make([]*byte, len(sequences))
-	cPairs := make([]*byte, len(pairs))
-	cSeqBytes := make([][]byte, len(sequences))
-	cPairBytes := make([][]byte, len(pairs))
-
-	for i := 0; i < len(sequences); i++ {
-		// Append null terminator and keep reference to prevent GC
-		cSeqBytes[i] = append([]byte(sequences[i]), 0)
-		cPairBytes[i] = append([]byte(pairs[i]), 0)
-		cSequences[i] = &cSeqBytes[i][0]
-		cPairs[i] = &cPairBytes[i][0]
-	}
+	cSequences, cSeqBytes := toCStrings(sequences)
+	cPairs, cPairBytes := toCStrings(pairs)
 
 	// Allocate output buffers
 	buffers := make([]Buffer, len(sequences))
 
-	rc := t.encodeBatchPairs(
-		t.tokenizerh,
-		(**byte)(unsafe.Pointer(&cSequences[0])), // #nosec G103 -- Passing stable Go-managed C-string pointers to FFI.
-		(**byte)(unsafe.Pointer(&cPairs[0])),     // #nosec G103 -- Passing stable Go-managed C-string pointers to FFI.
-		uintptr(len(sequences)),
-		&options,
-		&buffers[0],
-	)
+	err = t.callNative("encode_batch_pairs", func() int32 {
+		return t.encodeBatchPairs(
+			h,
+			(**byte)(unsafe.Pointer(&cSequences[0])), // #nosec G103 -- Passing stable Go-managed C-string pointers to FFI.
+			(**byte)(unsafe.Pointer(&cPairs[0])),     // #nosec G103 -- Passing stable Go-managed C-string pointers to FFI.
+			uintptr(len(sequences)),
+			&options,
+			&buffers[0],
+		)
+	})
+	runtime.KeepAlive(cSeqBytes)
+	runtime.KeepAlive(cPairBytes)
 
-	if rc < 0 {
-		lastError := getErrorForCode(rc)
-		return nil, errors.Wrap(lastError, "failed to encode pairs")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode pairs")
 	}
 	defer func() {
 		for i := range buffers {
@@ -566,51 +990,7 @@ func (t *Tokenizer) EncodePairs(sequences []string, pairs []string, opts ...Enco
 		}
 	}()
 
-	// Convert buffers to results
-	results := make([]*EncodeResult, len(buffers))
-	for i := range buffers {
-		buff := &buffers[i]
-		result := &EncodeResult{}
-
-		if buff.IDs != nil {
-			result.IDs = append([]uint32(nil), unsafe.Slice(buff.IDs, buff.Len)...) // #nosec G103 -- FFI buffer originates from trusted Rust library memory.
-		}
-		if buff.TypeIDs != nil {
-			result.TypeIDs = append([]uint32(nil), unsafe.Slice(buff.TypeIDs, buff.Len)...) // #nosec G103 -- FFI buffer originates from trusted Rust library memory.
-		}
-
-		specialTokensMask, attentionMask := MasksFromBuf(*buff)
-		if specialTokensMask != nil {
-			result.SpecialTokensMask = make([]uint32, 0, len(specialTokensMask))
-			result.SpecialTokensMask = append(result.SpecialTokensMask, specialTokensMask...)
-		}
-		if attentionMask != nil {
-			result.AttentionMask = make([]uint32, 0, len(attentionMask))
-			result.AttentionMask = append(result.AttentionMask, attentionMask...)
-		}
-
-		result.Tokens = TokensFromBuf(*buff)
-
-		if buff.Offsets != nil {
-			offsets := unsafe.Slice((*[2]uint)(unsafe.Pointer(buff.Offsets)), buff.Len) // #nosec G103 -- Offset buffer pointer is owned by the Rust FFI layer.
-			result.Offsets = make([]uint32, 0, len(offsets)*2)
-			for _, offset := range offsets {
-				start, err := uintToUint32Bounded(offset[0], "offset start")
-				if err != nil {
-					return nil, err
-				}
-				end, err := uintToUint32Bounded(offset[1], "offset end")
-				if err != nil {
-					return nil, err
-				}
-				result.Offsets = append(result.Offsets, start, end)
-			}
-		}
-
-		results[i] = result
-	}
-
-	return results, nil
+	return t.encodeResultsFromBuffers(h, buffers)
 }
 
 // EncodePair encodes a single sequence pair.
@@ -645,10 +1025,11 @@ func (t *Tokenizer) Decode(ids []uint32, skipSpecialTokens bool) (string, error)
 		return "", err
 	}
 	var cStrPtr unsafe.Pointer
-	errCode := t.decode(t.tokenizerh, idsPtr, idLen, skipSpecialTokens, &cStrPtr)
-	if errCode != SUCCESS {
-		lastError := getErrorForCode(errCode)
-		return "", errors.Wrapf(lastError, "failed to decode ids")
+	err = t.callNative("decode", func() int32 {
+		return t.decode(t.tokenizerh, idsPtr, idLen, skipSpecialTokens, &cStrPtr)
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to decode ids")
 	}
 
 	if cStrPtr == nil {
@@ -665,6 +1046,56 @@ func (t *Tokenizer) Decode(ids []uint32, skipSpecialTokens bool) (string, error)
 	return result, nil
 }
 
+// DecodeBatch decodes multiple sequences of token IDs in a single FFI call.
+// The sequences are decoded in parallel by the Rust library. Empty sequences
+// decode to an empty string.
+func (t *Tokenizer) DecodeBatch(sequences [][]uint32, skipSpecialTokens bool) ([]string, error) {
+	unlock, err := t.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if t.decodeBatch == nil || t.tokenizerh == nil {
+		return nil, errors.New("decode_batch function is not initialized or tokenizer is not loaded")
+	}
+
+	if len(sequences) == 0 {
+		return []string{}, nil
+	}
+
+	idPtrs := make([]*uint32, len(sequences))
+	lens := make([]uintptr, len(sequences))
+	for i, ids := range sequences {
+		if len(ids) > 0 {
+			idPtrs[i] = &ids[0]
+		}
+		lens[i] = uintptr(len(ids))
+	}
+
+	var arr StringArray
+	err = t.callNative("decode_batch", func() int32 {
+		return t.decodeBatch(t.tokenizerh, &idPtrs[0], &lens[0], uintptr(len(sequences)), skipSpecialTokens, &arr)
+	})
+	runtime.KeepAlive(sequences)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode batch")
+	}
+	defer t.freeStringArray(&arr)
+
+	if arr.Len != uintptr(len(sequences)) || arr.Strings == nil {
+		return nil, errors.Errorf("decode_batch returned %d strings for %d sequences", arr.Len, len(sequences))
+	}
+
+	results := make([]string, len(sequences))
+	for i, p := range unsafe.Slice(arr.Strings, arr.Len) { // #nosec G103 -- String pointer array is returned from trusted Rust FFI.
+		if p != nil {
+			results[i] = goStringFromPtr(unsafe.Pointer(p)) // #nosec G103 -- Pointer points to FFI-managed null-terminated bytes.
+		}
+	}
+	return results, nil
+}
+
 // goStringFromPtr converts a C string to a Go string
 func goStringFromPtr(ptr unsafe.Pointer) string {
 	if ptr == nil {
@@ -692,52 +1123,15 @@ func (t *Tokenizer) VocabSize() (uint32, error) {
 		return 0, errors.New("vocabSize function is not initialized or tokenizer is not loaded")
 	}
 	var size uint32
-	errCode := t.vocabSize(t.tokenizerh, &size)
-	if errCode != SUCCESS {
-		lastError := getErrorForCode(errCode)
-		return 0, errors.Wrapf(lastError, "failed to get vocab size")
+	err = t.callNative("vocab_size", func() int32 {
+		return t.vocabSize(t.tokenizerh, &size)
+	})
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to get vocab size")
 	}
 	return size, nil
 }
 
-func getErrorForCode(errCode int32) error {
-	if errCode == SUCCESS {
-		return nil // No error
-	}
-	switch errCode {
-	case ErrInvalidUTF8:
-		return errors.New("invalid UTF-8 in input message")
-	case ErrEncodingFailed:
-		return errors.New("tokenization failed")
-	case ErrNullOutput:
-		return errors.New("internal error: null output buffer")
-	case ErrInvalidTokenizerRef:
-		return errors.New("invalid tokenizer reference")
-	case ErrNullInput:
-		return errors.New("null input provided")
-	case ErrTokenizerCreationFailed:
-		return errors.New("failed to create tokenizer instance")
-	case ErrInvalidPath:
-		return errors.New("invalid file path provided")
-	case ErrFileNotFound:
-		return errors.New("file not found at specified path")
-	case ErrTruncationFailed:
-		return errors.New("truncation failed")
-	case ErrPaddingFailed:
-		return errors.New("padding failed")
-	case ErrDecodeFailed:
-		return errors.New("decoding failed")
-	case ErrCStringConversionFailed:
-		return errors.New("C string conversion failed")
-	case ErrInvalidIDs:
-		return errors.New("invalid IDs provided for decoding")
-	case ErrInvalidOptions:
-		return errors.New("invalid options provided for encoding/decoding")
-	default:
-		return errors.Errorf("unknown error code: %d", errCode)
-	}
-}
-
 // GetLibraryVersion returns the version of the tokenizer library.
 // It returns "unknown" when the version callback is unavailable or the tokenizer is closed.
 func (t *Tokenizer) GetLibraryVersion() string {
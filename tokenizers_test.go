@@ -10,6 +10,7 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"unsafe"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/ebitengine/purego"
@@ -791,6 +792,252 @@ func TestEncodePairs(t *testing.T) {
 	})
 }
 
+func TestEncodeBatch(t *testing.T) {
+	libpath := checkLibraryExists(t)
+	tok, err := FromFile("./tokenizer.json", WithLibraryPath(libpath))
+	require.NoError(t, err, "Failed to load tokenizer from file")
+	t.Cleanup(func() {
+		_ = tok.Close()
+	})
+
+	t.Run("Matches single encode", func(t *testing.T) {
+		messages := []string{"Hello, world!", "How are you?", "Tokenization is fun."}
+
+		results, err := tok.EncodeBatch(messages, WithReturnAllAttributes())
+		require.NoError(t, err, "Failed to encode batch")
+		require.Len(t, results, len(messages))
+
+		for i, message := range messages {
+			single, err := tok.Encode(message, WithReturnAllAttributes())
+			require.NoError(t, err)
+			require.Equal(t, single.IDs, results[i].IDs, "Result %d IDs should match Encode", i)
+			require.Equal(t, single.Tokens, results[i].Tokens, "Result %d tokens should match Encode", i)
+			require.Equal(t, single.Offsets, results[i].Offsets, "Result %d offsets should match Encode", i)
+		}
+	})
+
+	t.Run("Empty batch", func(t *testing.T) {
+		results, err := tok.EncodeBatch([]string{}, WithReturnTokens())
+		require.NoError(t, err, "Failed to encode empty batch")
+		require.Len(t, results, 0)
+	})
+
+	t.Run("Batch longest padding", func(t *testing.T) {
+		padTok, err := FromFile("./tokenizer.json",
+			WithLibraryPath(libpath),
+			WithPadding(true, PaddingStrategy{Tag: PaddingStrategyBatchLongest}),
+		)
+		require.NoError(t, err, "Failed to load tokenizer with padding")
+		t.Cleanup(func() {
+			_ = padTok.Close()
+		})
+
+		results, err := padTok.EncodeBatch([]string{"Hi", strings.Repeat("word ", 20)}, WithReturnAttentionMask())
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		require.Equal(t, len(results[0].IDs), len(results[1].IDs), "Batch entries should be padded to the same length")
+		require.Contains(t, results[0].AttentionMask, uint32(0), "Shorter entry should contain padding")
+	})
+}
+
+func TestEncodeResultFromBufferOverflowing(t *testing.T) {
+	windowIDs := [][]uint32{{3, 4, 5}, {5, 6, 7}}
+	windowOffsets := [][]uintptr{{6, 7, 8, 9, 10, 11}, {10, 11, 12, 13, 14, 15}}
+	windows := make([]Buffer, len(windowIDs))
+	for i := range windowIDs {
+		windows[i] = Buffer{
+			IDs:     &windowIDs[i][0],
+			Offsets: &windowOffsets[i][0],
+			Len:     uintptr(len(windowIDs[i])),
+		}
+	}
+	mainIDs := []uint32{1, 2, 3}
+	buf := Buffer{
+		IDs:            &mainIDs[0],
+		Len:            uintptr(len(mainIDs)),
+		Overflowing:    &windows[0],
+		OverflowingLen: uintptr(len(windows)),
+	}
+
+	result, err := encodeResultFromBuffer(&buf)
+	require.NoError(t, err)
+	require.Equal(t, mainIDs, result.IDs)
+	require.Len(t, result.Overflowing, 2)
+	for i, window := range result.Overflowing {
+		require.Equal(t, windowIDs[i], window.IDs)
+		require.Len(t, window.Offsets, len(windowIDs[i])*2)
+		require.Nil(t, window.Overflowing)
+	}
+	require.Equal(t, []uint32{10, 11, 12, 13, 14, 15}, result.Overflowing[1].Offsets)
+}
+
+func TestWithTruncationStrideValidation(t *testing.T) {
+	t.Run("Stride without truncation", func(t *testing.T) {
+		_, err := FromBytes([]byte("{}"), WithTruncationStride(4))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "requires truncation")
+	})
+
+	t.Run("Stride not smaller than max length", func(t *testing.T) {
+		_, err := FromBytes([]byte("{}"),
+			WithTruncation(8, TruncationDirectionDefault, TruncationStrategyDefault),
+			WithTruncationStride(8),
+		)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "must be smaller than max length")
+	})
+}
+
+func TestTruncationStrideOverflowing(t *testing.T) {
+	libpath := checkLibraryExists(t)
+	const maxLen, stride = 16, 4
+	tok, err := FromFile("./tokenizer.json",
+		WithLibraryPath(libpath),
+		WithTruncation(maxLen, TruncationDirectionRight, TruncationStrategyLongestFirst),
+		WithTruncationStride(stride),
+		WithPadding(true, PaddingStrategy{Tag: PaddingStrategyFixed, FixedSize: maxLen}),
+	)
+	require.NoError(t, err, "Failed to load tokenizer from file")
+	t.Cleanup(func() {
+		_ = tok.Close()
+	})
+
+	text := strings.Repeat("the quick brown fox jumps over the lazy dog ", 6)
+
+	t.Run("Without WithReturnOverflowing", func(t *testing.T) {
+		res, err := tok.Encode(text)
+		require.NoError(t, err)
+		require.Len(t, res.IDs, maxLen)
+		require.Nil(t, res.Overflowing)
+	})
+
+	t.Run("Sliding windows", func(t *testing.T) {
+		res, err := tok.Encode(text, WithReturnTokens(), WithReturnOffsets(), WithReturnOverflowing())
+		require.NoError(t, err)
+		require.Len(t, res.IDs, maxLen)
+		require.NotEmpty(t, res.Overflowing, "Long text should produce overflowing windows")
+
+		// Each window starts with the last `stride` tokens of the previous one
+		require.Equal(t, res.Tokens[maxLen-stride:], res.Overflowing[0].Tokens[:stride])
+		require.Equal(t, res.Offsets[(maxLen-stride)*2:], res.Overflowing[0].Offsets[:stride*2],
+			"Offsets of overflowing windows should point into the original text")
+		for i, window := range res.Overflowing {
+			require.NotEmpty(t, window.IDs, "Window %d should have IDs", i)
+			require.Len(t, window.Offsets, len(window.IDs)*2, "Window %d should have offsets", i)
+		}
+	})
+}
+
+func TestPaddingOptionsFromParams(t *testing.T) {
+	t.Run("Defaults keep tokenizer pad token", func(t *testing.T) {
+		opts, padToken := paddingOptionsFromParams(PaddingParams{})
+		require.Equal(t, PaddingOptionsVersion, opts.Version)
+		require.True(t, opts.Enabled)
+		require.Equal(t, PaddingDirectionRight, opts.Direction)
+		require.Nil(t, opts.PadToken)
+		require.Nil(t, padToken)
+	})
+
+	t.Run("Custom pad token", func(t *testing.T) {
+		opts, padToken := paddingOptionsFromParams(PaddingParams{
+			Strategy:        PaddingStrategy{Tag: PaddingStrategyFixed, FixedSize: 32},
+			Direction:       PaddingDirectionLeft,
+			PadToMultipleOf: 8,
+			PadToken:        "<|endoftext|>",
+			PadID:           50256,
+			PadTypeID:       1,
+		})
+		require.Equal(t, PaddingDirectionLeft, opts.Direction)
+		require.Equal(t, PaddingStrategyFixed, opts.Strategy.Tag)
+		require.Equal(t, uintptr(32), opts.Strategy.FixedSize)
+		require.Equal(t, uintptr(8), opts.PadToMultipleOf)
+		require.Equal(t, uint32(50256), opts.PadID)
+		require.Equal(t, uint32(1), opts.PadTypeID)
+		require.NotNil(t, opts.PadToken)
+		require.Equal(t, "<|endoftext|>", goStringFromPtr(unsafe.Pointer(opts.PadToken)))
+		require.Equal(t, byte(0), padToken[len(padToken)-1])
+	})
+}
+
+func TestPaddingOptionsLayout(t *testing.T) {
+	if unsafe.Sizeof(uintptr(0)) != 8 {
+		t.Skip("Layout offsets are asserted for 64-bit platforms")
+	}
+	// Must match the #[repr(C)] PaddingOptions struct in src/lib.rs
+	var opts PaddingOptions
+	require.Equal(t, uintptr(0), unsafe.Offsetof(opts.Version))
+	require.Equal(t, uintptr(4), unsafe.Offsetof(opts.Enabled))
+	require.Equal(t, uintptr(5), unsafe.Offsetof(opts.Direction))
+	require.Equal(t, uintptr(8), unsafe.Offsetof(opts.Strategy))
+	require.Equal(t, uintptr(24), unsafe.Offsetof(opts.PadToMultipleOf))
+	require.Equal(t, uintptr(32), unsafe.Offsetof(opts.PadID))
+	require.Equal(t, uintptr(36), unsafe.Offsetof(opts.PadTypeID))
+	require.Equal(t, uintptr(40), unsafe.Offsetof(opts.PadToken))
+}
+
+func TestWithPaddingOptionsValidation(t *testing.T) {
+	tok := &Tokenizer{}
+	err := WithPaddingOptions(PaddingParams{Direction: PaddingDirection(7)})(tok)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid padding direction")
+
+	err = WithPaddingOptions(PaddingParams{Strategy: PaddingStrategy{Tag: PaddingStrategyFixed}})(tok)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "fixed padding size")
+
+	params := PaddingParams{PadToken: "<pad>", PadID: 3}
+	require.NoError(t, WithPaddingOptions(params)(tok))
+	require.True(t, tok.PaddingEnabled)
+	require.Equal(t, params, tok.paddingParams)
+}
+
+func TestFromFileWithPaddingOptions(t *testing.T) {
+	libpath := checkLibraryExists(t)
+
+	t.Run("Left padding with custom pad token", func(t *testing.T) {
+		tok, err := FromFile("./tokenizer.json",
+			WithLibraryPath(libpath),
+			WithPaddingOptions(PaddingParams{
+				Strategy:  PaddingStrategy{Tag: PaddingStrategyFixed, FixedSize: 16},
+				Direction: PaddingDirectionLeft,
+				PadToken:  "[UNK]",
+				PadID:     100,
+			}),
+		)
+		require.NoError(t, err, "Failed to load tokenizer from file")
+		t.Cleanup(func() {
+			_ = tok.Close()
+		})
+
+		res, err := tok.Encode("Hello, world!", WithReturnAttentionMask())
+		require.NoError(t, err)
+		require.Len(t, res.IDs, 16)
+		require.Equal(t, uint32(100), res.IDs[0], "Padding should be applied on the left with the custom pad id")
+		require.Equal(t, "[UNK]", res.Tokens[0])
+		require.Equal(t, uint32(0), res.AttentionMask[0])
+		require.Equal(t, uint32(1), res.AttentionMask[len(res.AttentionMask)-1])
+	})
+
+	t.Run("Pad to multiple of", func(t *testing.T) {
+		tok, err := FromFile("./tokenizer.json",
+			WithLibraryPath(libpath),
+			WithPaddingOptions(PaddingParams{
+				Strategy:        PaddingStrategy{Tag: PaddingStrategyBatchLongest},
+				PadToMultipleOf: 8,
+			}),
+		)
+		require.NoError(t, err, "Failed to load tokenizer from file")
+		t.Cleanup(func() {
+			_ = tok.Close()
+		})
+
+		res, err := tok.Encode("Hello, world!")
+		require.NoError(t, err)
+		require.Zero(t, len(res.IDs)%8, "Length should be a multiple of 8")
+		require.Equal(t, "[PAD]", res.Tokens[len(res.Tokens)-1])
+	})
+}
+
 func TestAbi(t *testing.T) {
 	t.Run("Compatible ABI", func(t *testing.T) {
 		constraint, err := semver.NewConstraint("v0.1.x")
@@ -0,0 +1,156 @@
+package tokenizers
+
+import (
+	"iter"
+	"os"
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+// Models trained by the library, in the order of the Rust TrainerOptions model field.
+const (
+	trainerModelBPE uint8 = iota
+	trainerModelWordPiece
+	trainerModelUnigram
+	trainerModelWordLevel
+)
+
+// TrainerOptions mirrors the Rust TrainerOptions struct.
+type TrainerOptions struct {
+	Model                   uint8
+	VocabSize               uintptr
+	MinFrequency            uint64
+	SpecialTokens           **byte
+	SpecialTokensLen        uintptr
+	InitialAlphabet         *uint32
+	InitialAlphabetLen      uintptr
+	LimitAlphabet           uintptr
+	UnkToken                *byte
+	ContinuingSubwordPrefix *byte
+	EndOfWordSuffix         *byte
+}
+
+// TrainerParams configures the training of a new tokenizer. Zero values keep the
+// defaults of the HuggingFace trainers; fields that do not apply to the trained model
+// are ignored.
+type TrainerParams struct {
+	VocabSize     uintptr  // Target vocabulary size, including special tokens
+	MinFrequency  uint64   // Minimum frequency of a pair or word to be kept (BPE, WordPiece, WordLevel)
+	SpecialTokens []string // Added as special tokens, with the first IDs of the vocabulary
+	// InitialAlphabet lists characters that are always part of the vocabulary, even if
+	// they do not occur in the corpus (BPE, WordPiece, Unigram).
+	InitialAlphabet []rune
+	LimitAlphabet   uintptr // Maximum number of distinct characters kept (BPE, WordPiece)
+	// UnkToken is the token for input the model cannot represent (WordPiece, Unigram,
+	// WordLevel). Unigram tokenizers without one fail to encode unseen characters.
+	UnkToken                string
+	ContinuingSubwordPrefix string // Prefix of non-initial subwords, "##" for WordPiece by default (BPE, WordPiece)
+	EndOfWordSuffix         string // Suffix of word-final subwords (BPE, WordPiece)
+}
+
+// Corpus is the training data of the Train functions. Files are read line by line and
+// each line is one sequence; Texts are used as-is, one sequence each.
+type Corpus struct {
+	Files []string
+	Texts []string
+}
+
+// CorpusFromSeq collects the sequences of seq into a Corpus. All sequences are held in
+// memory, as the native trainer takes the whole corpus in one call; corpora that do not
+// fit in memory should be written to Files instead.
+func CorpusFromSeq(seq iter.Seq[string]) Corpus {
+	var texts []string
+	for text := range seq {
+		texts = append(texts, text)
+	}
+	return Corpus{Texts: texts}
+}
+
+// TrainBPE trains a byte-level BPE tokenizer, as used by GPT-2 and RoBERTa, on corpus.
+// The byte alphabet is always part of the vocabulary, so any input can be encoded and
+// decoded losslessly. opts are applied as for FromBytes.
+func TrainBPE(corpus Corpus, params TrainerParams, opts ...TokenizerOption) (*Tokenizer, error) {
+	return train(trainerModelBPE, corpus, params, opts)
+}
+
+// TrainWordPiece trains a WordPiece tokenizer, as used by BERT, on corpus. Input is split
+// on whitespace and punctuation; it is not lowercased. opts are applied as for FromBytes.
+func TrainWordPiece(corpus Corpus, params TrainerParams, opts ...TokenizerOption) (*Tokenizer, error) {
+	return train(trainerModelWordPiece, corpus, params, opts)
+}
+
+// TrainUnigram trains a Unigram tokenizer, as used by SentencePiece models such as T5, on
+// corpus. Spaces are encoded with the "▁" metaspace. opts are applied as for FromBytes.
+func TrainUnigram(corpus Corpus, params TrainerParams, opts ...TokenizerOption) (*Tokenizer, error) {
+	return train(trainerModelUnigram, corpus, params, opts)
+}
+
+// TrainWordLevel trains a tokenizer that maps whole words, split on whitespace and
+// punctuation, to IDs. opts are applied as for FromBytes.
+func TrainWordLevel(corpus Corpus, params TrainerParams, opts ...TokenizerOption) (*Tokenizer, error) {
+	return train(trainerModelWordLevel, corpus, params, opts)
+}
+
+func train(model uint8, corpus Corpus, params TrainerParams, opts []TokenizerOption) (*Tokenizer, error) {
+	if len(corpus.Files) == 0 && len(corpus.Texts) == 0 {
+		return nil, errors.New("training corpus cannot be empty")
+	}
+	for _, file := range corpus.Files {
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			return nil, errors.Errorf("training file does not exist at path: %s", file)
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "failed to access training file: %s", file)
+		}
+	}
+
+	files, filesBacking := toCStrings(corpus.Files)
+	texts, textsBacking := toCStrings(corpus.Texts)
+	specialTokens, specialTokensBacking := toCStrings(params.SpecialTokens)
+	alphabet := make([]uint32, len(params.InitialAlphabet))
+	for i, r := range params.InitialAlphabet {
+		alphabet[i] = uint32(r) // #nosec G115 -- Invalid runes are rejected by the library.
+	}
+	unkToken, unkTokenBacking := optionalCString(params.UnkToken)
+	prefix, prefixBacking := optionalCString(params.ContinuingSubwordPrefix)
+	suffix, suffixBacking := optionalCString(params.EndOfWordSuffix)
+
+	trainerOpts := &TrainerOptions{
+		Model:                   model,
+		VocabSize:               params.VocabSize,
+		MinFrequency:            params.MinFrequency,
+		SpecialTokens:           firstElem(specialTokens),
+		SpecialTokensLen:        uintptr(len(specialTokens)),
+		InitialAlphabet:         firstElem(alphabet),
+		InitialAlphabetLen:      uintptr(len(alphabet)),
+		LimitAlphabet:           params.LimitAlphabet,
+		UnkToken:                unkToken,
+		ContinuingSubwordPrefix: prefix,
+		EndOfWordSuffix:         suffix,
+	}
+
+	tokenizer, err := newTokenizer(opts, func(t *Tokenizer, tOpts *TokenizerOptions, result *TokenizerResult) error {
+		err := t.callNative("train_tokenizer", func() int32 {
+			return t.trainTokenizer(trainerOpts, firstElem(files), uintptr(len(files)), firstElem(texts), uintptr(len(texts)), tOpts, result)
+		})
+		return errors.Wrap(err, "failed to train tokenizer")
+	})
+	runtime.KeepAlive(filesBacking)
+	runtime.KeepAlive(textsBacking)
+	runtime.KeepAlive(specialTokensBacking)
+	runtime.KeepAlive(alphabet)
+	runtime.KeepAlive(unkTokenBacking)
+	runtime.KeepAlive(prefixBacking)
+	runtime.KeepAlive(suffixBacking)
+	return tokenizer, err
+}
+
+// optionalCString returns a null-terminated copy of s, or nil if s is empty. The returned
+// byte slice backs the pointer and must be kept alive until the FFI call returns.
+func optionalCString(s string) (*byte, []byte) {
+	if s == "" {
+		return nil, nil
+	}
+	b := append([]byte(s), 0)
+	return &b[0], b
+}
@@ -0,0 +1,97 @@
+package tokenizers
+
+import (
+	"slices"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrainerOptionsLayout(t *testing.T) {
+	if unsafe.Sizeof(uintptr(0)) != 8 {
+		t.Skip("Layout offsets are asserted for 64-bit platforms")
+	}
+	// Must match the #[repr(C)] TrainerOptions struct in src/lib.rs
+	var opts TrainerOptions
+	require.Equal(t, uintptr(8), unsafe.Offsetof(opts.VocabSize))
+	require.Equal(t, uintptr(24), unsafe.Offsetof(opts.SpecialTokens))
+	require.Equal(t, uintptr(64), unsafe.Offsetof(opts.UnkToken))
+	require.Equal(t, uintptr(88), unsafe.Sizeof(opts))
+}
+
+func TestCorpusFromSeq(t *testing.T) {
+	corpus := CorpusFromSeq(slices.Values([]string{"hello", "world"}))
+	require.Equal(t, Corpus{Texts: []string{"hello", "world"}}, corpus)
+}
+
+func TestTrainValidation(t *testing.T) {
+	_, err := TrainBPE(Corpus{}, TrainerParams{})
+	require.ErrorContains(t, err, "training corpus cannot be empty")
+
+	_, err = TrainBPE(Corpus{Files: []string{"testdata/does-not-exist.txt"}}, TrainerParams{})
+	require.ErrorContains(t, err, "training file does not exist")
+}
+
+func TestTrain(t *testing.T) {
+	libpath := checkLibraryExists(t)
+	corpus := Corpus{Files: []string{"testdata/corpus.txt"}}
+	params := TrainerParams{
+		VocabSize:     300,
+		SpecialTokens: []string{"[UNK]", "[PAD]"},
+		UnkToken:      "[UNK]",
+	}
+
+	trainers := map[string]func(Corpus, TrainerParams, ...TokenizerOption) (*Tokenizer, error){
+		"BPE":       TrainBPE,
+		"WordPiece": TrainWordPiece,
+		"Unigram":   TrainUnigram,
+		"WordLevel": TrainWordLevel,
+	}
+	for name, trainFn := range trainers {
+		t.Run(name, func(t *testing.T) {
+			tok, err := trainFn(corpus, params, WithLibraryPath(libpath))
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				_ = tok.Close()
+			})
+
+			id, ok := tok.TokenToID("[UNK]")
+			require.True(t, ok, "Special tokens should be part of the vocabulary")
+			require.Equal(t, uint32(0), id)
+
+			size, err := tok.VocabSize()
+			require.NoError(t, err)
+			require.LessOrEqual(t, size, uint32(params.VocabSize)+256)
+
+			res, err := tok.Encode("the lazy fox")
+			require.NoError(t, err)
+			require.NotEmpty(t, res.IDs)
+		})
+	}
+
+	t.Run("In-memory texts with load-time options", func(t *testing.T) {
+		tok, err := TrainBPE(Corpus{Texts: []string{"hello world", "hello there"}}, TrainerParams{VocabSize: 280},
+			WithLibraryPath(libpath), WithTruncation(2, TruncationDirectionRight, TruncationStrategyLongestFirst))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			_ = tok.Close()
+		})
+
+		res, err := tok.Encode("hello world hello")
+		require.NoError(t, err)
+		require.Len(t, res.IDs, 2)
+
+		require.NoError(t, tok.DisableTruncation())
+		res, err = tok.Encode("hello wörld")
+		require.NoError(t, err)
+		text, err := tok.Decode(res.IDs, false)
+		require.NoError(t, err)
+		require.Equal(t, "hello wörld", text, "Byte-level BPE decodes unseen characters losslessly")
+	})
+
+	t.Run("Invalid alphabet", func(t *testing.T) {
+		_, err := TrainWordPiece(corpus, TrainerParams{InitialAlphabet: []rune{0xD800}}, WithLibraryPath(libpath))
+		require.ErrorIs(t, err, ErrInvalidOptions)
+	})
+}
@@ -0,0 +1,203 @@
+package tokenizers
+
+import (
+	"runtime"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+// VocabBuffer mirrors the Rust VocabBuffer struct: parallel arrays of tokens and IDs.
+type VocabBuffer struct {
+	Tokens **byte
+	IDs    *uint32
+	Len    uintptr
+}
+
+// TokenToID returns the ID of token, including added tokens.
+// The second return value is false if the token is not in the vocabulary,
+// the tokenizer is closed or the lookup fails.
+func (t *Tokenizer) TokenToID(token string) (uint32, bool) {
+	unlock, err := t.beginOperation()
+	if err != nil {
+		return 0, false
+	}
+	defer unlock()
+
+	if t.tokenToID == nil || t.tokenizerh == nil {
+		return 0, false
+	}
+	var id uint32
+	var found bool
+	err = t.callNative("token_to_id", func() int32 {
+		return t.tokenToID(t.tokenizerh, token, &id, &found)
+	})
+	if err != nil {
+		return 0, false
+	}
+	return id, found
+}
+
+// IDToToken returns the token for id, including added tokens.
+// The second return value is false if the ID is not in the vocabulary,
+// the tokenizer is closed or the lookup fails.
+func (t *Tokenizer) IDToToken(id uint32) (string, bool) {
+	unlock, err := t.beginOperation()
+	if err != nil {
+		return "", false
+	}
+	defer unlock()
+
+	if t.idToToken == nil || t.tokenizerh == nil {
+		return "", false
+	}
+	var cStrPtr unsafe.Pointer
+	err = t.callNative("id_to_token", func() int32 {
+		return t.idToToken(t.tokenizerh, id, &cStrPtr)
+	})
+	if err != nil || cStrPtr == nil {
+		return "", false
+	}
+	token := goStringFromPtr(cStrPtr)
+	t.freeString(cStrPtr)
+	return token, true
+}
+
+// GetVocab returns the full token to ID mapping. When withAddedTokens is true the
+// tokens added on top of the model vocabulary (special tokens etc.) are included.
+// It returns nil if the tokenizer is closed or the vocabulary cannot be exported.
+func (t *Tokenizer) GetVocab(withAddedTokens bool) map[string]uint32 {
+	unlock, err := t.beginOperation()
+	if err != nil {
+		return nil
+	}
+	defer unlock()
+
+	if t.getVocab == nil || t.tokenizerh == nil {
+		return nil
+	}
+	var buf VocabBuffer
+	err = t.callNative("get_vocab", func() int32 {
+		return t.getVocab(t.tokenizerh, withAddedTokens, &buf)
+	})
+	if err != nil {
+		return nil
+	}
+	defer t.freeVocab(&buf)
+
+	return vocabFromBuffer(buf)
+}
+
+// vocabFromBuffer copies a Rust-owned VocabBuffer into a Go map.
+func vocabFromBuffer(buf VocabBuffer) map[string]uint32 {
+	vocab := make(map[string]uint32, buf.Len)
+	if buf.Tokens == nil || buf.IDs == nil || buf.Len == 0 {
+		return vocab
+	}
+	tokens := unsafe.Slice(buf.Tokens, buf.Len) // #nosec G103 -- Token pointer array is returned from trusted Rust FFI.
+	ids := unsafe.Slice(buf.IDs, buf.Len)       // #nosec G103 -- ID array is returned from trusted Rust FFI.
+	for i, p := range tokens {
+		if p == nil {
+			continue
+		}
+		vocab[goStringFromPtr(unsafe.Pointer(p))] = ids[i] // #nosec G103 -- Pointer points to FFI-managed null-terminated token bytes.
+	}
+	return vocab
+}
+
+// AddedToken describes a token added to the vocabulary with AddTokens or AddSpecialTokens.
+// Use NewAddedToken for the defaults of the HuggingFace library.
+type AddedToken struct {
+	Content    string
+	SingleWord bool // Only match the token as a whole word
+	LStrip     bool // Strip whitespace on the left of the token when matching
+	RStrip     bool // Strip whitespace on the right of the token when matching
+	Normalized bool // Match the token against the normalized input
+	Special    bool // Skip the token when decoding with skipSpecialTokens
+}
+
+// NewAddedToken returns an AddedToken with the HuggingFace defaults: regular tokens are
+// matched against the normalized input, special tokens against the raw input.
+func NewAddedToken(content string, special bool) AddedToken {
+	return AddedToken{Content: content, Normalized: !special, Special: special}
+}
+
+// AddedTokenOptions mirrors the Rust AddedTokenOptions struct.
+type AddedTokenOptions struct {
+	Content    *byte
+	SingleWord bool
+	LStrip     bool
+	RStrip     bool
+	Normalized bool
+	Special    bool
+}
+
+// AddTokens adds tokens to the vocabulary and returns how many of them were new.
+// Tokens already in the vocabulary are ignored. It waits for in-flight calls to finish;
+// calls made afterwards, as well as VocabSize, GetVocab and serialization, see the new
+// tokens.
+func (t *Tokenizer) AddTokens(tokens []AddedToken) (int, error) {
+	return t.addVocabTokens(tokens, false)
+}
+
+// AddSpecialTokens adds tokens to the vocabulary as special tokens and returns how many
+// of them were new. The Special flag is set on every token, so they are never split and
+// are skipped when decoding with skipSpecialTokens.
+func (t *Tokenizer) AddSpecialTokens(tokens []AddedToken) (int, error) {
+	special := make([]AddedToken, len(tokens))
+	for i, token := range tokens {
+		token.Special = true
+		special[i] = token
+	}
+	return t.addVocabTokens(special, true)
+}
+
+func (t *Tokenizer) addVocabTokens(tokens []AddedToken, special bool) (int, error) {
+	for _, token := range tokens {
+		if token.Content == "" {
+			return 0, errors.New("added token content cannot be empty")
+		}
+	}
+
+	unlock, err := t.beginExclusiveOperation()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	if t.addTokens == nil || t.tokenizerh == nil {
+		return 0, errors.New("add_tokens function is not initialized or tokenizer is not loaded")
+	}
+	if len(tokens) == 0 {
+		return 0, nil
+	}
+
+	contents := make([]string, len(tokens))
+	for i, token := range tokens {
+		contents[i] = token.Content
+	}
+	cContents, cContentBytes := toCStrings(contents)
+	opts := make([]AddedTokenOptions, len(tokens))
+	for i, token := range tokens {
+		opts[i] = AddedTokenOptions{
+			Content:    cContents[i],
+			SingleWord: token.SingleWord,
+			LStrip:     token.LStrip,
+			RStrip:     token.RStrip,
+			Normalized: token.Normalized,
+			Special:    token.Special,
+		}
+	}
+
+	var added uintptr
+	err = t.callNative("add_tokens", func() int32 {
+		return t.addTokens(t.tokenizerh, &opts[0], uintptr(len(opts)), special, &added)
+	})
+	runtime.KeepAlive(cContentBytes)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to add tokens")
+	}
+	// Cached per-call copies were made from the old vocabulary.
+	t.freeVariants()
+	return int(added), nil
+}
@@ -0,0 +1,161 @@
+package tokenizers
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVocabFromBuffer(t *testing.T) {
+	t.Run("Empty", func(t *testing.T) {
+		vocab := vocabFromBuffer(VocabBuffer{})
+		require.NotNil(t, vocab)
+		require.Empty(t, vocab)
+	})
+
+	t.Run("Tokens and IDs", func(t *testing.T) {
+		tokens := StringsToPtrArray([]string{"hello", "world", "[CLS]"})
+		ids := []uint32{7592, 2088, 101}
+		vocab := vocabFromBuffer(VocabBuffer{
+			Tokens: tokens.Ptr(),
+			IDs:    &ids[0],
+			Len:    uintptr(tokens.Len()),
+		})
+		require.Equal(t, map[string]uint32{"hello": 7592, "world": 2088, "[CLS]": 101}, vocab)
+	})
+}
+
+func TestAddedTokenOptionsLayout(t *testing.T) {
+	if unsafe.Sizeof(uintptr(0)) != 8 {
+		t.Skip("Layout offsets are asserted for 64-bit platforms")
+	}
+	// Must match the #[repr(C)] AddedTokenOptions struct in src/lib.rs
+	var opts AddedTokenOptions
+	require.Equal(t, uintptr(8), unsafe.Offsetof(opts.SingleWord))
+	require.Equal(t, uintptr(12), unsafe.Offsetof(opts.Special))
+	require.Equal(t, uintptr(16), unsafe.Sizeof(opts))
+}
+
+func TestNewAddedToken(t *testing.T) {
+	require.Equal(t, AddedToken{Content: "<doc>", Normalized: true}, NewAddedToken("<doc>", false))
+	require.Equal(t, AddedToken{Content: "<eot>", Special: true}, NewAddedToken("<eot>", true))
+}
+
+func TestAddTokens(t *testing.T) {
+	libpath := checkLibraryExists(t)
+	tok, err := FromFile("./tokenizer.json", WithLibraryPath(libpath))
+	require.NoError(t, err, "Failed to load tokenizer from file")
+	t.Cleanup(func() {
+		_ = tok.Close()
+	})
+
+	sizeBefore, err := tok.VocabSize()
+	require.NoError(t, err)
+
+	t.Run("Regular tokens", func(t *testing.T) {
+		added, err := tok.AddTokens([]AddedToken{NewAddedToken("<doc>", false), NewAddedToken("hello", false)})
+		require.NoError(t, err)
+		require.Equal(t, 1, added, "Tokens already in the vocabulary are not counted")
+
+		id, ok := tok.TokenToID("<doc>")
+		require.True(t, ok)
+		require.Equal(t, sizeBefore, id)
+
+		res, err := tok.Encode("hello <doc> world", WithAddSpecialTokens(), WithReturnTokens())
+		require.NoError(t, err)
+		require.Equal(t, []string{"[CLS]", "hello", "<doc>", "world", "[SEP]"}, res.Tokens[:5])
+	})
+
+	t.Run("Special tokens", func(t *testing.T) {
+		added, err := tok.AddSpecialTokens([]AddedToken{{Content: "<eot>"}})
+		require.NoError(t, err)
+		require.Equal(t, 1, added)
+
+		size, err := tok.VocabSize()
+		require.NoError(t, err)
+		require.Equal(t, sizeBefore+2, size)
+
+		id, ok := tok.TokenToID("<eot>")
+		require.True(t, ok)
+		text, err := tok.Decode([]uint32{7592, id}, true)
+		require.NoError(t, err)
+		require.Equal(t, "hello", text, "Special tokens are skipped when decoding")
+	})
+
+	t.Run("Empty content", func(t *testing.T) {
+		_, err := tok.AddTokens([]AddedToken{{}})
+		require.Error(t, err)
+	})
+
+	t.Run("Closed tokenizer", func(t *testing.T) {
+		closed, err := FromFile("./tokenizer.json", WithLibraryPath(libpath))
+		require.NoError(t, err)
+		require.NoError(t, closed.Close())
+
+		_, err = closed.AddTokens([]AddedToken{NewAddedToken("<doc>", false)})
+		require.ErrorIs(t, err, ErrTokenizerClosed)
+		_, err = closed.AddSpecialTokens([]AddedToken{NewAddedToken("<eot>", true)})
+		require.ErrorIs(t, err, ErrTokenizerClosed)
+	})
+}
+
+func TestVocabLookup(t *testing.T) {
+	libpath := checkLibraryExists(t)
+	tok, err := FromFile("./tokenizer.json", WithLibraryPath(libpath))
+	require.NoError(t, err, "Failed to load tokenizer from file")
+	t.Cleanup(func() {
+		_ = tok.Close()
+	})
+
+	t.Run("TokenToID", func(t *testing.T) {
+		id, ok := tok.TokenToID("hello")
+		require.True(t, ok)
+		require.Equal(t, uint32(7592), id)
+
+		id, ok = tok.TokenToID("[SEP]")
+		require.True(t, ok, "Added tokens should be resolved")
+		require.Equal(t, uint32(102), id)
+
+		_, ok = tok.TokenToID("definitely-not-a-token")
+		require.False(t, ok)
+	})
+
+	t.Run("IDToToken", func(t *testing.T) {
+		token, ok := tok.IDToToken(7592)
+		require.True(t, ok)
+		require.Equal(t, "hello", token)
+
+		_, ok = tok.IDToToken(1 << 30)
+		require.False(t, ok)
+	})
+
+	t.Run("GetVocab", func(t *testing.T) {
+		size, err := tok.VocabSize()
+		require.NoError(t, err)
+
+		vocab := tok.GetVocab(true)
+		require.Len(t, vocab, int(size))
+		require.Equal(t, uint32(7592), vocab["hello"])
+		require.Equal(t, uint32(101), vocab["[CLS]"])
+
+		for token, id := range vocab {
+			roundTrip, ok := tok.IDToToken(id)
+			require.True(t, ok)
+			require.Equal(t, token, roundTrip)
+			break
+		}
+	})
+
+	t.Run("Closed tokenizer", func(t *testing.T) {
+		closed, err := FromFile("./tokenizer.json", WithLibraryPath(libpath))
+		require.NoError(t, err)
+		require.NoError(t, closed.Close())
+
+		_, ok := closed.TokenToID("hello")
+		require.False(t, ok)
+		_, ok = closed.IDToToken(7592)
+		require.False(t, ok)
+		require.Nil(t, closed.GetVocab(true))
+	})
+}